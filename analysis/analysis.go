@@ -0,0 +1,340 @@
+// Package analysis goes over a recorded session and sums up every lap: its
+// time and sectors, the fuel it took, the tyre temperatures, the top speed and
+// the slowest point of every corner.
+//
+// The laps are split where the lap timer starts a new one, so the lap and
+// sector times are the ones the devices show. Values are in the units of the
+// telemetry registry
+package analysis
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	conv "esdi/conversions"
+	"esdi/telemetry"
+)
+
+// cornerHysteresis is how much the speed has to drop and come back up for a
+// minimum to count as a corner, in m/s
+const cornerHysteresis = 3.0
+
+// Reasons a lap is not valid
+const (
+	InvalidOutLap     = "out lap"    // Started in the pits
+	InvalidPitLap     = "pit lap"    // Went into the pits
+	InvalidIncomplete = "incomplete" // The recording didn't have all of it
+	InvalidNotTimed   = "not timed"  // Reset, towed or off the track
+)
+
+// Report sums up the laps of a session
+type Report struct {
+	Provider string `json:"provider"`
+	Car      string `json:"car"`
+	Track    string `json:"track"`
+	Sectors  int    `json:"sectors"`
+	Units    Units  `json:"units"`
+	Laps     []Lap  `json:"laps"`
+	// BestLap is the index on Laps of the fastest valid lap, -1 if none is
+	BestLap int `json:"best_lap"`
+}
+
+// Units are the units of the values of the report
+type Units struct {
+	Time        conv.Unit `json:"time"`
+	Fuel        conv.Unit `json:"fuel"`
+	Speed       conv.Unit `json:"speed"`
+	Temperature conv.Unit `json:"temperature"`
+}
+
+type Lap struct {
+	Number int     `json:"number"`
+	Time   float64 `json:"time"`
+	// Sectors has a time per sector, 0 when the sector wasn't timed
+	Sectors  []float64 `json:"sectors"`
+	FuelUsed float64   `json:"fuel_used"`
+	// Tyres are in the order of the corners: LF, RF, LR, RR
+	Tyres        [4]TyreTemps  `json:"tyres"`
+	TopSpeed     float64       `json:"top_speed"`
+	CornerSpeeds []CornerSpeed `json:"corner_speeds"`
+	Valid        bool          `json:"valid"`
+	Invalid      string        `json:"invalid,omitempty"` // Why it isn't valid
+}
+
+// TyreTemps are the surface temperatures of a tyre over a lap, of the
+// inside, middle and outside together
+type TyreTemps struct {
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// CornerSpeed is the slowest point of a corner
+type CornerSpeed struct {
+	Dist  float64 `json:"dist"` // Lap distance, 0 to 1
+	Speed float64 `json:"speed"`
+}
+
+// fields are what the analysis reads, the lap timing and sector timing come
+// along with their outputs
+func fields() []telemetry.FieldID {
+	ids := []telemetry.FieldID{
+		telemetry.LapNumber, telemetry.LapDistPct, telemetry.SessionTime, telemetry.Speed,
+		telemetry.FuelLevel, telemetry.TyreTemps, telemetry.OnPitRoad, telemetry.CarName,
+		telemetry.TrackName, telemetry.LTCurrentLap, telemetry.LTLastLap,
+	}
+
+	return append(ids, telemetry.SectorLast[:]...)
+}
+
+// Analyze reads every frame of src and sums up its laps
+func Analyze(src telemetry.FrameSource) (*Report, error) {
+	request := make(map[int16]telemetry.FieldID)
+	for k, id := range fields() {
+		request[int16(k+1)] = id
+	}
+	src.Subscribe(request)
+
+	a := analyzer{
+		report: &Report{
+			Provider: src.Name(),
+			Units: Units{
+				Time: conv.Seconds, Fuel: unitOf(telemetry.FuelLevel),
+				Speed: unitOf(telemetry.Speed), Temperature: unitOf(telemetry.TyreTemps),
+			},
+			BestLap: -1,
+		},
+	}
+
+	for {
+		td, t, err := src.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		a.frame(td, t.Seconds())
+	}
+
+	if a.lap == nil {
+		return nil, errors.New("the recording has no frames")
+	}
+
+	a.endLap(InvalidIncomplete)
+	a.report.BestLap = bestLap(a.report.Laps)
+
+	return a.report, nil
+}
+
+// analyzer follows the laps frame by frame
+type analyzer struct {
+	report *Report
+
+	lap     *lapState
+	started bool // If the lap timer started a lap already
+	lastLap telemetry.TelemetryField
+	sectors [telemetry.MaxSectors]telemetry.TelemetryField
+}
+
+// lapState is a lap being driven
+type lapState struct {
+	Lap
+
+	start     float64
+	startFuel float64
+	lastFuel  float64
+	numbers   []int // Lap number of every frame, the middle one names the lap
+
+	tyreSums   [4]float64
+	tyreFrames int
+
+	cornerMax float64 // Fastest since the last corner
+	cornerMin CornerSpeed
+	braking   bool
+}
+
+func (a *analyzer) frame(td *telemetry.TelemetryData, t float64) {
+	if a.lap == nil {
+		a.report.Car = td.Values[telemetry.CarName].Str
+		a.report.Track = td.Values[telemetry.TrackName].Str
+		a.lap = a.newLap(td, t, false)
+	}
+
+	// The sectors are done first, the last one ends on the line with the lap
+	for k := range a.sectors {
+		tf := &td.Values[telemetry.SectorLast[k]]
+		if tf.IsFloat() {
+			a.report.Sectors = max(a.report.Sectors, k+1)
+			if !sameValue(tf, &a.sectors[k]) {
+				a.lap.Sectors[k] = tf.Float()
+			}
+		}
+		a.sectors[k] = *tf
+	}
+
+	// A new lap starts when the lap timer starts over, the lap time is the
+	// one the timer took when it timed the lap
+	current := &td.Values[telemetry.LTCurrentLap]
+	if current.IsFloat() && (!a.started || current.Float() < a.lap.Time) {
+		start := t - current.Float()
+		if last := &td.Values[telemetry.LTLastLap]; a.started && !sameValue(last, &a.lastLap) {
+			a.lap.Time = last.Float()
+			a.endLap("")
+		} else {
+			a.lap.Time = start - a.lap.start
+			a.endLap(InvalidNotTimed)
+		}
+
+		a.lap = a.newLap(td, start, true)
+		a.started = true
+	}
+	a.lastLap = td.Values[telemetry.LTLastLap]
+
+	a.lap.add(td)
+	if current.IsFloat() {
+		a.lap.Time = current.Float()
+	} else {
+		a.lap.Time = t - a.lap.start
+	}
+}
+
+// newLap starts a lap at time start, onLine if it started on the line
+func (a *analyzer) newLap(td *telemetry.TelemetryData, start float64, onLine bool) *lapState {
+	fuel := td.Values[telemetry.FuelLevel].Float()
+	lap := &lapState{
+		Lap: Lap{
+			Sectors: make([]float64, telemetry.MaxSectors),
+			Valid:   true,
+		},
+		start:     start,
+		startFuel: fuel,
+		lastFuel:  fuel,
+	}
+
+	switch {
+	case td.Values[telemetry.OnPitRoad].Raw != 0:
+		lap.Valid, lap.Invalid = false, InvalidOutLap
+	case !onLine:
+		lap.Valid, lap.Invalid = false, InvalidIncomplete
+	}
+
+	return lap
+}
+
+// endLap works out the totals of the lap and adds it to the report, invalid
+// for the given reason unless it's invalid already
+func (a *analyzer) endLap(invalid string) {
+	lap := a.lap
+	if len(lap.numbers) == 0 {
+		// The recording started on the line
+		return
+	}
+	if invalid != "" && lap.Valid {
+		lap.Valid, lap.Invalid = false, invalid
+	}
+
+	lap.Number = lap.numbers[len(lap.numbers)/2]
+	lap.FuelUsed = math.Max(lap.startFuel-lap.lastFuel, 0)
+	for c := range lap.Tyres {
+		if lap.tyreFrames > 0 {
+			lap.Tyres[c].Avg = lap.tyreSums[c] / float64(lap.tyreFrames)
+		}
+	}
+
+	lap.Sectors = lap.Sectors[:a.report.Sectors]
+	if lap.CornerSpeeds == nil {
+		lap.CornerSpeeds = []CornerSpeed{}
+	}
+
+	a.report.Laps = append(a.report.Laps, lap.Lap)
+}
+
+// add takes in a frame of the lap
+func (l *lapState) add(td *telemetry.TelemetryData) {
+	l.numbers = append(l.numbers, int(td.Values[telemetry.LapNumber].Float()))
+
+	if fuel := &td.Values[telemetry.FuelLevel]; fuel.IsFloat() {
+		l.lastFuel = fuel.Float()
+	}
+
+	// Going into the pits, coming out of them is an out lap
+	if td.Values[telemetry.OnPitRoad].Raw != 0 && l.Valid {
+		l.Valid, l.Invalid = false, InvalidPitLap
+	}
+
+	l.addTyres(&td.Values[telemetry.TyreTemps])
+
+	speed := &td.Values[telemetry.Speed]
+	if speed.IsFloat() {
+		l.addSpeed(speed.Float(), td.Values[telemetry.LapDistPct].Float())
+	}
+}
+
+func (l *lapState) addTyres(temps *telemetry.TelemetryField) {
+	parts := temps.Len() / len(l.Tyres)
+	if first := temps.At(0); parts == 0 || !first.IsFloat() {
+		return
+	}
+
+	for c := range l.Tyres {
+		sum := 0.0
+		for p := range parts {
+			e := temps.At(c*parts + p)
+			v := e.Float()
+
+			sum += v
+			if l.tyreFrames == 0 && p == 0 || v > l.Tyres[c].Max {
+				l.Tyres[c].Max = v
+			}
+		}
+		l.tyreSums[c] += sum / float64(parts)
+	}
+	l.tyreFrames++
+}
+
+// addSpeed follows the speed for the top speed and the corners. A corner is a
+// drop of the speed that comes back up, its minimum is kept
+func (l *lapState) addSpeed(speed float64, dist float64) {
+	l.TopSpeed = math.Max(l.TopSpeed, speed)
+
+	if !l.braking {
+		l.cornerMax = math.Max(l.cornerMax, speed)
+		if speed < l.cornerMax-cornerHysteresis {
+			l.braking = true
+			l.cornerMin = CornerSpeed{Dist: dist, Speed: speed}
+		}
+		return
+	}
+
+	if speed < l.cornerMin.Speed {
+		l.cornerMin = CornerSpeed{Dist: dist, Speed: speed}
+	}
+	if speed > l.cornerMin.Speed+cornerHysteresis {
+		l.CornerSpeeds = append(l.CornerSpeeds, l.cornerMin)
+		l.braking = false
+		l.cornerMax = speed
+	}
+}
+
+// bestLap finds the fastest valid lap
+func bestLap(laps []Lap) int {
+	best := -1
+	for k := range laps {
+		if laps[k].Valid && (best < 0 || laps[k].Time < laps[best].Time) {
+			best = k
+		}
+	}
+
+	return best
+}
+
+func unitOf(id telemetry.FieldID) conv.Unit {
+	def, _ := telemetry.GetField(id)
+	return def.Unit
+}
+
+func sameValue(a, b *telemetry.TelemetryField) bool {
+	return a.Type == b.Type && a.Raw == b.Raw && a.Str == b.Str
+}
@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"esdi/providers/iracing"
+)
+
+func analyzeIBT(t *testing.T) *Report {
+	t.Helper()
+
+	src, err := iracing.NewIRacingProvider(slog.New(slog.DiscardHandler), "../test_telem.ibt", "", "")
+	if err != nil {
+		t.Fatalf("failed to open the ibt: %v", err)
+	}
+	defer src.Close()
+
+	report, err := Analyze(src)
+	if err != nil {
+		t.Fatalf("failed to analyze: %v", err)
+	}
+
+	return report
+}
+
+// test_telem.ibt leaves the pit stall on lap 0 and is still on the pit road
+// when it crosses the line, it ends early on lap 1
+func Test_Analyze(t *testing.T) {
+	report := analyzeIBT(t)
+
+	if report.Track != "Okayama International Circuit" || report.Car != "Mazda MX-5 Cup" {
+		t.Errorf("unexpected session %s at %s", report.Car, report.Track)
+	}
+	if report.Units.Speed != "m/s" {
+		t.Errorf("expected the speeds in m/s, got %s", report.Units.Speed)
+	}
+	if len(report.Laps) != 2 {
+		t.Fatalf("expected 2 laps, got %d", len(report.Laps))
+	}
+
+	out, in := report.Laps[0], report.Laps[1]
+	if out.Number != 0 || out.Valid || out.Invalid != InvalidOutLap {
+		t.Errorf("expected lap 0 to be an out lap, got %+v", out)
+	}
+	if in.Number != 1 || in.Valid || in.Invalid != InvalidOutLap {
+		t.Errorf("expected lap 1 to be an out lap too, got %+v", in)
+	}
+	if report.BestLap != -1 {
+		t.Errorf("expected no best lap, got %d", report.BestLap)
+	}
+
+	for _, lap := range report.Laps {
+		if lap.Time <= 0 || lap.TopSpeed <= 0 || lap.FuelUsed < 0 {
+			t.Errorf("unexpected totals on lap %d: %+v", lap.Number, lap)
+		}
+		if len(lap.Sectors) != report.Sectors {
+			t.Errorf("expected %d sectors on lap %d, got %d", report.Sectors, lap.Number, len(lap.Sectors))
+		}
+		for c, tyre := range lap.Tyres {
+			if tyre.Avg <= 0 || tyre.Max < tyre.Avg {
+				t.Errorf("unexpected temps of tyre %d on lap %d: %+v", c, lap.Number, tyre)
+			}
+		}
+	}
+
+	// The recording is 18s long, lap 1 starts 10s in
+	if out.Time < 9 || out.Time > 11 || in.Time < 7 || in.Time > 9 {
+		t.Errorf("unexpected lap times %.3f and %.3f", out.Time, in.Time)
+	}
+}
+
+func Test_AnalyzeJSON(t *testing.T) {
+	report := analyzeIBT(t)
+
+	out, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("failed to marshal the report: %v", err)
+	}
+
+	var back Report
+	if err := json.Unmarshal(out, &back); err != nil {
+		t.Fatalf("failed to read the report back: %v", err)
+	}
+	if len(back.Laps) != len(report.Laps) || back.Laps[0].Invalid != report.Laps[0].Invalid ||
+		back.Laps[1].TopSpeed != report.Laps[1].TopSpeed {
+		t.Errorf("the report changed on the way back: %s", out)
+	}
+}
+
+// Corners are where the speed drops and comes back up
+func Test_Corners(t *testing.T) {
+	var lap lapState
+	speeds := []float64{50, 48, 40, 30, 32, 45, 50, 44, 42, 43, 60}
+	for k, s := range speeds {
+		lap.addSpeed(s, float64(k)/10)
+	}
+
+	expect := []CornerSpeed{{Dist: 0.3, Speed: 30}, {Dist: 0.8, Speed: 42}}
+	if len(lap.CornerSpeeds) != len(expect) {
+		t.Fatalf("expected %d corners, got %v", len(expect), lap.CornerSpeeds)
+	}
+	for k := range expect {
+		if lap.CornerSpeeds[k] != expect[k] {
+			t.Errorf("expected corner %d at %v, got %v", k, expect[k], lap.CornerSpeeds[k])
+		}
+	}
+	if lap.TopSpeed != 60 {
+		t.Errorf("expected a top speed of 60, got %v", lap.TopSpeed)
+	}
+}
@@ -0,0 +1,177 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"esdi/telemetry"
+)
+
+// DefaultComparePoints is how many distances two laps are compared at, about
+// every 4 metres of a 4km track
+const DefaultComparePoints = 1000
+
+// Trace is a lap sampled by lap distance, the channels the comparison lines up
+type Trace struct {
+	Lap    int          `json:"lap"`
+	Points []TracePoint `json:"points"`
+}
+
+type TracePoint struct {
+	Dist     float64 `json:"dist"` // Lap distance, 0 to 1
+	Time     float64 `json:"time"` // Since the start of the lap
+	Speed    float64 `json:"speed"`
+	Throttle float64 `json:"throttle"` // 0 to 1
+	Brake    float64 `json:"brake"`    // 0 to 1
+	Gear     int     `json:"gear"`     // -1 is reverse, 0 neutral
+}
+
+// Comparison are two laps lined up by lap distance
+type Comparison struct {
+	A      *Trace           `json:"a"`
+	B      *Trace           `json:"b"`
+	Points []ComparedPoints `json:"points"`
+}
+
+// ComparedPoints are both laps at the same distance
+type ComparedPoints struct {
+	Dist float64 `json:"dist"`
+	// Delta is B's time minus A's, positive when B is behind
+	Delta float64    `json:"delta"`
+	A     TracePoint `json:"a"`
+	B     TracePoint `json:"b"`
+}
+
+// LoadLap reads the frames of lap from src into a trace
+func LoadLap(src telemetry.FrameSource, lap int) (*Trace, error) {
+	src.Subscribe(map[int16]telemetry.FieldID{
+		1: telemetry.LapNumber, 2: telemetry.LapDistPct, 3: telemetry.Speed,
+		4: telemetry.Throttle, 5: telemetry.Brake, 6: telemetry.Gear,
+	})
+
+	trace := &Trace{Lap: lap}
+	var start float64
+	crossed := false // If the recording has the line crossing that started the lap
+	for {
+		td, t, err := src.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		number := &td.Values[telemetry.LapNumber]
+		if number.Type == telemetry.DataTypeCHAR {
+			return nil, errors.New("the recording has no laps")
+		}
+		if int(number.Float()) != lap {
+			if len(trace.Points) > 0 {
+				break
+			}
+			crossed = true
+			continue
+		}
+
+		dist := td.Values[telemetry.LapDistPct].Float()
+		if len(trace.Points) == 0 {
+			// The lap number and distance don't change on the same frame, the
+			// end of the last lap may still be there. Laps the recording starts
+			// on start anywhere, ex: out of the pit stall
+			if crossed && dist > 0.5 {
+				continue
+			}
+			start = t.Seconds()
+		} else if dist < trace.Points[len(trace.Points)-1].Dist {
+			// Past the line before the lap number changed, or went backwards
+			if dist < 0.5 {
+				break
+			}
+			continue
+		}
+
+		trace.Points = append(trace.Points, TracePoint{
+			Dist:     dist,
+			Time:     t.Seconds() - start,
+			Speed:    td.Values[telemetry.Speed].Float(),
+			Throttle: td.Values[telemetry.Throttle].Float(),
+			Brake:    td.Values[telemetry.Brake].Float(),
+			Gear:     gearNumber(&td.Values[telemetry.Gear]),
+		})
+	}
+
+	if len(trace.Points) < 2 {
+		return nil, fmt.Errorf("lap %d is not in the recording", lap)
+	}
+
+	return trace, nil
+}
+
+// At interpolates the trace at a lap distance, the gear is the one of the
+// frame before
+func (tr *Trace) At(dist float64) TracePoint {
+	pts := tr.Points
+	k := sort.Search(len(pts), func(k int) bool { return pts[k].Dist >= dist })
+	switch {
+	case k == 0:
+		return pts[0]
+	case k == len(pts):
+		return pts[len(pts)-1]
+	}
+
+	a, b := pts[k-1], pts[k]
+	f := 0.0
+	if b.Dist > a.Dist {
+		f = (dist - a.Dist) / (b.Dist - a.Dist)
+	}
+
+	return TracePoint{
+		Dist:     dist,
+		Time:     lerp(a.Time, b.Time, f),
+		Speed:    lerp(a.Speed, b.Speed, f),
+		Throttle: lerp(a.Throttle, b.Throttle, f),
+		Brake:    lerp(a.Brake, b.Brake, f),
+		Gear:     a.Gear,
+	}
+}
+
+// Compare lines up two laps at points distances, evenly spaced over the part
+// of the lap both traces have
+func Compare(a *Trace, b *Trace, points int) (*Comparison, error) {
+	from := max(a.Points[0].Dist, b.Points[0].Dist)
+	to := min(a.Points[len(a.Points)-1].Dist, b.Points[len(b.Points)-1].Dist)
+	if points < 2 || to <= from {
+		return nil, errors.New("the laps have no distance in common")
+	}
+
+	// The delta starts at 0 where the laps start to be compared
+	offset := b.At(from).Time - a.At(from).Time
+
+	cmp := &Comparison{A: a, B: b, Points: make([]ComparedPoints, points)}
+	for k := range cmp.Points {
+		dist := from + (to-from)*float64(k)/float64(points-1)
+		pa, pb := a.At(dist), b.At(dist)
+
+		cmp.Points[k] = ComparedPoints{Dist: dist, Delta: pb.Time - pa.Time - offset, A: pa, B: pb}
+	}
+
+	return cmp, nil
+}
+
+// gearNumber turns the gear letter into a number to plot
+func gearNumber(tf *telemetry.TelemetryField) int {
+	switch g := rune(tf.Raw); {
+	case g == 'R':
+		return -1
+	case g >= '1' && g <= '9':
+		return int(g - '0')
+	}
+
+	return 0
+}
+
+func lerp(a float64, b float64, f float64) float64 {
+	return a + (b-a)*f
+}
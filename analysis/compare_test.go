@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+
+	"esdi/providers/iracing"
+)
+
+func loadIBTLap(t *testing.T, lap int) (*Trace, error) {
+	t.Helper()
+
+	src, err := iracing.NewIRacingProvider(slog.New(slog.DiscardHandler), "../test_telem.ibt", "", "")
+	if err != nil {
+		t.Fatalf("failed to open the ibt: %v", err)
+	}
+	defer src.Close()
+
+	return LoadLap(src, lap)
+}
+
+func Test_LoadLap(t *testing.T) {
+	// Lap 0 starts in the pit stall, before the line
+	out, err := loadIBTLap(t, 0)
+	if err != nil {
+		t.Fatalf("failed to load lap 0: %v", err)
+	}
+	if first := out.Points[0]; first.Dist < 0.9 || first.Time != 0 {
+		t.Errorf("expected lap 0 to start by the end of the lap, got %+v", first)
+	}
+
+	// Lap 1 starts on the line, the recording ends early on it
+	lap, err := loadIBTLap(t, 1)
+	if err != nil {
+		t.Fatalf("failed to load lap 1: %v", err)
+	}
+	if first := lap.Points[0]; first.Dist > 0.01 {
+		t.Errorf("expected lap 1 to start on the line, got %+v", first)
+	}
+	for k := 1; k < len(lap.Points); k++ {
+		if lap.Points[k].Dist < lap.Points[k-1].Dist || lap.Points[k].Time <= lap.Points[k-1].Time {
+			t.Fatalf("expected the trace to go forward, got %+v after %+v", lap.Points[k], lap.Points[k-1])
+		}
+	}
+
+	if _, err := loadIBTLap(t, 5); err == nil {
+		t.Errorf("expected a lap that isn't there to fail")
+	}
+}
+
+func Test_Compare(t *testing.T) {
+	a, err := loadIBTLap(t, 1)
+	if err != nil {
+		t.Fatalf("failed to load lap 1: %v", err)
+	}
+
+	// The same lap is never ahead or behind
+	cmp, err := Compare(a, a, 100)
+	if err != nil {
+		t.Fatalf("failed to compare: %v", err)
+	}
+	if len(cmp.Points) != 100 {
+		t.Fatalf("expected 100 points, got %d", len(cmp.Points))
+	}
+	for _, pt := range cmp.Points {
+		if math.Abs(pt.Delta) > 1e-9 || pt.A != pt.B {
+			t.Fatalf("expected no difference at %.3f, got %+v", pt.Dist, pt)
+		}
+	}
+
+	// The same lap 10% slower loses 10% of the time
+	b := &Trace{Lap: 2, Points: make([]TracePoint, len(a.Points))}
+	for k, pt := range a.Points {
+		pt.Time *= 1.1
+		pt.Speed /= 1.1
+		b.Points[k] = pt
+	}
+
+	cmp, err = Compare(a, b, 100)
+	if err != nil {
+		t.Fatalf("failed to compare: %v", err)
+	}
+	last := cmp.Points[len(cmp.Points)-1]
+	if expect := last.A.Time * 0.1; math.Abs(last.Delta-expect) > 0.01 {
+		t.Errorf("expected B %.3fs behind at the end, got %.3f", expect, last.Delta)
+	}
+	for k := 1; k < len(cmp.Points); k++ {
+		if cmp.Points[k].Delta < cmp.Points[k-1].Delta {
+			t.Fatalf("expected the delta to only grow, got %.3f after %.3f",
+				cmp.Points[k].Delta, cmp.Points[k-1].Delta)
+		}
+	}
+
+	// Lap 0 ends where lap 1 starts, they have no distance in common
+	out, err := loadIBTLap(t, 0)
+	if err != nil {
+		t.Fatalf("failed to load lap 0: %v", err)
+	}
+	if _, err := Compare(out, a, 100); err == nil {
+		t.Errorf("expected laps with no distance in common to fail")
+	}
+}
+
+func Test_TraceAt(t *testing.T) {
+	tr := &Trace{Points: []TracePoint{
+		{Dist: 0.1, Time: 1, Speed: 10, Gear: 2},
+		{Dist: 0.3, Time: 3, Speed: 30, Gear: 3},
+	}}
+
+	pt := tr.At(0.15)
+	if pt.Time != 1.5 || pt.Speed != 15 || pt.Gear != 2 {
+		t.Errorf("unexpected point at 0.15: %+v", pt)
+	}
+	if pt := tr.At(0); pt != tr.Points[0] {
+		t.Errorf("expected the first point before the trace, got %+v", pt)
+	}
+	if pt := tr.At(1); pt != tr.Points[1] {
+		t.Errorf("expected the last point after the trace, got %+v", pt)
+	}
+}
@@ -0,0 +1,82 @@
+package cdashdisplay
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	helper "esdi/helpers"
+	"esdi/telemetry"
+)
+
+// flashPeriod is how long a flashing alert stays on and off
+const flashPeriod = 500 * time.Millisecond
+
+// alertFor works out which flag alert the windows should show for the data, ""
+// when they should show their own colours
+func (l *LayoutTree) alertFor(data *telemetry.TelemetryData) string {
+	flag := data.Values[telemetry.FlagState].Str
+	alert, ok := l.FlagAlerts[flag]
+	if !ok {
+		return ""
+	}
+
+	if alert.Flash && (data.LastDataPoll.UnixMilli()/flashPeriod.Milliseconds())%2 == 1 {
+		return ""
+	}
+
+	return flag
+}
+
+// alertDecorations are the decorations of a window while the alert is shown
+func (l *LayoutTree) alertDecorations(w *DesktopUIWindow, flag string) UIDecorations {
+	decor := w.Decor
+	alert, ok := l.FlagAlerts[flag]
+	if !ok {
+		return decor
+	}
+
+	decor.BGColour = alert.BGColour
+	decor.BorderColour = alert.BorderColour
+	decor.HasBorder = 1
+
+	return decor
+}
+
+// updateFlagAlert recolours the windows when the alert they should show
+// changes. The layout keeps the windows' own decorations, only the device gets
+// the alert colours
+func (d *CDashDisplay) updateFlagAlert(data *telemetry.TelemetryData) {
+	layout := d.State.Layout
+	flag := layout.alertFor(data)
+	if flag == d.shownAlert {
+		return
+	}
+
+	for _, idx := range slices.Sorted(maps.Keys(layout.Windows)) {
+		w := layout.Windows[idx]
+
+		packet := UIWindowUpdatePacket{
+			WinID:  idx,
+			Window: w.UIWindow,
+		}
+		packet.Window.Decor = layout.alertDecorations(w, flag)
+
+		bytes, err := helper.StructToBytes(packet)
+		if err != nil {
+			pLogger.Error(fmt.Sprintf("failed to encode the flag alert: %v", err))
+			return
+		}
+
+		err = d.WT.SendCommand(updateWindowCMDID, bytes, nil)
+		if err != nil {
+			pLogger.Error(fmt.Sprintf("failed to show flag alert %q on window %d: %v", flag, idx, err))
+			return
+		}
+
+		d.packer.Forget(idx)
+	}
+
+	d.shownAlert = flag
+}
@@ -91,6 +91,7 @@ func findDisplayPort() (*communication.WalkieTalkie, error) {
 		return nil, fmt.Errorf("couldn't find cdashdisplay")
 	}
 
-	pLogger.Info(fmt.Sprintf("found cdashdisplay on port: %s", wt.Cfg.Name))
+	pLogger.Info(fmt.Sprintf("found cdashdisplay on port: %s, it needs window protocol %d",
+		wt.Cfg.Name, WindowProtocol))
 	return wt, nil
 }
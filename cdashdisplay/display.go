@@ -8,8 +8,10 @@ import (
 	"log/slog"
 	"os"
 	"path"
+	"sync"
 	"time"
 
+	conv "esdi/conversions"
 	helper "esdi/helpers"
 	"esdi/peripheral/communication"
 	"esdi/peripheral/communication/packets"
@@ -114,26 +116,46 @@ func NewCDashState() *CDashState {
 type CDashDisplay struct {
 	WT    *communication.WalkieTalkie
 	State *CDashState
+	// Units are the global unit preferences, windows can override them
+	Units conv.UnitPreferences
+
+	// mut guards the layout, the packer and the shown alert. SendData runs on
+	// the stream's goroutine while the windows are edited
+	mut    sync.Mutex
+	packer *telemetry.Packer
+	// shownAlert is the flag whose alert colours the windows have, "" for none
+	shownAlert string
 }
 
 func NewCDashDisplay() (*CDashDisplay, error) {
 	// Look for the port
 	p, err := findDisplayPort()
 	if err != nil {
-		pLogger.Info("failed to find cdashdisplay port: %s", err.Error())
+		pLogger.Info(fmt.Sprintf("failed to find cdashdisplay port: %s", err.Error()))
 		return nil, err
 	}
 
-	return &CDashDisplay{
+	d := &CDashDisplay{
 		WT:    p,
 		State: NewCDashState(),
-	}, nil
+	}
+	d.packer = telemetry.NewPacker(d.unitFor)
+	d.packer.Elements = d.elementFor
+
+	return d, nil
 }
 
 func (d *CDashDisplay) SendCommand() {
 }
 
 func (d *CDashDisplay) CreateWindow(win *DesktopUIWindow) (*DesktopUIWindow, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	return d.createWindow(win)
+}
+
+func (d *CDashDisplay) createWindow(win *DesktopUIWindow) (*DesktopUIWindow, error) {
 	bytes, err := helper.StructToBytes(win.UIWindow)
 	if err != nil {
 		return nil, err
@@ -150,12 +172,21 @@ func (d *CDashDisplay) CreateWindow(win *DesktopUIWindow) (*DesktopUIWindow, err
 
 	pLogger.Info(fmt.Sprintf("Recived ID message: %v", wID))
 
+	// The device may give us back the ID of a window it destroyed, tables have
+	// to be sent whole to the new one
+	d.packer.Forget(wID.ID)
+	// Have the alert colours sent again so they cover the new window
+	d.shownAlert = ""
+
 	d.State.Layout.AddWindow(win)
 
 	return win, nil
 }
 
 func (d *CDashDisplay) UpdateWindow(win *DesktopUIWindow) error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
 	data := UIWindowUpdatePacket{
 		WinID:  win.UIData.IDX,
 		Window: win.UIWindow,
@@ -176,6 +207,9 @@ func (d *CDashDisplay) UpdateWindow(win *DesktopUIWindow) error {
 	// I get it and update it in the controller
 	// I send the pointer here
 	// -> it should be the same pointer then right?
+	d.packer.Forget(win.UIData.IDX)
+	d.shownAlert = ""
+
 	pLogger.Debug(fmt.Sprintf("PreUpdate ID:  %p", win))
 	d.State.Layout.Windows[win.UIData.IDX] = win
 	pLogger.Debug(fmt.Sprintf("PostUpdate ID: %p", win))
@@ -186,6 +220,13 @@ func (d *CDashDisplay) UpdateWindow(win *DesktopUIWindow) error {
 }
 
 func (d *CDashDisplay) DestroyWindow(wID int16) error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	return d.destroyWindow(wID)
+}
+
+func (d *CDashDisplay) destroyWindow(wID int16) error {
 	type UIWindowDestructPacket struct {
 		WinID int16
 	}
@@ -233,6 +274,9 @@ func (d *CDashDisplay) updateWindowDimensions(win *UIWindow, packet UpdateDimsPa
 }
 
 func (d *CDashDisplay) MoveWindow(wID int16, delta *helper.Vector) error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
 	// Get the window from the layout
 	window, ok := d.State.Layout.Windows[wID]
 	if !ok {
@@ -260,6 +304,9 @@ func (d *CDashDisplay) MoveWindow(wID int16, delta *helper.Vector) error {
 }
 
 func (d *CDashDisplay) ResizeWindow(wID int16, delta *helper.Vector) error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
 	// Get the window from the layout
 	window, ok := d.State.Layout.Windows[wID]
 	if !ok {
@@ -304,7 +351,9 @@ func (d *CDashDisplay) SaveLayout(outputPath string) error {
 		return err
 	}
 
+	d.mut.Lock()
 	data, err := yaml.Marshal(d.State.Layout)
+	d.mut.Unlock()
 	if err != nil {
 		return err
 	}
@@ -329,8 +378,19 @@ func (d *CDashDisplay) LoadLayout(layoutName string) error {
 		return err
 	}
 
+	err = layout.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid layout %s: %w", layoutName, err)
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.State.Layout.FlagAlerts = layout.FlagAlerts
+	d.shownAlert = ""
+
 	for _, w := range layout.Windows {
-		_, err = d.CreateWindow(w)
+		_, err = d.createWindow(w)
 		if err != nil {
 			// NOTE: Add a way to handle multiple errors ?
 			return err
@@ -341,12 +401,18 @@ func (d *CDashDisplay) LoadLayout(layoutName string) error {
 }
 
 func (d *CDashDisplay) UnloadLayout() error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.State.Layout.FlagAlerts = nil
+	d.shownAlert = ""
+
 	var err error
 	for _, w := range d.State.Layout.Windows {
 		pLogger.Debug(fmt.Sprintf("= Removing %d ==============================================",
 			w.UIData.IDX))
 
-		err = d.DestroyWindow(w.UIData.IDX)
+		err = d.destroyWindow(w.UIData.IDX)
 		time.Sleep(75 * time.Millisecond)
 		if err != nil {
 			pLogger.Error(fmt.Sprintf("failed to destroy window: %+v", err))
@@ -358,11 +424,44 @@ func (d *CDashDisplay) UnloadLayout() error {
 			w.UIData.IDX))
 	}
 
+	// The device has no windows left, whatever tables it had are gone
+	d.packer.Reset()
+
 	return nil
 }
 
+// unitFor resolves the unit a window wants the given field in
+func (d *CDashDisplay) unitFor(winID int16, id telemetry.FieldID) conv.Unit {
+	def, ok := telemetry.GetField(id)
+	if !ok {
+		return conv.UnitNone
+	}
+
+	prefs := d.Units
+	if w, ok := d.State.Layout.Windows[winID]; ok {
+		prefs = prefs.Override(w.UIData.Units)
+	}
+
+	return prefs.Resolve(def.Unit)
+}
+
+// elementFor resolves the element of an array field a window shows
+func (d *CDashDisplay) elementFor(winID int16, _ telemetry.FieldID) (int, bool) {
+	w, ok := d.State.Layout.Windows[winID]
+	if !ok || w.UIData.Index == nil {
+		return 0, false
+	}
+
+	return *w.UIData.Index, true
+}
+
 func (d *CDashDisplay) SendData(data *telemetry.TelemetryData) {
-	packet := data.Pack()
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.updateFlagAlert(data)
+
+	packet := d.packer.Pack(data)
 
 	bytes, err := helper.StructToBytes(packet)
 	if err != nil {
@@ -385,6 +484,8 @@ func (d *CDashDisplay) SendData(data *telemetry.TelemetryData) {
 	// var ack packets.AckPacket
 	err = d.WT.SendCommand(sendDataCMDID, bytes, nil)
 	if err != nil && err != io.EOF {
+		// The packer took the tables as sent, have them sent whole again
+		d.packer.Reset()
 		return
 	}
 }
@@ -1,9 +1,27 @@
 package cdashdisplay
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"esdi/telemetry"
+)
 
 type LayoutTree struct {
 	Windows map[int16]*DesktopUIWindow `yaml:"Windows"`
+	// FlagAlerts recolours every window while the flag they are keyed by is
+	// the one telemetry.FlagState shows, ex: BLUE
+	FlagAlerts map[string]FlagAlert `yaml:"FlagAlerts,omitempty"`
+}
+
+// FlagAlert are the colours the windows take while a flag is out
+type FlagAlert struct {
+	BGColour     uint16 `yaml:"BGColour"`
+	BorderColour uint16 `yaml:"BorderColour"`
+	// Flash alternates between these colours and the window's own ones
+	Flash bool `yaml:"Flash,omitempty"`
 }
 
 func NewLayoutTree() *LayoutTree {
@@ -13,7 +31,7 @@ func NewLayoutTree() *LayoutTree {
 }
 
 func (l *LayoutTree) AddWindow(w *DesktopUIWindow) {
-	pLogger.Debug(fmt.Sprintf("adding window '%d' - %v", w.UIData.IDX))
+	pLogger.Debug(fmt.Sprintf("adding window '%d' - %v", w.UIData.IDX, w.Title))
 	l.Windows[w.UIData.IDX] = w
 	pLogger.Debug(fmt.Sprintf("new map - %v", l.Windows))
 }
@@ -23,3 +41,48 @@ func (l *LayoutTree) RemoveWindow(idx int16) {
 	delete(l.Windows, idx)
 	pLogger.Debug(fmt.Sprintf("new map - %v", l.Windows))
 }
+
+// Validate checks that every window in the layout shows a field the telemetry
+// registry knows about, that tables go on TABLE windows, that indexes pick an
+// element of an array and that the units exist. The flag alerts have to be
+// keyed by flags. All the problems are reported at once
+func (l *LayoutTree) Validate() error {
+	var errs []error
+	for _, flag := range slices.Sorted(maps.Keys(l.FlagAlerts)) {
+		if !telemetry.IsFlagName(flag) {
+			errs = append(errs, fmt.Errorf("flag alert: unknown flag %q", flag))
+		}
+	}
+
+	for _, idx := range slices.Sorted(maps.Keys(l.Windows)) {
+		w := l.Windows[idx]
+		id, ok := telemetry.GetFieldID(w.UIData.TelemetryField)
+		if !ok {
+			errs = append(errs, fmt.Errorf("window %d (%s): unknown telemetry field %q",
+				idx, w.Title, w.UIData.TelemetryField))
+		}
+
+		if def, _ := telemetry.GetField(id); ok && def.Type == telemetry.DataTypeTABLE &&
+			w.Opts.WinType != WinTypeTABLE {
+			errs = append(errs, fmt.Errorf("window %d (%s): %q is a table, it needs a %s window",
+				idx, w.Title, w.UIData.TelemetryField, WinTYPES[WinTypeTABLE]))
+		}
+
+		if def, _ := telemetry.GetField(id); ok && w.UIData.Index != nil {
+			switch {
+			case def.Type != telemetry.DataTypeARRAY:
+				errs = append(errs, fmt.Errorf("window %d (%s): %q is not an array, it has no index",
+					idx, w.Title, w.UIData.TelemetryField))
+			case *w.UIData.Index < 0 || *w.UIData.Index >= def.Len:
+				errs = append(errs, fmt.Errorf("window %d (%s): index %d is out of %q, it has %d elements",
+					idx, w.Title, *w.UIData.Index, w.UIData.TelemetryField, def.Len))
+			}
+		}
+
+		if err := w.UIData.Units.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("window %d (%s): %w", idx, w.Title, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -3,6 +3,10 @@ package cdashdisplay
 // In this file we will place all structs that are 1:1 representation of the
 // types in the device transport layer ->
 
+import (
+	conv "esdi/conversions"
+)
+
 const (
 	ShowIDFalse uint8 = 0
 	ShowIDTrue  uint8 = 1
@@ -35,6 +39,10 @@ type UIDecorations struct {
 	Padding      uint8  `yaml:"Padding"`
 }
 
+// WindowProtocol is the version of the window the devices get, the firmware has
+// to match it. 2 added the Precision at the end
+const WindowProtocol = 2
+
 // NOTE: we can't use FString32 for this - too many bytes
 // NOTE: use a bit flags for this options instead
 type UIWindowOpts struct {
@@ -43,11 +51,16 @@ type UIWindowOpts struct {
 	PreviewValue FString32 `yaml:"PreviewValue"`
 }
 
+// UIWindow goes to the devices byte for byte, new fields go at the end so the
+// ones before stay where the firmware reads them
 type UIWindow struct {
 	Dims  UIDimensions  `yaml:"Dims"`
 	Decor UIDecorations `yaml:"Decor"`
 	Opts  UIWindowOpts  `yaml:"Opts"`
 	Title FString32     `yaml:"Title"`
+	// Precision is the number of decimal places the device renders float
+	// values with
+	Precision uint8 `yaml:"Precision"`
 }
 
 type DesktopUIWindow struct {
@@ -58,6 +71,11 @@ type DesktopUIWindow struct {
 type DesktopUIData struct {
 	IDX            int16  `yaml:"WID"`
 	TelemetryField string `yaml:"TelemetryField"`
+	// Units overrides the global unit preferences for this window only
+	Units conv.UnitPreferences `yaml:"Units,omitempty"`
+	// Index picks a single element of an array field, the window gets the
+	// whole array without it
+	Index *int `yaml:"Index,omitempty"`
 }
 
 type UIWindowUpdatePacket struct {
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"esdi/analysis"
+	"esdi/providers"
+	"esdi/tui"
+
+	"github.com/spf13/cobra"
+)
+
+func analyzeCmdAction(cmd *cobra.Command, args []string) {
+	in := args[0]
+
+	src, err := providers.OpenFrames(slog.Default(), in)
+	if err != nil {
+		fmt.Printf("Error opening the recording: %s\n", err.Error())
+		return
+	}
+	defer src.Close()
+
+	report, err := analysis.Analyze(src)
+	if err != nil {
+		fmt.Printf("Error analyzing %s: %s\n", in, err.Error())
+		return
+	}
+
+	asJSON, _ := cmd.Flags().GetBool("json")
+	out, _ := cmd.Flags().GetString("out")
+	if !asJSON && out == "" {
+		if err := tui.ShowLapReport(report); err != nil {
+			fmt.Printf("Error showing the report: %s\n", err.Error())
+		}
+		return
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Printf("Error writing the report: %s\n", err.Error())
+		return
+	}
+
+	if out == "" {
+		fmt.Println(string(data))
+		return
+	}
+
+	if err := os.WriteFile(out, append(data, '\n'), 0644); err != nil {
+		fmt.Printf("Error writing the report: %s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("Analyzed %d laps to %s\n", len(report.Laps), out)
+}
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze <recording>",
+	Short: "shows a lap by lap report of an .ibt or ESDI recording",
+	Long: `Sums up every lap of a recording: lap and sector times, fuel used, tyre
+temperatures, top speed and the minimum speed of every corner. Out laps, pit laps
+and laps the recording doesn't have all of are marked invalid. The report is shown
+in a table, or written as JSON with --json or --out`,
+	Args: cobra.ExactArgs(1),
+	Run:  analyzeCmdAction,
+}
+
+func init() {
+	rootCmd.AddCommand(analyzeCmd)
+
+	analyzeCmd.Flags().Bool("json", false, "print the report as JSON instead of showing it")
+	analyzeCmd.Flags().StringP("out", "o", "", "write the report as JSON to this file")
+}
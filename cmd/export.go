@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"esdi/export"
+	"esdi/providers"
+
+	"github.com/spf13/cobra"
+)
+
+func exportCmdAction(cmd *cobra.Command, args []string) {
+	in := args[0]
+
+	opts, err := exportOptions(cmd)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	out, _ := cmd.Flags().GetString("out")
+	if out == "" {
+		out = strings.TrimSuffix(in, filepath.Ext(in)) + ".csv"
+	}
+
+	src, err := providers.OpenFrames(slog.Default(), in)
+	if err != nil {
+		fmt.Printf("Error opening the recording: %s\n", err.Error())
+		return
+	}
+	defer src.Close()
+
+	f, err := os.Create(out)
+	if err != nil {
+		fmt.Printf("Error creating the export: %s\n", err.Error())
+		return
+	}
+
+	var w export.Writer
+	if filepath.Ext(out) == export.ColumnarExt {
+		w = export.NewColumnarWriter(f)
+	} else {
+		cw := export.NewCSVWriter(f)
+		cw.Units, _ = cmd.Flags().GetBool("units")
+		w = cw
+	}
+
+	rows, err := export.Export(src, w, opts)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Printf("Error exporting %s: %s\n", in, err.Error())
+		return
+	}
+
+	fmt.Printf("Exported %d rows to %s\n", rows, out)
+}
+
+// exportOptions reads the flags that pick what goes in the export
+func exportOptions(cmd *cobra.Command) (export.Options, error) {
+	var opts export.Options
+	var err error
+
+	if fields, _ := cmd.Flags().GetStringSlice("fields"); len(fields) > 0 {
+		if opts.Fields, err = export.ParseFields(fields); err != nil {
+			return opts, err
+		}
+	}
+
+	opts.From, _ = cmd.Flags().GetDuration("from")
+	opts.To, _ = cmd.Flags().GetDuration("to")
+	opts.Laps, _ = cmd.Flags().GetIntSlice("laps")
+	opts.Rate, _ = cmd.Flags().GetFloat64("rate")
+
+	return opts, nil
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export <recording>",
+	Short: "exports an .ibt or ESDI recording to CSV or a columnar file",
+	Long: `Exports the telemetry fields of a recording, named by their keys and in
+the units of the registry. The output format follows its extension: ` + export.ColumnarExt + `
+for the columnar format, CSV otherwise`,
+	Args: cobra.ExactArgs(1),
+	Run:  exportCmdAction,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringP("out", "o", "", "output file, the recording's name with .csv by default")
+	exportCmd.Flags().StringSliceP("fields", "f", nil, "fields to export by key or name, every field by default")
+	exportCmd.Flags().Duration("from", 0, "start the export at this time, ex: 1m30s")
+	exportCmd.Flags().Duration("to", 0, "end the export at this time")
+	exportCmd.Flags().IntSlice("laps", nil, "only export these laps")
+	exportCmd.Flags().Float64("rate", 0, "resample to this many rows per second, every frame by default")
+	exportCmd.Flags().Bool("units", false, "add a row with the units under the CSV header")
+}
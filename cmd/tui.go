@@ -3,20 +3,76 @@ package cmd
 import (
 	"fmt"
 	"log/slog"
+	"path/filepath"
 
+	"esdi/providers"
+	"esdi/recording"
+	"esdi/telemetry"
 	"esdi/tui"
 
 	"github.com/spf13/cobra"
 )
 
 func tuiCmdAction(cmd *cobra.Command, args []string) {
-	err := tui.Run(slog.Default())
+	var provider telemetry.TelemetryProvider
+
+	replay, _ := cmd.Flags().GetString("replay")
+	switch {
+	case filepath.Ext(replay) == recording.Ext:
+		rp, err := providers.NewReplayProvider(slog.Default(), replay)
+		if err != nil {
+			fmt.Printf("Error opening the recording: %s\n", err.Error())
+			return
+		}
+		provider = rp
+	case replay != "":
+		pb, err := newPlayback(cmd, replay)
+		if err != nil {
+			fmt.Printf("Error opening the recording: %s\n", err.Error())
+			return
+		}
+		provider = pb
+	}
+
+	err := tui.Run(slog.Default(), provider)
 	if err != nil {
 		fmt.Printf("Error running TUI: %s\n", err.Error())
 		return
 	}
 }
 
+// newPlayback opens a recording with the playback flags applied
+func newPlayback(cmd *cobra.Command, path string) (telemetry.PlaybackProvider, error) {
+	pb, err := providers.NewPlayback(slog.Default(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	speed, _ := cmd.Flags().GetFloat64("speed")
+	if err = pb.SetSpeed(speed); err != nil {
+		return nil, err
+	}
+
+	loop, _ := cmd.Flags().GetBool("loop")
+	pb.SetLoop(loop)
+
+	if cmd.Flags().Changed("seek") {
+		seek, _ := cmd.Flags().GetDuration("seek")
+		if err = pb.Seek(seek); err != nil {
+			return nil, err
+		}
+	}
+
+	if cmd.Flags().Changed("lap") {
+		lap, _ := cmd.Flags().GetInt("lap")
+		if err = pb.SeekLap(lap); err != nil {
+			return nil, err
+		}
+	}
+
+	return pb, nil
+}
+
 // removeLabelCmd represents the removeLabel command
 var tuiCmd = &cobra.Command{
 	Use:   "tui",
@@ -27,4 +83,11 @@ var tuiCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(tuiCmd)
+
+	// Playing a recording instead of the sim
+	tuiCmd.Flags().StringP("replay", "r", "", "play an .ibt or "+recording.Ext+" recording instead of the sim")
+	tuiCmd.Flags().Float64("speed", 1, "playback speed of the recording, 0.25 to 8")
+	tuiCmd.Flags().Bool("loop", false, "play the recording again when it ends")
+	tuiCmd.Flags().Duration("seek", 0, "start the recording at this time, ex: 1m30s")
+	tuiCmd.Flags().Int("lap", 0, "start the recording at this lap")
 }
@@ -4,8 +4,12 @@ package config
 // NOTE: actually make a package out of this if possible - lets try
 
 import (
+	"fmt"
 	"os"
 
+	conv "esdi/conversions"
+	"esdi/telemetry"
+
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,6 +19,14 @@ type ESDICfg struct {
 	DefaultSim    string `yaml:"default_sim"`
 	DefaultLayout string `yaml:"default_layout"`
 	MetricsServer bool   `yaml:"metrics_server"`
+	// Units the devices show the data in, layouts can override these per window
+	Units conv.UnitPreferences `yaml:"units"`
+	// Fields the user derives from the telemetry, see telemetry.ExpressionDef
+	VirtualFields []telemetry.ExpressionDef `yaml:"virtual_fields"`
+	// Shift light profiles, picked by the name of the car being driven
+	ShiftLights *telemetry.ShiftLightConfig `yaml:"shift_lights"`
+	// Sectors of the tracks, these take over the ones the sim reports
+	TrackSectors []telemetry.TrackSectors `yaml:"track_sectors"`
 }
 
 func (cfg *ESDICfg) loadConfiguration(path string) error {
@@ -28,6 +40,11 @@ func (cfg *ESDICfg) loadConfiguration(path string) error {
 		return err
 	}
 
+	err = instance.Units.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid units: %w", err)
+	}
+
 	return nil
 }
 
@@ -1,6 +1,184 @@
 // Package conversions will host all of our unit conversion functions
 package conversions
 
-func MsToKph(v float32) uint16 {
-	return uint16((3600 * v) / 1000)
+import (
+	"cmp"
+	"fmt"
+)
+
+// Unit is a physical unit as written in the configuration files, ex: "km/h"
+type Unit string
+
+const (
+	UnitNone Unit = ""
+
+	// Speed
+	MetersPerSecond Unit = "m/s"
+	Kph             Unit = "km/h"
+	Mph             Unit = "mph"
+
+	// Temperature
+	Celsius    Unit = "C"
+	Fahrenheit Unit = "F"
+
+	// Pressure
+	Bar Unit = "bar"
+	Psi Unit = "psi"
+	KPa Unit = "kPa"
+
+	// Volume
+	Litre    Unit = "l"
+	USGallon Unit = "gal"
+	UKGallon Unit = "impgal"
+
+	// Units we don't convert but still want to name
+	Seconds      Unit = "s"
+	RPM          Unit = "rpm"
+	Percent      Unit = "%"
+	Meters       Unit = "m"
+	Radians      Unit = "rad"
+	Volts        Unit = "V"
+	Acceleration Unit = "m/s^2"
+)
+
+// Dimension groups the units that can be converted between each other
+type Dimension uint8
+
+const (
+	DimensionNone Dimension = iota
+	DimensionSpeed
+	DimensionTemperature
+	DimensionPressure
+	DimensionVolume
+)
+
+// unitInfo describes a unit by how it relates to the base unit of its dimension:
+// base = value*scale + offset
+type unitInfo struct {
+	dim    Dimension
+	scale  float64
+	offset float64
+}
+
+var units = map[Unit]unitInfo{
+	MetersPerSecond: {DimensionSpeed, 1, 0},
+	Kph:             {DimensionSpeed, 1 / 3.6, 0},
+	Mph:             {DimensionSpeed, 0.44704, 0},
+
+	Celsius:    {DimensionTemperature, 1, 0},
+	Fahrenheit: {DimensionTemperature, 5.0 / 9.0, -32 * 5.0 / 9.0},
+
+	Bar: {DimensionPressure, 1, 0},
+	Psi: {DimensionPressure, 0.0689475729, 0},
+	KPa: {DimensionPressure, 0.01, 0},
+
+	Litre:    {DimensionVolume, 1, 0},
+	USGallon: {DimensionVolume, 3.785411784, 0},
+	UKGallon: {DimensionVolume, 4.54609, 0},
+}
+
+// Dimension returns the dimension of the unit, units we don't know how to
+// convert return DimensionNone
+func (u Unit) Dimension() Dimension {
+	return units[u].dim
+}
+
+// Convert converts a value between two units of the same dimension
+func Convert(v float64, from Unit, to Unit) (float64, error) {
+	if from == to {
+		return v, nil
+	}
+
+	src, ok := units[from]
+	if !ok {
+		return v, fmt.Errorf("unknown unit %q", from)
+	}
+
+	dst, ok := units[to]
+	if !ok {
+		return v, fmt.Errorf("unknown unit %q", to)
+	}
+
+	if src.dim != dst.dim {
+		return v, fmt.Errorf("can't convert %q to %q", from, to)
+	}
+
+	base := v*src.scale + src.offset
+	return (base - dst.offset) / dst.scale, nil
+}
+
+// DefaultSpeed is the speed unit when there's no preference, the speed was
+// always shown in km/h before the preferences
+const DefaultSpeed = Kph
+
+// UnitPreferences holds the units the user wants to see. Empty entries mean
+// "leave it as it comes", except for the speed, see DefaultSpeed
+type UnitPreferences struct {
+	Speed       Unit `yaml:"speed,omitempty"`
+	Temperature Unit `yaml:"temperature,omitempty"`
+	Pressure    Unit `yaml:"pressure,omitempty"`
+	Volume      Unit `yaml:"volume,omitempty"`
+}
+
+// Override returns a copy of these preferences with the entries set on other
+// taking precedence. Used to apply per window preferences on top of the global
+// ones
+func (p UnitPreferences) Override(other UnitPreferences) UnitPreferences {
+	if other.Speed != UnitNone {
+		p.Speed = other.Speed
+	}
+	if other.Temperature != UnitNone {
+		p.Temperature = other.Temperature
+	}
+	if other.Pressure != UnitNone {
+		p.Pressure = other.Pressure
+	}
+	if other.Volume != UnitNone {
+		p.Volume = other.Volume
+	}
+
+	return p
+}
+
+// Resolve returns the unit a value given in the from unit should be shown in
+func (p UnitPreferences) Resolve(from Unit) Unit {
+	var pref Unit
+	switch from.Dimension() {
+	case DimensionSpeed:
+		pref = cmp.Or(p.Speed, DefaultSpeed)
+	case DimensionTemperature:
+		pref = p.Temperature
+	case DimensionPressure:
+		pref = p.Pressure
+	case DimensionVolume:
+		pref = p.Volume
+	}
+
+	if pref == UnitNone {
+		return from
+	}
+
+	return pref
+}
+
+// Validate makes sure every preference is a unit we know, and of the right kind
+func (p UnitPreferences) Validate() error {
+	checks := []struct {
+		unit Unit
+		dim  Dimension
+		name string
+	}{
+		{p.Speed, DimensionSpeed, "speed"},
+		{p.Temperature, DimensionTemperature, "temperature"},
+		{p.Pressure, DimensionPressure, "pressure"},
+		{p.Volume, DimensionVolume, "volume"},
+	}
+
+	for _, c := range checks {
+		if c.unit != UnitNone && c.unit.Dimension() != c.dim {
+			return fmt.Errorf("%q is not a %s unit", c.unit, c.name)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,256 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	conv "esdi/conversions"
+	"esdi/telemetry"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ColumnarExt is the extension of the columnar exports
+const ColumnarExt = ".esdicol"
+
+// A columnar export keeps each column in one block, so a column can be loaded
+// without reading the others:
+//
+//	"ESDICOL" + version    8 bytes
+//	header length          uint32
+//	header                 YAML, see ColumnarHeader
+//	columns...             in the order of the header, Size bytes each
+//
+// Numbers are float64, NaN where the value is missing. Strings and chars are a
+// uint16 length and the bytes, empty where the value is missing. The first
+// column is the time in seconds. All the numbers are little endian
+const (
+	columnarMagic   = "ESDICOL"
+	columnarVersion = 1
+)
+
+// Encodings of the columns
+const (
+	EncodingFloat64 = "float64"
+	EncodingString  = "string"
+)
+
+var ErrNotColumnar = errors.New("not an ESDI columnar export")
+
+// ColumnarHeader describes the columns of the export
+type ColumnarHeader struct {
+	Version int          `yaml:"version"`
+	Rows    int          `yaml:"rows"`
+	Columns []ColumnInfo `yaml:"columns"`
+}
+
+type ColumnInfo struct {
+	Name     string    `yaml:"name"`
+	Unit     conv.Unit `yaml:"unit,omitempty"`
+	Encoding string    `yaml:"encoding"`
+	Size     int       `yaml:"size"` // Bytes the column takes
+}
+
+// ColumnData is a column read back, the values are in Floats or Strings
+// depending on the encoding
+type ColumnData struct {
+	ColumnInfo
+	Floats  []float64
+	Strings []string
+}
+
+// ColumnarWriter holds the rows until it is closed, the columns can only be
+// written once every row is known
+type ColumnarWriter struct {
+	w      io.Writer
+	closer io.Closer
+	rows   int
+	cols   []ColumnData
+}
+
+// NewColumnarWriter writes the export to w, it is closed with the writer when
+// it's an io.Closer
+func NewColumnarWriter(w io.Writer) *ColumnarWriter {
+	cw := &ColumnarWriter{w: w}
+	if c, ok := w.(io.Closer); ok {
+		cw.closer = c
+	}
+
+	return cw
+}
+
+func (cw *ColumnarWriter) WriteHeader(cols []Column) error {
+	cw.cols = make([]ColumnData, len(cols)+1)
+	cw.cols[0].ColumnInfo = ColumnInfo{Name: "Time", Unit: conv.Seconds, Encoding: EncodingFloat64}
+
+	for k, col := range cols {
+		def, _ := telemetry.GetField(col.ID)
+
+		elem := def.Type
+		if col.Elem >= 0 {
+			elem = def.Elem
+		}
+
+		// Chars are letters like the gear's N and R, not numbers
+		encoding := EncodingFloat64
+		if elem == telemetry.DataTypeSTRING || elem == telemetry.DataTypeCHAR {
+			encoding = EncodingString
+		}
+
+		cw.cols[k+1].ColumnInfo = ColumnInfo{Name: col.Name, Unit: col.Unit, Encoding: encoding}
+	}
+
+	return nil
+}
+
+func (cw *ColumnarWriter) WriteRow(t time.Duration, values []telemetry.TelemetryField) error {
+	cw.cols[0].Floats = append(cw.cols[0].Floats, t.Seconds())
+
+	for k := range values {
+		col := &cw.cols[k+1]
+		tf := &values[k]
+
+		if col.Encoding == EncodingString {
+			s := ""
+			if !missing(tf) {
+				s = tf.String()
+			}
+			col.Strings = append(col.Strings, s)
+			continue
+		}
+
+		v := math.NaN()
+		if tf.Type != telemetry.DataTypeSTRING && tf.Type != telemetry.DataTypeCHAR {
+			v = tf.Float()
+		}
+		col.Floats = append(col.Floats, v)
+	}
+
+	cw.rows++
+	return nil
+}
+
+// Close writes the header and every column
+func (cw *ColumnarWriter) Close() error {
+	err := cw.write()
+
+	if cw.closer != nil {
+		if cerr := cw.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+func (cw *ColumnarWriter) write() error {
+	blocks := make([][]byte, len(cw.cols))
+	header := ColumnarHeader{
+		Version: columnarVersion,
+		Rows:    cw.rows,
+		Columns: make([]ColumnInfo, len(cw.cols)),
+	}
+
+	for k := range cw.cols {
+		col := &cw.cols[k]
+
+		var block []byte
+		for _, v := range col.Floats {
+			block = binary.LittleEndian.AppendUint64(block, math.Float64bits(v))
+		}
+		for _, s := range col.Strings {
+			s = s[:min(len(s), math.MaxUint16)]
+			block = binary.LittleEndian.AppendUint16(block, uint16(len(s)))
+			block = append(block, s...)
+		}
+
+		col.Size = len(block)
+		header.Columns[k] = col.ColumnInfo
+		blocks[k] = block
+	}
+
+	out, err := yaml.Marshal(&header)
+	if err != nil {
+		return fmt.Errorf("failed to write the export header: %w", err)
+	}
+
+	w := bufio.NewWriter(cw.w)
+	w.WriteString(columnarMagic)
+	w.WriteByte(columnarVersion)
+	binary.Write(w, binary.LittleEndian, uint32(len(out)))
+	w.Write(out)
+	for _, block := range blocks {
+		w.Write(block)
+	}
+
+	return w.Flush()
+}
+
+// ReadColumnar reads a columnar export back
+func ReadColumnar(r io.Reader) ([]ColumnData, error) {
+	br := bufio.NewReader(r)
+
+	var start [len(columnarMagic) + 1 + 4]byte
+	if _, err := io.ReadFull(br, start[:]); err != nil || string(start[:len(columnarMagic)]) != columnarMagic {
+		return nil, ErrNotColumnar
+	}
+	if v := start[len(columnarMagic)]; v != columnarVersion {
+		return nil, fmt.Errorf("columnar export version %d is not supported", v)
+	}
+
+	out := make([]byte, binary.LittleEndian.Uint32(start[len(columnarMagic)+1:]))
+	if _, err := io.ReadFull(br, out); err != nil {
+		return nil, fmt.Errorf("failed to read the export header: %w", err)
+	}
+
+	var header ColumnarHeader
+	if err := yaml.Unmarshal(out, &header); err != nil {
+		return nil, fmt.Errorf("failed to read the export header: %w", err)
+	}
+
+	cols := make([]ColumnData, len(header.Columns))
+	for k, info := range header.Columns {
+		block := make([]byte, info.Size)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return nil, fmt.Errorf("failed to read column %s: %w", info.Name, err)
+		}
+
+		col := &cols[k]
+		col.ColumnInfo = info
+
+		switch info.Encoding {
+		case EncodingFloat64:
+			if len(block) != header.Rows*8 {
+				return nil, fmt.Errorf("column %s has %d bytes for %d rows", info.Name,
+					len(block), header.Rows)
+			}
+			col.Floats = make([]float64, header.Rows)
+			for r := range col.Floats {
+				col.Floats[r] = math.Float64frombits(binary.LittleEndian.Uint64(block[r*8:]))
+			}
+		case EncodingString:
+			col.Strings = make([]string, 0, header.Rows)
+			for len(block) >= 2 {
+				l := int(binary.LittleEndian.Uint16(block))
+				if len(block) < 2+l {
+					break
+				}
+				col.Strings = append(col.Strings, string(block[2:2+l]))
+				block = block[2+l:]
+			}
+			if len(col.Strings) != header.Rows {
+				return nil, fmt.Errorf("column %s has %d of %d rows", info.Name,
+					len(col.Strings), header.Rows)
+			}
+		default:
+			return nil, fmt.Errorf("column %s has an unknown encoding %q", info.Name, info.Encoding)
+		}
+	}
+
+	return cols, nil
+}
@@ -0,0 +1,91 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	conv "esdi/conversions"
+	"esdi/telemetry"
+)
+
+// CSVWriter writes an export as CSV: a header with the column names, then a
+// row per frame starting with the time in seconds
+type CSVWriter struct {
+	// Units adds a second header row with the unit of each column
+	Units bool
+
+	w      *csv.Writer
+	closer io.Closer
+	record []string
+}
+
+// NewCSVWriter writes the CSV to w, it is closed with the writer when it's an
+// io.Closer
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	cw := &CSVWriter{w: csv.NewWriter(w)}
+	if c, ok := w.(io.Closer); ok {
+		cw.closer = c
+	}
+
+	return cw
+}
+
+func (cw *CSVWriter) WriteHeader(cols []Column) error {
+	cw.record = make([]string, len(cols)+1)
+
+	cw.record[0] = "Time"
+	for k, col := range cols {
+		cw.record[k+1] = col.Name
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return err
+	}
+
+	if !cw.Units {
+		return nil
+	}
+
+	cw.record[0] = string(conv.Seconds)
+	for k, col := range cols {
+		cw.record[k+1] = string(col.Unit)
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *CSVWriter) WriteRow(t time.Duration, values []telemetry.TelemetryField) error {
+	cw.record[0] = strconv.FormatFloat(t.Seconds(), 'f', 3, 64)
+	for k := range values {
+		cw.record[k+1] = formatValue(&values[k])
+	}
+
+	return cw.w.Write(cw.record)
+}
+
+func (cw *CSVWriter) Close() error {
+	cw.w.Flush()
+	err := cw.w.Error()
+
+	if cw.closer != nil {
+		if cerr := cw.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// formatValue writes the value as it is, floats with every digit they have
+func formatValue(tf *telemetry.TelemetryField) string {
+	switch {
+	case missing(tf):
+		return ""
+	case tf.Type == telemetry.DataTypeFLOAT32:
+		return strconv.FormatFloat(tf.Float(), 'g', -1, 32)
+	case tf.Type == telemetry.DataTypeFLOAT64:
+		return strconv.FormatFloat(tf.Float(), 'g', -1, 64)
+	}
+
+	return tf.String()
+}
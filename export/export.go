@@ -0,0 +1,231 @@
+// Package export turns recordings into files the usual data tools read: CSV
+// and a columnar binary format. The columns are the fields of the telemetry
+// registry, named by their keys and in the units the providers fill them in
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	conv "esdi/conversions"
+	"esdi/telemetry"
+)
+
+// Options picks what goes in the export
+type Options struct {
+	// Fields to export, every primitive field of the source when empty
+	Fields []telemetry.FieldID
+	// From and To limit the export to a range from the start of the
+	// recording, a zero To goes to the end
+	From time.Duration
+	To   time.Duration
+	// Laps to export, every lap when empty
+	Laps []int
+	// Rate resamples the frames to this many rows per second, the last frame
+	// before each row is repeated. 0 exports every frame
+	Rate float64
+}
+
+// Column is a column of the export, a field or an element of an array field
+type Column struct {
+	Name string // Key of the field, Key[k] for array elements
+	Unit conv.Unit
+	ID   telemetry.FieldID
+	Elem int // Element of the array, -1 for the other fields
+}
+
+// Writer writes the rows of an export
+type Writer interface {
+	WriteHeader(cols []Column) error
+	// WriteRow writes a row, a value per column. Unused values are missing
+	// values, not a "-"
+	WriteRow(t time.Duration, values []telemetry.TelemetryField) error
+	Close() error
+}
+
+// ParseFields finds the fields by their keys or names
+func ParseFields(names []string) ([]telemetry.FieldID, error) {
+	ids := make([]telemetry.FieldID, 0, len(names))
+	for _, name := range names {
+		id, ok := telemetry.GetFieldIDByKey(name)
+		if !ok {
+			id, ok = telemetry.GetFieldID(name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// Export reads every frame of src and writes the ones the options pick to w,
+// returns how many rows it wrote. w is not closed
+func Export(src telemetry.FrameSource, w Writer, opts Options) (int, error) {
+	if opts.Rate < 0 || (opts.To != 0 && opts.To < opts.From) {
+		return 0, errors.New("invalid export options")
+	}
+
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = sourceFields(src.Name())
+	}
+
+	cols, err := columns(fields)
+	if err != nil {
+		return 0, err
+	}
+
+	// Windows only matter to the devices, any ID binds the field. The lap
+	// is read for the filter even when it isn't exported
+	request := make(map[int16]telemetry.FieldID, len(fields)+1)
+	for k, id := range fields {
+		request[int16(k+1)] = id
+	}
+	if len(opts.Laps) > 0 {
+		request[-1] = telemetry.LapNumber
+	}
+	src.Subscribe(request)
+
+	if err := w.WriteHeader(cols); err != nil {
+		return 0, err
+	}
+
+	var period time.Duration
+	if opts.Rate > 0 {
+		period = time.Duration(float64(time.Second) / opts.Rate)
+	}
+
+	rows := 0
+	row := make([]telemetry.TelemetryField, len(cols))
+	prev := make([]telemetry.TelemetryField, len(cols))
+	hasPrev := false
+
+	var start, next time.Duration
+	first := true
+	for {
+		td, t, err := src.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+
+		if first {
+			start = t
+			first = false
+		}
+
+		at := t - start
+		if at < opts.From {
+			continue
+		}
+		if opts.To != 0 && at > opts.To {
+			break
+		}
+
+		if len(opts.Laps) > 0 {
+			lap := &td.Values[telemetry.LapNumber]
+			if missing(lap) {
+				return rows, errors.New("the recording has no laps to pick from")
+			}
+			if !slices.Contains(opts.Laps, int(lap.Float())) {
+				// The rows start over with the next lap we export
+				hasPrev = false
+				continue
+			}
+		}
+
+		fillRow(row, cols, td)
+
+		if period == 0 {
+			if err := w.WriteRow(at, row); err != nil {
+				return rows, err
+			}
+			rows++
+			continue
+		}
+
+		if !hasPrev {
+			next = at
+		}
+		for ; next <= at; next += period {
+			values := prev
+			if next == at || !hasPrev {
+				values = row
+			}
+
+			if err := w.WriteRow(next, values); err != nil {
+				return rows, err
+			}
+			rows++
+		}
+
+		row, prev = prev, row
+		hasPrev = true
+	}
+
+	return rows, nil
+}
+
+// sourceFields are the primitive fields the provider supplies, the ones worth
+// exporting when none were picked
+func sourceFields(provider string) []telemetry.FieldID {
+	var fields []telemetry.FieldID
+	for _, def := range telemetry.FieldsFromSource(provider) {
+		if def.Type != telemetry.DataTypeTABLE {
+			fields = append(fields, def.ID)
+		}
+	}
+
+	return fields
+}
+
+// columns lays out the fields, arrays take a column per element
+func columns(fields []telemetry.FieldID) ([]Column, error) {
+	var cols []Column
+	for _, id := range fields {
+		def, ok := telemetry.GetField(id)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %d", id)
+		}
+
+		switch def.Type {
+		case telemetry.DataTypeTABLE:
+			return nil, fmt.Errorf("%s is a table, tables can't be exported", def.Key)
+		case telemetry.DataTypeARRAY:
+			for k := range def.Len {
+				cols = append(cols, Column{
+					Name: fmt.Sprintf("%s[%d]", def.Key, k), Unit: def.Unit, ID: id, Elem: k,
+				})
+			}
+		default:
+			cols = append(cols, Column{Name: def.Key, Unit: def.Unit, ID: id, Elem: -1})
+		}
+	}
+
+	return cols, nil
+}
+
+func fillRow(row []telemetry.TelemetryField, cols []Column, td *telemetry.TelemetryData) {
+	for k, col := range cols {
+		tf := &td.Values[col.ID]
+		if col.Elem >= 0 {
+			row[k] = tf.At(col.Elem)
+			continue
+		}
+
+		row[k] = telemetry.TelemetryField{Type: tf.Type, Raw: tf.Raw, Str: tf.Str}
+	}
+}
+
+// missing reports if the value wasn't filled in by the provider
+func missing(tf *telemetry.TelemetryField) bool {
+	return tf.Type == telemetry.DataTypeCHAR && tf.Raw == uint64('-')
+}
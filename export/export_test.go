@@ -0,0 +1,184 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"log/slog"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"esdi/providers/iracing"
+	"esdi/telemetry"
+)
+
+// test_telem.ibt is 1079 frames at 60Hz, lap 1 starts on frame 603
+const (
+	testFrames   = 1079
+	testLapStart = 603
+)
+
+func openIBT(t *testing.T) telemetry.FrameSource {
+	t.Helper()
+
+	src, err := iracing.NewIRacingProvider(slog.New(slog.DiscardHandler), "../test_telem.ibt", "", "")
+	if err != nil {
+		t.Fatalf("failed to open the ibt: %v", err)
+	}
+	t.Cleanup(func() { src.Close() })
+
+	return src
+}
+
+func exportCSV(t *testing.T, opts Options) [][]string {
+	t.Helper()
+
+	var out bytes.Buffer
+	w := NewCSVWriter(&out)
+	w.Units = true
+
+	rows, err := Export(openIBT(t), w, opts)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close the export: %v", err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read the CSV back: %v", err)
+	}
+	if len(records) != rows+2 {
+		t.Fatalf("expected %d rows and the header, got %d", rows, len(records))
+	}
+
+	return records
+}
+
+func seconds(t *testing.T, cell string) time.Duration {
+	t.Helper()
+
+	s, err := strconv.ParseFloat(cell, 64)
+	if err != nil {
+		t.Fatalf("bad time %q: %v", cell, err)
+	}
+
+	return time.Duration(s * float64(time.Second))
+}
+
+func Test_ExportCSV(t *testing.T) {
+	fields, err := ParseFields([]string{"Speed", "Gear", "Lap Number", "TrackName"})
+	if err != nil {
+		t.Fatalf("failed to parse the fields: %v", err)
+	}
+
+	records := exportCSV(t, Options{Fields: fields})
+
+	expect := []string{"Time", "Speed", "Gear", "LapNumber", "TrackName"}
+	for k, name := range expect {
+		if records[0][k] != name {
+			t.Fatalf("expected the columns %v, got %v", expect, records[0])
+		}
+	}
+	if records[1][0] != "s" || records[1][1] != "m/s" || records[1][2] != "" {
+		t.Errorf("expected the units row, got %v", records[1])
+	}
+
+	rows := records[2:]
+	if len(rows) != testFrames {
+		t.Fatalf("expected a row per frame, got %d", len(rows))
+	}
+	if rows[0][0] != "0.000" || rows[0][4] != "Okayama International Circuit" {
+		t.Errorf("unexpected first row %v", rows[0])
+	}
+	if rows[testLapStart-1][3] != "0" || rows[testLapStart][3] != "1" {
+		t.Errorf("expected lap 1 to start on row %d, got %v", testLapStart, rows[testLapStart])
+	}
+
+	if _, err := ParseFields([]string{"NotAField"}); err == nil {
+		t.Errorf("expected an unknown field to fail")
+	}
+}
+
+func Test_ExportFilters(t *testing.T) {
+	fields := []telemetry.FieldID{telemetry.LapNumber}
+
+	// A second of a recording at 60Hz
+	rows := exportCSV(t, Options{Fields: fields, From: 2 * time.Second, To: 3 * time.Second})[2:]
+	if len(rows) < 59 || len(rows) > 61 {
+		t.Errorf("expected about 60 rows, got %d", len(rows))
+	}
+	if at := seconds(t, rows[0][0]); at < 2*time.Second || at > 2*time.Second+20*time.Millisecond {
+		t.Errorf("expected the export to start at 2s, got %s", at)
+	}
+
+	// Lap 1 only, the lap isn't exported
+	rows = exportCSV(t, Options{Fields: []telemetry.FieldID{telemetry.Speed}, Laps: []int{1}})[2:]
+	if len(rows) != testFrames-testLapStart {
+		t.Errorf("expected %d rows of lap 1, got %d", testFrames-testLapStart, len(rows))
+	}
+
+	// Resampled to 10Hz, evenly spaced from the first row
+	rows = exportCSV(t, Options{Fields: fields, Rate: 10})[2:]
+	if len(rows) < 178 || len(rows) > 181 {
+		t.Errorf("expected about 180 rows at 10Hz, got %d", len(rows))
+	}
+	for k := range rows {
+		if at := seconds(t, rows[k][0]); (at - time.Duration(k)*100*time.Millisecond).Abs() > time.Millisecond {
+			t.Fatalf("expected row %d at %dms, got %s", k, k*100, at)
+		}
+	}
+
+	// Upsampled, frames are repeated
+	rows = exportCSV(t, Options{Fields: fields, Rate: 120, To: time.Second})[2:]
+	if len(rows) < 119 || len(rows) > 121 {
+		t.Errorf("expected about 120 rows at 120Hz, got %d", len(rows))
+	}
+}
+
+func Test_ExportColumnar(t *testing.T) {
+	var out bytes.Buffer
+	w := NewColumnarWriter(&out)
+
+	fields := []telemetry.FieldID{telemetry.Speed, telemetry.Gear, telemetry.CarIdxLap}
+	rows, err := Export(openIBT(t), w, Options{Fields: fields, To: time.Second})
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close the export: %v", err)
+	}
+
+	cols, err := ReadColumnar(&out)
+	if err != nil {
+		t.Fatalf("failed to read the export back: %v", err)
+	}
+
+	def, _ := telemetry.GetField(telemetry.CarIdxLap)
+	if len(cols) != 3+def.Len {
+		t.Fatalf("expected time, speed, gear and %d cars, got %d columns", def.Len, len(cols))
+	}
+
+	at, speed, gear, car := cols[0], cols[1], cols[2], cols[3]
+	if at.Name != "Time" || len(at.Floats) != rows || at.Floats[0] != 0 {
+		t.Errorf("unexpected time column %s of %d rows", at.Name, len(at.Floats))
+	}
+	if speed.Name != "Speed" || speed.Unit != "m/s" || speed.Encoding != EncodingFloat64 ||
+		len(speed.Floats) != rows || math.IsNaN(speed.Floats[0]) {
+		t.Errorf("unexpected speed column %+v", speed.ColumnInfo)
+	}
+	if gear.Encoding != EncodingString || len(gear.Strings) != rows || gear.Strings[0] != "N" {
+		t.Errorf("expected the gear as letters, got %+v", gear.ColumnInfo)
+	}
+
+	// The ibt has no per car laps
+	if car.Name != "CarIdxLap[0]" || !math.IsNaN(car.Floats[0]) {
+		t.Errorf("expected %s to be missing, got %v", car.Name, car.Floats[0])
+	}
+
+	if _, err := ReadColumnar(bytes.NewReader([]byte("ESDIREC"))); err != ErrNotColumnar {
+		t.Errorf("expected %v, got %v", ErrNotColumnar, err)
+	}
+}
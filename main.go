@@ -41,7 +41,11 @@ func initApplication() {
 	}
 
 	// Setting up some internal data structures
-	telemetry.Init()
+	err = telemetry.Init(config.GetCfg().VirtualFields, config.GetCfg().ShiftLights,
+		config.GetCfg().TrackSectors)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to register virtual fields: %v", err))
+	}
 }
 
 func setupLogger() error {
@@ -0,0 +1,290 @@
+// Package assetto is the Assetto Corsa and Assetto Corsa Competizione data
+// provider. Both sims share their telemetry on three shared memory pages:
+// physics, graphics and static. ACC kept AC's layout and added to it, so one
+// provider reads both
+package assetto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"esdi/telemetry"
+)
+
+const (
+	NAME = telemetry.SourceAssettoCorsa
+
+	// DefaultDir is where the pages show up on Linux, the sims run under
+	// Proton and a bridge running next to them maps the pages to files there
+	DefaultDir = "/dev/shm"
+)
+
+// Assetto is our Assetto Corsa telemetry data provider - its a
+// TelemetryProvider interface
+type Assetto struct {
+	logger *slog.Logger
+	dir    string // Where the page files are
+
+	// Data Handling
+	mut      sync.Mutex
+	data     *telemetry.TelemetryData
+	updaters [telemetry.MaxFields]func(*telemetry.TelemetryField)
+
+	// The open pages, nil while the sim isn't found, and the copy of them the
+	// current frame is read from
+	shared   *sharedPages
+	physics  page
+	graphics page
+	static   page
+
+	// Timing information
+	ticker *time.Ticker
+
+	// Session tracking
+	session      sessionKey
+	sessionKnown bool
+
+	// Lifecycle
+	telemetry.SourceLifecycle
+	lastPacket int32     // Last physics packet we read
+	lastFrame  time.Time // When the packet last moved
+
+	// Stream
+	streamCh     chan telemetry.TelemetryData
+	streamCancel context.CancelFunc
+}
+
+// sharedPages are the three pages of the sim
+type sharedPages struct {
+	physics  *sharedPage
+	graphics *sharedPage
+	static   *sharedPage
+}
+
+// openPages opens the pages on dir, they are only there while the sim (or its
+// bridge) is running
+func openPages(dir string) (*sharedPages, error) {
+	var sp sharedPages
+	var err error
+
+	if sp.physics, err = openPage(filepath.Join(dir, physicsPage)); err != nil {
+		return nil, err
+	}
+	if sp.graphics, err = openPage(filepath.Join(dir, graphicsPage)); err != nil {
+		sp.Close()
+		return nil, err
+	}
+	if sp.static, err = openPage(filepath.Join(dir, staticPage)); err != nil {
+		sp.Close()
+		return nil, err
+	}
+
+	return &sp, nil
+}
+
+func (sp *sharedPages) Close() error {
+	var errs []error
+	for _, p := range []*sharedPage{sp.physics, sp.graphics, sp.static} {
+		if p != nil {
+			errs = append(errs, p.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewAssettoProvider reads the pages from dir, DefaultDir when empty. The sim
+// doesn't have to be running yet, the stream keeps looking for it
+func NewAssettoProvider(logger *slog.Logger, dir string) (*Assetto, error) {
+	if dir == "" {
+		dir = DefaultDir
+	}
+
+	a := &Assetto{
+		logger:   logger,
+		dir:      dir,
+		data:     telemetry.NewTelemetryData(),
+		physics:  make(page, physicsSize),
+		graphics: make(page, graphicsSize),
+		static:   make(page, staticSize),
+		ticker:   time.NewTicker(time.Second / tickRate),
+		streamCh: make(chan telemetry.TelemetryData, 1),
+	}
+
+	// Channels this provider knows how to read, keyed by the names the
+	// telemetry registry uses as this provider's sources. They are named after
+	// the members of the pages
+	channels := map[string]func(*telemetry.TelemetryField){
+		// Physics
+		"speedKmh":          a.speed,
+		"rpms":              a.rpm,
+		"gear":              a.gear,
+		"fuel":              a.physicsFloat(physFuel),
+		"gas":               a.physicsFloat(physGas),
+		"brake":             a.physicsFloat(physBrake),
+		"accG[0]":           a.accel(0),
+		"accG[1]":           a.accel(1),
+		"accG[2]":           a.accel(2),
+		"heading":           a.physicsFloat(physHeading),
+		"pitch":             a.physicsFloat(physPitch),
+		"roll":              a.physicsFloat(physRoll),
+		"waterTemp":         a.physicsFloat(physWaterTemp),
+		"pitLimiterOn":      a.pitLimiter,
+		"brakeBias":         a.brakeBias,
+		"absInAction":       a.physicsFlag(physABSInAction),
+		"airTemp":           a.physicsFloat(physAirTemp),
+		"roadTemp":          a.physicsFloat(physRoadTemp),
+		"tyreTempIMO":       a.tyreTemps,
+		"wheelsPressure":    a.tyrePressures,
+		"brakeTemp":         a.brakeTemps,
+		"wheelAngularSpeed": a.wheelSpeeds,
+		// Graphics
+		"completedLaps":         a.lapNumber,
+		"position":              a.graphicsUint8(graphPosition),
+		"iCurrentTime":          a.currentLapTime,
+		"iLastTime":             a.lastLapTime,
+		"iBestTime":             a.bestLapTime,
+		"sessionTimeLeft":       a.sessionTimeLeft,
+		"isInPit":               a.graphicsFlag(graphIsInPit),
+		"isInPitLane":           a.graphicsFlag(graphIsInPitLane),
+		"normalizedCarPosition": a.lapDistPct,
+		"flag":                  a.flags,
+		"session":               a.sessionType,
+		"TC":                    a.graphicsUint8(graphTC),
+		"ABS":                   a.graphicsUint8(graphABS),
+		// Static
+		"carModel":           a.staticString(statCarModel),
+		"track":              a.staticString(statTrack),
+		"trackConfiguration": a.staticString(statTrackConfiguration),
+		"playerName":         a.driverName,
+		"maxFuel":            a.staticFloat(statMaxFuel),
+		"trackSPlineLength":  a.staticFloat(statTrackSPlineLength),
+	}
+
+	err := telemetry.ValidateSources(NAME, func(key string) bool {
+		_, ok := channels[key]
+		return ok
+	})
+	if err != nil {
+		logger.Warn(fmt.Sprintf("registry and provider are out of sync: %v", err))
+	}
+
+	// Set the telemetry fields this provider doesn't supply as unused fields
+	for k := range a.updaters {
+		a.updaters[k] = a.unused
+	}
+
+	for _, def := range telemetry.FieldsFromSource(NAME) {
+		if update, ok := channels[def.Sources[NAME]]; ok {
+			a.updaters[def.ID] = update
+		}
+	}
+
+	state := telemetry.SourceWaiting
+	if shared, err := openPages(dir); err != nil {
+		logger.Info(fmt.Sprintf("Assetto Corsa is not running: %v", err))
+	} else {
+		a.shared = shared
+		state = telemetry.SourceConnected
+	}
+
+	a.SourceLifecycle = telemetry.NewSourceLifecycle(logger, NAME, state, &a.mut, a.data)
+
+	return a, nil
+}
+
+// readPages copies the pages the current frame is read from
+func (a *Assetto) readPages() error {
+	return errors.Join(
+		a.shared.physics.read(a.physics),
+		a.shared.graphics.read(a.graphics),
+		a.shared.static.read(a.static),
+	)
+}
+
+// readData reads the next frame from the pages. Reports if the session changed
+func (a *Assetto) readData() (bool, error) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if err := a.readPages(); err != nil {
+		return false, err
+	}
+
+	sessionChanged := a.updateSession()
+
+	// Read 1 to 1 data
+	for _, bind := range a.data.ActiveBinds {
+		a.updaters[bind.ID](&a.data.Values[bind.ID])
+	}
+
+	if sessionChanged {
+		a.data.SessionChanged()
+	}
+
+	// Set up virtual binds
+	for _, vBind := range a.data.VirtualBinds {
+		vBind.Process(a.data)
+	}
+
+	a.data.PenultimateDataPoll = a.data.LastDataPoll
+	a.data.LastDataPoll = time.Now()
+
+	return sessionChanged, nil
+}
+
+// Telemetry Provider Interface
+
+// Stream returns a channel that we will use to funnel the telemetry data back to the
+// UI, which then should broadcast it to the devices
+func (a *Assetto) Stream() (<-chan telemetry.TelemetryData, error) {
+	var ctx context.Context
+	ctx, a.streamCancel = context.WithCancel(context.Background())
+
+	// Start the stream
+	a.stream(ctx)
+
+	return a.streamCh, nil
+}
+
+func (a *Assetto) Name() string {
+	return NAME
+}
+
+func (a *Assetto) StopStream() {
+	if a.streamCancel == nil {
+		return
+	}
+
+	a.streamCancel()
+	a.streamCancel = nil
+}
+
+func (a *Assetto) Subscribe(requestFields map[int16]telemetry.FieldID) {
+	a.logger.Debug(fmt.Sprintf("Len Req: %d\n", len(requestFields)))
+
+	plan, err := telemetry.PlanSubscription(a.logger, requestFields)
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("failed to plan some fields: %v", err))
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	plan.Apply(a.data)
+
+	// Fields this provider doesn't supply are bound anyway, their updater
+	// marks them as unused
+	for _, id := range plan.Primitives {
+		a.data.ActiveBinds = append(a.data.ActiveBinds, telemetry.BoundField{
+			ID: id,
+		})
+	}
+
+	a.logger.Debug(fmt.Sprintf("Subscribed: %+v\n", a.data.ActiveBinds))
+}
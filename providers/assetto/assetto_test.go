@@ -0,0 +1,199 @@
+package assetto
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"esdi/providers/providertest"
+	"esdi/telemetry"
+)
+
+// testdata/acc is a dump of the three pages of ACC in a race at Monza: 180 km/h
+// in third, lap 4 with a blue flag out
+const dumpDir = "testdata/acc"
+
+func newTestProvider(t *testing.T, dir string) *Assetto {
+	t.Helper()
+
+	a, err := NewAssettoProvider(slog.New(slog.DiscardHandler), dir)
+	if err != nil {
+		t.Fatalf("failed to create the provider: %v", err)
+	}
+	t.Cleanup(a.ticker.Stop)
+
+	return a
+}
+
+func Test_PageDump(t *testing.T) {
+	a := newTestProvider(t, dumpDir)
+	if a.State() != telemetry.SourceConnected {
+		t.Fatalf("expected the pages to be found, got %s", a.State())
+	}
+	t.Cleanup(a.detach)
+
+	fields := providertest.SubscribeAll(a.Subscribe, NAME)
+	if _, err := a.readData(); err != nil {
+		t.Fatalf("failed to read the pages: %v", err)
+	}
+
+	providertest.CheckTypes(t, a.data, fields)
+
+	expect := map[telemetry.FieldID]string{
+		telemetry.Speed:           "50.0",
+		telemetry.Gear:            "3",
+		telemetry.RPM:             "6500",
+		telemetry.LatAccel:        "4.9",
+		telemetry.BrakeBias:       "56.0",
+		telemetry.CarName:         "ferrari_296_gt3",
+		telemetry.TrackName:       "monza",
+		telemetry.DriverName:      "Max Power",
+		telemetry.SessionType:     "Race",
+		telemetry.LapNumber:       "4",
+		telemetry.LapLastLapTime:  "01:42.345",
+		telemetry.LapBestLapTime:  "102.0",
+		telemetry.Position:        "5",
+		telemetry.TCSetting:       "3",
+		telemetry.PitSpeedLimiter: "   ",
+		telemetry.Flags:           "4",
+	}
+	providertest.CheckValues(t, a.data, expect)
+
+	// Left tyres have the outer edge on the left, right tyres on the right
+	temps := a.data.Values[telemetry.TyreTemps]
+	for k, want := range []float64{75, 82, 85, 86, 83, 76} {
+		if got := temps.At(k); got.Float() != want {
+			t.Errorf("tyre temp %d: expected %.0f, got %.1f", k, want, got.Float())
+		}
+	}
+
+	// The first frame finds the session, it isn't a change
+	if a.data.Session != 0 {
+		t.Errorf("expected no session change, got %d", a.data.Session)
+	}
+}
+
+// copyDump copies the dump to a directory of its own, to be written to
+func copyDump(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range []string{physicsPage, graphicsPage, staticPage} {
+		buf, err := os.ReadFile(filepath.Join(dumpDir, name))
+		if err != nil {
+			t.Fatalf("failed to read the dump: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), buf, 0o644); err != nil {
+			t.Fatalf("failed to copy the dump: %v", err)
+		}
+	}
+
+	return dir
+}
+
+// writePage writes an int32 member of a page in place, the page stays mapped
+func writePage(t *testing.T, path string, off int64, v int32) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(v))
+	if _, err := f.WriteAt(buf[:], off); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func Test_ShortPages(t *testing.T) {
+	// AC's graphics page ends before the members ACC added
+	dir := copyDump(t)
+	if err := os.Truncate(filepath.Join(dir, graphicsPage), graphNormalizedCarPos+4); err != nil {
+		t.Fatalf("failed to truncate the page: %v", err)
+	}
+
+	a := newTestProvider(t, dir)
+	t.Cleanup(a.detach)
+	a.Subscribe(map[int16]telemetry.FieldID{0: telemetry.LapDistPct, 1: telemetry.Flags})
+	if _, err := a.readData(); err != nil {
+		t.Fatalf("failed to read the pages: %v", err)
+	}
+
+	if got := a.data.Values[telemetry.LapDistPct].Float(); got < 0.41 || got > 0.43 {
+		t.Errorf("expected the lap distance to be read, got %.2f", got)
+	}
+	if got := a.data.Values[telemetry.Flags].Raw; got != 0 {
+		t.Errorf("expected no flags past the end of the page, got %b", got)
+	}
+}
+
+func Test_Flags(t *testing.T) {
+	tests := []struct {
+		name   string
+		flag   int32
+		expect telemetry.Flag
+	}{
+		{"test_none", acNoFlag, 0},
+		{"test_green", acGreenFlag, telemetry.FlagGreen},
+		{"test_penalty", acPenaltyFlag, telemetry.FlagBlack},
+		{"test_damage", acOrangeFlag, telemetry.FlagMeatball},
+		{"test_unknown", 42, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := toFlags(test.flag); got != test.expect {
+				t.Errorf("expected %b, got %b", test.expect, got)
+			}
+		})
+	}
+}
+
+func Test_StreamLifecycle(t *testing.T) {
+	dir := copyDump(t)
+
+	a := newTestProvider(t, dir)
+	a.ticker = time.NewTicker(time.Microsecond * 50)
+	t.Cleanup(a.ticker.Stop)
+
+	frames, _ := a.Stream()
+	t.Cleanup(a.StopStream)
+
+	var states []telemetry.SourceState
+	stopped := false
+	for len(states) < 4 {
+		select {
+		case <-frames:
+			// The sim goes back to the menus
+			if !stopped {
+				writePage(t, filepath.Join(dir, graphicsPage), graphStatus, statusOff)
+				stopped = true
+			}
+		case ev := <-a.Events():
+			states = append(states, ev.State)
+			if ev.State == telemetry.SourceDisconnected && !errors.Is(ev.Err, errSimStopped) {
+				t.Errorf("expected the sim to stop, got %v", ev.Err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out, got the states %v", states)
+		}
+	}
+
+	// The pages are still there but off, it waits for the next session
+	expect := []telemetry.SourceState{
+		telemetry.SourceConnected, telemetry.SourceStreaming,
+		telemetry.SourceDisconnected, telemetry.SourceWaiting,
+	}
+	for k := range expect {
+		if states[k] != expect[k] {
+			t.Fatalf("expected the states %v, got %v", expect, states)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package assetto
+
+import (
+	"esdi/telemetry"
+)
+
+// AC_FLAG_TYPE, the sims show a single flag at a time
+const (
+	acNoFlag        = 0
+	acBlueFlag      = 1
+	acYellowFlag    = 2
+	acBlackFlag     = 3
+	acWhiteFlag     = 4
+	acCheckeredFlag = 5
+	acPenaltyFlag   = 6
+	acGreenFlag     = 7
+	acOrangeFlag    = 8
+)
+
+// acFlags maps AC_FLAG_TYPE to ours. The penalty flag is shown as black and the
+// orange one, for damage, as the meatball
+var acFlags = map[int32]telemetry.Flag{
+	acBlueFlag:      telemetry.FlagBlue,
+	acYellowFlag:    telemetry.FlagYellow,
+	acBlackFlag:     telemetry.FlagBlack,
+	acWhiteFlag:     telemetry.FlagWhite,
+	acCheckeredFlag: telemetry.FlagChequered,
+	acPenaltyFlag:   telemetry.FlagBlack,
+	acGreenFlag:     telemetry.FlagGreen,
+	acOrangeFlag:    telemetry.FlagMeatball,
+}
+
+// toFlags translates an AC_FLAG_TYPE to a telemetry.Flag set, acNoFlag and the
+// flags we don't know are no flag
+func toFlags(flag int32) telemetry.Flag {
+	return acFlags[flag]
+}
+
+// flags is the binding of telemetry.Flags
+func (a *Assetto) flags(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeUINT16
+	out.Raw = uint64(toFlags(a.graphics.i32(graphFlag)))
+}
@@ -0,0 +1,151 @@
+package assetto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"esdi/telemetry"
+)
+
+const (
+	// tickRate is how often we read the pages, the sims write the physics a
+	// lot faster than any dash needs
+	tickRate = 60
+
+	// staleTimeout is how long the sim can be live without a new physics
+	// packet before we take it as gone
+	staleTimeout = 2 * time.Second
+	reconnectMin = 250 * time.Millisecond
+	reconnectMax = 5 * time.Second
+)
+
+var (
+	errSimStopped = errors.New("the sim stopped sending telemetry")
+	errSimStale   = fmt.Errorf("no new telemetry for %s", staleTimeout)
+)
+
+// sessionKey identifies a session. The sims don't number their sessions, a new
+// session type, car or track is a new session. ACC also counts the sessions of
+// the weekend
+type sessionKey struct {
+	session int32
+	index   int32
+	car     string
+	track   string
+}
+
+// currentSession reads the session we are in from the pages
+func (a *Assetto) currentSession() sessionKey {
+	return sessionKey{
+		session: a.graphics.i32(graphSession),
+		index:   a.graphics.i32(graphSessionIndex),
+		car:     a.static.wstr(statCarModel, wcharLen),
+		track:   a.static.wstr(statTrack, wcharLen),
+	}
+}
+
+// updateSession checks for a new session. Reports if the session changed
+func (a *Assetto) updateSession() bool {
+	session := a.currentSession()
+	sessionChanged := a.sessionKnown && session != a.session
+	if sessionChanged {
+		a.logger.Info(fmt.Sprintf("session changed from %+v to %+v", a.session, session))
+	}
+
+	a.session = session
+	a.sessionKnown = true
+
+	return sessionChanged
+}
+
+// connect opens the pages, for when the sim wasn't running
+func (a *Assetto) connect() error {
+	shared, err := openPages(a.dir)
+	if err != nil {
+		return err
+	}
+
+	a.mut.Lock()
+	a.shared = shared
+	a.mut.Unlock()
+
+	return nil
+}
+
+// detach drops the pages of a sim that went away
+func (a *Assetto) detach() {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	a.shared.Close()
+	a.shared = nil
+}
+
+// status reads the state the sim says it is in from the graphics page
+func (a *Assetto) status() int32 {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if err := a.shared.graphics.read(a.graphics); err != nil {
+		return statusOff
+	}
+
+	return a.graphics.i32(graphStatus)
+}
+
+// checkFresh fails when the sim is live and stopped moving the physics forward.
+// Paused and in a replay the physics don't move, that is fine
+func (a *Assetto) checkFresh(now time.Time) error {
+	packet := a.physics.i32(physPacketID)
+	if packet != a.lastPacket || a.graphics.i32(graphStatus) != statusLive {
+		a.lastPacket = packet
+		a.lastFrame = now
+		return nil
+	}
+
+	if now.Sub(a.lastFrame) > staleTimeout {
+		return errSimStale
+	}
+
+	return nil
+}
+
+// ready reports if the sim is in a session. The pages outlive the session, they
+// read as off until the sim is in one
+func (a *Assetto) ready() (bool, error) {
+	if a.status() == statusOff {
+		return false, nil
+	}
+
+	a.lastFrame = time.Now()
+	return true, nil
+}
+
+// read reads a frame, failing when the sim left the session or stopped sending
+// telemetry
+func (a *Assetto) read() (bool, error) {
+	sessionChanged, err := a.readData()
+	if err == nil && a.graphics.i32(graphStatus) == statusOff {
+		err = errSimStopped
+	}
+	if err == nil {
+		err = a.checkFresh(time.Now())
+	}
+
+	return sessionChanged, err
+}
+
+// stream runs the provider lifecycle, see telemetry.SourceLifecycle.Run. The
+// waits for the sim back off so a missing sim doesn't keep a core busy
+func (a *Assetto) stream(ctx context.Context) {
+	a.Run(ctx, telemetry.SourceSteps{
+		Connect: a.connect,
+		Ready:   a.ready,
+		Read:    a.read,
+		Reset:   a.detach,
+		Tick:    a.ticker.C,
+		Retry:   &telemetry.Backoff{Min: reconnectMin, Max: reconnectMax},
+	}, a.streamCh)
+}
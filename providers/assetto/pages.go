@@ -0,0 +1,144 @@
+package assetto
+
+import (
+	"encoding/binary"
+	"math"
+	"unicode/utf16"
+)
+
+// Names of the shared memory pages, on Linux they are files of these names on
+// the pages directory
+const (
+	physicsPage  = "acpmf_physics"
+	graphicsPage = "acpmf_graphics"
+	staticPage   = "acpmf_static"
+)
+
+// Sizes of ACC's pages. AC's pages are shorter, what is past their end reads
+// as zeros
+const (
+	physicsSize  = 800
+	graphicsSize = 1588
+	staticSize   = 820
+)
+
+// Where the members we read are on the physics page, SPageFilePhysics
+const (
+	physPacketID        = 0
+	physGas             = 4
+	physBrake           = 8
+	physFuel            = 12
+	physGear            = 16
+	physRPM             = 20
+	physSpeedKmh        = 28
+	physAccG            = 44 // float[3], x lateral, y vertical, z longitudinal
+	physWheelsPressure  = 88 // float[4], psi
+	physWheelAngularSpd = 104
+	physHeading         = 208
+	physPitch           = 212
+	physRoll            = 216
+	physPitLimiterOn    = 248
+	physAirTemp         = 288
+	physRoadTemp        = 292
+	physBrakeTemp       = 348
+	physTyreTempI       = 368
+	physTyreTempM       = 384
+	physTyreTempO       = 400
+	physBrakeBias       = 564
+	physABSInAction     = 676
+	physWaterTemp       = 712
+)
+
+// Where the members we read are on the graphics page, SPageFileGraphic.
+// NOTE: AC's page has carCoordinates after normalizedCarPosition and ends
+// there, the members after it are ACC's
+const (
+	graphPacketID         = 0
+	graphStatus           = 4
+	graphSession          = 8
+	graphCompletedLaps    = 132
+	graphPosition         = 136
+	graphCurrentTime      = 140 // ms
+	graphLastTime         = 144 // ms
+	graphBestTime         = 148 // ms
+	graphSessionTimeLeft  = 152 // ms
+	graphIsInPit          = 160
+	graphNormalizedCarPos = 248
+	graphFlag             = 1224
+	graphIsInPitLane      = 1236
+	graphTC               = 1268
+	graphABS              = 1280
+	graphSessionIndex     = 1320
+)
+
+// Where the members we read are on the static page, SPageFileStatic. Strings
+// are wchar_t[33], UTF-16 on Windows
+const (
+	statCarModel           = 68
+	statTrack              = 134
+	statPlayerName         = 200
+	statPlayerSurname      = 266
+	statMaxFuel            = 416
+	statTyreRadius         = 436 // float[4], m
+	statTrackSPlineLength  = 520
+	statTrackConfiguration = 524
+
+	wcharLen = 33
+)
+
+// Values of the graphics page status, AC_STATUS
+const (
+	statusOff    = 0
+	statusReplay = 1
+	statusLive   = 2
+	statusPause  = 3
+)
+
+// page is a copy of a shared memory page. Members past its end read as zeros so
+// AC's shorter pages can be read with ACC's layout
+type page []byte
+
+func (p page) i32(off int) int32 {
+	if off+4 > len(p) {
+		return 0
+	}
+
+	return int32(binary.LittleEndian.Uint32(p[off:]))
+}
+
+func (p page) f32(off int) float32 {
+	if off+4 > len(p) {
+		return 0
+	}
+
+	return math.Float32frombits(binary.LittleEndian.Uint32(p[off:]))
+}
+
+// f32s reads a float array of n members
+func (p page) f32s(off int, n int) []float32 {
+	out := make([]float32, n)
+	for k := range out {
+		out[k] = p.f32(off + 4*k)
+	}
+
+	return out
+}
+
+// wstr reads a wchar_t string of n characters, up to its terminator
+func (p page) wstr(off int, n int) string {
+	chars := make([]uint16, 0, n)
+	for k := range n {
+		at := off + 2*k
+		if at+2 > len(p) {
+			break
+		}
+
+		c := binary.LittleEndian.Uint16(p[at:])
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+
+	return string(utf16.Decode(chars))
+}
@@ -0,0 +1,40 @@
+//go:build !unix
+
+package assetto
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// sharedPage is a page file read on every frame.
+// NOTE: on Windows the sim shares the pages as named mappings and not as files,
+// this only works with something that writes them to files
+type sharedPage struct {
+	file *os.File
+}
+
+func openPage(path string) (*sharedPage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sharedPage{file: f}, nil
+}
+
+// read copies the page to dst, what the page doesn't have is zeroed
+func (sp *sharedPage) read(dst page) error {
+	n, err := sp.file.ReadAt(dst, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	clear(dst[n:])
+
+	return nil
+}
+
+func (sp *sharedPage) Close() error {
+	return sp.file.Close()
+}
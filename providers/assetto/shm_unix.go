@@ -0,0 +1,50 @@
+//go:build unix
+
+package assetto
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// sharedPage is a page file mapped in memory, the sim (or the bridge that runs
+// next to it under Proton) writes to it as we read
+type sharedPage struct {
+	mem []byte
+}
+
+func openPage(path string) (*sharedPage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+
+	mem, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s: %w", path, err)
+	}
+
+	return &sharedPage{mem: mem}, nil
+}
+
+// read copies the page to dst, what the page doesn't have is zeroed
+func (sp *sharedPage) read(dst page) error {
+	n := copy(dst, sp.mem)
+	clear(dst[n:])
+
+	return nil
+}
+
+func (sp *sharedPage) Close() error {
+	return syscall.Munmap(sp.mem)
+}
@@ -0,0 +1,243 @@
+package assetto
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"esdi/telemetry"
+)
+
+const (
+	LapTimeFormatStr = "04:05.000"
+
+	// noLapTime is what the sims write on the lap times before there is one
+	noLapTime = math.MaxInt32
+
+	kmhToMs   = 1 / 3.6
+	gravity   = 9.80665
+	psiToKPa  = 6.894757
+	cornerLen = 4
+)
+
+func (a *Assetto) unused(out *telemetry.TelemetryField) {
+	out.Unused()
+}
+
+// Physics page
+
+func (a *Assetto) speed(out *telemetry.TelemetryField) {
+	out.SetFloat32(a.physics.f32(physSpeedKmh) * kmhToMs)
+}
+
+func (a *Assetto) rpm(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeUINT16
+	out.Raw = uint64(uint16(max(a.physics.i32(physRPM), 0)))
+}
+
+// gear converts the gear of the sims (0 is reverse, 1 neutral, 2 first...) to
+// the character we show on the dash
+func (a *Assetto) gear(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeCHAR
+
+	switch gear := a.physics.i32(physGear); {
+	case gear == 0:
+		out.Raw = uint64('R')
+	case gear == 1:
+		out.Raw = uint64('N')
+	case gear > 1 && gear < 11:
+		out.Raw = uint64('0' + gear - 1)
+	default:
+		out.Raw = uint64('?')
+	}
+}
+
+// physicsFloat reads a float that is already in the unit of its field
+func (a *Assetto) physicsFloat(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.SetFloat32(a.physics.f32(off))
+	}
+}
+
+// physicsFlag reads a member that is on when it isn't 0, ACC keeps some of them
+// as floats
+func (a *Assetto) physicsFlag(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		setFlag(out, a.physics.f32(off) != 0)
+	}
+}
+
+// accel reads an axis of accG, the sims give it in G
+func (a *Assetto) accel(axis int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.SetFloat32(a.physics.f32(physAccG+4*axis) * gravity)
+	}
+}
+
+func (a *Assetto) pitLimiter(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeSTRING
+	if a.physics.i32(physPitLimiterOn) != 0 {
+		out.Str = "PIT"
+	} else {
+		out.Str = "   "
+	}
+}
+
+// brakeBias is a 0..1 fraction to the front on the physics page
+// NOTE: ACC's cars show the bias on the dash with an offset per car, this is
+// the bias before it
+func (a *Assetto) brakeBias(out *telemetry.TelemetryField) {
+	out.SetFloat32(a.physics.f32(physBrakeBias) * 100)
+}
+
+// tyreTemps puts the inner, middle and outer temperatures of the sims in the
+// left to right order of the field. The outer edge is the left one of the left
+// side tyres and the right one of the right side tyres
+func (a *Assetto) tyreTemps(out *telemetry.TelemetryField) {
+	inner := a.physics.f32s(physTyreTempI, cornerLen)
+	middle := a.physics.f32s(physTyreTempM, cornerLen)
+	outer := a.physics.f32s(physTyreTempO, cornerLen)
+
+	elems := make([]uint64, 0, 3*cornerLen)
+	for k := range cornerLen {
+		left, right := outer[k], inner[k]
+		if k%2 == 1 {
+			left, right = right, left
+		}
+
+		elems = append(elems, floatElems(left, middle[k], right)...)
+	}
+
+	out.SetArray(telemetry.DataTypeFLOAT32, elems)
+}
+
+func (a *Assetto) tyrePressures(out *telemetry.TelemetryField) {
+	pressures := a.physics.f32s(physWheelsPressure, cornerLen)
+	for k := range pressures {
+		pressures[k] *= psiToKPa
+	}
+
+	out.SetArray(telemetry.DataTypeFLOAT32, floatElems(pressures...))
+}
+
+func (a *Assetto) brakeTemps(out *telemetry.TelemetryField) {
+	out.SetArray(telemetry.DataTypeFLOAT32, floatElems(a.physics.f32s(physBrakeTemp, cornerLen)...))
+}
+
+// wheelSpeeds turns the rad/s of the wheels into the speed of the tyre on the
+// road, with the radius of the tyres on the static page
+func (a *Assetto) wheelSpeeds(out *telemetry.TelemetryField) {
+	speeds := a.physics.f32s(physWheelAngularSpd, cornerLen)
+	radius := a.static.f32s(statTyreRadius, cornerLen)
+	for k := range speeds {
+		speeds[k] = float32(math.Abs(float64(speeds[k] * radius[k])))
+	}
+
+	out.SetArray(telemetry.DataTypeFLOAT32, floatElems(speeds...))
+}
+
+// Graphics page
+
+func (a *Assetto) graphicsUint8(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.Type = telemetry.DataTypeUINT8
+		out.Raw = uint64(uint8(max(a.graphics.i32(off), 0)))
+	}
+}
+
+func (a *Assetto) graphicsFlag(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		setFlag(out, a.graphics.i32(off) != 0)
+	}
+}
+
+// lapNumber is the lap being driven, the sim counts the completed ones
+func (a *Assetto) lapNumber(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeUINT8
+	out.Raw = uint64(uint8(max(a.graphics.i32(graphCompletedLaps)+1, 1)))
+}
+
+func (a *Assetto) lapDistPct(out *telemetry.TelemetryField) {
+	out.SetFloat32(a.graphics.f32(graphNormalizedCarPos))
+}
+
+func (a *Assetto) currentLapTime(out *telemetry.TelemetryField) {
+	out.SetFloat32(lapTime(a.graphics.i32(graphCurrentTime)))
+}
+
+func (a *Assetto) lastLapTime(out *telemetry.TelemetryField) {
+	ms := int64(lapTime(a.graphics.i32(graphLastTime)) * 1000)
+
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = time.UnixMilli(ms).UTC().Format(LapTimeFormatStr)
+}
+
+func (a *Assetto) bestLapTime(out *telemetry.TelemetryField) {
+	out.SetFloat32(lapTime(a.graphics.i32(graphBestTime)))
+}
+
+func (a *Assetto) sessionTimeLeft(out *telemetry.TelemetryField) {
+	out.SetFloat64(max(float64(a.graphics.f32(graphSessionTimeLeft))/1000, 0))
+}
+
+// sessionTypeNames are the names of AC_SESSION_TYPE
+var sessionTypeNames = []string{
+	"Practice", "Qualify", "Race", "Hotlap", "Time Attack", "Drift", "Drag", "Hotstint", "Superpole",
+}
+
+func (a *Assetto) sessionType(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = "Unknown"
+	if session := a.graphics.i32(graphSession); session >= 0 && int(session) < len(sessionTypeNames) {
+		out.Str = sessionTypeNames[session]
+	}
+}
+
+// Static page
+
+func (a *Assetto) staticFloat(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.SetFloat32(a.static.f32(off))
+	}
+}
+
+func (a *Assetto) staticString(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.Type = telemetry.DataTypeSTRING
+		out.Str = a.static.wstr(off, wcharLen)
+	}
+}
+
+func (a *Assetto) driverName(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = strings.TrimSpace(a.static.wstr(statPlayerName, wcharLen) + " " +
+		a.static.wstr(statPlayerSurname, wcharLen))
+}
+
+// Helpers
+
+// lapTime turns the lap times of the sims, in ms, into seconds. No lap time is 0
+func lapTime(ms int32) float32 {
+	if ms <= 0 || ms >= noLapTime {
+		return 0
+	}
+
+	return float32(ms) / 1000
+}
+
+func setFlag(out *telemetry.TelemetryField, on bool) {
+	out.Type = telemetry.DataTypeUINT8
+	out.Raw = 0
+	if on {
+		out.Raw = 1
+	}
+}
+
+func floatElems(values ...float32) []uint64 {
+	elems := make([]uint64, len(values))
+	for k, v := range values {
+		elems[k] = uint64(math.Float32bits(v))
+	}
+
+	return elems
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"sync"
 	"time"
 
@@ -30,10 +31,14 @@ type BeamNG struct {
 
 	// timing
 	ticker *time.Ticker
+
+	// OutSim, for the lap distance. nil when we can't listen for it
+	outSim  *net.UDPConn
+	lapDist *telemetry.LapDistance
 }
 
 const (
-	NAME = "BeamNG.drive"
+	NAME = telemetry.SourceBeamNG
 )
 
 func NewBeamNGProvider(ip string, port int) (*BeamNG, error) {
@@ -47,38 +52,66 @@ func NewBeamNGProvider(ip string, port int) (*BeamNG, error) {
 		data:     telemetry.NewTelemetryData(),
 		SDK:      &beam,
 		ticker:   time.NewTicker(time.Second / 60),
+		lapDist:  telemetry.NewLapDistance(),
 	}
-
-	provider.updaters = [telemetry.MaxFields]func(*telemetry.TelemetryField){
-		telemetry.Speed:     provider.updateSpeed,
-		telemetry.Gear:      provider.updateGear,
-		telemetry.RPM:       provider.updateRPM,
-		telemetry.FuelLevel: provider.fuelLevel,
+	provider.listenOutSim(ip, port)
+
+	// Channels this provider knows how to read, keyed by the names the
+	// telemetry registry uses as this provider's sources
+	// NOTE: OutGauge has no lap distance or track, both come from the car's
+	// position on OutSim (sent to the port after OutGauge's) against the
+	// start/finish lines set up on the track sectors, see telemetry.LapDistance
+	// NOTE: OutGauge doesn't carry the flags or the scenario state either, the
+	// flag fields stay unused for BeamNG
+	channels := map[string]func(*telemetry.TelemetryField){
+		"Speed": provider.updateSpeed,
+		"Gear":  provider.updateGear,
+		"RPM":   provider.updateRPM,
+		"Car":   provider.carName,
+		"Fuel":  provider.fuelLevelPct,
+		// OutSim
+		"LapDist": provider.lapDistPct,
+		"Track":   provider.trackName,
 		// Engine Data
-		telemetry.OilPress:  provider.oilPressure,
-		telemetry.OilTemp:   provider.oilTemp,
-		telemetry.WaterTemp: provider.engTemp,
+		"OilPressure": provider.oilPressure,
+		"OilTemp":     provider.oilTemp,
+		"EngTemp":     provider.engTemp,
 		// Electrics (dash lights and so on)
-		telemetry.PitSpeedLimiter:   provider.pitSpeedLimiter,
-		telemetry.LeftIndicator:     provider.leftIndicator,
-		telemetry.RightIndicator:    provider.rightIndicator,
-		telemetry.Hazards:           provider.unused,
-		telemetry.ABSWarningLight:   provider.absLight,
-		telemetry.ParkingBrakeLight: provider.handbrakeLight,
-		telemetry.TCLight:           provider.tcLight,
-		telemetry.BatteryLight:      provider.batteryLight,
+		"PitSpeed":  provider.pitSpeedLimiter,
+		"SignalL":   provider.leftIndicator,
+		"SignalR":   provider.rightIndicator,
+		"ABS":       provider.absLight,
+		"Handbrake": provider.handbrakeLight,
+		"TC":        provider.tcLight,
+		"Battery":   provider.batteryLight,
+	}
+
+	err = telemetry.ValidateSources(NAME, func(key string) bool {
+		_, ok := channels[key]
+		return ok
+	})
+	if err != nil {
+		slog.Warn(fmt.Sprintf("registry and provider are out of sync: %v", err))
 	}
 
-	// Set the unset telemetry fields on the updaters as unused fields
-	for k := range int(telemetry.MaxFields) {
-		if provider.updaters[k] == nil {
-			provider.updaters[k] = provider.unused
+	// Set the telemetry fields this provider doesn't supply as unused fields
+	for k := range provider.updaters {
+		provider.updaters[k] = provider.unused
+	}
+
+	for _, def := range telemetry.FieldsFromSource(NAME) {
+		if update, ok := channels[def.Sources[NAME]]; ok {
+			provider.updaters[def.ID] = update
 		}
 	}
 
 	return provider, nil
 }
 
+func (b *BeamNG) Name() string {
+	return NAME
+}
+
 func (b *BeamNG) StopStream() {
 	if b.streamCancel == nil {
 		return
@@ -92,6 +125,9 @@ func (b *BeamNG) Stream() (<-chan telemetry.TelemetryData, error) {
 	ctx, b.streamCancel = context.WithCancel(context.Background())
 
 	// Start the stream
+	if b.outSim != nil {
+		go b.receiveOutSim(ctx)
+	}
 	b.stream(ctx)
 
 	return b.streamCh, nil
@@ -101,42 +137,22 @@ func (b *BeamNG) Subscribe(requestFields map[int16]telemetry.FieldID) {
 	// NOTE: document how the Subscribe funtion works
 	slog.Debug(fmt.Sprintf("Len Req: %d\n", len(requestFields)))
 
-	b.data.ActiveBinds = make([]telemetry.BoundField, 0, len(requestFields))
-
-	// First we must add the virtual fields
-	// we will add their dependencies and the primitives to a slice
-	pendingBinds := make([]telemetry.FieldID, telemetry.MaxFields)
-
-	for winID, id := range requestFields {
-		b.data.Values[id].IDs = append(b.data.Values[id].IDs, winID)
-
-		switch id {
-		case telemetry.RPMStateColour:
-			b.data.VirtualBinds = append(b.data.VirtualBinds, telemetry.NewRPMLights())
-		case telemetry.FCCurrentLap:
-			b.data.VirtualBinds = append(b.data.VirtualBinds,
-				telemetry.NewFuelCalculator(slog.Default().WithGroup("FUEL CALC")))
-		default:
-			// primitive telemetry field
-			pendingBinds = append(pendingBinds, id)
-		}
+	plan, err := telemetry.PlanSubscription(slog.Default(), requestFields)
+	if err != nil {
+		slog.Error(fmt.Sprintf("failed to plan some fields: %v", err))
 	}
 
-	boundCheck := make(map[telemetry.FieldID]bool)
+	b.mut.Lock()
+	defer b.mut.Unlock()
 
-	// Now that we know all the fields we need to bind we follow the binding procedure
-	for _, id := range pendingBinds {
-		// Check if we already bound this FieldID
-		if boundCheck[id] {
-			continue
-		}
+	plan.Apply(b.data)
 
-		binding := telemetry.BoundField{
+	// Fields this provider doesn't supply are bound anyway, their updater
+	// marks them as unused
+	for _, id := range plan.Primitives {
+		b.data.ActiveBinds = append(b.data.ActiveBinds, telemetry.BoundField{
 			ID: id,
-		}
-
-		b.data.ActiveBinds = append(b.data.ActiveBinds, binding)
-		boundCheck[id] = true
+		})
 	}
 
 	slog.Debug(fmt.Sprintf("Subscribed: %+v\n", b.data.ActiveBinds))
@@ -0,0 +1,71 @@
+package beamng
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// BeamNG sends the car's motion as OutSim packets, the same as LFS's with
+// "OutSim Opts" at 0. Only the position is read, to work the lap distance out
+// from, see telemetry.LapDistance
+const (
+	outSimPos  = 52 // int32[3], 1m is 65536
+	outSimSize = 64
+	outSimID   = 4 // Size of the optional ID at the end
+
+	// outSimReadTimeout is how long a read waits for a packet
+	outSimReadTimeout = 250 * time.Millisecond
+)
+
+// listenOutSim listens for OutSim on the port after OutGauge's. OutSim is
+// optional, without it there's no lap distance
+func (b *BeamNG) listenOutSim(ip string, port int) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(ip), Port: port + 1})
+	if err != nil {
+		slog.Warn(fmt.Sprintf("can't listen for OutSim, there will be no lap distance: %v", err))
+		return
+	}
+
+	b.outSim = conn
+}
+
+// receiveOutSim moves the car on the lap with every OutSim packet until the
+// context is done
+func (b *BeamNG) receiveOutSim(ctx context.Context) {
+	buf := make([]byte, 512)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// Don't block for long, we need to notice the stream stopping
+		b.outSim.SetReadDeadline(time.Now().Add(outSimReadTimeout))
+		n, _, err := b.outSim.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+
+		if n != outSimSize && n != outSimSize+outSimID {
+			slog.Debug(fmt.Sprintf("dropped an OutSim packet of %d bytes, is OutSim Opts set to 0?", n))
+			continue
+		}
+
+		x := float64(int32(binary.LittleEndian.Uint32(buf[outSimPos:]))) / 65536
+		y := float64(int32(binary.LittleEndian.Uint32(buf[outSimPos+4:]))) / 65536
+
+		b.mut.Lock()
+		b.lapDist.Update(x, y)
+		b.mut.Unlock()
+	}
+}
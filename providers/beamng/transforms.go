@@ -1,10 +1,9 @@
 package beamng
 
 import (
+	"bytes"
 	"strconv"
 
-	conv "esdi/conversions"
-
 	"esdi/telemetry"
 )
 
@@ -17,8 +16,7 @@ func (b *BeamNG) unused(out *telemetry.TelemetryField) {
 }
 
 func (b *BeamNG) updateSpeed(out *telemetry.TelemetryField) {
-	out.Type = telemetry.DataTypeUINT16
-	out.Raw = uint64(conv.MsToKph(b.SDK.Data.Speed))
+	out.SetFloat32(b.SDK.Data.Speed)
 }
 
 func (b *BeamNG) updateGear(out *telemetry.TelemetryField) {
@@ -32,20 +30,37 @@ func (b *BeamNG) updateRPM(out *telemetry.TelemetryField) {
 	out.Raw = uint64(uint16(b.SDK.Data.RPM))
 }
 
-func (b *BeamNG) fuelLevel(out *telemetry.TelemetryField) {
-	telemetry.FloatToStringTransform(b.SDK.Data.Fuel, out)
+func (b *BeamNG) carName(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = string(bytes.TrimRight(b.SDK.Data.Car[:], "\x00"))
+}
+
+// fuelLevelPct is 0 to 1 of the tank, OutGauge doesn't send the litres
+func (b *BeamNG) fuelLevelPct(out *telemetry.TelemetryField) {
+	out.SetFloat32(b.SDK.Data.Fuel)
+}
+
+// lapDistPct is -1 until the car drives through a start/finish line, like
+// iRacing's when the car isn't on track
+func (b *BeamNG) lapDistPct(out *telemetry.TelemetryField) {
+	out.SetFloat32(float32(b.lapDist.Pct()))
+}
+
+func (b *BeamNG) trackName(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = b.lapDist.Track()
 }
 
 func (b *BeamNG) oilPressure(out *telemetry.TelemetryField) {
-	telemetry.FloatToStringTransform(b.SDK.Data.OilPressure, out)
+	out.SetFloat32(b.SDK.Data.OilPressure)
 }
 
 func (b *BeamNG) oilTemp(out *telemetry.TelemetryField) {
-	telemetry.FloatToStringTransform(b.SDK.Data.OilTemp, out)
+	out.SetFloat32(b.SDK.Data.OilTemp)
 }
 
 func (b *BeamNG) engTemp(out *telemetry.TelemetryField) {
-	telemetry.FloatToStringTransform(b.SDK.Data.EngTemp, out)
+	out.SetFloat32(b.SDK.Data.EngTemp)
 }
 
 // NOTE: find how to empty this
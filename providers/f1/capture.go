@@ -0,0 +1,126 @@
+package f1
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// A capture is the packets the game sent, one after the other, each with when
+// it came in:
+//
+//	uint32 ms since the first packet
+//	uint16 length of the packet
+//	the packet
+//
+// Sent back to the provider a capture stands in for the game, see SendCapture
+
+// CapturedPacket is a packet of a capture
+type CapturedPacket struct {
+	At   time.Duration // Since the first packet
+	Data []byte
+}
+
+// captureHeaderSize is the size of the fields in front of every packet
+const captureHeaderSize = 6
+
+// WriteCapturedPacket adds a packet to a capture
+func WriteCapturedPacket(w io.Writer, cp CapturedPacket) error {
+	if len(cp.Data) > maxPacketSize {
+		return fmt.Errorf("packet of %d bytes, the most is %d", len(cp.Data), maxPacketSize)
+	}
+
+	var header [captureHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[0:], uint32(cp.At.Milliseconds()))
+	binary.LittleEndian.PutUint16(header[4:], uint16(len(cp.Data)))
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(cp.Data)
+
+	return err
+}
+
+// ReadCapture reads all the packets of a capture
+func ReadCapture(r io.Reader) ([]CapturedPacket, error) {
+	var packets []CapturedPacket
+
+	for {
+		var header [captureHeaderSize]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return packets, nil
+			}
+			return packets, fmt.Errorf("bad capture after %d packets: %w", len(packets), err)
+		}
+
+		cp := CapturedPacket{
+			At:   time.Duration(binary.LittleEndian.Uint32(header[0:])) * time.Millisecond,
+			Data: make([]byte, binary.LittleEndian.Uint16(header[4:])),
+		}
+		if _, err := io.ReadFull(r, cp.Data); err != nil {
+			return packets, fmt.Errorf("bad capture after %d packets: %w", len(packets), err)
+		}
+
+		packets = append(packets, cp)
+	}
+}
+
+// SendCapture sends the packets of a capture to addr at the pace they came in,
+// until the last one or until the context is done
+func SendCapture(ctx context.Context, addr string, packets []CapturedPacket) error {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	for _, cp := range packets {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(start.Add(cp.At))):
+		}
+
+		if _, err := conn.Write(cp.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Capture writes the packets the provider gets to w, to be sent back later.
+// Only the packets that come in after the call are written
+func (f *F1) Capture(w io.Writer) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	f.capture = w
+	f.captureStart = time.Time{}
+}
+
+// capturePacket writes a packet to the capture, if there's one
+func (f *F1) capturePacket(buf []byte, now time.Time) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if f.capture == nil {
+		return
+	}
+	if f.captureStart.IsZero() {
+		f.captureStart = now
+	}
+
+	err := WriteCapturedPacket(f.capture, CapturedPacket{At: now.Sub(f.captureStart), Data: buf})
+	if err != nil {
+		f.logger.Error(fmt.Sprintf("failed to capture a packet, capture stopped: %v", err))
+		f.capture = nil
+	}
+}
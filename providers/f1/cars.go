@@ -0,0 +1,50 @@
+package f1
+
+import (
+	"strconv"
+
+	"esdi/telemetry"
+)
+
+// readCars takes a snapshot of every car in the session from the lap data and
+// the participants. The game doesn't estimate where the cars are in time, the
+// estimate is the car's last lap over how far it is on the lap
+func (f *F1) readCars() []telemetry.CarState {
+	laps := f.packets[packetLapData]
+	participants := f.packets[packetParticipants]
+	if len(laps) == 0 || len(participants) == 0 {
+		return nil
+	}
+
+	player := int(laps.u8(headerPlayerCarIdx))
+
+	cars := make([]telemetry.CarState, 0, f.activeCars())
+	for idx := range f.activeCars() {
+		name := participants.str(car(idx, participantSize, participantsCars, participantName), participantNameLen)
+		number := participants.u8(car(idx, participantSize, participantsCars, participantRaceNumber))
+		lapTime := float64(laps.u32(car(idx, lapSize, 0, lapLastLapTimeInMS))) / 1000
+		distPct := f.distPct(laps.f32(car(idx, lapSize, 0, lapDistance)))
+
+		cars = append(cars, telemetry.CarState{
+			Idx:        idx,
+			Number:     strconv.Itoa(int(number)),
+			Name:       name,
+			Lap:        int(laps.u8(car(idx, lapSize, 0, lapCurrentLapNum))),
+			LapDistPct: distPct,
+			EstTime:    distPct * lapTime,
+			EstLapTime: lapTime,
+			IsPlayer:   idx == player,
+		})
+	}
+
+	return cars
+}
+
+// updateCars is the binding of telemetry.Cars, the cars go on the data and the
+// field holds how many there are
+func (f *F1) updateCars(out *telemetry.TelemetryField) {
+	f.data.Cars = f.readCars()
+
+	out.Type = telemetry.DataTypeUINT8
+	out.Raw = uint64(len(f.data.Cars))
+}
@@ -0,0 +1,334 @@
+// Package f1 is the data provider of the F1 games from Codemasters/EA. The game
+// sends its telemetry as UDP packets, each of a kind (motion, lap data, car
+// telemetry...) with every car of the session. We keep the last packet of each
+// kind and read the player's car from them
+package f1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"esdi/telemetry"
+)
+
+const (
+	NAME = telemetry.SourceF1
+
+	// DefaultUDPPort is the port the game sends to unless told otherwise
+	DefaultUDPPort = 20777
+
+	// maxPacketSize is more than the biggest packet the game sends
+	maxPacketSize = 2048
+)
+
+var errShortPacket = errors.New("packet too short")
+
+// F1 is our F1 telemetry data provider - its a TelemetryProvider interface
+type F1 struct {
+	logger *slog.Logger
+	conn   *net.UDPConn
+
+	// Data Handling
+	mut      sync.Mutex
+	data     *telemetry.TelemetryData
+	updaters [telemetry.MaxFields]func(*telemetry.TelemetryField)
+
+	// Last packet of each kind, as it came from the game
+	packets      [packetCount]packet
+	lastPacket   time.Time // When the last packet came in
+	formatWarned bool      // The game sends a format we don't read, told once
+	capture      io.Writer // Where the packets are captured to, see Capture
+	captureStart time.Time
+
+	// Timing information
+	ticker *time.Ticker
+
+	// Session tracking
+	sessionUID   uint64 // Of the last packet
+	session      uint64 // Of the last frame
+	sessionKnown bool
+
+	// Lifecycle
+	telemetry.SourceLifecycle
+
+	// Stream
+	streamCh     chan telemetry.TelemetryData
+	streamCancel context.CancelFunc
+}
+
+// NewF1Provider listens for the game on ip:port. On another box of the LAN
+// the game has to be set to send to this one, or to broadcast
+func NewF1Provider(logger *slog.Logger, ip string, port int) (*F1, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(ip), Port: port})
+	if err != nil {
+		return &F1{}, err
+	}
+
+	f := &F1{
+		logger:   logger,
+		conn:     conn,
+		data:     telemetry.NewTelemetryData(),
+		ticker:   time.NewTicker(time.Second / tickRate),
+		streamCh: make(chan telemetry.TelemetryData, 1),
+	}
+	f.SourceLifecycle = telemetry.NewSourceLifecycle(logger, NAME, telemetry.SourceWaiting, &f.mut, f.data)
+
+	// Channels this provider knows how to read, keyed by the names the
+	// telemetry registry uses as this provider's sources. They are named after
+	// the members of the packets
+	channels := map[string]func(*telemetry.TelemetryField){
+		// Header
+		"sessionTime": f.sessionTime,
+		// Motion
+		"gForceLateral":      f.accel(motionGForceLat),
+		"gForceLongitudinal": f.accel(motionGForceLong),
+		"gForceVertical":     f.accel(motionGForceVert),
+		"yaw":                f.motionFloat(motionYaw),
+		"pitch":              f.motionFloat(motionPitch),
+		"roll":               f.motionFloat(motionRoll),
+		// Car telemetry
+		"speed":                   f.speed,
+		"engineRPM":               f.rpm,
+		"gear":                    f.gear,
+		"throttle":                f.telemetryFloat(telemetryThrottle),
+		"brake":                   f.telemetryFloat(telemetryBrake),
+		"clutch":                  f.clutch,
+		"engineTemperature":       f.engineTemp,
+		"brakesTemperature":       f.brakeTemps,
+		"tyresSurfaceTemperature": f.tyreTemps(telemetryTyresSurfaceTemp),
+		"tyresInnerTemperature":   f.tyreTemps(telemetryTyresInnerTemp),
+		"tyresPressure":           f.tyrePressures,
+		// Car status
+		"fuelInTank":       f.statusFloat(statusFuelInTank),
+		"fuelCapacity":     f.statusFloat(statusFuelCapacity),
+		"frontBrakeBias":   f.brakeBias,
+		"antiLockBrakes":   f.statusUint8(statusAntiLockBrakes),
+		"tractionControl":  f.statusUint8(statusTractionControl),
+		"pitLimiterStatus": f.pitLimiter,
+		"vehicleFiaFlags":  f.flags,
+		// Lap data
+		"lastLapTimeInMS":    f.lastLapTime,
+		"currentLapTimeInMS": f.currentLapTime,
+		"currentLapNum":      f.lapUint8(lapCurrentLapNum),
+		"lapDistance":        f.lapDistPct,
+		"carPosition":        f.lapUint8(lapCarPosition),
+		"pitStatus":          f.onPitRoad,
+		// Lap data of every car
+		"lapData[].lapDistance":     f.carsLapDistPct,
+		"lapData[].currentLapNum":   f.carsLap,
+		"lapData[].carPosition":     f.carsUint8(lapCarPosition),
+		"lapData[].pitStatus":       f.carsOnPitRoad,
+		"lapData[].lastLapTimeInMS": f.carsLastLapTime,
+		// Session
+		"sessionTimeLeft":  f.sessionSeconds(sessionTimeLeft),
+		"sessionDuration":  f.sessionSeconds(sessionDuration),
+		"totalLaps":        f.totalLaps,
+		"airTemperature":   f.sessionTemp(sessionAirTemperature),
+		"trackTemperature": f.sessionTemp(sessionTrackTemperature),
+		"trackLength":      f.trackLength,
+		"trackId":          f.trackName,
+		"sessionType":      f.sessionType,
+		// Participants
+		"name":         f.driverName,
+		"participants": f.updateCars,
+	}
+
+	err = telemetry.ValidateSources(NAME, func(key string) bool {
+		_, ok := channels[key]
+		return ok
+	})
+	if err != nil {
+		logger.Warn(fmt.Sprintf("registry and provider are out of sync: %v", err))
+	}
+
+	// Set the telemetry fields this provider doesn't supply as unused fields
+	for k := range f.updaters {
+		f.updaters[k] = f.unused
+	}
+
+	for _, def := range telemetry.FieldsFromSource(NAME) {
+		if update, ok := channels[def.Sources[NAME]]; ok {
+			f.updaters[def.ID] = update
+		}
+	}
+
+	return f, nil
+}
+
+// handlePacket keeps a packet the game sent as the last one of its kind. The
+// packets of an older session are dropped when a new one starts
+func (f *F1) handlePacket(buf []byte, now time.Time) error {
+	p := packet(buf)
+	if len(p) < headerSize {
+		return errShortPacket
+	}
+
+	if format := p.u16(headerPacketFormat); format != packetFormat {
+		if !f.formatWarned {
+			f.logger.Warn(fmt.Sprintf("the game sends the %d UDP format, set it to %d", format, packetFormat))
+			f.formatWarned = true
+		}
+		return nil
+	}
+
+	id := p.u8(headerPacketID)
+	size, ok := packetSizes[id]
+	if !ok {
+		// Not a packet we read
+		return nil
+	}
+	if len(p) < size {
+		return fmt.Errorf("%w: packet %d has %d bytes, expected %d", errShortPacket, id, len(p), size)
+	}
+
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if uid := p.u64(headerSessionUID); uid != f.sessionUID {
+		for k := range f.packets {
+			f.packets[k] = f.packets[k][:0]
+		}
+		f.sessionUID = uid
+	}
+
+	f.packets[id] = append(f.packets[id][:0], p...)
+	f.lastPacket = now
+
+	return nil
+}
+
+// receive reads the packets until the context is done
+func (f *F1) receive(ctx context.Context) {
+	buf := make([]byte, maxPacketSize)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// Don't block for long, we need to notice the stream stopping
+		f.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		n, _, err := f.conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				f.logger.Debug(fmt.Sprintf("failed to read a packet: %v", err))
+			}
+			continue
+		}
+
+		now := time.Now()
+		f.capturePacket(buf[:n], now)
+		if err := f.handlePacket(buf[:n], now); err != nil {
+			f.logger.Debug(fmt.Sprintf("dropped a packet: %v", err))
+		}
+	}
+}
+
+// ready reports if the packets we can't do without came in
+func (f *F1) ready() bool {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	return len(f.packets[packetSession]) > 0 && len(f.packets[packetCarTelemetry]) > 0
+}
+
+// readData reads a frame from the last packets. Reports if the session changed
+func (f *F1) readData() bool {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	sessionChanged := f.sessionKnown && f.sessionUID != f.session
+	if sessionChanged {
+		f.logger.Info(fmt.Sprintf("session changed from %x to %x", f.session, f.sessionUID))
+	}
+	f.session = f.sessionUID
+	f.sessionKnown = true
+
+	// Read 1 to 1 data
+	for _, bind := range f.data.ActiveBinds {
+		f.updaters[bind.ID](&f.data.Values[bind.ID])
+	}
+
+	if sessionChanged {
+		f.data.SessionChanged()
+	}
+
+	// Set up virtual binds
+	for _, vBind := range f.data.VirtualBinds {
+		vBind.Process(f.data)
+	}
+
+	f.data.PenultimateDataPoll = f.data.LastDataPoll
+	f.data.LastDataPoll = time.Now()
+
+	return sessionChanged
+}
+
+// Telemetry Provider Interface
+
+// Stream returns a channel that we will use to funnel the telemetry data back to the
+// UI, which then should broadcast it to the devices
+func (f *F1) Stream() (<-chan telemetry.TelemetryData, error) {
+	var ctx context.Context
+	ctx, f.streamCancel = context.WithCancel(context.Background())
+
+	// Start the stream
+	go f.receive(ctx)
+	f.stream(ctx)
+
+	return f.streamCh, nil
+}
+
+// Close stops listening for the game
+func (f *F1) Close() error {
+	return f.conn.Close()
+}
+
+func (f *F1) Name() string {
+	return NAME
+}
+
+func (f *F1) StopStream() {
+	if f.streamCancel == nil {
+		return
+	}
+
+	f.streamCancel()
+	f.streamCancel = nil
+}
+
+func (f *F1) Subscribe(requestFields map[int16]telemetry.FieldID) {
+	f.logger.Debug(fmt.Sprintf("Len Req: %d\n", len(requestFields)))
+
+	plan, err := telemetry.PlanSubscription(f.logger, requestFields)
+	if err != nil {
+		f.logger.Error(fmt.Sprintf("failed to plan some fields: %v", err))
+	}
+
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	plan.Apply(f.data)
+
+	// Fields this provider doesn't supply are bound anyway, their updater
+	// marks them as unused
+	for _, id := range plan.Primitives {
+		f.data.ActiveBinds = append(f.data.ActiveBinds, telemetry.BoundField{
+			ID: id,
+		})
+	}
+
+	f.logger.Debug(fmt.Sprintf("Subscribed: %+v\n", f.data.ActiveBinds))
+}
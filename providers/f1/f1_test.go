@@ -0,0 +1,241 @@
+package f1
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"esdi/providers/providertest"
+	"esdi/telemetry"
+)
+
+// testdata/f1_24.capture is a race at Monza with 20 cars, the player is the car
+// on slot 3 doing 288 km/h in seventh on lap 3 with a yellow flag out. It's the
+// participants and the session followed by 10 frames of the other packets
+const capturePath = "testdata/f1_24.capture"
+
+func loadCapture(t *testing.T) []CapturedPacket {
+	t.Helper()
+
+	f, err := os.Open(capturePath)
+	if err != nil {
+		t.Fatalf("failed to open the capture: %v", err)
+	}
+	defer f.Close()
+
+	packets, err := ReadCapture(f)
+	if err != nil {
+		t.Fatalf("failed to read the capture: %v", err)
+	}
+
+	return packets
+}
+
+func newTestProvider(t *testing.T) *F1 {
+	t.Helper()
+
+	f, err := NewF1Provider(slog.New(slog.DiscardHandler), "127.0.0.1", 0)
+	if err != nil {
+		t.Fatalf("failed to create the provider: %v", err)
+	}
+	t.Cleanup(f.ticker.Stop)
+	t.Cleanup(func() { f.Close() })
+
+	return f
+}
+
+func Test_Packets(t *testing.T) {
+	f := newTestProvider(t)
+	fields := providertest.SubscribeAll(f.Subscribe, NAME)
+
+	for _, cp := range loadCapture(t) {
+		if err := f.handlePacket(cp.Data, time.Now()); err != nil {
+			t.Fatalf("failed to handle a packet: %v", err)
+		}
+	}
+	if !f.ready() {
+		t.Fatalf("expected the session and the car telemetry to be in")
+	}
+	f.readData()
+
+	providertest.CheckTypes(t, f.data, fields)
+
+	expect := map[telemetry.FieldID]string{
+		telemetry.Speed:           "80.0",
+		telemetry.Gear:            "7",
+		telemetry.RPM:             "11500",
+		telemetry.LatAccel:        "14.7",
+		telemetry.BrakeBias:       "57.0",
+		telemetry.FuelLevel:       "45.5",
+		telemetry.SessionTime:     "129.0",
+		telemetry.TrackName:       "Monza",
+		telemetry.TrackLength:     "5793.0",
+		telemetry.SessionType:     "Race",
+		telemetry.DriverName:      "Max Power",
+		telemetry.LapNumber:       "3",
+		telemetry.LapLastLapTime:  "01:22.348",
+		telemetry.LapDistPct:      "0.4",
+		telemetry.Position:        "4",
+		telemetry.OnPitRoad:       "0",
+		telemetry.PitSpeedLimiter: "   ",
+		telemetry.Flags:           "2",
+		telemetry.Cars:            "20",
+	}
+	providertest.CheckValues(t, f.data, expect)
+
+	// The wheels come as RL, RR, FL, FR
+	brakes := f.data.Values[telemetry.BrakeTemps]
+	for k, want := range []float64{600, 610, 500, 510} {
+		if got := brakes.At(k); got.Float() != want {
+			t.Errorf("brake temp %d: expected %.0f, got %.1f", k, want, got.Float())
+		}
+	}
+
+	// The slots past the active cars aren't in the world
+	onPit := f.data.Values[telemetry.CarIdxOnPitRoad]
+	if got := onPit.At(5); got.Raw != 1 {
+		t.Errorf("expected the car on slot 5 on pit road, got %d", got.Raw)
+	}
+	dists := f.data.Values[telemetry.CarIdxLapDistPct]
+	if got := dists.At(20); got.Float() != -1 {
+		t.Errorf("expected slot 20 out of the world, got %.2f", got.Float())
+	}
+
+	player := 0
+	for _, c := range f.data.Cars {
+		if c.IsPlayer {
+			player++
+			if c.Idx != 3 || c.Name != "Max Power" || c.Number != "4" {
+				t.Errorf("unexpected player car %+v", c)
+			}
+		}
+	}
+	if player != 1 {
+		t.Errorf("expected a single player car, got %d", player)
+	}
+}
+
+func Test_SessionChange(t *testing.T) {
+	f := newTestProvider(t)
+	f.Subscribe(map[int16]telemetry.FieldID{0: telemetry.Speed})
+
+	packets := loadCapture(t)
+	for _, cp := range packets {
+		f.handlePacket(cp.Data, time.Now())
+	}
+	f.readData()
+
+	// The first frame finds the session, it isn't a change
+	if f.data.Session != 0 {
+		t.Fatalf("expected no session change, got %d", f.data.Session)
+	}
+
+	// A packet of another session drops the packets of this one
+	next := bytes.Clone(packets[len(packets)-1].Data)
+	next[headerSessionUID]++
+	if err := f.handlePacket(next, time.Now()); err != nil {
+		t.Fatalf("failed to handle the packet: %v", err)
+	}
+	if f.ready() {
+		t.Errorf("expected the session packet of the old session to be gone")
+	}
+	if !f.readData() || f.data.Session != 1 {
+		t.Errorf("expected a session change, got %d", f.data.Session)
+	}
+
+	// Other years are left alone
+	old := bytes.Clone(next)
+	old[headerPacketFormat] = 0xE7 // 2023
+	if err := f.handlePacket(old, time.Now()); err != nil || !f.formatWarned {
+		t.Errorf("expected the packet to be ignored with a warning, got %v", err)
+	}
+
+	if err := f.handlePacket(next[:headerSize+10], time.Now()); err == nil {
+		t.Errorf("expected a short packet to fail")
+	}
+}
+
+func Test_Flags(t *testing.T) {
+	tests := []struct {
+		name   string
+		flag   int8
+		expect telemetry.Flag
+	}{
+		{"test_invalid", fiaInvalid, 0},
+		{"test_none", fiaNone, 0},
+		{"test_green", fiaGreen, telemetry.FlagGreen},
+		{"test_blue", fiaBlue, telemetry.FlagBlue},
+		{"test_yellow", fiaYellow, telemetry.FlagYellow},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := toFlags(test.flag); got != test.expect {
+				t.Errorf("expected %b, got %b", test.expect, got)
+			}
+		})
+	}
+}
+
+func Test_StreamCapture(t *testing.T) {
+	f := newTestProvider(t)
+	f.ticker = time.NewTicker(time.Millisecond)
+	t.Cleanup(f.ticker.Stop)
+	f.Subscribe(map[int16]telemetry.FieldID{0: telemetry.Speed})
+
+	var captured bytes.Buffer
+	f.Capture(&captured)
+
+	frames, _ := f.Stream()
+	t.Cleanup(f.StopStream)
+
+	// The capture stands in for the game
+	packets := loadCapture(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	sent := make(chan error, 1)
+	go func() {
+		sent <- SendCapture(ctx, f.conn.LocalAddr().String(), packets)
+	}()
+
+	var states []telemetry.SourceState
+	for len(states) < 3 {
+		select {
+		case frame := <-frames:
+			if got := frame.Values[telemetry.Speed].String(); got != "80.0" {
+				t.Fatalf("expected 80.0 m/s, got %s", got)
+			}
+			if len(states) == 2 {
+				states = append(states, telemetry.SourceStreaming)
+			}
+		case ev := <-f.Events():
+			states = append(states, ev.State)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out, got the states %v", states)
+		}
+	}
+
+	expect := []telemetry.SourceState{telemetry.SourceWaiting, telemetry.SourceConnected}
+	for k := range expect {
+		if states[k] != expect[k] {
+			t.Fatalf("expected the states %v, got %v", expect, states)
+		}
+	}
+
+	if err := <-sent; err != nil {
+		t.Fatalf("failed to send the capture: %v", err)
+	}
+
+	// Everything that came in was captured
+	f.Capture(nil)
+	back, err := ReadCapture(&captured)
+	if err != nil {
+		t.Fatalf("failed to read what was captured: %v", err)
+	}
+	if len(back) == 0 || len(back) > len(packets) {
+		t.Errorf("expected up to %d packets captured, got %d", len(packets), len(back))
+	}
+}
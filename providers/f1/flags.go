@@ -0,0 +1,35 @@
+package f1
+
+import (
+	"esdi/telemetry"
+)
+
+// vehicleFiaFlags, the game shows a single flag at a time
+const (
+	fiaInvalid = -1
+	fiaNone    = 0
+	fiaGreen   = 1
+	fiaBlue    = 2
+	fiaYellow  = 3
+)
+
+// fiaFlags maps the vehicleFiaFlags to ours
+var fiaFlags = map[int8]telemetry.Flag{
+	fiaGreen:  telemetry.FlagGreen,
+	fiaBlue:   telemetry.FlagBlue,
+	fiaYellow: telemetry.FlagYellow,
+}
+
+// toFlags translates a vehicleFiaFlags to a telemetry.Flag set, fiaNone and the
+// flags we don't know are no flag
+func toFlags(flag int8) telemetry.Flag {
+	return fiaFlags[flag]
+}
+
+// flags is the binding of telemetry.Flags
+func (f *F1) flags(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarStatus)
+
+	out.Type = telemetry.DataTypeUINT16
+	out.Raw = uint64(toFlags(p.i8(car(idx, statusSize, 0, statusVehicleFiaFlags))))
+}
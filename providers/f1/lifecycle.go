@@ -0,0 +1,83 @@
+package f1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"esdi/telemetry"
+)
+
+const (
+	// tickRate is how often we read a frame from the packets, the game can be
+	// set to send them at up to 120Hz
+	tickRate = 60
+
+	// staleTimeout is how long the game can go without sending a packet
+	// before we take it as gone. It stops sending in the menus
+	staleTimeout = 2 * time.Second
+	// readTimeout is how long a read waits for a packet
+	readTimeout = 250 * time.Millisecond
+)
+
+var errGameStale = fmt.Errorf("no packets for %s", staleTimeout)
+
+// stale reports if the game stopped sending packets
+func (f *F1) stale(now time.Time) bool {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	return now.Sub(f.lastPacket) > staleTimeout
+}
+
+// reset drops the packets of a game that went away
+func (f *F1) reset() {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	for k := range f.packets {
+		f.packets[k] = f.packets[k][:0]
+	}
+	f.lastPacket = time.Time{}
+}
+
+// connect waits for the game to start sending
+func (f *F1) connect() error {
+	if f.stale(time.Now()) {
+		return errGameStale
+	}
+
+	return nil
+}
+
+// checkReady reports if the packets it can't do without came in. The game going quiet
+// sends the source back to waiting
+func (f *F1) checkReady() (bool, error) {
+	if f.stale(time.Now()) {
+		return false, errGameStale
+	}
+
+	return f.ready(), nil
+}
+
+// read reads a frame, failing when the game stopped sending
+func (f *F1) read() (bool, error) {
+	if f.stale(time.Now()) {
+		return false, errGameStale
+	}
+
+	return f.readData(), nil
+}
+
+// stream runs the provider lifecycle, see telemetry.SourceLifecycle.Run. The game
+// sends to us, there's nothing to retry, every state just looks at what came in
+// on each tick
+func (f *F1) stream(ctx context.Context) {
+	f.Run(ctx, telemetry.SourceSteps{
+		Connect: f.connect,
+		Ready:   f.checkReady,
+		Read:    f.read,
+		Reset:   f.reset,
+		Tick:    f.ticker.C,
+	}, f.streamCh)
+}
@@ -0,0 +1,188 @@
+package f1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// packetFormat is the only format we read, the games can be told to send the
+// format of an older year but the layouts change every year
+const packetFormat = 2024
+
+// numCars is how many cars the per car arrays of the packets have
+const numCars = 22
+
+// Where the members are on PacketHeader, it's in front of every packet
+const (
+	headerPacketFormat = 0
+	headerPacketID     = 6
+	headerSessionUID   = 7
+	headerSessionTime  = 15
+	headerPlayerCarIdx = 27
+	headerSize         = 29
+)
+
+// Packet ids, the ones we read
+const (
+	packetMotion       = 0
+	packetSession      = 1
+	packetLapData      = 2
+	packetParticipants = 4
+	packetCarTelemetry = 6
+	packetCarStatus    = 7
+
+	packetCount = 16
+)
+
+// Sizes of the packets we read, shorter packets are dropped
+var packetSizes = map[uint8]int{
+	packetMotion:       1349,
+	packetSession:      753,
+	packetLapData:      1285,
+	packetParticipants: 1350,
+	packetCarTelemetry: 1352,
+	packetCarStatus:    1239,
+}
+
+// Where the members we read are on CarMotionData, a car of PacketMotionData
+const (
+	motionSize       = 60
+	motionGForceLat  = 36
+	motionGForceLong = 40
+	motionGForceVert = 44
+	motionYaw        = 48
+	motionPitch      = 52
+	motionRoll       = 56
+)
+
+// Where the members we read are on PacketSessionData, after the header
+const (
+	sessionTrackTemperature = 1 // int8
+	sessionAirTemperature   = 2 // int8
+	sessionTotalLaps        = 3
+	sessionTrackLength      = 4 // uint16, m
+	sessionType             = 6
+	sessionTrackID          = 7  // int8
+	sessionTimeLeft         = 9  // uint16, s
+	sessionDuration         = 11 // uint16, s
+)
+
+// Where the members we read are on LapData, a car of PacketLapData
+const (
+	lapSize               = 57
+	lapLastLapTimeInMS    = 0
+	lapCurrentLapTimeInMS = 4
+	lapDistance           = 20 // m, negative before the line on the first lap
+	lapCarPosition        = 32
+	lapCurrentLapNum      = 33
+	lapPitStatus          = 34 // 0 none, 1 pitting, 2 in the pit area
+)
+
+// Where the members we read are on PacketParticipantsData, after the header
+const (
+	participantsNumActiveCars = 0
+	participantsCars          = 1 // ParticipantData[22]
+
+	participantSize       = 60
+	participantRaceNumber = 5
+	participantName       = 7 // char[48], UTF-8
+	participantNameLen    = 48
+)
+
+// Where the members we read are on CarTelemetryData, a car of
+// PacketCarTelemetryData. Arrays of wheels are in the order RL, RR, FL, FR
+const (
+	telemetrySize             = 60
+	telemetrySpeed            = 0 // uint16, km/h
+	telemetryThrottle         = 2
+	telemetryBrake            = 10
+	telemetryClutch           = 14 // uint8, 0..100
+	telemetryGear             = 15 // int8, -1 reverse, 0 neutral
+	telemetryEngineRPM        = 16 // uint16
+	telemetryBrakesTemp       = 22 // uint16[4], C
+	telemetryTyresSurfaceTemp = 30 // uint8[4], C
+	telemetryTyresInnerTemp   = 34 // uint8[4], C
+	telemetryEngineTemp       = 38 // uint16, C
+	telemetryTyresPressure    = 40 // float[4], psi
+)
+
+// Where the members we read are on CarStatusData, a car of PacketCarStatusData
+const (
+	statusSize             = 55
+	statusTractionControl  = 0
+	statusAntiLockBrakes   = 1
+	statusFrontBrakeBias   = 3
+	statusPitLimiterStatus = 4
+	statusFuelInTank       = 5
+	statusFuelCapacity     = 9
+	statusVehicleFiaFlags  = 28 // int8
+)
+
+// wheelOrder is where the wheels of our LF, RF, LR, RR order are on the
+// packets' RL, RR, FL, FR order
+var wheelOrder = [4]int{2, 3, 0, 1}
+
+// packet is a packet as it came from the game. Members past its end read as
+// zeros
+type packet []byte
+
+func (p packet) u8(off int) uint8 {
+	if off+1 > len(p) {
+		return 0
+	}
+
+	return p[off]
+}
+
+func (p packet) i8(off int) int8 {
+	return int8(p.u8(off))
+}
+
+func (p packet) u16(off int) uint16 {
+	if off+2 > len(p) {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint16(p[off:])
+}
+
+func (p packet) u32(off int) uint32 {
+	if off+4 > len(p) {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint32(p[off:])
+}
+
+func (p packet) u64(off int) uint64 {
+	if off+8 > len(p) {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint64(p[off:])
+}
+
+func (p packet) f32(off int) float32 {
+	return math.Float32frombits(p.u32(off))
+}
+
+// str reads a NUL terminated string of up to n bytes
+func (p packet) str(off int, n int) string {
+	if off >= len(p) {
+		return ""
+	}
+
+	b := p[off:min(off+n, len(p))]
+	if end := bytes.IndexByte(b, 0); end >= 0 {
+		b = b[:end]
+	}
+
+	return string(b)
+}
+
+// car returns where the member at off of car idx is on a packet of per car
+// structs of size bytes, start is where the array is after the header
+func car(idx int, size int, start int, off int) int {
+	return headerSize + start + idx*size + off
+}
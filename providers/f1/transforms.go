@@ -0,0 +1,385 @@
+package f1
+
+import (
+	"math"
+	"time"
+
+	"esdi/telemetry"
+)
+
+const (
+	LapTimeFormatStr = "04:05.000"
+
+	kmhToMs  = 1 / 3.6
+	gravity  = 9.80665
+	psiToKPa = 6.894757
+)
+
+func (f *F1) unused(out *telemetry.TelemetryField) {
+	out.Unused()
+}
+
+// player returns the last packet of a kind and where the player's car is on
+// it. The index is out of the arrays while spectating, the player's members
+// read as zeros then
+func (f *F1) player(id int) (packet, int) {
+	p := f.packets[id]
+	return p, int(p.u8(headerPlayerCarIdx))
+}
+
+// Header
+
+func (f *F1) sessionTime(out *telemetry.TelemetryField) {
+	out.SetFloat64(float64(f.packets[packetCarTelemetry].f32(headerSessionTime)))
+}
+
+// Motion
+
+// accel reads a g force of the player's car, the game gives them in G
+func (f *F1) accel(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		p, idx := f.player(packetMotion)
+		out.SetFloat32(p.f32(car(idx, motionSize, 0, off)) * gravity)
+	}
+}
+
+func (f *F1) motionFloat(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		p, idx := f.player(packetMotion)
+		out.SetFloat32(p.f32(car(idx, motionSize, 0, off)))
+	}
+}
+
+// Car telemetry
+
+func (f *F1) speed(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarTelemetry)
+	out.SetFloat32(float32(p.u16(car(idx, telemetrySize, 0, telemetrySpeed))) * kmhToMs)
+}
+
+func (f *F1) rpm(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarTelemetry)
+	out.Type = telemetry.DataTypeUINT16
+	out.Raw = uint64(p.u16(car(idx, telemetrySize, 0, telemetryEngineRPM)))
+}
+
+// gear converts the gear of the game (-1 is reverse, 0 neutral, 1 first...) to
+// the character we show on the dash
+func (f *F1) gear(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarTelemetry)
+	out.Type = telemetry.DataTypeCHAR
+
+	switch gear := p.i8(car(idx, telemetrySize, 0, telemetryGear)); {
+	case gear == -1:
+		out.Raw = uint64('R')
+	case gear == 0:
+		out.Raw = uint64('N')
+	case gear > 0 && gear < 10:
+		out.Raw = uint64('0' + gear)
+	default:
+		out.Raw = uint64('?')
+	}
+}
+
+func (f *F1) telemetryFloat(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		p, idx := f.player(packetCarTelemetry)
+		out.SetFloat32(p.f32(car(idx, telemetrySize, 0, off)))
+	}
+}
+
+// clutch is 0..100 on the packet, the other pedals are 0..1
+func (f *F1) clutch(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarTelemetry)
+	out.SetFloat32(float32(p.u8(car(idx, telemetrySize, 0, telemetryClutch))) / 100)
+}
+
+// engineTemp is the only engine temperature the game sends
+func (f *F1) engineTemp(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarTelemetry)
+	out.SetFloat32(float32(p.u16(car(idx, telemetrySize, 0, telemetryEngineTemp))))
+}
+
+func (f *F1) brakeTemps(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarTelemetry)
+
+	var temps [4]float32
+	for k, wheel := range wheelOrder {
+		temps[k] = float32(p.u16(car(idx, telemetrySize, 0, telemetryBrakesTemp+2*wheel)))
+	}
+
+	out.SetArray(telemetry.DataTypeFLOAT32, floatElems(temps[:]...))
+}
+
+// tyreTemps reads a temperature of the tyres. The game has one per tyre, it
+// goes on the left, middle and right of the tyre alike
+func (f *F1) tyreTemps(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		p, idx := f.player(packetCarTelemetry)
+
+		temps := make([]float32, 0, 3*len(wheelOrder))
+		for _, wheel := range wheelOrder {
+			temp := float32(p.u8(car(idx, telemetrySize, 0, off+wheel)))
+			temps = append(temps, temp, temp, temp)
+		}
+
+		out.SetArray(telemetry.DataTypeFLOAT32, floatElems(temps...))
+	}
+}
+
+func (f *F1) tyrePressures(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarTelemetry)
+
+	var pressures [4]float32
+	for k, wheel := range wheelOrder {
+		pressures[k] = p.f32(car(idx, telemetrySize, 0, telemetryTyresPressure+4*wheel)) * psiToKPa
+	}
+
+	out.SetArray(telemetry.DataTypeFLOAT32, floatElems(pressures[:]...))
+}
+
+// Car status
+
+// statusFloat reads a float of the car status.
+// NOTE: the game gives the fuel in kg and not in litres, unit conversions of
+// the fuel fields won't make sense for F1
+func (f *F1) statusFloat(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		p, idx := f.player(packetCarStatus)
+		out.SetFloat32(p.f32(car(idx, statusSize, 0, off)))
+	}
+}
+
+func (f *F1) statusUint8(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		p, idx := f.player(packetCarStatus)
+		out.Type = telemetry.DataTypeUINT8
+		out.Raw = uint64(p.u8(car(idx, statusSize, 0, off)))
+	}
+}
+
+func (f *F1) brakeBias(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarStatus)
+	out.SetFloat32(float32(p.u8(car(idx, statusSize, 0, statusFrontBrakeBias))))
+}
+
+func (f *F1) pitLimiter(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetCarStatus)
+
+	out.Type = telemetry.DataTypeSTRING
+	if p.u8(car(idx, statusSize, 0, statusPitLimiterStatus)) != 0 {
+		out.Str = "PIT"
+	} else {
+		out.Str = "   "
+	}
+}
+
+// Lap data
+
+func (f *F1) lapUint8(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		p, idx := f.player(packetLapData)
+		out.Type = telemetry.DataTypeUINT8
+		out.Raw = uint64(p.u8(car(idx, lapSize, 0, off)))
+	}
+}
+
+func (f *F1) lastLapTime(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetLapData)
+	ms := p.u32(car(idx, lapSize, 0, lapLastLapTimeInMS))
+
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = time.UnixMilli(int64(ms)).UTC().Format(LapTimeFormatStr)
+}
+
+func (f *F1) currentLapTime(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetLapData)
+	out.SetFloat32(float32(p.u32(car(idx, lapSize, 0, lapCurrentLapTimeInMS))) / 1000)
+}
+
+func (f *F1) lapDistPct(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetLapData)
+	out.SetFloat32(float32(f.distPct(p.f32(car(idx, lapSize, 0, lapDistance)))))
+}
+
+func (f *F1) onPitRoad(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetLapData)
+	setFlag(out, p.u8(car(idx, lapSize, 0, lapPitStatus)) != 0)
+}
+
+// Lap data of every car, the arrays are as long as the field and the slots the
+// game doesn't have are left as cars that aren't in the world
+
+func (f *F1) carsLapDistPct(out *telemetry.TelemetryField) {
+	p := f.packets[packetLapData]
+	dists := make([]float32, telemetry.MaxArrayLen)
+	for k := range dists {
+		dists[k] = -1
+		if k < f.activeCars() {
+			dists[k] = float32(f.distPct(p.f32(car(k, lapSize, 0, lapDistance))))
+		}
+	}
+
+	out.SetArray(telemetry.DataTypeFLOAT32, floatElems(dists...))
+}
+
+func (f *F1) carsLap(out *telemetry.TelemetryField) {
+	p := f.packets[packetLapData]
+	laps := make([]uint64, telemetry.MaxArrayLen)
+	for k := range laps {
+		lap := int32(-1)
+		if k < f.activeCars() {
+			lap = int32(p.u8(car(k, lapSize, 0, lapCurrentLapNum)))
+		}
+		laps[k] = uint64(uint32(lap))
+	}
+
+	out.SetArray(telemetry.DataTypeINT32, laps)
+}
+
+func (f *F1) carsUint8(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		p := f.packets[packetLapData]
+		values := make([]uint64, telemetry.MaxArrayLen)
+		for k := range min(f.activeCars(), len(values)) {
+			values[k] = uint64(p.u8(car(k, lapSize, 0, off)))
+		}
+
+		out.SetArray(telemetry.DataTypeUINT8, values)
+	}
+}
+
+func (f *F1) carsOnPitRoad(out *telemetry.TelemetryField) {
+	p := f.packets[packetLapData]
+	values := make([]uint64, telemetry.MaxArrayLen)
+	for k := range min(f.activeCars(), len(values)) {
+		if p.u8(car(k, lapSize, 0, lapPitStatus)) != 0 {
+			values[k] = 1
+		}
+	}
+
+	out.SetArray(telemetry.DataTypeUINT8, values)
+}
+
+func (f *F1) carsLastLapTime(out *telemetry.TelemetryField) {
+	p := f.packets[packetLapData]
+	times := make([]float32, telemetry.MaxArrayLen)
+	for k := range min(f.activeCars(), len(times)) {
+		times[k] = float32(p.u32(car(k, lapSize, 0, lapLastLapTimeInMS))) / 1000
+	}
+
+	out.SetArray(telemetry.DataTypeFLOAT32, floatElems(times...))
+}
+
+// Session
+
+func (f *F1) sessionSeconds(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.SetFloat64(float64(f.packets[packetSession].u16(headerSize + off)))
+	}
+}
+
+func (f *F1) totalLaps(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeINT32
+	out.Raw = uint64(f.packets[packetSession].u8(headerSize + sessionTotalLaps))
+}
+
+func (f *F1) sessionTemp(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.SetFloat32(float32(f.packets[packetSession].i8(headerSize + off)))
+	}
+}
+
+func (f *F1) trackLength(out *telemetry.TelemetryField) {
+	out.SetFloat32(float32(f.packets[packetSession].u16(headerSize + sessionTrackLength)))
+}
+
+// trackNames are the names of the track ids
+var trackNames = []string{
+	"Melbourne", "Paul Ricard", "Shanghai", "Sakhir", "Catalunya", "Monaco", "Montreal",
+	"Silverstone", "Hockenheim", "Hungaroring", "Spa", "Monza", "Singapore", "Suzuka",
+	"Abu Dhabi", "Texas", "Brazil", "Austria", "Sochi", "Mexico", "Baku", "Sakhir Short",
+	"Silverstone Short", "Texas Short", "Suzuka Short", "Hanoi", "Zandvoort", "Imola",
+	"Portimao", "Jeddah", "Miami", "Las Vegas", "Losail",
+}
+
+func (f *F1) trackName(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = nameOf(trackNames, int(f.packets[packetSession].i8(headerSize+sessionTrackID)))
+}
+
+// sessionTypeNames are the names of the session types, 0 is unknown
+var sessionTypeNames = []string{
+	"Unknown", "Practice 1", "Practice 2", "Practice 3", "Short Practice",
+	"Qualifying 1", "Qualifying 2", "Qualifying 3", "Short Qualifying", "One-Shot Qualifying",
+	"Sprint Shootout 1", "Sprint Shootout 2", "Sprint Shootout 3", "Short Sprint Shootout",
+	"One-Shot Sprint Shootout", "Race", "Race 2", "Race 3", "Time Trial",
+}
+
+func (f *F1) sessionType(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = nameOf(sessionTypeNames, int(f.packets[packetSession].u8(headerSize+sessionType)))
+}
+
+// Participants
+
+func (f *F1) driverName(out *telemetry.TelemetryField) {
+	p, idx := f.player(packetParticipants)
+
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = p.str(car(idx, participantSize, participantsCars, participantName), participantNameLen)
+}
+
+// Helpers
+
+// activeCars is how many cars of the per car arrays are in the session, all of
+// them until the participants come in
+func (f *F1) activeCars() int {
+	p := f.packets[packetParticipants]
+	if len(p) == 0 {
+		return numCars
+	}
+
+	return min(int(p.u8(headerSize+participantsNumActiveCars)), numCars)
+}
+
+// distPct turns a distance on the lap into a fraction of the lap. The distance
+// is negative before the line on the first lap, that's the end of the lap
+func (f *F1) distPct(dist float32) float64 {
+	length := float64(f.packets[packetSession].u16(headerSize + sessionTrackLength))
+	if length <= 0 {
+		return 0
+	}
+
+	pct := float64(dist) / length
+	if pct < 0 {
+		pct += 1
+	}
+
+	return math.Max(0, math.Min(pct, 1))
+}
+
+func nameOf(names []string, k int) string {
+	if k < 0 || k >= len(names) {
+		return "Unknown"
+	}
+
+	return names[k]
+}
+
+func setFlag(out *telemetry.TelemetryField, on bool) {
+	out.Type = telemetry.DataTypeUINT8
+	out.Raw = 0
+	if on {
+		out.Raw = 1
+	}
+}
+
+func floatElems(values ...float32) []uint64 {
+	elems := make([]uint64, len(values))
+	for k, v := range values {
+		elems[k] = uint64(math.Float32bits(v))
+	}
+
+	return elems
+}
@@ -0,0 +1,54 @@
+package iracing
+
+import (
+	"esdi/telemetry"
+)
+
+// readCars takes a snapshot of every car in the session from the CarIdx arrays
+// and the drivers in the session info. Recorded telemetry only has the player's
+// car, the arrays aren't there and we get no cars
+func (i *IRacing) readCars() []telemetry.CarState {
+	si := i.SDK.SessionInfo
+	if si == nil {
+		return nil
+	}
+
+	lapDist, ok1 := i.SDK.Vars.Vars["CarIdxLapDistPct"].Value.([]float32)
+	estTime, ok2 := i.SDK.Vars.Vars["CarIdxEstTime"].Value.([]float32)
+	laps, ok3 := i.SDK.Vars.Vars["CarIdxLap"].Value.([]int32)
+	if !ok1 || !ok2 || !ok3 {
+		return nil
+	}
+
+	cars := make([]telemetry.CarState, 0, len(si.DriverInfo.Drivers))
+	for _, d := range si.DriverInfo.Drivers {
+		idx := d.CarIdx
+		if idx < 0 || idx >= len(lapDist) || idx >= len(estTime) || idx >= len(laps) {
+			continue
+		}
+
+		cars = append(cars, telemetry.CarState{
+			Idx:         idx,
+			Number:      d.CarNumber,
+			Name:        d.UserName,
+			Lap:         int(laps[idx]),
+			LapDistPct:  float64(lapDist[idx]),
+			EstTime:     float64(estTime[idx]),
+			EstLapTime:  d.CarClassEstLapTime,
+			IsPlayer:    idx == si.DriverInfo.DriverCarIdx,
+			IsPaceCar:   d.CarIsPaceCar == 1,
+			IsSpectator: d.IsSpectator == 1 || d.UserName == "",
+		})
+	}
+
+	return cars
+}
+
+// updateCars is the binding of telemetry.Cars, the cars go on the data and the
+// field holds how many there are
+func (i *IRacing) updateCars(out *telemetry.TelemetryField) {
+	i.data.Cars = i.readCars()
+
+	out.Type = telemetry.DataTypeUINT8
+	out.Raw = uint64(len(i.data.Cars))
+}
@@ -1,45 +1,116 @@
 package iracing
 
-// This file maps the data from the desktop provider data structure to iRacing
+// This file maps the data from the desktop provider data structure to iRacing.
+// The SDK variable each field reads from lives in the telemetry registry, here we
+// only keep the fields that need more than a plain type conversion
 
 import (
+	"fmt"
+
 	"esdi/telemetry"
 )
 
-var internalToSDKFieldNames = map[telemetry.FieldID]string{
-	telemetry.Speed:     "Speed",
-	telemetry.Gear:      "Gear",
-	telemetry.RPM:       "RPM",
-	telemetry.FuelLevel: "FuelLevel",
-	// Engine Data
-	telemetry.OilPress:  "OilPress",
-	telemetry.OilTemp:   "OilTemp",
-	telemetry.WaterTemp: "WaterTemp",
+var fieldTransforms = map[telemetry.FieldID]func(any, *telemetry.TelemetryField){
+	telemetry.Gear: GearTransform,
 	// Engine Warnings
-	telemetry.PitSpeedLimiter: "irsdk_pitSpeedLimiter",
-	// Ajudstements
-	telemetry.BrakeBias:       "dcBrakeBias",
-	telemetry.ABSSetting:      "dcABS",
-	telemetry.TCSetting:       "dcTractionControl",
-	telemetry.ThrottleSetting: "dcThrottleShape",
+	telemetry.PitSpeedLimiter: PitSpeedLimiterTransform,
+	telemetry.EngineWarnings:  BitfieldTransform,
 	// Lap Data
-	telemetry.LapLastLapTime: "LapLastLapTime",
-	telemetry.LapNumber:      "Lap",
-	// Tire data
-	telemetry.LFtempL: "LFtempCL",
-	telemetry.LFtempM: "LFtempCM",
-	telemetry.LFtempR: "LFtempCR",
-	telemetry.RFtempL: "RFtempCL",
-	telemetry.RFtempM: "RFtempCM",
-	telemetry.RFtempR: "RFtempCR",
-	telemetry.LRtempL: "LRtempCL",
-	telemetry.LRtempM: "LRtempCM",
-	telemetry.LRtempR: "LRtempCR",
-	telemetry.RRtempL: "RRtempCL",
-	telemetry.RRtempM: "RRtempCM",
-	telemetry.RRtempR: "RRtempCR",
+	telemetry.LapLastLapTime: LapTimeTransform,
 	// Session Data
-	telemetry.SessionTime:       "SessionTime",
-	telemetry.ReplaySessionTime: "ReplaySessionTime",
-	telemetry.Empty:             "empty",
+	telemetry.SessionFlags: BitfieldTransform,
+	telemetry.Empty:        telemetry.EmptyTransform,
+}
+
+// sessionInfoFields are the sources we read from the session info instead of the
+// telemetry variables. They are only read again when the session info or the
+// session change
+var sessionInfoFields = map[string]func(*sessionInfo) any{
+	"CarScreenName": func(si *sessionInfo) any {
+		if d, ok := si.player(); ok {
+			return d.CarScreenName
+		}
+		return nil
+	},
+	"DriverCarFuelMaxLtr": func(si *sessionInfo) any {
+		return si.DriverInfo.DriverCarFuelMaxLtr * si.DriverInfo.DriverCarMaxFuelPct
+	},
+	"DriverCarSLFirstRPM": func(si *sessionInfo) any {
+		return si.DriverInfo.DriverCarSLFirstRPM
+	},
+	"DriverCarSLShiftRPM": func(si *sessionInfo) any {
+		return si.DriverInfo.DriverCarSLShiftRPM
+	},
+	"DriverCarSLBlinkRPM": func(si *sessionInfo) any {
+		return si.DriverInfo.DriverCarSLBlinkRPM
+	},
+	"TrackDisplayName": func(si *sessionInfo) any {
+		return si.WeekendInfo.TrackDisplayName
+	},
+	"TrackConfigName": func(si *sessionInfo) any {
+		return si.WeekendInfo.TrackConfigName
+	},
+	// ex: "3.70 km"
+	"TrackLength": func(si *sessionInfo) any {
+		var km float64
+		if _, err := fmt.Sscanf(si.WeekendInfo.TrackLength, "%f km", &km); err != nil {
+			return nil
+		}
+		return km * 1000
+	},
+	"TrackSkies": func(si *sessionInfo) any {
+		return si.WeekendInfo.TrackSkies
+	},
+	"TrackWeatherType": func(si *sessionInfo) any {
+		return si.WeekendInfo.TrackWeatherType
+	},
+	"SplitTimeInfo": func(si *sessionInfo) any {
+		splits := make([]float64, len(si.SplitTimeInfo.Sectors))
+		for k, sector := range si.SplitTimeInfo.Sectors {
+			splits[k] = sector.SectorStartPct
+		}
+		return telemetry.FormatSplits(splits)
+	},
+	"SessionType": func(si *sessionInfo) any {
+		if k := si.session(); k >= 0 {
+			return si.SessionInfo.Sessions[k].SessionType
+		}
+		return nil
+	},
+	"SessionName": func(si *sessionInfo) any {
+		if k := si.session(); k >= 0 {
+			return si.SessionInfo.Sessions[k].SessionName
+		}
+		return nil
+	},
+	"UserName": func(si *sessionInfo) any {
+		if d, ok := si.player(); ok {
+			return d.UserName
+		}
+		return nil
+	},
+	"CarNumber": func(si *sessionInfo) any {
+		if d, ok := si.player(); ok {
+			return d.CarNumber
+		}
+		return nil
+	},
+	"CarClassShortName": func(si *sessionInfo) any {
+		if d, ok := si.player(); ok {
+			return d.CarClassShortName
+		}
+		return nil
+	},
+	"IRating": func(si *sessionInfo) any {
+		if d, ok := si.player(); ok {
+			return d.IRating
+		}
+		return nil
+	},
+	"LicString": func(si *sessionInfo) any {
+		if d, ok := si.player(); ok {
+			return d.LicString
+		}
+		return nil
+	},
 }
@@ -0,0 +1,70 @@
+package iracing
+
+import (
+	"esdi/telemetry"
+)
+
+// irsdk_Flags, the ones we show on the dash
+const (
+	irsdkCheckered     = 0x00000001
+	irsdkWhite         = 0x00000002
+	irsdkGreen         = 0x00000004
+	irsdkYellow        = 0x00000008
+	irsdkRed           = 0x00000010
+	irsdkBlue          = 0x00000020
+	irsdkDebris        = 0x00000040
+	irsdkYellowWaving  = 0x00000100
+	irsdkGreenHeld     = 0x00000400
+	irsdkCaution       = 0x00004000
+	irsdkCautionWaving = 0x00008000
+	irsdkBlack         = 0x00010000
+	irsdkDisqualify    = 0x00020000
+	irsdkRepair        = 0x00100000
+	irsdkStartGo       = 0x80000000
+)
+
+// irsdkFlags maps the irsdk_Flags to ours, more than one can map to the same
+// flag (ex: caution and debris are shown as yellow)
+var irsdkFlags = []struct {
+	bits uint32
+	flag telemetry.Flag
+}{
+	{irsdkCheckered, telemetry.FlagChequered},
+	{irsdkWhite, telemetry.FlagWhite},
+	{irsdkGreen | irsdkGreenHeld | irsdkStartGo, telemetry.FlagGreen},
+	{irsdkYellow | irsdkYellowWaving | irsdkCaution | irsdkCautionWaving | irsdkDebris, telemetry.FlagYellow},
+	{irsdkRed, telemetry.FlagRed},
+	{irsdkBlue, telemetry.FlagBlue},
+	{irsdkBlack | irsdkDisqualify, telemetry.FlagBlack},
+	{irsdkRepair, telemetry.FlagMeatball},
+}
+
+// toFlags translates irsdk_Flags to a telemetry.Flag set
+func toFlags(bits uint32) telemetry.Flag {
+	var flags telemetry.Flag
+	for _, f := range irsdkFlags {
+		if bits&f.bits != 0 {
+			flags |= f.flag
+		}
+	}
+
+	return flags
+}
+
+// updateFlags is the binding of telemetry.Flags. SessionFlags has the flags
+// of the whole session and the player's own flags (blue, black, meatball) when
+// we are driving, the player's entry of CarIdxSessionFlags has them while
+// spectating or in the replays too
+func (i *IRacing) updateFlags(out *telemetry.TelemetryField) {
+	bits := bitfield(i.SDK.Vars.Vars["SessionFlags"].Value)
+
+	perCar, ok := i.SDK.Vars.Vars["CarIdxSessionFlags"].Value.([]string)
+	if si := i.SDK.SessionInfo; ok && si != nil {
+		if idx := si.DriverInfo.DriverCarIdx; idx >= 0 && idx < len(perCar) {
+			bits |= bitfield(perCar[idx])
+		}
+	}
+
+	out.Type = telemetry.DataTypeUINT16
+	out.Raw = uint64(toFlags(bits))
+}
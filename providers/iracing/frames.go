@@ -0,0 +1,42 @@
+package iracing
+
+import (
+	"errors"
+	"time"
+
+	"esdi/telemetry"
+)
+
+// ReadFrame reads the next frame of an .ibt without waiting for its time, see
+// telemetry.FrameSource. The frames are timed by their SessionTime
+func (i *IRacing) ReadFrame() (*telemetry.TelemetryData, time.Duration, error) {
+	if i.live {
+		return nil, 0, errors.New("only recordings can be read frame by frame")
+	}
+
+	var t time.Duration
+	_, err := i.readFrame(func() time.Time {
+		st, _ := i.SDK.Vars.Vars["SessionTime"].Value.(float64)
+		t = seconds(st)
+		return i.data.InitialTime.Add(t)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return i.data, t, nil
+}
+
+// Close closes the SDK and the .ibt, the provider can't be used anymore
+func (i *IRacing) Close() error {
+	i.ticker.Stop()
+	if i.SDK != nil {
+		i.SDK.Close()
+	}
+
+	if i.file == nil {
+		return nil
+	}
+
+	return i.file.Close()
+}
@@ -6,26 +6,26 @@ package iracing
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
-	"strconv"
 	"sync"
 	"time"
 
-	conv "esdi/conversions"
 	"esdi/telemetry"
 
 	"github.com/ESilva15/goirsdk"
 )
 
 const (
-	NAME = "iRacing"
+	NAME = telemetry.SourceIRacing
 )
 
 // IRacing is our iRacing telemetry data provider - its a TelemetryProvider interface
 type IRacing struct {
 	logger *slog.Logger
 	SDK    *goirsdk.IBT
+	file   *os.File // The .ibt we read from, the SDK leaves closing it to us
 
 	// Data Handling
 	mut  sync.Mutex
@@ -34,6 +34,18 @@ type IRacing struct {
 	// Timing information
 	ticker *time.Ticker // ticker will keep polling intervals constant
 
+	// Session tracking
+	sessionInfoUpdate int32 // Session info version we have, from the header
+	session           sessionKey
+	sessionKnown      bool
+	sessionGen        int // Bumped when the session info fields need a refresh
+
+	// Lifecycle
+	telemetry.SourceLifecycle
+	live      bool      // Reading from the sim instead of a file
+	lastTick  int32     // Last telemetry tick we read
+	lastFrame time.Time // When the tick last moved
+
 	// Stream
 	streamCh     chan telemetry.TelemetryData
 	streamCancel context.CancelFunc
@@ -51,96 +63,88 @@ func NewIRacingProvider(
 	// Maybe this can be changed so we don't have to run it with these ifs but by configuring our
 	// provider
 	var file goirsdk.Reader = nil
+	var ibt *os.File
 	if source != "" {
-		file, err = os.Open(source)
+		ibt, err = os.Open(source)
 		if err != nil {
 			return &IRacing{}, err
 			// log.Fatalf("Failed to open IBT file: %v", err)
 		}
+		file = ibt
 	}
 
-	sdk, err := goirsdk.Init(file, telemOut, yamlOut)
-	if err != nil {
-		logger.Error("failed to open the IRSDK instance")
-		return &IRacing{}, err
-	}
-
-	return &IRacing{
+	i := &IRacing{
 		logger:   logger,
-		SDK:      sdk,
+		file:     ibt,
 		data:     telemetry.NewTelemetryData(),
 		streamCh: make(chan telemetry.TelemetryData, 1),
-		// NOTE: This is because I stupidly recorded a test IBT file in 240
-		ticker: time.NewTicker(time.Second / 240),
-	}, nil
-}
-
-func (i *IRacing) isDataAvailable() bool {
-	// Its offline telemetry, data must be available
-	if i.SDK.File != nil {
-		return true
+		ticker:   time.NewTicker(time.Second / defaultTickRate),
+		live:     file == nil,
 	}
 
-	// Check if live telemetry is on
-	if i.SDK.IsConnected() {
-		return true
+	state := telemetry.SourceWaiting
+	sdk, err := goirsdk.Init(file, telemOut, yamlOut)
+	switch {
+	case err != nil && i.live:
+		// The sim isn't running yet, the stream keeps looking for it
+		logger.Info(fmt.Sprintf("iRacing is not running: %v", err))
+	case err != nil:
+		logger.Error("failed to open the IRSDK instance")
+		ibt.Close()
+		return &IRacing{}, err
+	default:
+		i.attach(sdk)
+		state = telemetry.SourceConnected
 	}
 
-	return false
-}
-
-func (i *IRacing) stream(ctx context.Context) {
-	i.data.InitialTime = time.Now()
+	i.SourceLifecycle = telemetry.NewSourceLifecycle(logger, NAME, state, &i.mut, i.data)
 
-	go func() {
-		for {
-			// Explicitly intercpt cancellation
-			select {
-			case <-ctx.Done():
-				return
-			default:
-			}
-
-			// We start by checking if we do or do not have data available
-			if !i.isDataAvailable() {
-				continue
-			}
+	return i, nil
+}
 
-			select {
-			case <-ctx.Done():
-				return
-			case <-i.ticker.C:
-				i.readData()
-
-				// Publish data
-				select {
-				case i.streamCh <- *i.data:
-				default:
-					// skip this data, don't allow publishers to lag behind
-				}
-			}
-		}
-	}()
+// readData reads the next frame. It fails when the frame can't be read, with
+// io.EOF when a recording has no more frames. Reports if the session changed
+func (i *IRacing) readData() (bool, error) {
+	return i.readFrame(time.Now)
 }
 
-func (i *IRacing) readData() {
+// readFrame is readData with the clock of the frames, now is called once the
+// frame is read
+func (i *IRacing) readFrame(now func() time.Time) (bool, error) {
 	i.mut.Lock()
 	defer i.mut.Unlock()
 
-	var err error
-
-	_, err = i.SDK.Update(time.Millisecond * 16)
+	state, err := i.SDK.Update(time.Millisecond * 16)
 	if err != nil {
-		return
+		return false, err
+	}
+	if state == goirsdk.Ended {
+		return false, io.EOF
 	}
 
+	sessionChanged := i.updateSession()
+
 	// Read 1 to 1 data
 	for _, b := range i.data.ActiveBinds {
-		v := i.SDK.Vars.Vars[b.Key].Value
+		if b.Update != nil {
+			b.Update(&i.data.Values[b.ID])
+			continue
+		}
+
+		var v any
+		if b.Fetch != nil {
+			v = b.Fetch()
+		} else {
+			v = i.SDK.Vars.Vars[b.Key].Value
+		}
 
 		b.Transform(v, &i.data.Values[b.ID])
 	}
 
+	if sessionChanged {
+		i.data.SessionChanged()
+	}
+
 	// Set up virtual binds
 	i.logger.Debug("Entering virtual binds loop")
 	for _, vBind := range i.data.VirtualBinds {
@@ -150,7 +154,9 @@ func (i *IRacing) readData() {
 	}
 
 	i.data.PenultimateDataPoll = i.data.LastDataPoll
-	i.data.LastDataPoll = time.Now()
+	i.data.LastDataPoll = now()
+
+	return sessionChanged, nil
 }
 
 // Telemetry Provider Interface
@@ -167,6 +173,10 @@ func (i *IRacing) Stream() (<-chan telemetry.TelemetryData, error) {
 	return i.streamCh, nil
 }
 
+func (i *IRacing) Name() string {
+	return NAME
+}
+
 func (i *IRacing) StopStream() {
 	if i.streamCancel == nil {
 		return
@@ -179,131 +189,61 @@ func (i *IRacing) StopStream() {
 func (i *IRacing) Subscribe(requestFields map[int16]telemetry.FieldID) {
 	i.logger.Debug(fmt.Sprintf("Len Req: %d\n", len(requestFields)))
 
-	i.data.ActiveBinds = make([]telemetry.BoundField, 0, len(requestFields))
-
-	// First we must add the virtual fields
-	// we will add their dependencies and the primitives to a slice
-	pendingBinds := make([]telemetry.FieldID, telemetry.MaxFields)
-
-	for winID, id := range requestFields {
-		i.data.Values[id].IDs = append(i.data.Values[id].IDs, winID)
-
-		switch id {
-		case telemetry.RPMStateColour:
-			i.data.VirtualBinds = append(i.data.VirtualBinds, telemetry.NewRPMLights())
-		case telemetry.FCCurrentLap:
-			i.data.VirtualBinds = append(i.data.VirtualBinds,
-				telemetry.NewFuelCalculator(i.logger.WithGroup("FUEL CALC")))
-		default:
-			// primitive telemetry field
-			pendingBinds = append(pendingBinds, id)
-		}
+	plan, err := telemetry.PlanSubscription(i.logger, requestFields)
+	if err != nil {
+		i.logger.Error(fmt.Sprintf("failed to plan some fields: %v", err))
 	}
 
-	boundCheck := make(map[telemetry.FieldID]bool)
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	plan.Apply(i.data)
 
 	// Now that we know all the fields we need to bind we follow the binding procedure
-	for _, id := range pendingBinds {
-		// Check if we already bound this FieldID
-		if boundCheck[id] {
-			continue
-		}
-
+	for _, id := range plan.Primitives {
 		// Translate the UI FieldIDs to this provider's field names
-		sdkKey, ok := internalToSDKFieldNames[id]
+		def, _ := telemetry.GetField(id)
+		sdkKey, ok := def.SourceKey(NAME)
 		if !ok {
-			i.logger.Debug("failed to get internal id")
+			i.logger.Debug(fmt.Sprintf("field %q is not provided by %s", def.Name, NAME))
 			// Need to find a way to pass a message saying something wasn't right
 			continue
 		}
 
 		binding := telemetry.BoundField{
-			Key: sdkKey,
-			ID:  id,
+			Key:       sdkKey,
+			ID:        id,
+			Transform: telemetry.DefaultTransform(def),
 		}
 
-		switch id {
-		case telemetry.Speed:
-			binding.Transform = func(v any, out *telemetry.TelemetryField) {
-				out.Type = telemetry.DataTypeUINT16
-				out.Raw = uint64(conv.MsToKph(v.(float32)))
-			}
-		case telemetry.Gear:
-			binding.Transform = func(v any, out *telemetry.TelemetryField) {
-				out.Type = telemetry.DataTypeCHAR
-				// out.Raw = uint64(v.(int))
-
-				gear := 0
-				if val, ok := v.(int32); ok {
-					gear = int(val)
-				} else if val, ok := v.(int); ok {
-					gear = val
-				}
-
-				switch {
-				case gear == 0:
-					out.Raw = uint64('N') // ASCII 78
-				case gear < 0:
-					out.Raw = uint64('R') // ASCII 82
-				case gear > 0 && gear < 10:
-					// Quickest way to turn 1 into '1', 2 into '2', etc.
-					// ASCII '0' is 48, so 48 + 1 = 49 ('1')
-					out.Raw = uint64('0' + gear)
-				default:
-					out.Raw = uint64('?') // Fallback
+		// Arrays made of a variable per element are gathered in a slice
+		if keys := def.SourceKeys(NAME); len(keys) > 1 {
+			binding.Fetch = func() any {
+				values := make([]any, len(keys))
+				for k, key := range keys {
+					values[k] = i.SDK.Vars.Vars[key].Value
 				}
+				return values
 			}
-		case telemetry.RPM:
-			binding.Transform = func(v any, out *telemetry.TelemetryField) {
-				out.Type = telemetry.DataTypeUINT16
-				out.Raw = uint64(uint16(v.(float32)))
-			}
-		case telemetry.FuelLevel:
-			binding.Transform = telemetry.FloatToStringTransformDEPRECATE
-		// Engine Data
-		case telemetry.OilPress:
-			binding.Transform = telemetry.FloatToStringTransformDEPRECATE
-		case telemetry.OilTemp:
-			binding.Transform = telemetry.FloatToStringTransformDEPRECATE
-		case telemetry.WaterTemp:
-			binding.Transform = telemetry.FloatToStringTransformDEPRECATE
-		// Something else
-		case telemetry.PitSpeedLimiter:
-			binding.Transform = PitSpeedLimiterTransform
-		// Adjustements
-		case telemetry.BrakeBias:
-			binding.Transform = telemetry.FloatToStringTransformDEPRECATE
-		case telemetry.ABSSetting:
-			binding.Transform = telemetry.FloatToUInt8TransformDEPRECATE
-		case telemetry.TCSetting:
-			binding.Transform = telemetry.FloatToUInt8TransformDEPRECATE
-		case telemetry.ThrottleSetting:
-			binding.Transform = telemetry.FloatToUInt8TransformDEPRECATE
-		case telemetry.LFtempM:
-			binding.Transform = func(v any, out *telemetry.TelemetryField) {
-				out.Type = telemetry.DataTypeSTRING
-				out.Str = strconv.FormatFloat(float64(v.(float32)), 'f', 1, 32)
-			}
-		case telemetry.SessionTime:
-			binding.Transform = func(v any, out *telemetry.TelemetryField) {
-				out.Type = telemetry.DataTypeSTRING
-				out.Str = strconv.FormatFloat(v.(float64), 'f', 1, 32)
-			}
-		case telemetry.ReplaySessionTime:
-			binding.Transform = func(v any, out *telemetry.TelemetryField) {
-				out.Type = telemetry.DataTypeSTRING
-				out.Str = strconv.FormatFloat(v.(float64), 'f', 1, 32)
-			}
-		case telemetry.Empty:
-			binding.Transform = telemetry.EmptyTransform
-		case telemetry.LapLastLapTime:
-			binding.Transform = LapTimeTransform
-		case telemetry.LapNumber:
-			binding.Transform = telemetry.UInt8Transform
+		}
+
+		if transform, ok := fieldTransforms[id]; ok {
+			binding.Transform = transform
+		}
+
+		if fetch, ok := sessionInfoFields[sdkKey]; ok {
+			binding.Update = i.sessionInfoUpdater(fetch, binding.Transform)
+		}
+
+		if id == telemetry.Cars {
+			binding.Update = i.updateCars
+		}
+
+		if id == telemetry.Flags {
+			binding.Update = i.updateFlags
 		}
 
 		i.data.ActiveBinds = append(i.data.ActiveBinds, binding)
-		boundCheck[id] = true
 	}
 
 	i.logger.Debug(fmt.Sprintf("Subscribed: %+v\n", i.data.ActiveBinds))
@@ -0,0 +1,295 @@
+package iracing
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"esdi/providers/providertest"
+	"esdi/telemetry"
+)
+
+// notRecorded are the channels test_telem.ibt doesn't have. Recordings only
+// carry the player's car and this car has no driver controls
+var notRecorded = map[string]bool{
+	"CarIdx":            true, // The car snapshot, see readCars
+	"CarIdxLapDistPct":  true,
+	"CarIdxLap":         true,
+	"CarIdxPosition":    true,
+	"CarIdxOnPitRoad":   true,
+	"CarIdxEstTime":     true,
+	"CarIdxLastLapTime": true,
+	"dcBrakeBias":       true,
+	"dcABS":             true,
+	"dcTractionControl": true,
+	"dcThrottleShape":   true,
+	"ReplaySessionTime": true, // Only live, while watching a replay
+	"empty":             true, // Placeholder for the Empty field
+}
+
+func Test_IBTFieldTypes(t *testing.T) {
+	i, err := NewIRacingProvider(slog.New(slog.DiscardHandler), "../../test_telem.ibt", "", "")
+	if err != nil {
+		t.Fatalf("failed to open the ibt: %v", err)
+	}
+	t.Cleanup(i.ticker.Stop)
+
+	fields := providertest.SubscribeAll(i.Subscribe, NAME)
+	for range 10 {
+		i.readData()
+	}
+
+	for _, def := range fields {
+		recorded := true
+		for _, key := range def.SourceKeys(NAME) {
+			_, isVar := i.SDK.Vars.Vars[key]
+			_, isSessionInfo := sessionInfoFields[key]
+			switch {
+			case notRecorded[key]:
+				recorded = false
+			case !isVar && !isSessionInfo:
+				t.Errorf("%s: iRacing has no channel %q", def.Key, key)
+				recorded = false
+			}
+		}
+
+		if !recorded {
+			continue
+		}
+
+		providertest.CheckType(t, def, i.data.Values[def.ID])
+	}
+}
+
+func Test_CloseFile(t *testing.T) {
+	i, err := NewIRacingProvider(slog.New(slog.DiscardHandler), "../../test_telem.ibt", "", "")
+	if err != nil {
+		t.Fatalf("failed to open the ibt: %v", err)
+	}
+
+	if err := i.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := i.file.Stat(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected the ibt to be closed, got %v", err)
+	}
+}
+
+func Test_SessionInfo(t *testing.T) {
+	i, err := NewIRacingProvider(slog.New(slog.DiscardHandler), "../../test_telem.ibt", "", "")
+	if err != nil {
+		t.Fatalf("failed to open the ibt: %v", err)
+	}
+	t.Cleanup(i.ticker.Stop)
+
+	if i.refreshSessionInfo() {
+		t.Errorf("expected the session info to be the one the SDK read")
+	}
+
+	// Pretend the sim wrote a new version of it
+	i.sessionInfoUpdate = -1
+	i.SDK.SessionInfo = nil
+	if !i.refreshSessionInfo() || i.SDK.SessionInfo == nil {
+		t.Fatalf("expected the session info to be read again")
+	}
+
+	i.Subscribe(map[int16]telemetry.FieldID{
+		0: telemetry.TrackName, 1: telemetry.TrackLength, 2: telemetry.SessionType,
+		3: telemetry.DriverName, 4: telemetry.CarNumber,
+	})
+	i.readData()
+
+	expect := map[telemetry.FieldID]string{
+		telemetry.TrackName:   "Okayama International Circuit",
+		telemetry.TrackLength: "3650.0",
+		telemetry.SessionType: "Offline Testing",
+		telemetry.DriverName:  "Eduardo Silva20",
+		telemetry.CarNumber:   "64",
+	}
+	providertest.CheckValues(t, i.data, expect)
+
+	// The first frame finds the session, it isn't a change
+	if i.data.Session != 0 {
+		t.Errorf("expected no session change, got %d", i.data.Session)
+	}
+}
+
+func Test_Flags(t *testing.T) {
+	tests := []struct {
+		name   string
+		bits   uint32
+		expect telemetry.Flag
+	}{
+		{"test_none", 0, 0},
+		{"test_start", irsdkStartGo, telemetry.FlagGreen},
+		{"test_caution", irsdkCautionWaving | irsdkYellow, telemetry.FlagYellow},
+		{"test_lapped", irsdkGreen | irsdkBlue, telemetry.FlagGreen | telemetry.FlagBlue},
+		{"test_meatball", irsdkRepair, telemetry.FlagMeatball},
+		{"test_disqualified", irsdkDisqualify, telemetry.FlagBlack},
+		{"test_last_lap", irsdkWhite | irsdkCheckered, telemetry.FlagWhite | telemetry.FlagChequered},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := toFlags(test.bits); got != test.expect {
+				t.Errorf("expected %b, got %b", test.expect, got)
+			}
+		})
+	}
+}
+
+func Test_StreamLifecycle(t *testing.T) {
+	i, err := NewIRacingProvider(slog.New(slog.DiscardHandler), "../../test_telem.ibt", "", "")
+	if err != nil {
+		t.Fatalf("failed to open the ibt: %v", err)
+	}
+	// No need to wait for the recording's pace
+	i.ticker = time.NewTicker(time.Microsecond * 50)
+	t.Cleanup(i.ticker.Stop)
+
+	frames, _ := i.Stream()
+	t.Cleanup(i.StopStream)
+
+	var states []telemetry.SourceState
+	for len(states) < 3 {
+		select {
+		case <-frames:
+		case ev := <-i.Events():
+			states = append(states, ev.State)
+			if ev.State == telemetry.SourceDisconnected && !errors.Is(ev.Err, io.EOF) {
+				t.Errorf("expected the recording to end, got %v", ev.Err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out, got the states %v", states)
+		}
+	}
+
+	expect := []telemetry.SourceState{
+		telemetry.SourceConnected, telemetry.SourceStreaming, telemetry.SourceDisconnected,
+	}
+	for k := range expect {
+		if states[k] != expect[k] {
+			t.Fatalf("expected the states %v, got %v", expect, states)
+		}
+	}
+
+	// The listeners stop getting frames once the source is lost
+	select {
+	case <-frames:
+	default:
+	}
+	select {
+	case <-frames:
+		t.Errorf("got a frame after the recording ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func Test_Playback(t *testing.T) {
+	p, err := NewPlayback(slog.New(slog.DiscardHandler), "../../test_telem.ibt")
+	if err != nil {
+		t.Fatalf("failed to open the ibt: %v", err)
+	}
+	t.Cleanup(p.ticker.Stop)
+
+	sessionTime := func() float64 {
+		v, _ := p.SDK.Vars.Vars["SessionTime"].Value.(float64)
+		return v
+	}
+
+	t.Run("test_seek_time", func(t *testing.T) {
+		if err := p.Seek(5 * time.Second); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if read, err := p.step(0); !read || err != nil {
+			t.Fatalf("expected the seek to show a frame, got %v %v", read, err)
+		}
+
+		if got := sessionTime() - p.start; got < 5 || got > 5.02 {
+			t.Errorf("expected to be 5s in, got %f", got)
+		}
+	})
+
+	t.Run("test_paced_by_session_time", func(t *testing.T) {
+		for _, speed := range []float64{1, 2, 0.25} {
+			if err := p.SetSpeed(speed); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			before := sessionTime()
+			p.step(time.Second)
+
+			if got := sessionTime() - before; got < speed-0.02 || got > speed+0.02 {
+				t.Errorf("%gx: expected %gs of the recording, got %f", speed, speed, got)
+			}
+		}
+
+		if err := p.SetSpeed(16); err == nil {
+			t.Errorf("expected 16x to be too fast")
+		}
+	})
+
+	t.Run("test_pause", func(t *testing.T) {
+		p.Pause()
+		defer p.Resume()
+
+		before := sessionTime()
+		if read, _ := p.step(time.Second); read || sessionTime() != before {
+			t.Errorf("expected a paused playback to stay put")
+		}
+	})
+
+	t.Run("test_seek_lap", func(t *testing.T) {
+		if err := p.SeekLap(1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		p.step(0)
+
+		if st := p.Status(); st.Lap != 1 || p.SDK.Vars.Tick != 604 {
+			t.Errorf("expected the start of lap 1 on frame 603, got lap %d on frame %d",
+				st.Lap, p.SDK.Vars.Tick-1)
+		}
+
+		if err := p.SeekLap(7); err == nil {
+			t.Errorf("expected an error for a lap that isn't there")
+		}
+	})
+
+	t.Run("test_end", func(t *testing.T) {
+		if err := p.Seek(p.length()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		p.step(0)
+
+		if _, err := p.step(time.Second); !errors.Is(err, io.EOF) {
+			t.Errorf("expected the recording to end, got %v", err)
+		}
+
+		if st := p.Status(); st.Time != st.Length {
+			t.Errorf("expected to stay at the end, got %s of %s", st.Time, st.Length)
+		}
+	})
+
+	t.Run("test_loop", func(t *testing.T) {
+		p.SetLoop(true)
+		defer p.SetLoop(false)
+
+		session := p.data.Session
+		if read, err := p.step(time.Second); !read || err != nil {
+			t.Fatalf("expected the recording to start over, got %v %v", read, err)
+		}
+
+		if got := sessionTime() - p.start; got > 0.02 {
+			t.Errorf("expected to be back at the start, got %f in", got)
+		}
+
+		if p.data.Session == session {
+			t.Errorf("expected the loop to start a new session for the virtual fields")
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package iracing
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"esdi/telemetry"
+
+	"github.com/ESilva15/goirsdk"
+)
+
+const (
+	// headerStatus is where the sim says if it is connected, see
+	// goirsdk.TelemetryHeaders
+	headerStatus = 4
+	// statusConnected is irsdk_stConnected
+	statusConnected = 1
+
+	// staleTimeout is how long the sim can go without a new frame before we
+	// take it as gone
+	staleTimeout = 2 * time.Second
+	reconnectMin = 250 * time.Millisecond
+	reconnectMax = 5 * time.Second
+
+	// defaultTickRate is the rate the sim writes the telemetry at, used until
+	// the SDK tells us
+	defaultTickRate = 60
+)
+
+var (
+	errSimStopped = errors.New("the sim stopped sending telemetry")
+	errSimStale   = fmt.Errorf("no new telemetry for %s", staleTimeout)
+)
+
+// attach starts using a freshly opened SDK. The session key is kept, a new SDK
+// in another session is reported as a session change
+func (i *IRacing) attach(sdk *goirsdk.IBT) {
+	i.SDK = sdk
+	// The SDK already read this version of the session info
+	i.sessionInfoUpdate = sdk.Headers.SessionInfoUpdate
+	i.sessionGen++
+	i.lastTick = -1
+	i.ticker.Reset(time.Second / time.Duration(max(sdk.Headers.TickRate, 1)))
+}
+
+// connect opens the SDK on the sim's memory, for when the sim wasn't running
+func (i *IRacing) connect() error {
+	sdk, err := goirsdk.Init(nil, "", "")
+	if err != nil {
+		return err
+	}
+
+	i.mut.Lock()
+	i.attach(sdk)
+	i.mut.Unlock()
+
+	return nil
+}
+
+// detach drops the SDK of a sim that went away
+func (i *IRacing) detach() {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	i.SDK.Close()
+	i.SDK = nil
+}
+
+// simConnected reads the status the sim keeps on the header, the SDK only reads
+// it once. Recordings are always there
+func (i *IRacing) simConnected() bool {
+	if !i.live {
+		return true
+	}
+
+	var status [4]byte
+	if _, err := i.SDK.File.ReadAt(status[:], headerStatus); err != nil {
+		return false
+	}
+
+	return binary.LittleEndian.Uint32(status[:])&statusConnected != 0
+}
+
+// checkFresh fails when the sim stopped moving the telemetry forward
+func (i *IRacing) checkFresh(now time.Time) error {
+	if tick := i.SDK.Vars.Tick; tick != i.lastTick {
+		i.lastTick = tick
+		i.lastFrame = now
+		return nil
+	}
+
+	if now.Sub(i.lastFrame) > staleTimeout {
+		return errSimStale
+	}
+
+	return nil
+}
+
+// ready reports if the sim is sending telemetry
+func (i *IRacing) ready() (bool, error) {
+	if !i.simConnected() {
+		return false, nil
+	}
+
+	i.lastFrame = time.Now()
+	return true, nil
+}
+
+// read reads a frame, failing when the sim stopped sending them
+func (i *IRacing) read() (bool, error) {
+	if !i.simConnected() {
+		return false, errSimStopped
+	}
+
+	sessionChanged, err := i.readData()
+	if err == nil {
+		err = i.checkFresh(time.Now())
+	}
+
+	return sessionChanged, err
+}
+
+// stream runs the provider lifecycle, see telemetry.SourceLifecycle.Run. The
+// waits for the sim back off so a missing sim doesn't keep a core busy.
+// Recordings stop at the end instead
+func (i *IRacing) stream(ctx context.Context) {
+	i.Run(ctx, telemetry.SourceSteps{
+		Connect: i.connect,
+		Ready:   i.ready,
+		Read:    i.read,
+		Reset:   i.detach,
+		Tick:    i.ticker.C,
+		Retry:   &telemetry.Backoff{Min: reconnectMin, Max: reconnectMax},
+		Once:    !i.live,
+	}, i.streamCh)
+}
@@ -0,0 +1,364 @@
+package iracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"esdi/telemetry"
+)
+
+// Playback plays an .ibt at the pace it was recorded at, following the
+// SessionTime of its frames, and can be paused, sped up, seeked and looped.
+// It is the iRacing provider reading a file
+type Playback struct {
+	*IRacing
+
+	frames  int              // Frames in the recording
+	start   float64          // SessionTime of the first frame
+	end     float64          // SessionTime of the last frame
+	offsets map[string]int32 // Where the variables we seek by are in a frame
+
+	// Controls, the stream reads them on every step
+	ctrl   sync.Mutex
+	clock  float64 // SessionTime the playback is at
+	lap    int
+	speed  float64
+	paused bool
+	loop   bool
+	seek   int // Frame to go to on the next step, -1 for none
+}
+
+func NewPlayback(logger *slog.Logger, source string) (*Playback, error) {
+	if source == "" {
+		return nil, errors.New("playback needs a recording")
+	}
+
+	i, err := NewIRacingProvider(logger, source, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Playback{
+		IRacing: i,
+		speed:   1,
+		seek:    -1,
+		offsets: make(map[string]int32),
+	}
+
+	// The stream writes the SDK variables, seeking can't read them
+	for _, key := range []string{"SessionTime", "Lap"} {
+		if v, ok := i.SDK.Vars.Vars[key]; ok {
+			p.offsets[key] = v.Offset
+		}
+	}
+
+	// The disk header doesn't always have the record count, the size of the
+	// file does
+	p.frames = p.countFrames()
+	if p.frames == 0 {
+		return nil, fmt.Errorf("%s has no frames", source)
+	}
+
+	if p.start, err = p.frameTime(0); err != nil {
+		return nil, err
+	}
+	if p.end, err = p.frameTime(p.frames - 1); err != nil {
+		return nil, err
+	}
+	p.clock = p.start
+
+	return p, nil
+}
+
+// Controls
+
+func (p *Playback) Pause() {
+	p.ctrl.Lock()
+	defer p.ctrl.Unlock()
+
+	p.paused = true
+}
+
+func (p *Playback) Resume() {
+	p.ctrl.Lock()
+	defer p.ctrl.Unlock()
+
+	p.paused = false
+}
+
+func (p *Playback) SetSpeed(speed float64) error {
+	if err := telemetry.ValidatePlaybackSpeed(speed); err != nil {
+		return err
+	}
+
+	p.ctrl.Lock()
+	defer p.ctrl.Unlock()
+
+	p.speed = speed
+	return nil
+}
+
+func (p *Playback) SetLoop(loop bool) {
+	p.ctrl.Lock()
+	defer p.ctrl.Unlock()
+
+	p.loop = loop
+}
+
+func (p *Playback) Seek(t time.Duration) error {
+	target := p.start + t.Seconds()
+	if t < 0 || target > p.end {
+		return fmt.Errorf("can't seek to %s, the recording is %s long", t, p.length())
+	}
+
+	frame := p.search(func(frame int) (bool, error) {
+		ft, err := p.frameTime(frame)
+		return ft >= target, err
+	})
+
+	p.ctrl.Lock()
+	defer p.ctrl.Unlock()
+
+	p.seek = frame
+	return nil
+}
+
+func (p *Playback) SeekLap(lap int) error {
+	frame := p.search(func(frame int) (bool, error) {
+		l, err := p.frameLap(frame)
+		return l >= lap, err
+	})
+
+	// Laps can be skipped, ex: a reset to the pits
+	if frame == p.frames {
+		return fmt.Errorf("the recording has no lap %d", lap)
+	}
+	if l, err := p.frameLap(frame); err != nil || l != lap {
+		return fmt.Errorf("the recording has no lap %d", lap)
+	}
+
+	p.ctrl.Lock()
+	defer p.ctrl.Unlock()
+
+	p.seek = frame
+	return nil
+}
+
+func (p *Playback) Status() telemetry.PlaybackStatus {
+	p.ctrl.Lock()
+	defer p.ctrl.Unlock()
+
+	return telemetry.PlaybackStatus{
+		Time:   seconds(p.clock - p.start),
+		Length: p.length(),
+		Lap:    p.lap,
+		Speed:  p.speed,
+		Paused: p.paused,
+		Loop:   p.loop,
+	}
+}
+
+// Telemetry Provider Interface
+
+func (p *Playback) Stream() (<-chan telemetry.TelemetryData, error) {
+	var ctx context.Context
+	ctx, p.streamCancel = context.WithCancel(context.Background())
+
+	p.stream(ctx)
+
+	return p.streamCh, nil
+}
+
+// Internal
+
+// stream plays the recording. Every tick moves the clock by the time that
+// passed times the speed and reads the frames up to it, the last one read is
+// published. The end of the recording is reported as a lost source, seeking
+// plays it again
+func (p *Playback) stream(ctx context.Context) {
+	p.data.InitialTime = time.Now()
+
+	p.Publish(nil)
+	p.SetState(telemetry.SourceStreaming, nil)
+
+	go func() {
+		last := time.Now()
+
+		for {
+			var now time.Time
+			select {
+			case <-ctx.Done():
+				return
+			case now = <-p.ticker.C:
+			}
+
+			read, err := p.step(now.Sub(last))
+			last = now
+
+			if read {
+				if p.State() != telemetry.SourceStreaming {
+					p.SetState(telemetry.SourceStreaming, nil)
+				}
+
+				// Publish data
+				select {
+				case p.streamCh <- *p.data:
+				default:
+					// skip this data, don't allow publishers to lag behind
+				}
+			}
+
+			switch {
+			case errors.Is(err, io.EOF):
+				if p.State() == telemetry.SourceStreaming {
+					p.Lost(err)
+				}
+			case err != nil:
+				p.Lost(err)
+				<-ctx.Done()
+				return
+			}
+		}
+	}()
+}
+
+// step moves the playback forward by elapsed, reports if it read any frame. It
+// fails with io.EOF at the end of a recording that doesn't loop
+func (p *Playback) step(elapsed time.Duration) (bool, error) {
+	p.ctrl.Lock()
+	defer p.ctrl.Unlock()
+
+	seeked := p.seek >= 0
+	switch {
+	case seeked:
+		if err := p.goTo(p.seek); err != nil {
+			return false, err
+		}
+		p.seek = -1
+	case p.paused:
+		return false, nil
+	default:
+		p.clock += elapsed.Seconds() * p.speed
+	}
+
+	read := false
+	for {
+		frame := int(p.SDK.Vars.Tick)
+		if frame >= p.frames {
+			if !p.loop {
+				p.clock = p.end
+				return read, io.EOF
+			}
+
+			if err := p.goTo(0); err != nil {
+				return read, err
+			}
+			continue
+		}
+
+		t, err := p.frameTime(frame)
+		if err != nil {
+			return read, err
+		}
+
+		// A seek always shows the frame it lands on
+		if t > p.clock && (read || !seeked) {
+			return read, nil
+		}
+
+		if _, err := p.readData(); err != nil {
+			return read, err
+		}
+		read = true
+
+		if lap, ok := p.SDK.Vars.Vars["Lap"].Value.(int); ok {
+			p.lap = lap
+		}
+	}
+}
+
+// goTo moves the playback to a frame. The virtual fields see it as a new
+// session, the frames in between never happened for them
+func (p *Playback) goTo(frame int) error {
+	t, err := p.frameTime(frame)
+	if err != nil {
+		return err
+	}
+
+	p.mut.Lock()
+	p.SDK.Vars.Tick = int32(frame)
+	p.data.SessionChanged()
+	p.mut.Unlock()
+
+	p.clock = t
+	p.Publish(nil)
+
+	return nil
+}
+
+func (p *Playback) length() time.Duration {
+	return seconds(p.end - p.start)
+}
+
+// frameOffset is where a frame starts in the file
+func (p *Playback) frameOffset(frame int) int64 {
+	return int64(p.SDK.Headers.BufOffset) + int64(frame)*int64(p.SDK.Headers.BufLen)
+}
+
+// countFrames finds how many whole frames the file has
+func (p *Playback) countFrames() int {
+	var b [1]byte
+	return sort.Search(math.MaxInt32, func(frame int) bool {
+		_, err := p.SDK.File.ReadAt(b[:], p.frameOffset(frame+1)-1)
+		return err != nil
+	})
+}
+
+// search finds the first frame the condition holds for, the frames must be
+// ordered by it. Frames that can't be read stop the search
+func (p *Playback) search(cond func(frame int) (bool, error)) int {
+	return sort.Search(p.frames, func(frame int) bool {
+		ok, err := cond(frame)
+		return ok || err != nil
+	})
+}
+
+// readFrameVar reads a single variable of a frame without reading the frame
+func (p *Playback) readFrameVar(frame int, key string, out any) error {
+	offset, ok := p.offsets[key]
+	if !ok {
+		return fmt.Errorf("the recording has no %s", key)
+	}
+
+	buf := make([]byte, binary.Size(out))
+	if _, err := p.SDK.File.ReadAt(buf, p.frameOffset(frame)+int64(offset)); err != nil {
+		return err
+	}
+
+	return binary.Read(bytes.NewReader(buf), binary.LittleEndian, out)
+}
+
+func (p *Playback) frameTime(frame int) (float64, error) {
+	var t float64
+	err := p.readFrameVar(frame, "SessionTime", &t)
+	return t, err
+}
+
+func (p *Playback) frameLap(frame int) (int, error) {
+	var lap int32
+	err := p.readFrameVar(frame, "Lap", &lap)
+	return int(lap), err
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,164 @@
+package iracing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"esdi/telemetry"
+
+	"github.com/ESilva15/goirsdk"
+	"golang.org/x/text/encoding/charmap"
+	"gopkg.in/yaml.v3"
+)
+
+// Where the session info is described in the telemetry header, see
+// goirsdk.TelemetryHeaders
+const (
+	headerSessionInfoUpdate = 12
+	headerSessionInfoLength = 16
+	headerSessionInfoOffset = 20
+	headerSessionInfoEnd    = 24
+)
+
+// sessionKey identifies a session. The sim goes from one session to the next
+// (practice, qualifying, race) in the same event
+type sessionKey struct {
+	uniqueID int
+	num      int
+}
+
+// sessionInfo is the session info the sim gives us with the session we are in
+type sessionInfo struct {
+	*goirsdk.SessionInfoYAML
+	SessionNum int
+}
+
+// player returns the driver of the player's car
+func (si *sessionInfo) player() (goirsdk.Driver, bool) {
+	for _, d := range si.DriverInfo.Drivers {
+		if d.CarIdx == si.DriverInfo.DriverCarIdx {
+			return d, true
+		}
+	}
+
+	return goirsdk.Driver{}, false
+}
+
+// session returns the index of the current session on the session list
+func (si *sessionInfo) session() int {
+	for k, s := range si.SessionInfo.Sessions {
+		if s.SessionNum == si.SessionNum {
+			return k
+		}
+	}
+
+	return -1
+}
+
+// refreshSessionInfo reads the session info again when the sim tells us it
+// changed, the SDK only reads it once when it starts. Reports if it did
+func (i *IRacing) refreshSessionInfo() bool {
+	var header [headerSessionInfoEnd]byte
+	if _, err := i.SDK.File.ReadAt(header[:], 0); err != nil {
+		return false
+	}
+
+	update := int32(binary.LittleEndian.Uint32(header[headerSessionInfoUpdate:]))
+	if update == i.sessionInfoUpdate {
+		return false
+	}
+
+	length := int32(binary.LittleEndian.Uint32(header[headerSessionInfoLength:]))
+	offset := int32(binary.LittleEndian.Uint32(header[headerSessionInfoOffset:]))
+
+	buf := make([]byte, length)
+	if _, err := i.SDK.File.ReadAt(buf, int64(offset)); err != nil {
+		i.logger.Error(fmt.Sprintf("failed to read the session info: %v", err))
+		return false
+	}
+
+	si, err := parseSessionInfo(buf)
+	if err != nil {
+		// We try again on the next frame
+		i.logger.Debug(fmt.Sprintf("failed to parse the session info: %v", err))
+		return false
+	}
+
+	i.logger.Debug(fmt.Sprintf("session info changed, update %d", update))
+
+	i.sessionInfoUpdate = update
+	i.SDK.SessionInfo = si
+
+	return true
+}
+
+// parseSessionInfo parses the session info YAML, the sim writes it in
+// Windows-1252
+func parseSessionInfo(buf []byte) (*goirsdk.SessionInfoYAML, error) {
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var si goirsdk.SessionInfoYAML
+	err = yaml.Unmarshal(bytes.TrimRight(decoded, "\x00"), &si)
+	if err != nil {
+		return nil, err
+	}
+
+	return &si, nil
+}
+
+// currentSession reads the session we are in from the telemetry
+func (i *IRacing) currentSession() sessionKey {
+	uniqueID, _ := i.SDK.Vars.Vars["SessionUniqueID"].Value.(int)
+	num, _ := i.SDK.Vars.Vars["SessionNum"].Value.(int)
+
+	return sessionKey{uniqueID: uniqueID, num: num}
+}
+
+// updateSession checks for a new session info or a new session, the fields
+// read from the session info are only fetched again after one of them. Reports
+// if the session changed
+func (i *IRacing) updateSession() bool {
+	infoChanged := i.refreshSessionInfo()
+
+	session := i.currentSession()
+	sessionChanged := i.sessionKnown && session != i.session
+	if sessionChanged {
+		i.logger.Info(fmt.Sprintf("session changed from %d to %d", i.session.num, session.num))
+	}
+
+	if infoChanged || session != i.session || !i.sessionKnown {
+		i.sessionGen++
+	}
+
+	i.session = session
+	i.sessionKnown = true
+
+	return sessionChanged
+}
+
+// sessionInfoUpdater binds a field read from the session info. The value is
+// only fetched again when the session info or the session change
+func (i *IRacing) sessionInfoUpdater(
+	fetch func(*sessionInfo) any,
+	transform func(any, *telemetry.TelemetryField),
+) func(*telemetry.TelemetryField) {
+	seen := -1
+
+	return func(out *telemetry.TelemetryField) {
+		if seen == i.sessionGen {
+			return
+		}
+		seen = i.sessionGen
+
+		if i.SDK.SessionInfo == nil {
+			transform(nil, out)
+			return
+		}
+
+		transform(fetch(&sessionInfo{i.SDK.SessionInfo, i.session.num}), out)
+	}
+}
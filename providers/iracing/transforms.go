@@ -1,6 +1,7 @@
 package iracing
 
 import (
+	"strconv"
 	"time"
 
 	"esdi/telemetry"
@@ -32,3 +33,49 @@ func PitSpeedLimiterTransform(v any, out *telemetry.TelemetryField) {
 		out.Str = "   "
 	}
 }
+
+func GearTransform(v any, out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeCHAR
+
+	gear := 0
+	if val, ok := v.(int32); ok {
+		gear = int(val)
+	} else if val, ok := v.(int); ok {
+		gear = val
+	}
+
+	switch {
+	case gear == 0:
+		out.Raw = uint64('N') // ASCII 78
+	case gear < 0:
+		out.Raw = uint64('R') // ASCII 82
+	case gear > 0 && gear < 10:
+		// Quickest way to turn 1 into '1', 2 into '2', etc.
+		// ASCII '0' is 48, so 48 + 1 = 49 ('1')
+		out.Raw = uint64('0' + gear)
+	default:
+		out.Raw = uint64('?') // Fallback
+	}
+}
+
+// BitfieldTransform reads the bitfields the SDK gives us as hex strings, ex:
+// "0x10004000"
+func BitfieldTransform(v any, out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeUINT32
+	out.Raw = uint64(bitfield(v))
+}
+
+// bitfield parses a bitfield the way the SDK hands it to us, 0 if it can't
+func bitfield(v any) uint32 {
+	switch val := v.(type) {
+	case string:
+		bits, err := strconv.ParseUint(val, 0, 32)
+		if err == nil {
+			return uint32(bits)
+		}
+	case int:
+		return uint32(val)
+	}
+
+	return 0
+}
@@ -0,0 +1,273 @@
+// Package lfs is the data provider of Live for Speed. LFS sends the car's dash
+// as OutGauge packets and its motion as OutSim packets, both over UDP. They are
+// told apart by their size, so LFS can send both to the same port:
+//
+//	OutGauge Mode 1
+//	OutGauge IP   127.0.0.1
+//	OutGauge Port 30000
+//	OutSim Mode   1
+//	OutSim IP     127.0.0.1
+//	OutSim Port   30000
+//	OutSim Opts   0
+//
+// on LFS's cfg.txt. OutSim is optional, without it the motion fields stay empty
+package lfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"esdi/telemetry"
+)
+
+const (
+	NAME = telemetry.SourceLFS
+
+	// DefaultUDPPort is the port we ask LFS to send OutGauge and OutSim to
+	DefaultUDPPort = 30000
+
+	// maxPacketSize is more than the biggest packet LFS sends
+	maxPacketSize = 512
+)
+
+var errUnknownPacket = errors.New("not an OutGauge or OutSim packet")
+
+// LFS is our Live for Speed telemetry data provider - its a TelemetryProvider
+// interface
+type LFS struct {
+	logger *slog.Logger
+	conn   *net.UDPConn
+
+	// Data Handling
+	mut      sync.Mutex
+	data     *telemetry.TelemetryData
+	updaters [telemetry.MaxFields]func(*telemetry.TelemetryField)
+
+	// Last packets, as they came from LFS
+	gauge      packet
+	sim        packet
+	lastPacket time.Time // When the last packet came in
+
+	// Timing information
+	ticker *time.Ticker
+
+	// Lifecycle
+	telemetry.SourceLifecycle
+
+	// Stream
+	streamCh     chan telemetry.TelemetryData
+	streamCancel context.CancelFunc
+}
+
+// NewLFSProvider listens for LFS on ip:port
+// NOTE: neither packet says anything about the session, the session never
+// changes for LFS
+func NewLFSProvider(logger *slog.Logger, ip string, port int) (*LFS, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(ip), Port: port})
+	if err != nil {
+		return &LFS{}, err
+	}
+
+	l := &LFS{
+		logger:   logger,
+		conn:     conn,
+		data:     telemetry.NewTelemetryData(),
+		ticker:   time.NewTicker(time.Second / tickRate),
+		streamCh: make(chan telemetry.TelemetryData, 1),
+	}
+	l.SourceLifecycle = telemetry.NewSourceLifecycle(logger, NAME, telemetry.SourceWaiting, &l.mut, l.data)
+
+	// Channels this provider knows how to read, keyed by the names the
+	// telemetry registry uses as this provider's sources. They are named after
+	// the members of the packets
+	channels := map[string]func(*telemetry.TelemetryField){
+		// OutGauge
+		"Speed":       l.gaugeFloat(gaugeSpeed),
+		"RPM":         l.rpm,
+		"Gear":        l.gear,
+		"Car":         l.carName,
+		"Fuel":        l.gaugeFloat(gaugeFuel),
+		"EngTemp":     l.gaugeFloat(gaugeEngTemp),
+		"OilPressure": l.gaugeFloat(gaugeOilPressure),
+		"OilTemp":     l.gaugeFloat(gaugeOilTemp),
+		"Throttle":    l.gaugeFloat(gaugeThrottle),
+		"Brake":       l.gaugeFloat(gaugeBrake),
+		"Clutch":      l.gaugeFloat(gaugeClutch),
+		// OutGauge dash lights
+		"PitSpeed":  l.pitSpeedLimiter,
+		"OilWarn":   l.oilWarning,
+		"SignalL":   l.light(dlSignalL, '<'),
+		"SignalR":   l.light(dlSignalR, '>'),
+		"ABS":       l.light(dlABS, 'A'),
+		"Handbrake": l.light(dlHandbrake, 'P'),
+		"TC":        l.light(dlTC, 'T'),
+		"Battery":   l.light(dlBattery, 'B'),
+		// OutSim
+		"Heading":   l.simFloat(simHeading),
+		"Pitch":     l.simFloat(simPitch),
+		"Roll":      l.simFloat(simRoll),
+		"AccelLat":  l.accelLat,
+		"AccelLong": l.accelLong,
+		"AccelVert": l.accelVert,
+	}
+
+	err = telemetry.ValidateSources(NAME, func(key string) bool {
+		_, ok := channels[key]
+		return ok
+	})
+	if err != nil {
+		logger.Warn(fmt.Sprintf("registry and provider are out of sync: %v", err))
+	}
+
+	// Set the telemetry fields this provider doesn't supply as unused fields
+	for k := range l.updaters {
+		l.updaters[k] = l.unused
+	}
+
+	for _, def := range telemetry.FieldsFromSource(NAME) {
+		if update, ok := channels[def.Sources[NAME]]; ok {
+			l.updaters[def.ID] = update
+		}
+	}
+
+	return l, nil
+}
+
+// handlePacket keeps a packet LFS sent as the last one of its kind
+func (l *LFS) handlePacket(buf []byte, now time.Time) error {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	switch len(buf) {
+	case gaugeSize, gaugeSize + idSize:
+		l.gauge = append(l.gauge[:0], buf...)
+	case simSize, simSize + idSize:
+		l.sim = append(l.sim[:0], buf...)
+	default:
+		return fmt.Errorf("%w: %d bytes, is OutSim Opts set to 0?", errUnknownPacket, len(buf))
+	}
+
+	l.lastPacket = now
+
+	return nil
+}
+
+// receive reads the packets until the context is done
+func (l *LFS) receive(ctx context.Context) {
+	buf := make([]byte, maxPacketSize)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// Don't block for long, we need to notice the stream stopping
+		l.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		n, _, err := l.conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				l.logger.Debug(fmt.Sprintf("failed to read a packet: %v", err))
+			}
+			continue
+		}
+
+		if err := l.handlePacket(buf[:n], time.Now()); err != nil {
+			l.logger.Debug(fmt.Sprintf("dropped a packet: %v", err))
+		}
+	}
+}
+
+// ready reports if the OutGauge packets came in, OutSim isn't needed
+func (l *LFS) ready() bool {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	return len(l.gauge) > 0
+}
+
+// readData reads a frame from the last packets
+func (l *LFS) readData() {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	// Read 1 to 1 data
+	for _, bind := range l.data.ActiveBinds {
+		l.updaters[bind.ID](&l.data.Values[bind.ID])
+	}
+
+	// Set up virtual binds
+	for _, vBind := range l.data.VirtualBinds {
+		vBind.Process(l.data)
+	}
+
+	l.data.PenultimateDataPoll = l.data.LastDataPoll
+	l.data.LastDataPoll = time.Now()
+}
+
+// Telemetry Provider Interface
+
+// Stream returns a channel that we will use to funnel the telemetry data back to the
+// UI, which then should broadcast it to the devices
+func (l *LFS) Stream() (<-chan telemetry.TelemetryData, error) {
+	var ctx context.Context
+	ctx, l.streamCancel = context.WithCancel(context.Background())
+
+	// Start the stream
+	go l.receive(ctx)
+	l.stream(ctx)
+
+	return l.streamCh, nil
+}
+
+// Close stops listening for LFS
+func (l *LFS) Close() error {
+	return l.conn.Close()
+}
+
+func (l *LFS) Name() string {
+	return NAME
+}
+
+func (l *LFS) StopStream() {
+	if l.streamCancel == nil {
+		return
+	}
+
+	l.streamCancel()
+	l.streamCancel = nil
+}
+
+func (l *LFS) Subscribe(requestFields map[int16]telemetry.FieldID) {
+	l.logger.Debug(fmt.Sprintf("Len Req: %d\n", len(requestFields)))
+
+	plan, err := telemetry.PlanSubscription(l.logger, requestFields)
+	if err != nil {
+		l.logger.Error(fmt.Sprintf("failed to plan some fields: %v", err))
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	plan.Apply(l.data)
+
+	// Fields this provider doesn't supply are bound anyway, their updater
+	// marks them as unused
+	for _, id := range plan.Primitives {
+		l.data.ActiveBinds = append(l.data.ActiveBinds, telemetry.BoundField{
+			ID: id,
+		})
+	}
+
+	l.logger.Debug(fmt.Sprintf("Subscribed: %+v\n", l.data.ActiveBinds))
+}
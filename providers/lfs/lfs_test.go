@@ -0,0 +1,216 @@
+package lfs
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"esdi/providers/providertest"
+	"esdi/telemetry"
+)
+
+// testdata has an OutGauge packet, with an ID, and an OutSim packet of an XRT in
+// third doing 41.5 m/s heading west. The left indicator, ABS, TC, battery and pit
+// limiter lights are on. They weren't captured from LFS, they were written by
+// hand from the packet layouts of InSim.txt, the offsets are the ones on
+// packets.go
+const (
+	gaugePath = "testdata/outgauge.bin"
+	simPath   = "testdata/outsim.bin"
+)
+
+func loadPacket(t *testing.T, path string) []byte {
+	t.Helper()
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the packet: %v", err)
+	}
+
+	return buf
+}
+
+func newTestProvider(t *testing.T) *LFS {
+	t.Helper()
+
+	l, err := NewLFSProvider(slog.New(slog.DiscardHandler), "127.0.0.1", 0)
+	if err != nil {
+		t.Fatalf("failed to create the provider: %v", err)
+	}
+	t.Cleanup(l.ticker.Stop)
+	t.Cleanup(func() { l.Close() })
+
+	return l
+}
+
+func Test_Packets(t *testing.T) {
+	l := newTestProvider(t)
+	fields := providertest.SubscribeAll(l.Subscribe, NAME)
+
+	for _, path := range []string{gaugePath, simPath} {
+		if err := l.handlePacket(loadPacket(t, path), time.Now()); err != nil {
+			t.Fatalf("failed to handle %s: %v", path, err)
+		}
+	}
+	l.readData()
+
+	for _, def := range fields {
+		if tf := l.data.Values[def.ID]; tf.Type != def.Type {
+			t.Errorf("%s: expected type %d, got %d (%s)", def.Key, def.Type, tf.Type, tf.String())
+		}
+	}
+
+	expect := map[telemetry.FieldID]string{
+		telemetry.Speed:             "41.5",
+		telemetry.RPM:               "6250",
+		telemetry.Gear:              "3",
+		telemetry.CarName:           "XRT",
+		telemetry.WaterTemp:         "92.0",
+		telemetry.OilPress:          "3.5",
+		telemetry.OilTemp:           "105.0",
+		telemetry.PitSpeedLimiter:   "PIT",
+		telemetry.OilPressWarning:   "0",
+		telemetry.LeftIndicator:     "<",
+		telemetry.RightIndicator:    " ",
+		telemetry.ABSWarningLight:   "A",
+		telemetry.ParkingBrakeLight: " ",
+		telemetry.TCLight:           "T",
+		telemetry.BatteryLight:      "B",
+	}
+	providertest.CheckValues(t, l.data, expect)
+
+	// Heading west the car's forward is the world's -X and its left -Y
+	expectFloat := map[telemetry.FieldID]float64{
+		telemetry.FuelLevelPct: 0.62,
+		telemetry.Throttle:     0.75,
+		telemetry.Brake:        0.25,
+		telemetry.Yaw:          math.Pi / 2,
+		telemetry.LongAccel:    3,
+		telemetry.LatAccel:     -4,
+		telemetry.VertAccel:    9.5,
+	}
+	for id, want := range expectFloat {
+		if got := l.data.Values[id].Float(); math.Abs(got-want) > 1e-5 {
+			t.Errorf("%s: expected %.3f, got %.3f", telemetry.GetFieldName(id), want, got)
+		}
+	}
+}
+
+func Test_DashLights(t *testing.T) {
+	tests := []struct {
+		name   string
+		lights uint32
+		expect map[telemetry.FieldID]string
+	}{
+		{
+			"test_off", 0,
+			map[telemetry.FieldID]string{
+				telemetry.LeftIndicator: " ", telemetry.RightIndicator: " ", telemetry.ABSWarningLight: " ",
+				telemetry.TCLight: " ", telemetry.BatteryLight: " ", telemetry.PitSpeedLimiter: "   ",
+			},
+		},
+		{
+			"test_hazards", dlSignalL | dlSignalR,
+			map[telemetry.FieldID]string{
+				telemetry.LeftIndicator: "<", telemetry.RightIndicator: ">", telemetry.ABSWarningLight: " ",
+			},
+		},
+		{
+			"test_warnings", dlOilWarn | dlHandbrake | dlShift,
+			map[telemetry.FieldID]string{
+				telemetry.OilPressWarning: "1", telemetry.ParkingBrakeLight: "P", telemetry.TCLight: " ",
+			},
+		},
+	}
+
+	l := newTestProvider(t)
+	providertest.SubscribeAll(l.Subscribe, NAME)
+	gauge := loadPacket(t, gaugePath)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			binary.LittleEndian.PutUint32(gauge[gaugeShowLights:], test.lights)
+			l.handlePacket(gauge, time.Now())
+			l.readData()
+
+			providertest.CheckValues(t, l.data, test.expect)
+		})
+	}
+}
+
+func Test_PacketSizes(t *testing.T) {
+	l := newTestProvider(t)
+
+	// Without the IDs
+	if err := l.handlePacket(loadPacket(t, gaugePath)[:gaugeSize], time.Now()); err != nil {
+		t.Errorf("expected an OutGauge packet without an ID, got %v", err)
+	}
+	sim := append(loadPacket(t, simPath), 1, 0, 0, 0)
+	if err := l.handlePacket(sim, time.Now()); err != nil || len(l.sim) != simSize+idSize {
+		t.Errorf("expected an OutSim packet with an ID, got %v", err)
+	}
+
+	// OutSim Opts other than 0 sends a bigger packet
+	if err := l.handlePacket(make([]byte, 280), time.Now()); err == nil {
+		t.Errorf("expected an unknown packet to fail")
+	}
+}
+
+func Test_StreamLifecycle(t *testing.T) {
+	l := newTestProvider(t)
+	l.ticker = time.NewTicker(time.Millisecond)
+	t.Cleanup(l.ticker.Stop)
+	l.Subscribe(map[int16]telemetry.FieldID{0: telemetry.Speed, 1: telemetry.Gear})
+
+	frames, _ := l.Stream()
+	t.Cleanup(l.StopStream)
+
+	conn, err := net.Dial("udp", l.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("failed to dial the provider: %v", err)
+	}
+	defer conn.Close()
+
+	// LFS sends the packets until it's told to stop
+	gauge := loadPacket(t, gaugePath)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				conn.Write(gauge)
+			}
+		}
+	}()
+
+	var states []telemetry.SourceState
+	for len(states) < 4 {
+		select {
+		case frame := <-frames:
+			speed, gear := frame.Values[telemetry.Speed], frame.Values[telemetry.Gear]
+			if speed.String() != "41.5" || gear.String() != "3" {
+				t.Fatalf("expected 41.5 m/s in third, got %s in %s", speed.String(), gear.String())
+			}
+			states = append(states, telemetry.SourceStreaming)
+		case ev := <-l.Events():
+			states = append(states, ev.State)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out, got the states %v", states)
+		}
+	}
+
+	expect := []telemetry.SourceState{
+		telemetry.SourceWaiting, telemetry.SourceConnected, telemetry.SourceStreaming,
+	}
+	if !slices.Equal(states[:3], expect) {
+		t.Errorf("expected the states %v, got %v", expect, states)
+	}
+}
@@ -0,0 +1,84 @@
+package lfs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"esdi/telemetry"
+)
+
+const (
+	// tickRate is how often we read a frame from the packets, LFS sends them
+	// every "OutGauge Delay" hundredths of a second
+	tickRate = 60
+
+	// staleTimeout is how long LFS can go without sending a packet before we
+	// take it as gone. With Mode 1 it stops sending when not driving
+	staleTimeout = 2 * time.Second
+	// readTimeout is how long a read waits for a packet
+	readTimeout = 250 * time.Millisecond
+)
+
+var errGameStale = fmt.Errorf("no packets for %s", staleTimeout)
+
+// stale reports if LFS stopped sending packets
+func (l *LFS) stale(now time.Time) bool {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	return now.Sub(l.lastPacket) > staleTimeout
+}
+
+// reset drops the packets of a game that went away
+func (l *LFS) reset() {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	l.gauge = l.gauge[:0]
+	l.sim = l.sim[:0]
+	l.lastPacket = time.Time{}
+}
+
+// connect waits for LFS to start sending
+func (l *LFS) connect() error {
+	if l.stale(time.Now()) {
+		return errGameStale
+	}
+
+	return nil
+}
+
+// checkReady reports if the OutGauge packets came in. LFS going quiet
+// sends the source back to waiting
+func (l *LFS) checkReady() (bool, error) {
+	if l.stale(time.Now()) {
+		return false, errGameStale
+	}
+
+	return l.ready(), nil
+}
+
+// read reads a frame, failing when LFS stopped sending. The session never
+// changes for LFS
+func (l *LFS) read() (bool, error) {
+	if l.stale(time.Now()) {
+		return false, errGameStale
+	}
+
+	l.readData()
+	return false, nil
+}
+
+// stream runs the provider lifecycle, see telemetry.SourceLifecycle.Run. LFS
+// sends to us, there's nothing to retry, every state just looks at what came in
+// on each tick
+func (l *LFS) stream(ctx context.Context) {
+	l.Run(ctx, telemetry.SourceSteps{
+		Connect: l.connect,
+		Ready:   l.checkReady,
+		Read:    l.read,
+		Reset:   l.reset,
+		Tick:    l.ticker.C,
+	}, l.streamCh)
+}
@@ -0,0 +1,105 @@
+package lfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// Where the members are on the OutGauge packet. LFS adds the ID at the end when
+// "OutGauge ID" is set in cfg.txt
+const (
+	gaugeTime        = 0  // uint32, ms
+	gaugeCar         = 4  // char[4]
+	gaugeFlags       = 8  // uint16, OG_x
+	gaugeGear        = 10 // uint8, 0 reverse, 1 neutral, 2 first...
+	gaugePLID        = 11
+	gaugeSpeed       = 12 // m/s
+	gaugeRPM         = 16
+	gaugeTurbo       = 20 // bar
+	gaugeEngTemp     = 24 // C
+	gaugeFuel        = 28 // 0 to 1
+	gaugeOilPressure = 32 // bar
+	gaugeOilTemp     = 36 // C
+	gaugeDashLights  = 40 // uint32, the DL_x lights the car has
+	gaugeShowLights  = 44 // uint32, the DL_x lights that are on
+	gaugeThrottle    = 48 // 0 to 1
+	gaugeBrake       = 52 // 0 to 1
+	gaugeClutch      = 56 // 0 to 1
+	gaugeDisplay1    = 60 // char[16], usually the fuel
+	gaugeDisplay2    = 76 // char[16], usually the settings
+	gaugeID          = 92 // int32, optional
+
+	gaugeSize = 92
+)
+
+// Where the members are on the OutSim packet, with "OutSim Opts" at 0. Vectors
+// are in the world's axes, X east, Y north and Z up
+const (
+	simTime    = 0  // uint32, ms
+	simAngVel  = 4  // float[3], rad/s
+	simHeading = 16 // rad, anticlockwise from above, 0 facing north
+	simPitch   = 20 // rad
+	simRoll    = 24 // rad
+	simAccel   = 28 // float[3], m/s^2
+	simVel     = 40 // float[3], m/s
+	simPos     = 52 // int32[3], 1m is 65536
+	simID      = 64 // int32, optional
+
+	simSize = 64
+)
+
+// idSize is the size of the optional ID at the end of both packets
+const idSize = 4
+
+// DL_x, the dash lights of OutGauge
+const (
+	dlShift     = 1 << 0
+	dlFullBeam  = 1 << 1
+	dlHandbrake = 1 << 2
+	dlPitSpeed  = 1 << 3
+	dlTC        = 1 << 4
+	dlSignalL   = 1 << 5
+	dlSignalR   = 1 << 6
+	dlSignalAny = 1 << 7
+	dlOilWarn   = 1 << 8
+	dlBattery   = 1 << 9
+	dlABS       = 1 << 10
+)
+
+// packet is a packet as it came from LFS. Members past its end read as zeros
+type packet []byte
+
+func (p packet) u8(off int) uint8 {
+	if off+1 > len(p) {
+		return 0
+	}
+
+	return p[off]
+}
+
+func (p packet) u32(off int) uint32 {
+	if off+4 > len(p) {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint32(p[off:])
+}
+
+func (p packet) f32(off int) float32 {
+	return math.Float32frombits(p.u32(off))
+}
+
+// str reads a NUL terminated string of up to n bytes
+func (p packet) str(off int, n int) string {
+	if off >= len(p) {
+		return ""
+	}
+
+	b := p[off:min(off+n, len(p))]
+	if end := bytes.IndexByte(b, 0); end >= 0 {
+		b = b[:end]
+	}
+
+	return string(b)
+}
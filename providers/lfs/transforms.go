@@ -0,0 +1,108 @@
+package lfs
+
+import (
+	"math"
+
+	"esdi/telemetry"
+)
+
+func (l *LFS) unused(out *telemetry.TelemetryField) {
+	out.Unused()
+}
+
+// OutGauge
+
+func (l *LFS) gaugeFloat(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.SetFloat32(l.gauge.f32(off))
+	}
+}
+
+func (l *LFS) rpm(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeUINT16
+	out.Raw = uint64(uint16(l.gauge.f32(gaugeRPM)))
+}
+
+// gear converts the OutGauge gear (0 is reverse, 1 neutral, 2 first...) to the
+// character we show on the dash
+func (l *LFS) gear(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeCHAR
+
+	switch gear := l.gauge.u8(gaugeGear); {
+	case gear == 0:
+		out.Raw = uint64('R')
+	case gear == 1:
+		out.Raw = uint64('N')
+	case gear < 11:
+		out.Raw = uint64('0' + gear - 1)
+	default:
+		out.Raw = uint64('?')
+	}
+}
+
+// carName is the short name of the car, ex: XFG, FBM
+func (l *LFS) carName(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeSTRING
+	out.Str = l.gauge.str(gaugeCar, 4)
+}
+
+// OutGauge dash lights
+
+// lightOn reports if a DL_x light is on
+func (l *LFS) lightOn(dl uint32) bool {
+	return l.gauge.u32(gaugeShowLights)&dl != 0
+}
+
+// light shows chr while the DL_x light is on
+func (l *LFS) light(dl uint32, chr rune) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.Type = telemetry.DataTypeCHAR
+		out.Raw = uint64(' ')
+		if l.lightOn(dl) {
+			out.Raw = uint64(chr)
+		}
+	}
+}
+
+func (l *LFS) pitSpeedLimiter(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeSTRING
+	if l.lightOn(dlPitSpeed) {
+		out.Str = "PIT"
+	} else {
+		out.Str = "   "
+	}
+}
+
+func (l *LFS) oilWarning(out *telemetry.TelemetryField) {
+	out.Type = telemetry.DataTypeUINT8
+	out.Raw = 0
+	if l.lightOn(dlOilWarn) {
+		out.Raw = 1
+	}
+}
+
+// OutSim
+
+func (l *LFS) simFloat(off int) func(*telemetry.TelemetryField) {
+	return func(out *telemetry.TelemetryField) {
+		out.SetFloat32(l.sim.f32(off))
+	}
+}
+
+// accelLat and accelLong turn OutSim's acceleration, in the world's axes, to
+// the car's. The car faces (-sin, cos) of the heading, lateral is positive to
+// the left as it is for iRacing
+func (l *LFS) accelLat(out *telemetry.TelemetryField) {
+	x, y, heading := l.sim.f32(simAccel), l.sim.f32(simAccel+4), float64(l.sim.f32(simHeading))
+	out.SetFloat32(-x*float32(math.Cos(heading)) - y*float32(math.Sin(heading)))
+}
+
+func (l *LFS) accelLong(out *telemetry.TelemetryField) {
+	x, y, heading := l.sim.f32(simAccel), l.sim.f32(simAccel+4), float64(l.sim.f32(simHeading))
+	out.SetFloat32(-x*float32(math.Sin(heading)) + y*float32(math.Cos(heading)))
+}
+
+// accelVert is the same on both, the car's pitch and roll are left out
+func (l *LFS) accelVert(out *telemetry.TelemetryField) {
+	out.SetFloat32(l.sim.f32(simAccel + 8))
+}
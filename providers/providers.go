@@ -2,10 +2,17 @@
 package providers
 
 import (
+	"fmt"
 	"log/slog"
+	"path/filepath"
 
+	"esdi/providers/assetto"
 	"esdi/providers/beamng"
+	"esdi/providers/f1"
 	"esdi/providers/iracing"
+	"esdi/providers/lfs"
+	"esdi/providers/replay"
+	"esdi/recording"
 	"esdi/telemetry"
 )
 
@@ -17,12 +24,21 @@ type Provider struct {
 }
 
 var Providers = map[string]Provider{
+	assetto.NAME: {
+		Name: assetto.NAME,
+	},
 	beamng.NAME: {
 		Name: beamng.NAME,
 	},
+	f1.NAME: {
+		Name: f1.NAME,
+	},
 	iracing.NAME: {
 		Name: iracing.NAME,
 	},
+	lfs.NAME: {
+		Name: lfs.NAME,
+	},
 }
 
 func NewIRacingProvider(logger *slog.Logger, source string,
@@ -33,6 +49,77 @@ func NewIRacingProvider(logger *slog.Logger, source string,
 	return provider
 }
 
+// NewPlayback plays an iRacing recording, see iracing.Playback
+func NewPlayback(logger *slog.Logger, source string) (telemetry.PlaybackProvider, error) {
+	provider, err := iracing.NewPlayback(logger, source)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// NewReplayProvider plays a recording ESDI made, see replay.Replay
+func NewReplayProvider(logger *slog.Logger, source string) (telemetry.LifecycleProvider, error) {
+	provider, err := replay.NewReplayProvider(logger, source)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// OpenFrames opens a recording to be read frame by frame, an .ibt or one ESDI
+// made
+func OpenFrames(logger *slog.Logger, path string) (telemetry.FrameSource, error) {
+	switch filepath.Ext(path) {
+	case ".ibt":
+		provider, err := iracing.NewIRacingProvider(logger, path, "", "")
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case recording.Ext:
+		provider, err := replay.NewReplayProvider(logger, path)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	}
+
+	return nil, fmt.Errorf("%s is not an .ibt or %s recording", path, recording.Ext)
+}
+
+// NewAssettoProvider reads Assetto Corsa's or ACC's shared memory pages from
+// dir, assetto.DefaultDir when empty
+func NewAssettoProvider(logger *slog.Logger, dir string) telemetry.LifecycleProvider {
+	provider, _ := assetto.NewAssettoProvider(logger, dir)
+
+	return provider
+}
+
+// NewF1Provider listens for the F1 games' UDP telemetry on ip:port,
+// f1.DefaultUDPPort being the game's default
+func NewF1Provider(logger *slog.Logger, ip string, port int) (telemetry.LifecycleProvider, error) {
+	provider, err := f1.NewF1Provider(logger, ip, port)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// NewLFSProvider listens for Live for Speed's OutGauge and OutSim packets on
+// ip:port, lfs.DefaultUDPPort being the one we ask LFS to send them to
+func NewLFSProvider(logger *slog.Logger, ip string, port int) (telemetry.LifecycleProvider, error) {
+	provider, err := lfs.NewLFSProvider(logger, ip, port)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
 func NewBeamNGProvider(ip string, port int) telemetry.TelemetryProvider {
 	provider, _ := beamng.NewBeamNGProvider(ip, port)
 
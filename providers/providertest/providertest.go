@@ -0,0 +1,58 @@
+// Package providertest has the checks the tests of the providers share, every
+// provider is checked against the registry the same way
+package providertest
+
+import (
+	"testing"
+
+	"esdi/telemetry"
+)
+
+// SubscribeAll subscribes to every field the source supplies, returns them
+func SubscribeAll(subscribe func(map[int16]telemetry.FieldID), source string) []telemetry.FieldDef {
+	fields := telemetry.FieldsFromSource(source)
+	requests := make(map[int16]telemetry.FieldID, len(fields))
+	for k, def := range fields {
+		requests[int16(k)] = def.ID
+	}
+
+	subscribe(requests)
+
+	return fields
+}
+
+// CheckType fails when the value isn't of the type the registry has for it,
+// arrays also have to have the elements it has
+func CheckType(t *testing.T, def telemetry.FieldDef, tf telemetry.TelemetryField) {
+	t.Helper()
+
+	if tf.Type != def.Type {
+		t.Errorf("%s: expected type %d, got %d (%s)", def.Key, def.Type, tf.Type, tf.String())
+		return
+	}
+
+	if def.Type == telemetry.DataTypeARRAY && (tf.Elem != def.Elem || tf.Len() != def.Len) {
+		t.Errorf("%s: expected %d elements of type %d, got %d of type %d",
+			def.Key, def.Len, def.Elem, tf.Len(), tf.Elem)
+	}
+}
+
+// CheckTypes is CheckType for every field
+func CheckTypes(t *testing.T, td *telemetry.TelemetryData, fields []telemetry.FieldDef) {
+	t.Helper()
+
+	for _, def := range fields {
+		CheckType(t, def, td.Values[def.ID])
+	}
+}
+
+// CheckValues fails for every field that doesn't read as expected
+func CheckValues(t *testing.T, td *telemetry.TelemetryData, expect map[telemetry.FieldID]string) {
+	t.Helper()
+
+	for id, want := range expect {
+		if got := td.Values[id].String(); got != want {
+			t.Errorf("%s: expected %q, got %q", telemetry.GetFieldName(id), want, got)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package replay is the provider that plays back the recordings ESDI makes of
+// any sim, see the recording package
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"esdi/recording"
+	"esdi/telemetry"
+)
+
+// Replay plays a recording at the pace it was recorded at. The recorded values
+// go on the fields they were recorded for and the virtual fields are computed
+// again, so the devices can't tell it apart from the sim
+type Replay struct {
+	logger *slog.Logger
+	source string
+	reader *recording.Reader
+
+	// Data Handling
+	mut  sync.Mutex
+	data *telemetry.TelemetryData
+
+	// Lifecycle
+	telemetry.SourceLifecycle
+	started bool
+	session uint32 // Recorded session of the last frame
+
+	// Stream
+	streamCh     chan telemetry.TelemetryData
+	streamCancel context.CancelFunc
+}
+
+func NewReplayProvider(logger *slog.Logger, source string) (*Replay, error) {
+	if source == "" {
+		return nil, errors.New("replay needs a recording")
+	}
+
+	reader, err := recording.Open(source)
+	if err != nil {
+		return nil, err
+	}
+
+	h := reader.Header()
+	logger.Info(fmt.Sprintf("replaying %s: %s at %s, recorded %s", h.Provider, h.Car,
+		h.Track, h.Start.Format(time.DateTime)))
+
+	r := &Replay{
+		logger:   logger,
+		source:   source,
+		reader:   reader,
+		data:     telemetry.NewTelemetryData(),
+		streamCh: make(chan telemetry.TelemetryData, 1),
+	}
+	r.SourceLifecycle = telemetry.NewSourceLifecycle(logger, h.Provider, telemetry.SourceConnected, &r.mut,
+		r.data)
+
+	return r, nil
+}
+
+// Header describes the recording being played
+func (r *Replay) Header() recording.Header {
+	return r.reader.Header()
+}
+
+// Telemetry Provider Interface
+
+// Name is the provider the recording was made from
+func (r *Replay) Name() string {
+	return r.reader.Header().Provider
+}
+
+func (r *Replay) Stream() (<-chan telemetry.TelemetryData, error) {
+	var ctx context.Context
+	ctx, r.streamCancel = context.WithCancel(context.Background())
+
+	r.stream(ctx)
+
+	return r.streamCh, nil
+}
+
+func (r *Replay) StopStream() {
+	if r.streamCancel == nil {
+		return
+	}
+
+	r.streamCancel()
+	r.streamCancel = nil
+}
+
+// Subscribe binds the fields like the sims do, the fields that weren't recorded
+// are unused
+func (r *Replay) Subscribe(requestFields map[int16]telemetry.FieldID) {
+	plan, err := telemetry.PlanSubscription(r.logger, requestFields)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("failed to plan some fields: %v", err))
+	}
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	plan.Apply(r.data)
+
+	for _, id := range plan.Primitives {
+		r.data.ActiveBinds = append(r.data.ActiveBinds, telemetry.BoundField{ID: id})
+		r.updateField(id)
+	}
+}
+
+// Internal
+
+// stream reads the frames and publishes each one when its time comes. The end
+// of the recording is reported as a lost source
+func (r *Replay) stream(ctx context.Context) {
+	r.data.InitialTime = time.Now()
+
+	r.Publish(nil)
+
+	go func() {
+		// Where the recording starts on our clock, the frames are timed from it
+		start := time.Now().Add(-r.reader.Time())
+
+		for {
+			err := r.next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					r.logger.Error(fmt.Sprintf("failed to read %s: %v", r.source, err))
+				}
+
+				r.Lost(err)
+				<-ctx.Done()
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(start.Add(r.reader.Time()))):
+			}
+
+			sessionChanged := r.readData(time.Now())
+
+			if r.State() != telemetry.SourceStreaming {
+				r.SetState(telemetry.SourceStreaming, nil)
+			} else if sessionChanged {
+				r.Publish(nil)
+			}
+
+			// Publish data
+			select {
+			case r.streamCh <- *r.data:
+			default:
+				// skip this data, don't allow publishers to lag behind
+			}
+		}
+	}()
+}
+
+// next reads the next frame, Subscribe reads the values the reader keeps
+func (r *Replay) next() error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	return r.reader.Next()
+}
+
+// ReadFrame reads the next frame without waiting for its time, see
+// telemetry.FrameSource
+func (r *Replay) ReadFrame() (*telemetry.TelemetryData, time.Duration, error) {
+	if err := r.next(); err != nil {
+		return nil, 0, err
+	}
+
+	r.readData(r.data.InitialTime.Add(r.reader.Time()))
+
+	return r.data, r.reader.Time(), nil
+}
+
+// Close closes the recording, the provider can't be used anymore
+func (r *Replay) Close() error {
+	return r.reader.Close()
+}
+
+// readData moves the data to the frame the reader is at, now is when it was
+// read. Reports if the session changed
+func (r *Replay) readData(now time.Time) bool {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	for _, b := range r.data.ActiveBinds {
+		r.updateField(b.ID)
+	}
+	r.data.Cars = r.reader.Cars()
+
+	sessionChanged := r.started && r.reader.Session() != r.session
+	r.session = r.reader.Session()
+	r.started = true
+
+	if sessionChanged {
+		r.data.SessionChanged()
+	}
+
+	for _, vBind := range r.data.VirtualBinds {
+		vBind.Process(r.data)
+	}
+
+	r.data.PenultimateDataPoll = r.data.LastDataPoll
+	r.data.LastDataPoll = now
+
+	return sessionChanged
+}
+
+// updateField copies the recorded value of a field, keeping the windows it goes
+// to
+func (r *Replay) updateField(id telemetry.FieldID) {
+	out := &r.data.Values[id]
+
+	v, ok := r.reader.Value(id)
+	if !ok {
+		out.Unused()
+		return
+	}
+
+	v.IDs = out.IDs
+	*out = v
+}
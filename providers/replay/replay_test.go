@@ -0,0 +1,151 @@
+package replay
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"esdi/recording"
+	"esdi/telemetry"
+)
+
+// record writes a session of three frames, 10ms apart, with the speed going up
+// and a new session on the last one
+func record(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "session"+recording.Ext)
+
+	w, err := recording.Create(path, telemetry.SourceBeamNG)
+	if err != nil {
+		t.Fatalf("failed to create the recording: %v", err)
+	}
+
+	var td telemetry.TelemetryData
+	td.ActiveBinds = []telemetry.BoundField{{ID: telemetry.Speed}, {ID: telemetry.Gear}}
+	td.Values[telemetry.Gear] = telemetry.TelemetryField{Type: telemetry.DataTypeINT8, Raw: 2}
+
+	start := time.Now()
+	for k := range 3 {
+		td.Values[telemetry.Speed].SetFloat32(float32(10 * (k + 1)))
+		td.Session = uint32(k / 2)
+
+		if err := w.WriteFrame(&td, start.Add(time.Duration(k)*10*time.Millisecond)); err != nil {
+			t.Fatalf("failed to write frame %d: %v", k, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close the recording: %v", err)
+	}
+
+	return path
+}
+
+func Test_Replay(t *testing.T) {
+	r, err := NewReplayProvider(slog.New(slog.DiscardHandler), record(t))
+	if err != nil {
+		t.Fatalf("failed to open the recording: %v", err)
+	}
+
+	if r.Name() != telemetry.SourceBeamNG {
+		t.Errorf("expected the recorded provider, got %s", r.Name())
+	}
+
+	r.Subscribe(map[int16]telemetry.FieldID{
+		0x01: telemetry.Speed,
+		0x02: telemetry.Gear,
+		0x03: telemetry.RPM,
+	})
+
+	frames, _ := r.Stream()
+	t.Cleanup(r.StopStream)
+
+	var got []telemetry.TelemetryData
+	var states []telemetry.SourceState
+	for len(states) == 0 || states[len(states)-1] != telemetry.SourceDisconnected {
+		select {
+		case data := <-frames:
+			got = append(got, data)
+		case ev := <-r.Events():
+			states = append(states, ev.State)
+			if ev.State == telemetry.SourceDisconnected && !errors.Is(ev.Err, io.EOF) {
+				t.Errorf("expected the recording to end, got %v", ev.Err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out, got the states %v", states)
+		}
+	}
+
+	// The last frame is sent before the end of the recording is
+	select {
+	case data := <-frames:
+		got = append(got, data)
+	default:
+	}
+
+	// The frames are 10ms apart, the listener keeps up with all of them
+	if len(got) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(got))
+	}
+
+	for k, data := range got {
+		speed := data.Values[telemetry.Speed]
+		if speed.Float() != float64(10*(k+1)) {
+			t.Errorf("frame %d: expected the speed at %d, got %s", k, 10*(k+1), speed.String())
+		}
+		if len(speed.IDs) != 1 || speed.IDs[0] != 0x01 {
+			t.Errorf("frame %d: expected the speed to go to window 1, got %v", k, speed.IDs)
+		}
+
+		if gear := data.Values[telemetry.Gear]; gear.String() != "2" {
+			t.Errorf("frame %d: expected the gear to be 2, got %s", k, gear.String())
+		}
+
+		// Not recorded
+		if rpm := data.Values[telemetry.RPM]; rpm.String() != "-" {
+			t.Errorf("frame %d: expected the RPM to be unused, got %s", k, rpm.String())
+		}
+	}
+
+	if got[1].Session != 0 || got[2].Session != 1 {
+		t.Errorf("expected a new session on the last frame, got %d and %d", got[1].Session,
+			got[2].Session)
+	}
+
+	if got[2].LastDataPoll.Sub(got[0].LastDataPoll) < 20*time.Millisecond {
+		t.Errorf("expected the frames at the pace they were recorded, got %s",
+			got[2].LastDataPoll.Sub(got[0].LastDataPoll))
+	}
+}
+
+func Test_ReplayFrames(t *testing.T) {
+	r, err := NewReplayProvider(slog.New(slog.DiscardHandler), record(t))
+	if err != nil {
+		t.Fatalf("failed to open the recording: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+
+	r.Subscribe(map[int16]telemetry.FieldID{0x01: telemetry.Speed})
+
+	for k := range 3 {
+		td, at, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("failed to read frame %d: %v", k, err)
+		}
+
+		// Read as fast as we can, on the clock of the recording
+		if expect := time.Duration(k) * 10 * time.Millisecond; at != expect ||
+			td.LastDataPoll.Sub(td.InitialTime) != expect {
+			t.Errorf("frame %d: expected it at %s, got %s", k, expect, at)
+		}
+		if speed := td.Values[telemetry.Speed]; speed.Float() != float64(10*(k+1)) {
+			t.Errorf("frame %d: expected the speed at %d, got %s", k, 10*(k+1), speed.String())
+		}
+	}
+
+	if _, _, err := r.ReadFrame(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the end of the recording, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+// Package recording writes the telemetry of any provider to a file and reads it
+// back, so sessions can be replayed without the sim.
+//
+// A recording is append only:
+//
+//	"ESDIREC" + version    8 bytes
+//	header length          uint32
+//	header                 YAML, see Header
+//	frames...
+//
+// Every frame carries only the values that changed since the frame before it,
+// the first one has all of them:
+//
+//	frame length           uint32, of what follows
+//	time                   int64, nanoseconds since Header.Start
+//	session                uint32, TelemetryData.Session
+//	number of values       uint16
+//	values...              uint16 index on Header.Fields + the packed value,
+//	                       see telemetry.TelemetryField.PackValue
+//	number of cars         uint16, 0xffff when they didn't change
+//	cars...                see packCar
+//
+// All the numbers are little endian
+package recording
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	conv "esdi/conversions"
+	"esdi/telemetry"
+)
+
+// Ext is the extension of the recordings
+const Ext = ".esdirec"
+
+const (
+	magic   = "ESDIREC"
+	version = 1
+
+	// carsUnchanged is the number of cars of a frame whose cars are the ones of
+	// the frame before
+	carsUnchanged = math.MaxUint16
+)
+
+var (
+	ErrNotRecording = errors.New("not an ESDI recording")
+	errShortFrame   = errors.New("frame is cut short")
+)
+
+// Header describes the recording, it is written once at the start
+type Header struct {
+	Version  int       `yaml:"version"`
+	Provider string    `yaml:"provider"`
+	Car      string    `yaml:"car"`
+	Track    string    `yaml:"track"`
+	Start    time.Time `yaml:"start"`
+	// Fields is the registry when the recording was made, the frames refer to
+	// the fields by their index here so recordings outlive changes to it
+	Fields []FieldInfo `yaml:"fields"`
+}
+
+// FieldInfo is a field as the registry described it
+type FieldInfo struct {
+	Key  string             `yaml:"key"`
+	Unit conv.Unit          `yaml:"unit,omitempty"`
+	Type telemetry.DataType `yaml:"type"`
+}
+
+// snapshotRegistry describes every field of the registry, in the order of
+// their IDs
+func snapshotRegistry() []FieldInfo {
+	defs := telemetry.Fields()
+	fields := make([]FieldInfo, len(defs))
+	for k, def := range defs {
+		fields[k] = FieldInfo{Key: def.Key, Unit: def.Unit, Type: def.Type}
+	}
+
+	return fields
+}
+
+// packCar packs a car of the standings:
+// int16 index, the number and the name as strings of up to 255 bytes, int32
+// lap, float64 lap distance, estimated time and estimated lap time, then a
+// byte with the player, pace car and spectator flags
+func packCar(dest []byte, c *telemetry.CarState) []byte {
+	dest = binary.LittleEndian.AppendUint16(dest, uint16(c.Idx))
+	dest = packString(dest, c.Number)
+	dest = packString(dest, c.Name)
+	dest = binary.LittleEndian.AppendUint32(dest, uint32(int32(c.Lap)))
+	dest = binary.LittleEndian.AppendUint64(dest, math.Float64bits(c.LapDistPct))
+	dest = binary.LittleEndian.AppendUint64(dest, math.Float64bits(c.EstTime))
+	dest = binary.LittleEndian.AppendUint64(dest, math.Float64bits(c.EstLapTime))
+
+	var flags uint8
+	for k, set := range []bool{c.IsPlayer, c.IsPaceCar, c.IsSpectator} {
+		if set {
+			flags |= 1 << k
+		}
+	}
+
+	return append(dest, flags)
+}
+
+// unpackCar reads a car packed by packCar, returns how many bytes it read
+func unpackCar(src []byte, c *telemetry.CarState) (int, error) {
+	var n, l int
+	var err error
+
+	if len(src) < 2 {
+		return 0, errShortFrame
+	}
+	c.Idx = int(int16(binary.LittleEndian.Uint16(src)))
+	n += 2
+
+	if c.Number, l, err = unpackString(src[n:]); err != nil {
+		return 0, err
+	}
+	n += l
+
+	if c.Name, l, err = unpackString(src[n:]); err != nil {
+		return 0, err
+	}
+	n += l
+
+	if len(src) < n+4+3*8+1 {
+		return 0, errShortFrame
+	}
+	c.Lap = int(int32(binary.LittleEndian.Uint32(src[n:])))
+	n += 4
+	c.LapDistPct = math.Float64frombits(binary.LittleEndian.Uint64(src[n:]))
+	n += 8
+	c.EstTime = math.Float64frombits(binary.LittleEndian.Uint64(src[n:]))
+	n += 8
+	c.EstLapTime = math.Float64frombits(binary.LittleEndian.Uint64(src[n:]))
+	n += 8
+
+	flags := src[n]
+	c.IsPlayer = flags&1 != 0
+	c.IsPaceCar = flags&2 != 0
+	c.IsSpectator = flags&4 != 0
+
+	return n + 1, nil
+}
+
+func packString(dest []byte, s string) []byte {
+	l := min(len(s), math.MaxUint8)
+
+	dest = append(dest, uint8(l))
+	return append(dest, s[:l]...)
+}
+
+func unpackString(src []byte) (string, int, error) {
+	if len(src) < 1 || len(src) < 1+int(src[0]) {
+		return "", 0, errShortFrame
+	}
+
+	l := int(src[0])
+	return string(src[1 : 1+l]), 1 + l, nil
+}
@@ -0,0 +1,190 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"esdi/telemetry"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Reader reads the frames of a recording back. It keeps the values as of the
+// last frame read, by the IDs the fields have in the registry today. Fields the
+// registry no longer has are dropped
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer
+	header Header
+	ids    []telemetry.FieldID // Registry ID of each field of the header
+	known  []bool              // If the registry still has the field
+
+	time    time.Duration
+	session uint32
+	values  [telemetry.MaxFields]telemetry.TelemetryField
+	seen    [telemetry.MaxFields]bool
+	cars    []telemetry.CarState
+	buf     []byte
+}
+
+// Open opens the recording at path and reads its header
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r.closer = f
+
+	return r, nil
+}
+
+// NewReader reads a recording from r, starting with its header
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{r: bufio.NewReader(r)}
+
+	var start [len(magic) + 1 + 4]byte
+	if _, err := io.ReadFull(rd.r, start[:]); err != nil || string(start[:len(magic)]) != magic {
+		return nil, ErrNotRecording
+	}
+	if v := start[len(magic)]; v != version {
+		return nil, fmt.Errorf("recording version %d is not supported", v)
+	}
+
+	out := make([]byte, binary.LittleEndian.Uint32(start[len(magic)+1:]))
+	if _, err := io.ReadFull(rd.r, out); err != nil {
+		return nil, fmt.Errorf("failed to read the recording header: %w", err)
+	}
+	if err := yaml.Unmarshal(out, &rd.header); err != nil {
+		return nil, fmt.Errorf("failed to read the recording header: %w", err)
+	}
+
+	rd.ids = make([]telemetry.FieldID, len(rd.header.Fields))
+	rd.known = make([]bool, len(rd.header.Fields))
+	for k, f := range rd.header.Fields {
+		rd.ids[k], rd.known[k] = telemetry.GetFieldIDByKey(f.Key)
+	}
+
+	return rd, nil
+}
+
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next reads the next frame, io.EOF when there are no more. A frame cut short
+// by a recording that didn't close is taken as the end too
+func (r *Reader) Next() error {
+	var length [4]byte
+	if _, err := io.ReadFull(r.r, length[:]); err != nil {
+		return io.EOF
+	}
+
+	l := int(binary.LittleEndian.Uint32(length[:]))
+	if cap(r.buf) < l {
+		r.buf = make([]byte, l)
+	}
+	buf := r.buf[:l]
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return io.EOF
+	}
+
+	return r.readFrame(buf)
+}
+
+// Time is when the last frame was read, from the start of the recording
+func (r *Reader) Time() time.Duration {
+	return r.time
+}
+
+// Session is the TelemetryData.Session of the last frame
+func (r *Reader) Session() uint32 {
+	return r.session
+}
+
+// Value returns the field as of the last frame, false if the recording didn't
+// have it yet
+func (r *Reader) Value(id telemetry.FieldID) (telemetry.TelemetryField, bool) {
+	if int(id) >= len(r.values) || !r.seen[id] {
+		return telemetry.TelemetryField{}, false
+	}
+
+	return r.values[id], true
+}
+
+// Cars returns the cars as of the last frame, the slice is replaced when they
+// change and is never written to
+func (r *Reader) Cars() []telemetry.CarState {
+	return r.cars
+}
+
+// Close closes the file the reader was opened with
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+
+	return r.closer.Close()
+}
+
+func (r *Reader) readFrame(buf []byte) error {
+	if len(buf) < 8+4+2 {
+		return errShortFrame
+	}
+
+	r.time = time.Duration(binary.LittleEndian.Uint64(buf))
+	r.session = binary.LittleEndian.Uint32(buf[8:])
+	count := int(binary.LittleEndian.Uint16(buf[12:]))
+	n := 14
+
+	var dropped telemetry.TelemetryField
+	for range count {
+		if len(buf) < n+2 {
+			return errShortFrame
+		}
+		idx := int(binary.LittleEndian.Uint16(buf[n:]))
+		n += 2
+
+		out := &dropped
+		if idx < len(r.known) && r.known[idx] {
+			out = &r.values[r.ids[idx]]
+			r.seen[r.ids[idx]] = true
+		}
+
+		l, err := out.UnpackValue(buf[n:])
+		if err != nil {
+			return fmt.Errorf("failed to read the value of field %d: %w", idx, err)
+		}
+		n += l
+	}
+
+	if len(buf) < n+2 {
+		return errShortFrame
+	}
+	cars := int(binary.LittleEndian.Uint16(buf[n:]))
+	n += 2
+
+	if cars == carsUnchanged {
+		return nil
+	}
+
+	r.cars = make([]telemetry.CarState, cars)
+	for k := range r.cars {
+		l, err := unpackCar(buf[n:], &r.cars[k])
+		if err != nil {
+			return err
+		}
+		n += l
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package recording
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"esdi/telemetry"
+)
+
+// testFrames are a few frames of a session, the second one only changes the
+// speed and the last one starts a new session with other cars
+func testFrames() []telemetry.TelemetryData {
+	bind := func(ids ...telemetry.FieldID) []telemetry.BoundField {
+		binds := make([]telemetry.BoundField, len(ids))
+		for k, id := range ids {
+			binds[k] = telemetry.BoundField{ID: id}
+		}
+		return binds
+	}
+
+	var first telemetry.TelemetryData
+	first.ActiveBinds = bind(telemetry.Speed, telemetry.Gear, telemetry.CarName,
+		telemetry.TrackName, telemetry.CarIdxLap)
+	first.Values[telemetry.Speed].SetFloat32(41.5)
+	first.Values[telemetry.Gear] = telemetry.TelemetryField{Type: telemetry.DataTypeINT8, Raw: 3}
+	first.Values[telemetry.CarName] = telemetry.TelemetryField{Type: telemetry.DataTypeSTRING, Str: "Mazda MX-5"}
+	first.Values[telemetry.TrackName] = telemetry.TelemetryField{Type: telemetry.DataTypeSTRING, Str: "Okayama"}
+	first.Values[telemetry.CarIdxLap].SetArray(telemetry.DataTypeINT32, []uint64{2, 1})
+	first.Cars = []telemetry.CarState{
+		{Idx: 0, Number: "64", Name: "Eduardo Silva", Lap: 2, LapDistPct: 0.25, EstTime: 22.5, IsPlayer: true},
+		{Idx: 1, Number: "0", Name: "Pace Car", Lap: -1, LapDistPct: -1, IsPaceCar: true},
+	}
+
+	second := first
+	second.Values[telemetry.Speed].SetFloat32(43)
+
+	third := second
+	third.Session = 1
+	third.Values[telemetry.Gear].Raw = 4
+	third.Cars = []telemetry.CarState{{Idx: 3, Number: "7", Lap: 0, EstLapTime: 95.25}}
+
+	return []telemetry.TelemetryData{first, second, third}
+}
+
+func Test_RoundTrip(t *testing.T) {
+	start := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	frames := testFrames()
+
+	var file bytes.Buffer
+	w := NewWriter(&file, telemetry.SourceIRacing)
+	for k := range frames {
+		at := start.Add(time.Duration(k) * 16 * time.Millisecond)
+		if err := w.WriteFrame(&frames[k], at); err != nil {
+			t.Fatalf("failed to write frame %d: %v", k, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close the recording: %v", err)
+	}
+
+	r, err := NewReader(&file)
+	if err != nil {
+		t.Fatalf("failed to open the recording: %v", err)
+	}
+
+	h := r.Header()
+	if h.Provider != telemetry.SourceIRacing || h.Car != "Mazda MX-5" || h.Track != "Okayama" ||
+		!h.Start.Equal(start) || len(h.Fields) != telemetry.FieldCount() {
+		t.Errorf("unexpected header %+v", h)
+	}
+
+	for k, expect := range frames {
+		if err := r.Next(); err != nil {
+			t.Fatalf("failed to read frame %d: %v", k, err)
+		}
+
+		if at := time.Duration(k) * 16 * time.Millisecond; r.Time() != at {
+			t.Errorf("frame %d: expected it at %s, got %s", k, at, r.Time())
+		}
+		if r.Session() != expect.Session {
+			t.Errorf("frame %d: expected session %d, got %d", k, expect.Session, r.Session())
+		}
+
+		for _, b := range expect.ActiveBinds {
+			v, ok := r.Value(b.ID)
+			if !ok || v.String() != expect.Values[b.ID].String() {
+				t.Errorf("frame %d: expected %s to be %s, got %s", k, telemetry.GetFieldName(b.ID),
+					expect.Values[b.ID].String(), v.String())
+			}
+		}
+
+		cars := r.Cars()
+		if len(cars) != len(expect.Cars) {
+			t.Fatalf("frame %d: expected %d cars, got %d", k, len(expect.Cars), len(cars))
+		}
+		for c := range cars {
+			if cars[c] != expect.Cars[c] {
+				t.Errorf("frame %d: expected car %+v, got %+v", k, expect.Cars[c], cars[c])
+			}
+		}
+	}
+
+	if _, ok := r.Value(telemetry.RPM); ok {
+		t.Errorf("expected the RPM not to be recorded")
+	}
+
+	if err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the end of the recording, got %v", err)
+	}
+}
+
+func Test_Deltas(t *testing.T) {
+	frames := testFrames()
+	now := time.Now()
+
+	var file bytes.Buffer
+	w := NewWriter(&file, telemetry.SourceBeamNG)
+	if err := w.WriteFrame(&frames[0], now); err != nil {
+		t.Fatalf("failed to write the first frame: %v", err)
+	}
+	w.Flush()
+	first := file.Len()
+
+	if err := w.WriteFrame(&frames[1], now); err != nil {
+		t.Fatalf("failed to write the second frame: %v", err)
+	}
+	w.Flush()
+
+	// length, time, session, a value count of 1, the speed and the cars marker
+	if l := file.Len() - first; l != 4+8+4+2+(2+1+4)+2 {
+		t.Errorf("expected only the speed in the second frame, it took %d bytes", l)
+	}
+
+	// A recording cut short ends at the last whole frame
+	cut := bytes.NewReader(file.Bytes()[:file.Len()-3])
+	r, err := NewReader(cut)
+	if err != nil {
+		t.Fatalf("failed to open the recording: %v", err)
+	}
+	if err := r.Next(); err != nil {
+		t.Fatalf("failed to read the first frame: %v", err)
+	}
+	if err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the frame cut short to end the recording, got %v", err)
+	}
+
+	if _, err := NewReader(bytes.NewReader([]byte("IRSDK"))); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("expected %v, got %v", ErrNotRecording, err)
+	}
+}
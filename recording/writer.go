@@ -0,0 +1,186 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"esdi/telemetry"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Writer appends the frames of a provider to a recording. The header is written
+// with the first frame, that is when we know the car and the track
+type Writer struct {
+	w        *bufio.Writer
+	closer   io.Closer
+	provider string
+
+	started bool
+	start   time.Time
+	prev    [telemetry.MaxFields]telemetry.TelemetryField
+	sent    [telemetry.MaxFields]bool
+	cars    []telemetry.CarState
+	buf     []byte
+}
+
+// Create starts a recording of the given provider at path, an existing file is
+// replaced
+func Create(path string, provider string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := NewWriter(f, provider)
+	w.closer = f
+
+	return w, nil
+}
+
+// NewWriter writes a recording of the given provider to w
+func NewWriter(w io.Writer, provider string) *Writer {
+	return &Writer{
+		w:        bufio.NewWriter(w),
+		provider: provider,
+	}
+}
+
+// WriteFrame appends the values the provider bound and its cars. at is when
+// the frame was read, frames are expected in order
+func (w *Writer) WriteFrame(td *telemetry.TelemetryData, at time.Time) error {
+	if !w.started {
+		if err := w.writeHeader(td, at); err != nil {
+			return err
+		}
+	}
+
+	buf := w.buf[:0]
+	buf = binary.LittleEndian.AppendUint32(buf, 0) // Length, filled in below
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(at.Sub(w.start)))
+	buf = binary.LittleEndian.AppendUint32(buf, td.Session)
+
+	countAt := len(buf)
+	buf = binary.LittleEndian.AppendUint16(buf, 0)
+
+	var count uint16
+	for _, bind := range td.ActiveBinds {
+		tf := &td.Values[bind.ID]
+		if tf.Type == telemetry.DataTypeTABLE || (w.sent[bind.ID] && sameValue(&w.prev[bind.ID], tf)) {
+			continue
+		}
+
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(bind.ID))
+		buf = tf.PackValue(buf)
+		count++
+
+		w.prev[bind.ID] = *tf
+		w.sent[bind.ID] = true
+	}
+	binary.LittleEndian.PutUint16(buf[countAt:], count)
+
+	if sameCars(w.cars, td.Cars) {
+		buf = binary.LittleEndian.AppendUint16(buf, carsUnchanged)
+	} else {
+		l := min(len(td.Cars), carsUnchanged-1)
+
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(l))
+		for k := range td.Cars[:l] {
+			buf = packCar(buf, &td.Cars[k])
+		}
+		w.cars = td.Cars
+	}
+
+	binary.LittleEndian.PutUint32(buf, uint32(len(buf)-4))
+	w.buf = buf
+
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Flush writes the buffered frames to the file
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Close flushes the frames and closes the file the writer was created with
+func (w *Writer) Close() error {
+	err := w.w.Flush()
+
+	if w.closer != nil {
+		if cerr := w.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+func (w *Writer) writeHeader(td *telemetry.TelemetryData, at time.Time) error {
+	header := Header{
+		Version:  version,
+		Provider: w.provider,
+		Car:      stringValue(td, telemetry.CarName),
+		Track:    stringValue(td, telemetry.TrackName),
+		Start:    at,
+		Fields:   snapshotRegistry(),
+	}
+
+	out, err := yaml.Marshal(&header)
+	if err != nil {
+		return fmt.Errorf("failed to write the recording header: %w", err)
+	}
+
+	buf := append([]byte(magic), version)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(out)))
+	buf = append(buf, out...)
+
+	if _, err := w.w.Write(buf); err != nil {
+		return err
+	}
+
+	w.started = true
+	w.start = at
+
+	return nil
+}
+
+// stringValue is the value of a string field, empty when the provider didn't
+// fill it in
+func stringValue(td *telemetry.TelemetryData, id telemetry.FieldID) string {
+	if tf := &td.Values[id]; tf.Type == telemetry.DataTypeSTRING {
+		return tf.Str
+	}
+
+	return ""
+}
+
+func sameValue(a, b *telemetry.TelemetryField) bool {
+	if a.Type != b.Type {
+		return false
+	}
+
+	switch a.Type {
+	case telemetry.DataTypeSTRING:
+		return a.Str == b.Str
+	case telemetry.DataTypeARRAY:
+		return a.Elem == b.Elem && slices.Equal(a.Elems, b.Elems)
+	}
+
+	return a.Raw == b.Raw
+}
+
+// sameCars reports if the providers handed us the same cars again, they replace
+// the slice when the cars change
+func sameCars(a, b []telemetry.CarState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	return len(a) == 0 || &a[0] == &b[0]
+}
@@ -7,6 +7,7 @@ import (
 	"sync/atomic"
 
 	"esdi/cdashdisplay"
+	"esdi/config"
 	helper "esdi/helpers"
 	"esdi/peripheral"
 	"esdi/telemetry"
@@ -45,6 +46,8 @@ func (cds *CDashService) FindDevice() {
 		return
 	}
 
+	display.Units = config.GetCfg().Units
+
 	cds.CDash = display
 	cds.Logger.Info("found cdashdisplay on: " + display.WT.Cfg.Name)
 	cds.Messages <- "found cdashdisplay on: " + display.WT.Cfg.Name + "\n"
@@ -2,13 +2,35 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"esdi/providers"
+	"esdi/recording"
 	telem "esdi/telemetry"
 )
 
+const (
+	// recorderBuffer holds a few seconds of frames, the file can stall the
+	// recorder for a while without losing them
+	recorderBuffer = 256
+	// recordedWindows is the first host window ID of the fields we subscribe
+	// only to record them, they count down from here
+	recordedWindows int16 = -2
+)
+
+// recorder is the way to the goroutine writing a recording. The frames go
+// through a buffered channel, stop tells it to write what's left and finish
+type recorder struct {
+	frames chan telem.TelemetryData
+	stop   chan struct{}
+	done   chan error
+}
+
 // TelemetryService will be our base struct to handle telemetry data
 // It should hook to a data sink and handle it like iRacing, BeamNG, AC and so on
 type TelemetryService struct {
@@ -20,13 +42,23 @@ type TelemetryService struct {
 	// Channel for the UI
 	listeners     map[string]chan telem.TelemetryData
 	cancelForward context.CancelFunc
+	// Channels for the state of the provider's source
+	eventListeners map[string]chan telem.SourceEvent
+	// Fields the devices asked for, and the recording of the stream if any
+	fields     map[int16]telem.FieldID
+	subscribed bool
+	recording  string
+	recorder   *recorder
+	// How many times the stream had to wait for the recorder
+	recorderStalls atomic.Int64
 }
 
 func NewTelemetryService(logger *slog.Logger, cdash *CDashService) *TelemetryService {
 	newService := &TelemetryService{
-		logger:    logger,
-		cdash:     cdash,
-		listeners: make(map[string]chan telem.TelemetryData),
+		logger:         logger,
+		cdash:          cdash,
+		listeners:      make(map[string]chan telem.TelemetryData),
+		eventListeners: make(map[string]chan telem.SourceEvent),
 	}
 
 	// Need to instantiate a default provider here
@@ -50,10 +82,21 @@ func (t *TelemetryService) SwitchProvider(newProvider telem.TelemetryProvider) e
 
 	// Assign the new provider
 	t.ativeProvider = newProvider
+	t.subscribed = false
 
 	return nil
 }
 
+// Playback returns the controls of the active provider when it plays a
+// recording
+func (t *TelemetryService) Playback() (telem.PlaybackProvider, bool) {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+
+	pb, ok := t.ativeProvider.(telem.PlaybackProvider)
+	return pb, ok
+}
+
 func (t *TelemetryService) multiplexData(ctx context.Context, dataCh <-chan telem.TelemetryData) {
 	for {
 		select {
@@ -64,6 +107,14 @@ func (t *TelemetryService) multiplexData(ctx context.Context, dataCh <-chan tele
 				return
 			}
 
+			// The recorder can make us wait, not while holding the lock
+			t.mut.RLock()
+			rec := t.recorder
+			t.mut.RUnlock()
+			if rec != nil {
+				t.tapRecorder(ctx, rec, data)
+			}
+
 			t.mut.RLock()
 			for _, ch := range t.listeners {
 				select {
@@ -78,6 +129,49 @@ func (t *TelemetryService) multiplexData(ctx context.Context, dataCh <-chan tele
 	}
 }
 
+// tapRecorder hands a frame to the recorder ahead of the listeners. Unlike them
+// the recorder doesn't miss frames, when it falls behind the stream waits for it
+// and the wait is counted, the provider skips the frames it reads meanwhile
+func (t *TelemetryService) tapRecorder(ctx context.Context, rec *recorder, data telem.TelemetryData) {
+	select {
+	case rec.frames <- data:
+		return
+	default:
+	}
+
+	if t.recorderStalls.Add(1) == 1 {
+		t.logger.Warn("the recorder fell behind, the stream is waiting for it")
+	}
+
+	select {
+	case rec.frames <- data:
+	case <-rec.stop:
+	case <-ctx.Done():
+	}
+}
+
+func (t *TelemetryService) multiplexEvents(ctx context.Context, eventsCh <-chan telem.SourceEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-eventsCh:
+			t.logger.Info("source state changed", "provider", ev.Provider, "state", ev.State.String(),
+				"session", ev.Session, "error", ev.Err)
+
+			t.mut.RLock()
+			for _, ch := range t.eventListeners {
+				select {
+				case ch <- ev:
+				default:
+					// Same as the data, a listener that can't keep up misses it
+				}
+			}
+			t.mut.RUnlock()
+		}
+	}
+}
+
 func (t *TelemetryService) dropActiveProvider() {
 	if t.cancelForward != nil {
 		t.cancelForward()
@@ -114,8 +208,179 @@ func (t *TelemetryService) UnsubscribeListener(id string) {
 	}
 }
 
+// SubscribeEvents registers a listener for the state of the provider's source,
+// only providers that report it send anything
+func (t *TelemetryService) SubscribeEvents(id string, bufferSize int) <-chan telem.SourceEvent {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if ch, exists := t.eventListeners[id]; exists {
+		return ch
+	}
+
+	ch := make(chan telem.SourceEvent, bufferSize)
+	t.eventListeners[id] = ch
+
+	t.logger.Info("New event subscriber registered", "id", id)
+	return ch
+}
+
+func (t *TelemetryService) UnsubscribeEvents(id string) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if ch, exists := t.eventListeners[id]; exists {
+		close(ch)
+		delete(t.eventListeners, id)
+		t.logger.Info("Event subscriber removed", "id", id)
+	}
+}
+
+// SubscribeToFields subscribes the provider to the fields the devices asked for
+// and to every primitive it can supply, for the recordings. Subscribing again
+// builds the virtual fields again and loses what they tracked (fuel history,
+// bests, delta), so starting or stopping a recording must not need it
 func (t *TelemetryService) SubscribeToFields(fields map[int16]telem.FieldID) {
-	t.ativeProvider.Subscribe(fields)
+	t.mut.Lock()
+	t.fields = fields
+	t.subscribed = true
+	t.mut.Unlock()
+
+	t.ativeProvider.Subscribe(withRecordedFields(fields))
+}
+
+// StartRecording writes every frame of the stream to path, see the recording
+// package. The recording has every field the provider can supply, it doesn't
+// depend on the layout of the devices
+func (t *TelemetryService) StartRecording(path string) error {
+	t.mut.Lock()
+	if t.recording != "" {
+		t.mut.Unlock()
+		return fmt.Errorf("already recording to %s", t.recording)
+	}
+
+	w, err := recording.Create(path, t.ativeProvider.Name())
+	if err != nil {
+		t.mut.Unlock()
+		return err
+	}
+
+	t.recording = path
+	t.recorder = &recorder{
+		frames: make(chan telem.TelemetryData, recorderBuffer),
+		stop:   make(chan struct{}),
+		done:   make(chan error, 1),
+	}
+	t.recorderStalls.Store(0)
+	subscribed := t.subscribed
+	t.subscribed = true
+	go t.record(w, t.recorder)
+	t.mut.Unlock()
+
+	// Nothing subscribed yet, there are no virtual fields to lose
+	if !subscribed {
+		t.ativeProvider.Subscribe(withRecordedFields(nil))
+	}
+
+	t.logger.Info("recording started", "path", path)
+	return nil
+}
+
+// StopRecording finishes the recording with the frames it was handed
+func (t *TelemetryService) StopRecording() error {
+	t.mut.Lock()
+	if t.recording == "" {
+		t.mut.Unlock()
+		return errors.New("not recording")
+	}
+
+	path, rec := t.recording, t.recorder
+	t.recording = ""
+	t.recorder = nil
+	t.mut.Unlock()
+
+	close(rec.stop)
+	err := <-rec.done
+
+	t.logger.Info("recording stopped", "path", path, "stalls", t.recorderStalls.Load(), "error", err)
+	return err
+}
+
+// Recording returns where the stream is being recorded to, if it is
+func (t *TelemetryService) Recording() (string, bool) {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+
+	return t.recording, t.recording != ""
+}
+
+// record writes the frames until the recorder is stopped, then the ones still
+// buffered. The first error stops it, the frames after it are dropped and
+// counted
+func (t *TelemetryService) record(w *recording.Writer, rec *recorder) {
+	var err error
+	var dropped int
+	write := func(data telem.TelemetryData) {
+		if err != nil {
+			dropped++
+			return
+		}
+
+		at := data.LastDataPoll
+		if at.IsZero() {
+			at = time.Now()
+		}
+
+		if err = w.WriteFrame(&data, at); err != nil {
+			t.logger.Error("failed to record a frame", "error", err)
+		}
+	}
+
+loop:
+	for {
+		select {
+		case data := <-rec.frames:
+			write(data)
+		case <-rec.stop:
+			break loop
+		}
+	}
+
+	// We are the only reader, what's in the buffer was handed before the stop
+	for len(rec.frames) > 0 {
+		write(<-rec.frames)
+	}
+
+	if dropped > 0 {
+		t.logger.Error("frames were not recorded", "count", dropped)
+	}
+
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+
+	rec.done <- err
+}
+
+// withRecordedFields adds every primitive field to the subscription at host
+// window IDs, the providers bind the ones they supply
+func withRecordedFields(fields map[int16]telem.FieldID) map[int16]telem.FieldID {
+	all := make(map[int16]telem.FieldID, len(fields)+telem.FieldCount())
+	for winID, id := range fields {
+		all[winID] = id
+	}
+
+	winID := recordedWindows
+	for _, def := range telem.Fields() {
+		if def.IsVirtual() {
+			continue
+		}
+
+		all[winID] = def.ID
+		winID--
+	}
+
+	return all
 }
 
 func (t *TelemetryService) StartStream() {
@@ -133,6 +398,10 @@ func (t *TelemetryService) StartStream() {
 
 	// Multiplex this data
 	go t.multiplexData(ctx, simInCh)
+
+	if lp, ok := t.ativeProvider.(telem.LifecycleProvider); ok {
+		go t.multiplexEvents(ctx, lp.Events())
+	}
 }
 
 func (t *TelemetryService) StopStream() {
@@ -7,40 +7,137 @@ func EmptyTransform(v any, out *TelemetryField) {
 	out.Raw = uint64('-')
 }
 
-func UInt8Transform(v any, out *TelemetryField) {
-	out.Type = DataTypeUINT8
-
+func Float32Transform(v any, out *TelemetryField) {
 	if v == nil {
-		out.Raw = 0
+		out.SetFloat32(0)
 		return
 	}
 
-	out.Raw = uint64(v.(int))
+	out.SetFloat32(v.(float32))
 }
 
-func FloatToStringTransform(v float32, out *TelemetryField) {
-	out.Type = DataTypeSTRING
-	out.Str = strconv.FormatFloat(float64(v), 'f', 1, 32)
+func Float64Transform(v any, out *TelemetryField) {
+	if v == nil {
+		out.SetFloat64(0)
+		return
+	}
+
+	out.SetFloat64(v.(float64))
 }
 
-func FloatToStringTransformDEPRECATE(v any, out *TelemetryField) {
-	out.Type = DataTypeSTRING
+// CoerceTransform returns a transform that stores whatever number the provider
+// gives us as the DataType the field was registered with. Providers use it for
+// every field that doesn't need a transform of its own
+func CoerceTransform(t DataType) func(any, *TelemetryField) {
+	return func(v any, out *TelemetryField) {
+		var f float64
+		switch val := v.(type) {
+		case nil:
+			if t == DataTypeSTRING {
+				out.Type = DataTypeSTRING
+				out.Str = ""
+				return
+			}
+			f = 0
+		case bool:
+			if val {
+				f = 1
+			}
+		case int:
+			f = float64(val)
+		case int32:
+			f = float64(val)
+		case float32:
+			f = float64(val)
+		case float64:
+			f = val
+		case string:
+			out.Type = DataTypeSTRING
+			out.Str = val
+			return
+		default:
+			out.Type = DataTypeSTRING
+			out.Str = "NaN"
+			return
+		}
 
-	if v == nil {
-		out.Str = "inv"
-		return
+		switch t {
+		case DataTypeFLOAT32:
+			out.SetFloat32(float32(f))
+		case DataTypeFLOAT64:
+			out.SetFloat64(f)
+		case DataTypeSTRING:
+			out.Type = DataTypeSTRING
+			out.Str = strconv.FormatFloat(f, 'f', -1, 64)
+		case DataTypeINT8, DataTypeINT16, DataTypeINT32, DataTypeINT64:
+			out.Type = t
+			out.Raw = uint64(int64(f))
+		default:
+			if f < 0 {
+				f = 0
+			}
+			out.Type = t
+			out.Raw = uint64(f)
+		}
 	}
+}
+
+// ArrayTransform returns a transform that stores a whole array the provider
+// gives us, a slice of numbers or a []any with a value per element, as an array
+// of length elements of type elem. Missing elements are 0, extra ones dropped.
+// Every call stores a new slice so the data already sent isn't touched
+func ArrayTransform(elem DataType, length int) func(any, *TelemetryField) {
+	coerce := CoerceTransform(elem)
 
-	out.Str = strconv.FormatFloat(float64(v.(float32)), 'f', 1, 32)
+	return func(v any, out *TelemetryField) {
+		var values []any
+		switch val := v.(type) {
+		case nil:
+			out.Unused()
+			return
+		case []any:
+			values = val
+		case []float32:
+			values = toAny(val)
+		case []float64:
+			values = toAny(val)
+		case []int32:
+			values = toAny(val)
+		case []int:
+			values = toAny(val)
+		case []bool:
+			values = toAny(val)
+		default:
+			out.Unused()
+			return
+		}
+
+		elems := make([]uint64, length)
+		for k := range min(len(values), length) {
+			var e TelemetryField
+			coerce(values[k], &e)
+			elems[k] = e.Raw
+		}
+
+		out.SetArray(elem, elems)
+	}
 }
 
-func FloatToUInt8TransformDEPRECATE(v any, out *TelemetryField) {
-	out.Type = DataTypeUINT8
+func toAny[T any](values []T) []any {
+	res := make([]any, len(values))
+	for k, v := range values {
+		res[k] = v
+	}
+
+	return res
+}
 
-	if v == nil {
-		out.Raw = uint64(0)
-		return
+// DefaultTransform returns the transform for a field that needs nothing but a
+// type conversion, ArrayTransform for arrays and CoerceTransform otherwise
+func DefaultTransform(def *FieldDef) func(any, *TelemetryField) {
+	if def.Type == DataTypeARRAY {
+		return ArrayTransform(def.Elem, def.Len)
 	}
 
-	out.Raw = uint64(v.(float32))
+	return CoerceTransform(def.Type)
 }
@@ -1,15 +1,16 @@
 package telemetry
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
-)
 
-// NOTE: allow the user to create custom data things. For example, iRacing provides
-// multiple surface temps, but I guess the user doesn't want all of them at once.
-// allow him to make something that allows some data transformation to occur.
+	conv "esdi/conversions"
+)
 
 type FieldMapper struct {
 	SDKKey    string
@@ -24,6 +25,12 @@ type VirtualField interface {
 	EnsureSubscribed() []FieldID
 }
 
+// SessionListener is implemented by the virtual fields that keep state that
+// doesn't carry over to a new session, like lap history
+type SessionListener interface {
+	SessionChanged(td *TelemetryData)
+}
+
 // NOTE: Update the iracing SDK to write data to the same map ALWAYS, then
 // I can bind that address and read directly from there on the transform
 
@@ -47,18 +54,32 @@ var bufferPool = sync.Pool{
 type DataType uint8
 
 const (
-	DataTypeUINT8  DataType = 0
-	DataTypeINT8   DataType = 1
-	DataTypeUINT16 DataType = 2
-	DataTypeINT16  DataType = 3
-	DataTypeUINT32 DataType = 4
-	DataTypeINT32  DataType = 5
-	DataTypeUINT64 DataType = 6
-	DataTypeINT64  DataType = 7
-	DataTypeSTRING DataType = 8
-	DataTypeCHAR   DataType = 9
+	DataTypeUINT8   DataType = 0
+	DataTypeINT8    DataType = 1
+	DataTypeUINT16  DataType = 2
+	DataTypeINT16   DataType = 3
+	DataTypeUINT32  DataType = 4
+	DataTypeINT32   DataType = 5
+	DataTypeUINT64  DataType = 6
+	DataTypeINT64   DataType = 7
+	DataTypeSTRING  DataType = 8
+	DataTypeCHAR    DataType = 9
+	DataTypeFLOAT32 DataType = 10
+	DataTypeFLOAT64 DataType = 11
+	DataTypeTABLE   DataType = 12
+	DataTypeARRAY   DataType = 13
 )
 
+// MaxArrayLen is the most elements an array field can have, enough for a value
+// per car slot of the sims we support
+const MaxArrayLen = 64
+
+// numeric reports if values of this type are numbers, the types arrays can be
+// made of
+func (t DataType) numeric() bool {
+	return t <= DataTypeINT64 || t == DataTypeFLOAT32 || t == DataTypeFLOAT64
+}
+
 // TelemetryField will be the basic unit to hold telemetry data values in our
 // application.
 // From my testing, using an uint64 bucket is around 50x faster than any/interface{}
@@ -66,11 +87,21 @@ const (
 // consuming the same piece of data
 // NOTE: we can optimize this via a special command that says a given piece of data
 // is for multiple targets
+//
+// Floats are stored in the same bucket as their IEEE 754 bits, use SetFloat32,
+// SetFloat64 and Float to go in and out of it
+//
+// Arrays keep each element in a bucket of its own, all of the Elem type. Like
+// tables the elements are replaced and never written in place, copies of the
+// data that were already sent keep their own
 type TelemetryField struct {
-	IDs  []int16 // Identification for the serial device
-	Type DataType
-	Raw  uint64
-	Str  string // Only to be used with DataTypeSTRING
+	IDs   []int16 // Identification for the serial device
+	Type  DataType
+	Raw   uint64
+	Str   string   // Only to be used with DataTypeSTRING
+	Table *Table   // Only to be used with DataTypeTABLE
+	Elem  DataType // Only to be used with DataTypeARRAY
+	Elems []uint64 // Only to be used with DataTypeARRAY
 }
 
 func (tf *TelemetryField) Unused() {
@@ -78,6 +109,87 @@ func (tf *TelemetryField) Unused() {
 	tf.Raw = uint64('-')
 }
 
+func (tf *TelemetryField) SetFloat32(v float32) {
+	tf.Type = DataTypeFLOAT32
+	tf.Raw = uint64(math.Float32bits(v))
+}
+
+func (tf *TelemetryField) SetFloat64(v float64) {
+	tf.Type = DataTypeFLOAT64
+	tf.Raw = math.Float64bits(v)
+}
+
+// SetArray stores the raw values of an array of elem. The slice is kept as it
+// is, it must not be written to afterwards
+func (tf *TelemetryField) SetArray(elem DataType, elems []uint64) {
+	tf.Type = DataTypeARRAY
+	tf.Elem = elem
+	tf.Elems = elems
+}
+
+// Len returns the number of elements of the array, 0 for any other type
+func (tf *TelemetryField) Len() int {
+	if tf.Type != DataTypeARRAY {
+		return 0
+	}
+
+	return len(tf.Elems)
+}
+
+// At returns element k of the array as a field of its own. Its unused if the
+// array has no such element
+func (tf *TelemetryField) At(k int) TelemetryField {
+	if k < 0 || k >= tf.Len() {
+		var unused TelemetryField
+		unused.Unused()
+		return unused
+	}
+
+	return TelemetryField{Type: tf.Elem, Raw: tf.Elems[k]}
+}
+
+// IsFloat reports whether this field holds a floating point value
+func (tf *TelemetryField) IsFloat() bool {
+	return tf.Type == DataTypeFLOAT32 || tf.Type == DataTypeFLOAT64
+}
+
+// Float returns the numeric value of this field as a float64. Strings and chars
+// have no numeric value and return 0
+func (tf *TelemetryField) Float() float64 {
+	switch tf.Type {
+	case DataTypeFLOAT32:
+		return float64(math.Float32frombits(uint32(tf.Raw)))
+	case DataTypeFLOAT64:
+		return math.Float64frombits(tf.Raw)
+	case DataTypeUINT8, DataTypeUINT16, DataTypeUINT32, DataTypeUINT64:
+		return float64(tf.Raw)
+	case DataTypeINT8:
+		return float64(int8(tf.Raw))
+	case DataTypeINT16:
+		return float64(int16(tf.Raw))
+	case DataTypeINT32:
+		return float64(int32(tf.Raw))
+	case DataTypeINT64:
+		return float64(int64(tf.Raw))
+	}
+
+	return 0
+}
+
+// Format renders the field as a string, floats are rendered with the given
+// number of decimal places. This is meant for the edges of the application
+// (UI, logs), devices receive the raw value and do their own formatting
+func (tf *TelemetryField) Format(precision int) string {
+	switch tf.Type {
+	case DataTypeFLOAT32:
+		return strconv.FormatFloat(tf.Float(), 'f', precision, 32)
+	case DataTypeFLOAT64:
+		return strconv.FormatFloat(tf.Float(), 'f', precision, 64)
+	}
+
+	return tf.String()
+}
+
 // Pack will pack this current TelemetryField into bytes to send over the wire
 // Format:
 // 0x00 - Field ID
@@ -90,205 +202,284 @@ func (tf *TelemetryField) Unused() {
 // or
 // 0x02 - str len max is 255 chars
 // [0x02] - str
+// or
+// 0x02..0x05 - if its a float32 - IEEE 754 bits, little endian
+// or
+// 0x02..0x09 - if its a float64 - IEEE 754 bits, little endian
+// or
+// 0x02.. - if its a table, see Table.pack
+// or
+// 0x02 - if its an array - DataType of the elements
+// 0x03 - if its an array - number of elements
+// [0x04] - if its an array - every element, packed like the scalars above
 func (tf *TelemetryField) Pack(dest []byte) []byte {
 	// NOTE: maybe we can have a pool of these so we don't have to create them here
 	// or whatever
 	for _, id := range tf.IDs {
 		dest = append(dest, uint8(id), uint8(id>>8))
-		dest = append(dest, uint8(tf.Type))
-
-		switch tf.Type {
-		case DataTypeINT8, DataTypeUINT8, DataTypeCHAR:
-			dest = append(dest, uint8(tf.Raw))
-		case DataTypeINT16, DataTypeUINT16:
-			dest = append(dest, uint8(tf.Raw), uint8(tf.Raw>>8))
-		case DataTypeINT32, DataTypeUINT32:
-			dest = append(dest, uint8(tf.Raw), uint8(tf.Raw>>8), uint8(tf.Raw>>16), uint8(tf.Raw>>24))
-		case DataTypeINT64, DataTypeUINT64:
-			dest = append(dest, uint8(tf.Raw), uint8(tf.Raw>>8), uint8(tf.Raw>>16),
-				uint8(tf.Raw>>24), uint8(tf.Raw>>32), uint8(tf.Raw>>40), uint8(tf.Raw>>48),
-				uint8(tf.Raw>>56),
-			)
-		case DataTypeSTRING:
-			l := min(len(tf.Str), math.MaxUint8)
-
-			dest = append(dest, uint8(l))
-			dest = append(dest, tf.Str[:l]...)
+		if tf.Type == DataTypeTABLE {
+			dest = tf.Table.pack(dest, nil)
+			continue
 		}
+
+		dest = tf.packValue(dest)
 	}
 
 	return dest
 }
 
-func (tf *TelemetryField) String() string {
+// packValue packs the type and the value of the field, without the ID
+func (tf *TelemetryField) packValue(dest []byte) []byte {
+	dest = append(dest, uint8(tf.Type))
+
 	switch tf.Type {
 	case DataTypeSTRING:
-		return tf.Str
-	case DataTypeCHAR:
-		return string([]byte{byte(tf.Raw)})
-	case DataTypeUINT16, DataTypeUINT8:
-		return strconv.FormatUint(tf.Raw, 10)
-	case DataTypeINT8:
-		return strconv.FormatInt(int64(int16(tf.Raw)), 10)
-	case DataTypeINT16:
-		return strconv.FormatInt(int64(int(tf.Raw)), 10)
+		l := min(len(tf.Str), math.MaxUint8)
+
+		dest = append(dest, uint8(l))
+		dest = append(dest, tf.Str[:l]...)
+	case DataTypeARRAY:
+		l := min(len(tf.Elems), math.MaxUint8)
+
+		dest = append(dest, uint8(tf.Elem), uint8(l))
+		for _, raw := range tf.Elems[:l] {
+			dest = packRaw(dest, tf.Elem, raw)
+		}
+	default:
+		dest = packRaw(dest, tf.Type, tf.Raw)
 	}
 
-	return "NaN"
+	return dest
 }
 
-type FieldID uint16
+// packRaw packs a numeric value in as many bytes as its type takes
+func packRaw(dest []byte, t DataType, raw uint64) []byte {
+	switch t {
+	case DataTypeINT8, DataTypeUINT8, DataTypeCHAR:
+		dest = append(dest, uint8(raw))
+	case DataTypeINT16, DataTypeUINT16:
+		dest = append(dest, uint8(raw), uint8(raw>>8))
+	case DataTypeINT32, DataTypeUINT32, DataTypeFLOAT32:
+		dest = append(dest, uint8(raw), uint8(raw>>8), uint8(raw>>16), uint8(raw>>24))
+	case DataTypeINT64, DataTypeUINT64, DataTypeFLOAT64:
+		dest = append(dest, uint8(raw), uint8(raw>>8), uint8(raw>>16),
+			uint8(raw>>24), uint8(raw>>32), uint8(raw>>40), uint8(raw>>48),
+			uint8(raw>>56),
+		)
+	}
 
-// We use FirstField to start the count on the fields the user can select
-// the first three will be for internal use
-const (
-	Speed FieldID = iota
-	RPM
-	Gear
-	FuelLevel
-	// Engine Data
-	OilPress
-	OilTemp
-	WaterTemp
-	// Engine Warnings
-	PitSpeedLimiter
-	// Electrics (dash lights and so on)
-	LeftIndicator
-	RightIndicator
-	Hazards
-	ABSWarningLight
-	ParkingBrakeLight
-	TCLight
-	BatteryLight
-	// Adjustements
-	BrakeBias
-	ABSSetting
-	TCSetting
-	ThrottleSetting
-	// Lap Data
-	LapLastLapTime
-	LapNumber
-	// Tire Data
-	LFtempL
-	LFtempM
-	LFtempR
-	RFtempL
-	RFtempM
-	RFtempR
-	LRtempL
-	LRtempM
-	LRtempR
-	RRtempL
-	RRtempM
-	RRtempR
-	// Session Data
-	SessionTime
-	ReplaySessionTime
-	Empty
-	// Virtual Fields -- fields derived from primitive fields
-	// RPM Dash Lights
-	RPMStateColour
-	// Fuel Calculator
-	FCCurrentLap
-	FCLastLap
-	FCAverage
-	FCExpectedLaps
-	MaxFields
-)
-const FirstField = Speed
-
-var FieldNames = [MaxFields]string{
-	Speed:     "Speed",
-	RPM:       "RPM",
-	Gear:      "Gear",
-	FuelLevel: "Fuel Level",
-	// Engine Data
-	OilPress:  "Oil Pressure",
-	OilTemp:   "Oil Temperature",
-	WaterTemp: "Water Temperature",
-	// Engine Warnings
-	PitSpeedLimiter: "Pit Speed Limiter",
-	// Electrics (dash lights and so on)
-	LeftIndicator:     "Left Indicator",
-	RightIndicator:    "Right Indicator",
-	Hazards:           "Hazards",
-	ABSWarningLight:   "ABS Dash Light",
-	ParkingBrakeLight: "Parking Brake Dash Light",
-	TCLight:           "Traction Control Light",
-	BatteryLight:      "Battery Light",
-	// Ajustments
-	BrakeBias:       "BrakeBias",
-	ABSSetting:      "ABS Control",
-	TCSetting:       "TC Control",
-	ThrottleSetting: "Throttle Control",
-	// Lap Data
-	LapLastLapTime: "Last Lap Time",
-	LapNumber:      "Lap Number",
-	// Tire Data
-	LFtempL: "LF Surface Temp Left",
-	LFtempM: "LF Surface Temp Mid",
-	LFtempR: "LF Surface Temp Right",
-	RFtempL: "RF Surface Temp Left",
-	RFtempM: "RF Surface Temp Mid",
-	RFtempR: "RF Surface Temp Right",
-	LRtempL: "LR Surface Temp Left",
-	LRtempM: "LR Surface Temp Mid",
-	LRtempR: "LR Surface Temp Right",
-	RRtempL: "RR Surface Temp Left",
-	RRtempM: "RR Surface Temp Mid",
-	RRtempR: "RR Surface Temp Right",
-	// Session Data
-	SessionTime:       "SessionTime",
-	ReplaySessionTime: "ReplaySessionTime",
-	// Virtual Fields
-	// RPM Dash Lights
-	RPMStateColour: "RPM State Colour",
-	// Fuel Calculator
-	FCCurrentLap:   "Fuel Current Lap",
-	FCLastLap:      "Fuel Last Lap",
-	FCAverage:      "Fuel Average Usage",
-	FCExpectedLaps: "Fuel Expected Laps",
-	Empty:          "Emtpy",
-}
-
-func GetFieldName(id FieldID) string {
-	if id >= MaxFields {
-		return "Unknown"
+	return dest
+}
+
+// PackValue packs the type and the value of the field like the devices get
+// them, without the window IDs. Tables are not packed, their rows go out with
+// the IDs of the windows showing them
+func (tf *TelemetryField) PackValue(dest []byte) []byte {
+	if tf.Type == DataTypeTABLE {
+		return dest
+	}
+
+	return tf.packValue(dest)
+}
+
+// UnpackValue reads a value packed by PackValue into the field, the IDs are
+// left alone. Returns how many bytes it read
+func (tf *TelemetryField) UnpackValue(src []byte) (int, error) {
+	if len(src) < 1 {
+		return 0, errShortValue
+	}
+
+	t := DataType(src[0])
+	n := 1
+
+	switch t {
+	case DataTypeSTRING:
+		if len(src) < n+1 || len(src) < n+1+int(src[n]) {
+			return 0, errShortValue
+		}
+		l := int(src[n])
+		tf.Type = t
+		tf.Str = string(src[n+1 : n+1+l])
+		return n + 1 + l, nil
+	case DataTypeARRAY:
+		if len(src) < n+2 {
+			return 0, errShortValue
+		}
+		elem, l := DataType(src[n]), int(src[n+1])
+		n += 2
+
+		size := rawSize(elem)
+		if !elem.numeric() || len(src) < n+l*size {
+			return 0, errShortValue
+		}
+
+		elems := make([]uint64, l)
+		for k := range elems {
+			elems[k] = unpackRaw(src[n:], size)
+			n += size
+		}
+		tf.SetArray(elem, elems)
+		return n, nil
+	case DataTypeTABLE:
+		return 0, fmt.Errorf("tables can't be unpacked")
 	}
 
-	return FieldNames[id]
+	size := rawSize(t)
+	if size == 0 || len(src) < n+size {
+		return 0, errShortValue
+	}
+
+	tf.Type = t
+	tf.Raw = unpackRaw(src[n:], size)
+	return n + size, nil
 }
 
-var fieldNameToID map[string]FieldID
+var errShortValue = errors.New("value is cut short")
+
+// rawSize is how many bytes packRaw takes for the type, 0 if it isn't a scalar
+func rawSize(t DataType) int {
+	switch t {
+	case DataTypeINT8, DataTypeUINT8, DataTypeCHAR:
+		return 1
+	case DataTypeINT16, DataTypeUINT16:
+		return 2
+	case DataTypeINT32, DataTypeUINT32, DataTypeFLOAT32:
+		return 4
+	case DataTypeINT64, DataTypeUINT64, DataTypeFLOAT64:
+		return 8
+	}
+
+	return 0
+}
 
-func initFieldNamesMap() {
-	fieldNameToID = make(map[string]FieldID, MaxFields)
-	for id, name := range FieldNames {
-		fieldNameToID[name] = FieldID(id)
+// unpackRaw reads a little endian value of size bytes
+func unpackRaw(src []byte, size int) uint64 {
+	var raw uint64
+	for k := size - 1; k >= 0; k-- {
+		raw = raw<<8 | uint64(src[k])
 	}
+
+	return raw
 }
 
-func GetFieldID(name string) (FieldID, bool) {
-	id, ok := fieldNameToID[name]
-	return id, ok
+func (tf *TelemetryField) String() string {
+	switch tf.Type {
+	case DataTypeSTRING:
+		return tf.Str
+	case DataTypeCHAR:
+		return string([]byte{byte(tf.Raw)})
+	case DataTypeUINT64, DataTypeUINT32, DataTypeUINT16, DataTypeUINT8:
+		return strconv.FormatUint(tf.Raw, 10)
+	case DataTypeINT8:
+		return strconv.FormatInt(int64(int8(tf.Raw)), 10)
+	case DataTypeINT16:
+		return strconv.FormatInt(int64(int16(tf.Raw)), 10)
+	case DataTypeINT32:
+		return strconv.FormatInt(int64(int32(tf.Raw)), 10)
+	case DataTypeINT64:
+		return strconv.FormatInt(int64(tf.Raw), 10)
+	case DataTypeFLOAT32, DataTypeFLOAT64:
+		return tf.Format(1)
+	case DataTypeTABLE:
+		return tf.Table.String()
+	case DataTypeARRAY:
+		elems := make([]string, len(tf.Elems))
+		for k := range tf.Elems {
+			e := tf.At(k)
+			elems[k] = e.String()
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	}
+
+	return "NaN"
 }
 
 // TelemetryData is
 // I need to find a way of having the values be per window or some other
 type TelemetryData struct {
-	Values              [MaxFields]TelemetryField
+	Values [MaxFields]TelemetryField
+	// Cars is a snapshot of every car in the session, for the providers that
+	// know about them. Providers replace the slice instead of writing to it,
+	// copies of the data that were already sent keep their own snapshot
+	Cars                []CarState
 	ActiveBinds         []BoundField
 	VirtualBinds        []VirtualField
 	InitialTime         time.Time
 	PenultimateDataPoll time.Time
 	LastDataPoll        time.Time
+
+	// Session counts the session changes the provider saw, consumers compare
+	// it with the last one they got to notice a new session
+	Session uint32
 }
 
 func NewTelemetryData() *TelemetryData {
 	return &TelemetryData{}
 }
 
+// SessionChanged is the event providers fire when the sim moves to another
+// session. It goes to every virtual field listening for it, providers fire it
+// after reading the first frame of the new session and before processing the
+// virtual fields
+func (td *TelemetryData) SessionChanged() {
+	td.Session++
+
+	for _, vf := range td.VirtualBinds {
+		if l, ok := vf.(SessionListener); ok {
+			l.SessionChanged(td)
+		}
+	}
+}
+
 func (td *TelemetryData) Pack() []byte {
+	return td.PackWithUnits(nil)
+}
+
+// PackWithUnits packs the data like Pack but converts every field to the unit
+// resolve returns for the window consuming it. A nil resolve sends the values
+// in the units the providers filled them in
+func (td *TelemetryData) PackWithUnits(resolve UnitResolver) []byte {
+	return NewPacker(resolve).Pack(td)
+}
+
+// ElementResolver tells which element of an array field the given window
+// shows, false if it shows the whole array
+type ElementResolver func(winID int16, id FieldID) (int, bool)
+
+// Packer packs the data for one consumer. It converts the fields to the units
+// each window wants and remembers the tables it sent, so the next packets only
+// carry the rows that changed
+type Packer struct {
+	Units UnitResolver
+	// Elements picks the element of the arrays each window shows, windows get
+	// the whole array when its nil
+	Elements ElementResolver
+
+	sentTables map[int16]*Table
+}
+
+// NewPacker returns a packer that converts to the units resolve returns, a nil
+// resolve sends the values in the units the providers filled them in
+func NewPacker(resolve UnitResolver) *Packer {
+	return &Packer{
+		Units:      resolve,
+		sentTables: make(map[int16]*Table),
+	}
+}
+
+// Forget makes the next packet send the whole table of the given window, for
+// when the window was created again or the device lost it
+func (p *Packer) Forget(winID int16) {
+	delete(p.sentTables, winID)
+}
+
+// Reset forgets every table that was sent, for when the device lost all the
+// windows
+func (p *Packer) Reset() {
+	clear(p.sentTables)
+}
+
+func (p *Packer) Pack(td *TelemetryData) []byte {
 	bufPtr := bufferPool.Get().(*[]byte)
 	buf := (*bufPtr)[:0]
 
@@ -297,8 +488,20 @@ func (td *TelemetryData) Pack() []byte {
 	// }
 
 	for k := range td.Values {
-		if len(td.Values[k].IDs) > 0 {
-			buf = td.Values[k].Pack(buf)
+		tf := &td.Values[k]
+		if len(tf.IDs) == 0 {
+			continue
+		}
+
+		switch {
+		case tf.Type == DataTypeTABLE:
+			buf = p.packTable(buf, tf, FieldID(k))
+		case tf.Type == DataTypeARRAY:
+			buf = p.packArray(buf, tf, FieldID(k))
+		case p.Units == nil || !tf.convertible(FieldID(k)):
+			buf = tf.Pack(buf)
+		default:
+			buf = tf.packConverted(buf, FieldID(k), p.Units)
 		}
 	}
 
@@ -310,3 +513,59 @@ func (td *TelemetryData) Pack() []byte {
 
 	return result
 }
+
+// packTable packs the rows of the table that changed since the last time each
+// window got it, in the unit the window wants. Windows that didn't get any
+// change are skipped
+func (p *Packer) packTable(dest []byte, tf *TelemetryField, id FieldID) []byte {
+	for _, winID := range tf.IDs {
+		to := conv.UnitNone
+		if p.Units != nil {
+			to = p.Units(winID, id)
+		}
+
+		prev := p.sentTables[winID]
+		if prev == tf.Table && prev != nil {
+			continue
+		}
+
+		start := len(dest)
+		dest = append(dest, uint8(winID), uint8(winID>>8))
+		var changed bool
+		dest, changed = tf.Table.packChanges(dest, prev, to)
+		if !changed {
+			dest = dest[:start]
+		}
+
+		p.sentTables[winID] = tf.Table
+	}
+
+	return dest
+}
+
+// packArray packs one entry per window, either the whole array or the element
+// the window picked, in the unit the window wants
+func (p *Packer) packArray(dest []byte, tf *TelemetryField, id FieldID) []byte {
+	def, _ := GetField(id)
+
+	var winIDs [1]int16
+	for _, winID := range tf.IDs {
+		out := *tf
+		if p.Elements != nil {
+			if k, ok := p.Elements(winID, id); ok {
+				out = tf.At(k)
+			}
+		}
+
+		winIDs[0] = winID
+		out.IDs = winIDs[:]
+
+		if p.Units != nil && out.convertible(id) {
+			_ = out.Convert(def.Unit, p.Units(winID, id))
+		}
+
+		dest = out.Pack(dest)
+	}
+
+	return dest
+}
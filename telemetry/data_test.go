@@ -69,6 +69,24 @@ func Test_TelemetryField(t *testing.T) {
 			},
 			expect: []byte{0x06, 0x00, 0x09, 0x52},
 		},
+		{
+			name: "test_float32",
+			tf: TelemetryField{
+				IDs:  []int16{0x07},
+				Type: DataTypeFLOAT32,
+				Raw:  uint64(math.Float32bits(1.5)),
+			},
+			expect: []byte{0x07, 0x00, 0x0A, 0x00, 0x00, 0xC0, 0x3F},
+		},
+		{
+			name: "test_float64",
+			tf: TelemetryField{
+				IDs:  []int16{0x08},
+				Type: DataTypeFLOAT64,
+				Raw:  math.Float64bits(1.5),
+			},
+			expect: []byte{0x08, 0x00, 0x0B, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF8, 0x3F},
+		},
 	}
 
 	bufPtr := bufferPool.Get().(*[]byte)
@@ -132,3 +150,91 @@ func Test_TelemetryData(t *testing.T) {
 		t.Errorf("\nExpected: %v\nGot: %v\n", expect, result)
 	}
 }
+
+func Test_ArrayField(t *testing.T) {
+	tf := TelemetryField{IDs: []int16{0x01}}
+	ArrayTransform(DataTypeUINT8, 3)([]int32{7, 300, -1, 9}, &tf)
+
+	if tf.Type != DataTypeARRAY || tf.Len() != 3 {
+		t.Fatalf("expected a 3 element array, got %v of %d", tf.Type, tf.Len())
+	}
+
+	// Elements are coerced like single values, negatives clamp at 0 for unsigned
+	if got := tf.String(); got != "[7, 300, 0]" {
+		t.Errorf("expected [7, 300, 0], got %s", got)
+	}
+
+	expect := []byte{0x01, 0x00, 0x0D, 0x00, 0x03, 0x07, 0x2C, 0x00}
+	if packet := tf.Pack(nil); !bytes.Equal(packet, expect) {
+		t.Errorf("expected % x, got % x", expect, packet)
+	}
+
+	if e := tf.At(1); e.Type != DataTypeUINT8 || e.Raw != 300 {
+		t.Errorf("expected element 1 to be 300, got %s", e.String())
+	}
+	if e := tf.At(3); e.Type != DataTypeCHAR {
+		t.Errorf("expected element 3 to be unused, got %s", e.String())
+	}
+
+	// Arrays of a value per element are padded with zeros
+	prev := tf.Elems
+	ArrayTransform(DataTypeFLOAT32, 3)([]any{float32(1.5), nil}, &tf)
+	if got := tf.String(); got != "[1.5, 0.0, 0.0]" {
+		t.Errorf("expected [1.5, 0.0, 0.0], got %s", got)
+	}
+	if prev[0] != 7 {
+		t.Errorf("expected the previous elements to be left alone, got %v", prev)
+	}
+
+	ArrayTransform(DataTypeFLOAT32, 3)(nil, &tf)
+	if tf.Type != DataTypeCHAR {
+		t.Errorf("expected a missing array to be unused, got %v", tf.Type)
+	}
+}
+
+func Test_UnpackValue(t *testing.T) {
+	var float TelemetryField
+	float.SetFloat64(-12.75)
+
+	fields := []TelemetryField{
+		{Type: DataTypeUINT8, Raw: 200},
+		{Type: DataTypeINT16, Raw: uint64(0xFF38)},
+		{Type: DataTypeUINT32, Raw: math.MaxUint32},
+		{Type: DataTypeSTRING, Str: "Okayama"},
+		{Type: DataTypeCHAR, Raw: '-'},
+		float,
+		{Type: DataTypeARRAY, Elem: DataTypeINT32, Elems: []uint64{1, uint64(0xFFFFFFFF), 3}},
+	}
+
+	var packed []byte
+	for k := range fields {
+		packed = fields[k].PackValue(packed)
+	}
+
+	for k, expect := range fields {
+		out := TelemetryField{IDs: []int16{0x07}}
+		n, err := out.UnpackValue(packed)
+		if err != nil {
+			t.Fatalf("failed to unpack %s: %v", expect.String(), err)
+		}
+		packed = packed[n:]
+
+		if out.String() != expect.String() || out.Type != expect.Type {
+			t.Errorf("field %d: expected %s, got %s", k, expect.String(), out.String())
+		}
+		if len(out.IDs) != 1 || out.IDs[0] != 0x07 {
+			t.Errorf("field %d: expected the IDs to be left alone, got %v", k, out.IDs)
+		}
+	}
+
+	if len(packed) != 0 {
+		t.Errorf("expected every byte to be read, %d are left", len(packed))
+	}
+
+	// Values cut short are an error, not garbage
+	var tf TelemetryField
+	short := fields[2].PackValue(nil)
+	if _, err := tf.UnpackValue(short[:3]); err == nil {
+		t.Errorf("expected a value cut short to fail")
+	}
+}
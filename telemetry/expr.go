@@ -0,0 +1,454 @@
+package telemetry
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expressions let users derive their own fields from the ones we already have,
+// ex: `avg(LFtempL, LFtempM, LFtempR)` or `OilTemp > 120`.
+//
+// Grammar, from lowest to highest precedence:
+//   or         = and { "||" and }
+//   and        = comparison { "&&" comparison }
+//   comparison = sum [ ("<" | "<=" | ">" | ">=" | "==" | "!=") sum ]
+//   sum        = product { ("+" | "-") product }
+//   product    = unary { ("*" | "/") unary }
+//   unary      = [ "-" | "!" ] primary
+//   primary    = number | field | call | "(" or ")"
+//   call       = name "(" [ or { "," or } ] ")"
+//
+// Fields are referenced by their registry Key. Comparisons and logic operators
+// evaluate to 1 or 0
+
+// Expr is a parsed expression ready to be evaluated against telemetry data
+type Expr struct {
+	Source string
+	root   exprNode
+	refs   []FieldID
+}
+
+// Refs returns the fields the expression reads from, without duplicates
+func (e *Expr) Refs() []FieldID {
+	return e.refs
+}
+
+// Eval evaluates the expression against the given data
+func (e *Expr) Eval(td *TelemetryData) float64 {
+	return e.root.eval(td)
+}
+
+type exprNode interface {
+	eval(td *TelemetryData) float64
+}
+
+type numberNode float64
+
+func (n numberNode) eval(*TelemetryData) float64 {
+	return float64(n)
+}
+
+type fieldNode FieldID
+
+func (n fieldNode) eval(td *TelemetryData) float64 {
+	return td.Values[n].Float()
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n *unaryNode) eval(td *TelemetryData) float64 {
+	v := n.operand.eval(td)
+	if n.op == "!" {
+		return boolToFloat(v == 0)
+	}
+
+	return -v
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(td *TelemetryData) float64 {
+	l := n.left.eval(td)
+
+	// Short circuit the logic operators
+	switch n.op {
+	case "&&":
+		return boolToFloat(l != 0 && n.right.eval(td) != 0)
+	case "||":
+		return boolToFloat(l != 0 || n.right.eval(td) != 0)
+	}
+
+	r := n.right.eval(td)
+	switch n.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		if r == 0 {
+			return 0
+		}
+		return l / r
+	case "<":
+		return boolToFloat(l < r)
+	case "<=":
+		return boolToFloat(l <= r)
+	case ">":
+		return boolToFloat(l > r)
+	case ">=":
+		return boolToFloat(l >= r)
+	case "==":
+		return boolToFloat(l == r)
+	case "!=":
+		return boolToFloat(l != r)
+	}
+
+	return 0
+}
+
+type callNode struct {
+	fn   exprFunc
+	args []exprNode
+}
+
+func (n *callNode) eval(td *TelemetryData) float64 {
+	return n.fn.call(td, n.args)
+}
+
+type exprFunc struct {
+	minArgs int
+	maxArgs int // -1 for variadic
+	call    func(td *TelemetryData, args []exprNode) float64
+}
+
+var exprFuncs = map[string]exprFunc{
+	"avg": {1, -1, func(td *TelemetryData, args []exprNode) float64 {
+		sum := 0.0
+		for _, arg := range args {
+			sum += arg.eval(td)
+		}
+		return sum / float64(len(args))
+	}},
+	"min": {1, -1, func(td *TelemetryData, args []exprNode) float64 {
+		res := args[0].eval(td)
+		for _, arg := range args[1:] {
+			res = math.Min(res, arg.eval(td))
+		}
+		return res
+	}},
+	"max": {1, -1, func(td *TelemetryData, args []exprNode) float64 {
+		res := args[0].eval(td)
+		for _, arg := range args[1:] {
+			res = math.Max(res, arg.eval(td))
+		}
+		return res
+	}},
+	"abs": {1, 1, func(td *TelemetryData, args []exprNode) float64 {
+		return math.Abs(args[0].eval(td))
+	}},
+	"round": {1, 1, func(td *TelemetryData, args []exprNode) float64 {
+		return math.Round(args[0].eval(td))
+	}},
+	"if": {3, 3, func(td *TelemetryData, args []exprNode) float64 {
+		if args[0].eval(td) != 0 {
+			return args[1].eval(td)
+		}
+		return args[2].eval(td)
+	}},
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ParseExpr parses an expression, every field it references must already be in
+// the registry and hold a numeric value
+func ParseExpr(src string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens, seen: make(map[FieldID]bool)}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+
+	return &Expr{Source: src, root: root, refs: p.refs}, nil
+}
+
+type tokenKind uint8
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// Two character operators must come before their one character prefixes
+var exprOperators = []string{"<=", ">=", "==", "!=", "&&", "||",
+	"+", "-", "*", "/", "<", ">", "!", "(", ")", ","}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) ||
+				unicode.IsDigit(rune(src[i])) || src[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, src[start:i], start})
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{tokOp, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+
+	return append(tokens, token{tokEOF, "end of expression", len(src)}), nil
+}
+
+type exprParser struct {
+	tokens []token
+	cur    int
+	refs   []FieldID
+	seen   map[FieldID]bool
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.cur]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.cur]
+	if t.kind != tokEOF {
+		p.cur++
+	}
+	return t
+}
+
+// accept consumes the next token if its one of the given operators
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp {
+		return "", false
+	}
+
+	for _, op := range ops {
+		if t.text == op {
+			p.cur++
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return fmt.Errorf("expected %q at position %d, got %q", op, p.peek().pos, p.peek().text)
+	}
+	return nil
+}
+
+// parseBinary parses a left associative chain of operators with the same
+// precedence
+func (p *exprParser) parseBinary(operand func() (exprNode, error), ops ...string) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseBinary(p.parseComparison, "&&")
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("<=", ">=", "==", "!=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	return p.parseBinary(p.parseProduct, "+", "-")
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	return p.parseBinary(p.parseUnary, "*", "/")
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := p.accept("-", "!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return numberNode(v), nil
+	case tokIdent:
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+		return p.parseField(t)
+	case tokOp:
+		if t.text == "(" {
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+func (p *exprParser) parseField(t token) (exprNode, error) {
+	id, ok := GetFieldIDByKey(t.text)
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q at position %d", t.text, t.pos)
+	}
+
+	// Strings, arrays and tables have no single number to give
+	def, _ := GetField(id)
+	if !def.Type.numeric() {
+		return nil, fmt.Errorf("field %q at position %d is not numeric", t.text, t.pos)
+	}
+
+	if !p.seen[id] {
+		p.seen[id] = true
+		p.refs = append(p.refs, id)
+	}
+
+	return fieldNode(id), nil
+}
+
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	fn, ok := exprFuncs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.pos)
+	}
+
+	var args []exprNode
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs != -1 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments for %s at position %d: %d",
+			name.text, name.pos, len(args))
+	}
+
+	return &callNode{fn: fn, args: args}, nil
+}
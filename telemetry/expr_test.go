@@ -0,0 +1,78 @@
+package telemetry
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func Test_ParseExpr(t *testing.T) {
+	td := NewTelemetryData()
+	td.Values[LFtempL].SetFloat32(80)
+	td.Values[LFtempM].SetFloat32(90)
+	td.Values[LFtempR].SetFloat32(100)
+	td.Values[OilTemp].SetFloat32(125)
+	td.Values[Speed].SetFloat32(10)
+
+	type ExprTest struct {
+		name   string
+		src    string
+		expect float64
+	}
+
+	tests := []ExprTest{
+		{"test_avg", "avg(LFtempL, LFtempM, LFtempR)", 90},
+		{"test_scale", "Speed * 3.6", 36},
+		{"test_precedence", "1 + 2 * 3 - -1", 8},
+		{"test_parens", "(1 + 2) * 3", 9},
+		{"test_threshold", "OilTemp > 120", 1},
+		{"test_logic", "OilTemp > 120 && Speed < 5", 0},
+		{"test_if", "if(OilTemp >= 125, LFtempR, 0)", 100},
+		{"test_min_max", "max(LFtempL, LFtempR) - min(LFtempL, LFtempR)", 20},
+		{"test_division_by_zero", "Speed / 0", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := ParseExpr(test.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if res := expr.Eval(td); math.Abs(res-test.expect) > 1e-4 {
+				t.Errorf("expected %f, got %f", test.expect, res)
+			}
+		})
+	}
+}
+
+func Test_ParseExprErrors(t *testing.T) {
+	for _, src := range []string{
+		"NotAField + 1",
+		"LapLastLapTime * 2",
+		"avg()",
+		"abs(1, 2)",
+		"nope(1)",
+		"(1 + 2",
+		"1 +",
+		"1 $ 2",
+		"TyreTemps + 1",
+		"max(StandingsTable, 1)",
+	} {
+		if _, err := ParseExpr(src); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+}
+
+func Test_ExprRefs(t *testing.T) {
+	expr, err := ParseExpr("LFtempL + LFtempM + LFtempL * RPM")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expect := []FieldID{LFtempL, LFtempM, RPM}
+	if !slices.Equal(expr.Refs(), expect) {
+		t.Errorf("expected %v, got %v", expect, expr.Refs())
+	}
+}
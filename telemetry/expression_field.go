@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	conv "esdi/conversions"
+)
+
+// ExpressionDef is how users describe their own fields in the configuration:
+//
+//	virtual_fields:
+//	  - key: LFtempAvg
+//	    name: LF Surface Temp Avg
+//	    unit: C
+//	    expr: avg(LFtempL, LFtempM, LFtempR)
+type ExpressionDef struct {
+	Key  string    `yaml:"key"`
+	Name string    `yaml:"name"`
+	Unit conv.Unit `yaml:"unit"`
+	Expr string    `yaml:"expr"`
+}
+
+// ExpressionField is the VirtualField that runs a user expression
+type ExpressionField struct {
+	ID   FieldID
+	Expr *Expr
+}
+
+func NewExpressionField(id FieldID, expr *Expr) *ExpressionField {
+	return &ExpressionField{
+		ID:   id,
+		Expr: expr,
+	}
+}
+
+func (ef *ExpressionField) Process(td *TelemetryData) {
+	td.Values[ef.ID].SetFloat32(float32(ef.Expr.Eval(td)))
+}
+
+func (ef *ExpressionField) EnsureSubscribed() []FieldID {
+	return ef.Expr.Refs()
+}
+
+// RegisterExpression parses the expression and adds it to the registry as a
+// virtual field. Expressions can reference the ones registered before them
+func RegisterExpression(def ExpressionDef) (FieldID, error) {
+	if def.Key == "" {
+		return 0, fmt.Errorf("expression %q has no key", def.Expr)
+	}
+
+	if def.Name == "" {
+		def.Name = def.Key
+	}
+
+	expr, err := ParseExpr(def.Expr)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", def.Key, err)
+	}
+
+	var id FieldID
+	id, err = register(FieldDef{
+		Key:      def.Key,
+		Name:     def.Name,
+		Unit:     def.Unit,
+		Category: CategoryVirtual,
+		Type:     DataTypeFLOAT32,
+		Virtual: &Processor{
+			Name: def.Key,
+			Build: func(*slog.Logger) VirtualField {
+				return NewExpressionField(id, expr)
+			},
+		},
+	})
+	if errors.Is(err, ErrRegistryFull) {
+		return 0, fmt.Errorf("%s: %w, remove some of the virtual_fields from the configuration", def.Key, err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", def.Key, err)
+	}
+
+	return id, nil
+}
+
+// RegisterExpressions registers all the given expressions, the ones that fail
+// are skipped and reported together
+func RegisterExpressions(defs []ExpressionDef) error {
+	var errs []error
+	for _, def := range defs {
+		if _, err := RegisterExpression(def); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
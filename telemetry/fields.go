@@ -0,0 +1,855 @@
+package telemetry
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+
+	conv "esdi/conversions"
+)
+
+// This file is the single place where telemetry fields are described. To add a
+// new channel register it here and list the providers that supply it, providers
+// with special needs for a field can still add a transform on their side
+
+// iracing and beamng are shorthands to keep the table below readable
+func iracing(key string) map[string]string {
+	return map[string]string{SourceIRacing: key}
+}
+
+func iracingAndBeamNG(irKey string, bngKey string) map[string]string {
+	return map[string]string{SourceIRacing: irKey, SourceBeamNG: bngKey}
+}
+
+func beamng(key string) map[string]string {
+	return map[string]string{SourceBeamNG: key}
+}
+
+// assetto channels are the members of the shared memory pages AC and ACC share,
+// see the assetto provider
+func assetto(key string) map[string]string {
+	return map[string]string{SourceAssettoCorsa: key}
+}
+
+// f1 channels are the members of the F1 games' UDP packets, see the f1 provider
+func f1(key string) map[string]string {
+	return map[string]string{SourceF1: key}
+}
+
+// lfs channels are the members of Live for Speed's OutGauge and OutSim packets,
+// see the lfs provider
+func lfs(key string) map[string]string {
+	return map[string]string{SourceLFS: key}
+}
+
+// sources puts the channels of more than one provider together, ex:
+// sources(iracing("Speed"), assetto("speedKmh"))
+func sources(channels ...map[string]string) map[string]string {
+	out := make(map[string]string)
+	for _, c := range channels {
+		maps.Copy(out, c)
+	}
+
+	return out
+}
+
+// tyreCornerNames are the corners in the order array fields keep them
+var tyreCornerNames = []string{"LF", "RF", "LR", "RR"}
+
+// iracingCorners lists iRacing's channel of every corner, with a channel per
+// part of the tyre when parts are given, ex: LFwearL, LFwearM, LFwearR, RFwearL...
+func iracingCorners(channel string, parts ...string) map[string]string {
+	if len(parts) == 0 {
+		parts = []string{""}
+	}
+
+	keys := make([]string, 0, len(tyreCornerNames)*len(parts))
+	for _, corner := range tyreCornerNames {
+		for _, part := range parts {
+			keys = append(keys, corner+channel+part)
+		}
+	}
+
+	return iracing(strings.Join(keys, ","))
+}
+
+var (
+	Speed = Register(FieldDef{
+		Key: "Speed", Name: "Speed", Unit: conv.MetersPerSecond, Category: CategoryCar, Type: DataTypeFLOAT32,
+		Sources: sources(
+			iracingAndBeamNG("Speed", "Speed"), assetto("speedKmh"), f1("speed"), lfs("Speed"),
+		),
+	})
+	RPM = Register(FieldDef{
+		Key: "RPM", Name: "RPM", Unit: conv.RPM, Category: CategoryCar, Type: DataTypeUINT16,
+		Sources: sources(
+			iracingAndBeamNG("RPM", "RPM"), assetto("rpms"), f1("engineRPM"), lfs("RPM"),
+		),
+	})
+	Gear = Register(FieldDef{
+		Key: "Gear", Name: "Gear", Category: CategoryCar,
+		Type:    DataTypeCHAR,
+		Sources: sources(iracingAndBeamNG("Gear", "Gear"), assetto("gear"), f1("gear"), lfs("Gear")),
+	})
+	CarName = Register(FieldDef{
+		Key: "CarName", Name: "Car Name", Category: CategoryCar,
+		Type:    DataTypeSTRING,
+		Sources: sources(iracingAndBeamNG("CarScreenName", "Car"), assetto("carModel"), lfs("Car")),
+	})
+	FuelLevel = Register(FieldDef{
+		Key: "FuelLevel", Name: "Fuel Level", Unit: conv.Litre, Category: CategoryCar, Type: DataTypeFLOAT32,
+		Sources: sources(iracing("FuelLevel"), assetto("fuel"), f1("fuelInTank")),
+	})
+
+	FuelTankCapacity = Register(FieldDef{
+		Key: "FuelTankCapacity", Name: "Fuel Tank Capacity", Unit: conv.Litre, Category: CategoryCar,
+		Type:    DataTypeFLOAT32,
+		Sources: sources(iracing("DriverCarFuelMaxLtr"), assetto("maxFuel"), f1("fuelCapacity")),
+	})
+	OnPitRoad = Register(FieldDef{
+		Key: "OnPitRoad", Name: "On Pit Road", Category: CategoryCar,
+		Type: DataTypeUINT8, Sources: sources(iracing("OnPitRoad"), assetto("isInPitLane"), f1("pitStatus")),
+	})
+	// From 0 to 1, like the pedals
+	FuelLevelPct = Register(FieldDef{
+		Key: "FuelLevelPct", Name: "Fuel Level Percentage", Category: CategoryCar,
+		Type: DataTypeFLOAT32, Sources: sources(iracing("FuelLevelPct"), beamng("Fuel"), lfs("Fuel")),
+	})
+	Voltage = Register(FieldDef{
+		Key: "Voltage", Name: "Voltage", Unit: conv.Volts, Category: CategoryCar,
+		Type: DataTypeFLOAT32, Sources: iracing("Voltage"),
+	})
+	IsOnTrack = Register(FieldDef{
+		Key: "IsOnTrack", Name: "Is On Track", Category: CategoryCar,
+		Type: DataTypeUINT8, Sources: iracing("IsOnTrackCar"),
+	})
+	InPitStall = Register(FieldDef{
+		Key: "InPitStall", Name: "In Pit Stall", Category: CategoryCar,
+		Type: DataTypeUINT8, Sources: sources(iracing("PlayerCarInPitStall"), assetto("isInPit")),
+	})
+	LatAccel = Register(FieldDef{
+		Key: "LatAccel", Name: "Lateral Acceleration", Unit: conv.Acceleration, Category: CategoryCar,
+		Type: DataTypeFLOAT32, Sources: sources(
+			iracing("LatAccel"), assetto("accG[0]"), f1("gForceLateral"), lfs("AccelLat"),
+		),
+	})
+	LongAccel = Register(FieldDef{
+		Key: "LongAccel", Name: "Longitudinal Acceleration", Unit: conv.Acceleration,
+		Category: CategoryCar, Type: DataTypeFLOAT32,
+		Sources: sources(
+			iracing("LongAccel"), assetto("accG[2]"), f1("gForceLongitudinal"), lfs("AccelLong"),
+		),
+	})
+	VertAccel = Register(FieldDef{
+		Key: "VertAccel", Name: "Vertical Acceleration", Unit: conv.Acceleration, Category: CategoryCar,
+		Type: DataTypeFLOAT32, Sources: sources(
+			iracing("VertAccel"), assetto("accG[1]"), f1("gForceVertical"), lfs("AccelVert"),
+		),
+	})
+	Yaw = Register(FieldDef{
+		Key: "Yaw", Name: "Yaw", Unit: conv.Radians, Category: CategoryCar,
+		Type: DataTypeFLOAT32, Sources: sources(iracing("Yaw"), assetto("heading"), f1("yaw"), lfs("Heading")),
+	})
+	Pitch = Register(FieldDef{
+		Key: "Pitch", Name: "Pitch", Unit: conv.Radians, Category: CategoryCar,
+		Type: DataTypeFLOAT32, Sources: sources(iracing("Pitch"), assetto("pitch"), f1("pitch"), lfs("Pitch")),
+	})
+	Roll = Register(FieldDef{
+		Key: "Roll", Name: "Roll", Unit: conv.Radians, Category: CategoryCar,
+		Type: DataTypeFLOAT32, Sources: sources(iracing("Roll"), assetto("roll"), f1("roll"), lfs("Roll")),
+	})
+
+	// Engine Data
+	OilPress = Register(FieldDef{
+		Key: "OilPress", Name: "Oil Pressure", Unit: conv.Bar, Category: CategoryEngine,
+		Type: DataTypeFLOAT32, Sources: sources(iracingAndBeamNG("OilPress", "OilPressure"), lfs("OilPressure")),
+	})
+	OilTemp = Register(FieldDef{
+		Key: "OilTemp", Name: "Oil Temperature", Unit: conv.Celsius, Category: CategoryEngine,
+		Type: DataTypeFLOAT32, Sources: sources(iracingAndBeamNG("OilTemp", "OilTemp"), lfs("OilTemp")),
+	})
+	WaterTemp = Register(FieldDef{
+		Key: "WaterTemp", Name: "Water Temperature", Unit: conv.Celsius, Category: CategoryEngine,
+		Type: DataTypeFLOAT32, Sources: sources(
+			iracingAndBeamNG("WaterTemp", "EngTemp"), assetto("waterTemp"), f1("engineTemperature"),
+			lfs("EngTemp"),
+		),
+	})
+	OilLevel = Register(FieldDef{
+		Key: "OilLevel", Name: "Oil Level", Unit: conv.Litre, Category: CategoryEngine,
+		Type: DataTypeFLOAT32, Sources: iracing("OilLevel"),
+	})
+	WaterLevel = Register(FieldDef{
+		Key: "WaterLevel", Name: "Water Level", Unit: conv.Litre, Category: CategoryEngine,
+		Type: DataTypeFLOAT32, Sources: iracing("WaterLevel"),
+	})
+	FuelPress = Register(FieldDef{
+		Key: "FuelPress", Name: "Fuel Pressure", Unit: conv.Bar, Category: CategoryEngine,
+		Type: DataTypeFLOAT32, Sources: iracing("FuelPress"),
+	})
+	ManifoldPress = Register(FieldDef{
+		Key: "ManifoldPress", Name: "Manifold Pressure", Unit: conv.Bar, Category: CategoryEngine,
+		Type: DataTypeFLOAT32, Sources: iracing("ManifoldPress"),
+	})
+
+	// Shift points the sim reports for the car
+	ShiftLightFirstRPM = Register(FieldDef{
+		Key: "ShiftLightFirstRPM", Name: "Shift Light First RPM", Unit: conv.RPM,
+		Category: CategoryEngine, Type: DataTypeFLOAT32, Sources: iracing("DriverCarSLFirstRPM"),
+	})
+	ShiftLightShiftRPM = Register(FieldDef{
+		Key: "ShiftLightShiftRPM", Name: "Shift Light Shift RPM", Unit: conv.RPM,
+		Category: CategoryEngine, Type: DataTypeFLOAT32, Sources: iracing("DriverCarSLShiftRPM"),
+	})
+	ShiftLightBlinkRPM = Register(FieldDef{
+		Key: "ShiftLightBlinkRPM", Name: "Shift Light Blink RPM", Unit: conv.RPM,
+		Category: CategoryEngine, Type: DataTypeFLOAT32, Sources: iracing("DriverCarSLBlinkRPM"),
+	})
+
+	// Engine Warnings
+	PitSpeedLimiter = Register(FieldDef{
+		Key: "PitSpeedLimiter", Name: "Pit Speed Limiter", Category: CategoryEngine,
+		Type: DataTypeSTRING, Sources: sources(
+			iracingAndBeamNG("irsdk_pitSpeedLimiter", "PitSpeed"), assetto("pitLimiterOn"),
+			f1("pitLimiterStatus"), lfs("PitSpeed"),
+		),
+	})
+	// All the warnings as iRacing's irsdk_EngineWarnings bits
+	EngineWarnings = Register(FieldDef{
+		Key: "EngineWarnings", Name: "Engine Warnings", Category: CategoryEngine,
+		Type: DataTypeUINT32, Sources: iracing("EngineWarnings"),
+	})
+	WaterTempWarning = Register(FieldDef{
+		Key: "WaterTempWarning", Name: "Water Temperature Warning", Category: CategoryEngine,
+		Type: DataTypeUINT8, Sources: iracing("irsdk_waterTempWarning"),
+	})
+	FuelPressWarning = Register(FieldDef{
+		Key: "FuelPressWarning", Name: "Fuel Pressure Warning", Category: CategoryEngine,
+		Type: DataTypeUINT8, Sources: iracing("irsdk_fueldPressureWarning"),
+	})
+	OilPressWarning = Register(FieldDef{
+		Key: "OilPressWarning", Name: "Oil Pressure Warning", Category: CategoryEngine,
+		Type: DataTypeUINT8, Sources: sources(iracing("irsdk_oilPressureWarning"), lfs("OilWarn")),
+	})
+	EngineStalled = Register(FieldDef{
+		Key: "EngineStalled", Name: "Engine Stalled", Category: CategoryEngine,
+		Type: DataTypeUINT8, Sources: iracing("irsdk_engineStalled"),
+	})
+	RevLimiterActive = Register(FieldDef{
+		Key: "RevLimiterActive", Name: "Rev Limiter Active", Category: CategoryEngine,
+		Type: DataTypeUINT8, Sources: iracing("irsdk_revLimiterActive"),
+	})
+
+	// Electrics (dash lights and so on)
+	LeftIndicator = Register(FieldDef{
+		Key: "LeftIndicator", Name: "Left Indicator", Category: CategoryElectrics,
+		Type: DataTypeCHAR, Sources: sources(beamng("SignalL"), lfs("SignalL")),
+	})
+	RightIndicator = Register(FieldDef{
+		Key: "RightIndicator", Name: "Right Indicator", Category: CategoryElectrics,
+		Type: DataTypeCHAR, Sources: sources(beamng("SignalR"), lfs("SignalR")),
+	})
+	Hazards = Register(FieldDef{
+		Key: "Hazards", Name: "Hazards", Category: CategoryElectrics,
+		Type: DataTypeCHAR,
+	})
+	ABSWarningLight = Register(FieldDef{
+		Key: "ABSWarningLight", Name: "ABS Dash Light", Category: CategoryElectrics,
+		Type: DataTypeCHAR, Sources: sources(beamng("ABS"), lfs("ABS")),
+	})
+	ParkingBrakeLight = Register(FieldDef{
+		Key: "ParkingBrakeLight", Name: "Parking Brake Dash Light", Category: CategoryElectrics,
+		Type: DataTypeCHAR, Sources: sources(beamng("Handbrake"), lfs("Handbrake")),
+	})
+	TCLight = Register(FieldDef{
+		Key: "TCLight", Name: "Traction Control Light", Category: CategoryElectrics,
+		Type: DataTypeCHAR, Sources: sources(beamng("TC"), lfs("TC")),
+	})
+	BatteryLight = Register(FieldDef{
+		Key: "BatteryLight", Name: "Battery Light", Category: CategoryElectrics,
+		Type: DataTypeCHAR, Sources: sources(beamng("Battery"), lfs("Battery")),
+	})
+
+	// Adjustements
+	BrakeBias = Register(FieldDef{
+		Key: "BrakeBias", Name: "BrakeBias", Unit: conv.Percent, Category: CategoryAdjustments,
+		Type:    DataTypeFLOAT32,
+		Sources: sources(iracing("dcBrakeBias"), assetto("brakeBias"), f1("frontBrakeBias")),
+	})
+	ABSSetting = Register(FieldDef{
+		Key: "ABSSetting", Name: "ABS Control", Category: CategoryAdjustments,
+		Type: DataTypeUINT8, Sources: sources(iracing("dcABS"), assetto("ABS"), f1("antiLockBrakes")),
+	})
+	TCSetting = Register(FieldDef{
+		Key: "TCSetting", Name: "TC Control", Category: CategoryAdjustments, Type: DataTypeUINT8,
+		Sources: sources(iracing("dcTractionControl"), assetto("TC"), f1("tractionControl")),
+	})
+	ThrottleSetting = Register(FieldDef{
+		Key: "ThrottleSetting", Name: "Throttle Control", Category: CategoryAdjustments,
+		Type: DataTypeUINT8, Sources: iracing("dcThrottleShape"),
+	})
+
+	// Lap Data
+	LapLastLapTime = Register(FieldDef{
+		Key: "LapLastLapTime", Name: "Last Lap Time", Unit: conv.Seconds, Category: CategoryLap,
+		Type:    DataTypeSTRING,
+		Sources: sources(iracing("LapLastLapTime"), assetto("iLastTime"), f1("lastLapTimeInMS")),
+	})
+	LapNumber = Register(FieldDef{
+		Key: "LapNumber", Name: "Lap Number", Category: CategoryLap,
+		Type: DataTypeUINT8, Sources: sources(iracing("Lap"), assetto("completedLaps"), f1("currentLapNum")),
+	})
+	LapDistPct = Register(FieldDef{
+		Key: "LapDistPct", Name: "Lap Distance", Category: CategoryLap, Type: DataTypeFLOAT32,
+		Sources: sources(
+			iracing("LapDistPct"), beamng("LapDist"), assetto("normalizedCarPosition"), f1("lapDistance"),
+		),
+	})
+	LapsCompleted = Register(FieldDef{
+		Key: "LapsCompleted", Name: "Laps Completed", Category: CategoryLap,
+		Type: DataTypeINT16, Sources: iracing("LapCompleted"),
+	})
+	LapCurrentLapTime = Register(FieldDef{
+		Key: "LapCurrentLapTime", Name: "Current Lap Time", Unit: conv.Seconds, Category: CategoryLap,
+		Type:    DataTypeFLOAT32,
+		Sources: sources(iracing("LapCurrentLapTime"), assetto("iCurrentTime"), f1("currentLapTimeInMS")),
+	})
+	LapBestLapTime = Register(FieldDef{
+		Key: "LapBestLapTime", Name: "Best Lap Time", Unit: conv.Seconds, Category: CategoryLap,
+		Type: DataTypeFLOAT32, Sources: sources(iracing("LapBestLapTime"), assetto("iBestTime")),
+	})
+	LapDeltaToBestLap = Register(FieldDef{
+		Key: "LapDeltaToBestLap", Name: "Delta To Best Lap", Unit: conv.Seconds, Category: CategoryLap,
+		Type: DataTypeFLOAT32, Sources: iracing("LapDeltaToBestLap"),
+	})
+	LapDeltaToSessionBestLap = Register(FieldDef{
+		Key: "LapDeltaToSessionBestLap", Name: "Delta To Session Best Lap", Unit: conv.Seconds,
+		Category: CategoryLap, Type: DataTypeFLOAT32, Sources: iracing("LapDeltaToSessionBestLap"),
+	})
+	LapDeltaToOptimalLap = Register(FieldDef{
+		Key: "LapDeltaToOptimalLap", Name: "Delta To Optimal Lap", Unit: conv.Seconds,
+		Category: CategoryLap, Type: DataTypeFLOAT32, Sources: iracing("LapDeltaToOptimalLap"),
+	})
+
+	// Tire Data
+	LFtempL = registerTyreTemp("LFtempL", "LF Surface Temp Left", "LFtempCL")
+	LFtempM = registerTyreTemp("LFtempM", "LF Surface Temp Mid", "LFtempCM")
+	LFtempR = registerTyreTemp("LFtempR", "LF Surface Temp Right", "LFtempCR")
+	RFtempL = registerTyreTemp("RFtempL", "RF Surface Temp Left", "RFtempCL")
+	RFtempM = registerTyreTemp("RFtempM", "RF Surface Temp Mid", "RFtempCM")
+	RFtempR = registerTyreTemp("RFtempR", "RF Surface Temp Right", "RFtempCR")
+	LRtempL = registerTyreTemp("LRtempL", "LR Surface Temp Left", "LRtempCL")
+	LRtempM = registerTyreTemp("LRtempM", "LR Surface Temp Mid", "LRtempCM")
+	LRtempR = registerTyreTemp("LRtempR", "LR Surface Temp Right", "LRtempCR")
+	RRtempL = registerTyreTemp("RRtempL", "RR Surface Temp Left", "RRtempCL")
+	RRtempM = registerTyreTemp("RRtempM", "RR Surface Temp Mid", "RRtempCM")
+	RRtempR = registerTyreTemp("RRtempR", "RR Surface Temp Right", "RRtempCR")
+
+	// Tyre and brake arrays, a value per corner in the LF, RF, LR, RR order.
+	// Temps and wear have three per corner: left, middle and right
+	TyreTemps = Register(FieldDef{
+		Key: "TyreTemps", Name: "Tyre Surface Temps", Unit: conv.Celsius, Category: CategoryTyres,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 12,
+		Sources: sources(
+			iracingCorners("tempC", "L", "M", "R"), assetto("tyreTempIMO"), f1("tyresSurfaceTemperature"),
+		),
+	})
+	// Wear goes from 1 on a new tyre to 0
+	TyreWear = Register(FieldDef{
+		Key: "TyreWear", Name: "Tyre Wear", Category: CategoryTyres,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 12, Sources: iracingCorners("wear", "L", "M", "R"),
+	})
+	TyrePressures = Register(FieldDef{
+		Key: "TyrePressures", Name: "Tyre Pressures", Unit: conv.KPa, Category: CategoryTyres,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 4,
+		Sources: sources(iracingCorners("pressure"), assetto("wheelsPressure"), f1("tyresPressure")),
+	})
+	TyreColdPressures = Register(FieldDef{
+		Key: "TyreColdPressures", Name: "Tyre Cold Pressures", Unit: conv.KPa, Category: CategoryTyres,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 4, Sources: iracingCorners("coldPressure"),
+	})
+	BrakeTemps = Register(FieldDef{
+		Key: "BrakeTemps", Name: "Brake Temps", Unit: conv.Celsius, Category: CategoryTyres,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 4,
+		Sources: sources(assetto("brakeTemp"), f1("brakesTemperature")),
+	})
+	BrakeLinePressures = Register(FieldDef{
+		Key: "BrakeLinePressures", Name: "Brake Line Pressures", Unit: conv.Bar, Category: CategoryTyres,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 4, Sources: iracingCorners("brakeLinePress"),
+	})
+	TyreCarcassTemps = Register(FieldDef{
+		Key: "TyreCarcassTemps", Name: "Tyre Carcass Temps", Unit: conv.Celsius, Category: CategoryTyres,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 12,
+		Sources: sources(iracingCorners("temp", "L", "M", "R"), f1("tyresInnerTemperature")),
+	})
+
+	// Inputs, pedals go from 0 to 1. They are fractions, not percentages, so they
+	// have no unit
+	Throttle = Register(FieldDef{
+		Key: "Throttle", Name: "Throttle", Category: CategoryInputs,
+		Type:    DataTypeFLOAT32,
+		Sources: sources(iracing("Throttle"), assetto("gas"), f1("throttle"), lfs("Throttle")),
+	})
+	Brake = Register(FieldDef{
+		Key: "Brake", Name: "Brake", Category: CategoryInputs,
+		Type: DataTypeFLOAT32, Sources: sources(iracing("Brake"), assetto("brake"), f1("brake"), lfs("Brake")),
+	})
+	// iRacing's clutch is 1 when the pedal is up
+	Clutch = Register(FieldDef{
+		Key: "Clutch", Name: "Clutch", Category: CategoryInputs,
+		Type: DataTypeFLOAT32, Sources: sources(iracing("Clutch"), f1("clutch"), lfs("Clutch")),
+	})
+	Handbrake = Register(FieldDef{
+		Key: "Handbrake", Name: "Handbrake", Category: CategoryInputs,
+		Type: DataTypeFLOAT32, Sources: iracing("HandbrakeRaw"),
+	})
+	SteeringAngle = Register(FieldDef{
+		Key: "SteeringAngle", Name: "Steering Angle", Unit: conv.Radians, Category: CategoryInputs,
+		Type: DataTypeFLOAT32, Sources: iracing("SteeringWheelAngle"),
+	})
+	ABSActive = Register(FieldDef{
+		Key: "ABSActive", Name: "ABS Active", Category: CategoryInputs,
+		Type: DataTypeUINT8, Sources: sources(iracing("BrakeABSactive"), assetto("absInAction")),
+	})
+
+	// Suspension, a value per corner in the LF, RF, LR, RR order
+	RideHeights = Register(FieldDef{
+		Key: "RideHeights", Name: "Ride Heights", Unit: conv.Meters, Category: CategorySuspension,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 4, Sources: iracingCorners("rideHeight"),
+	})
+	ShockDeflections = Register(FieldDef{
+		Key: "ShockDeflections", Name: "Shock Deflections", Unit: conv.Meters, Category: CategorySuspension,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 4, Sources: iracingCorners("shockDefl"),
+	})
+	ShockVelocities = Register(FieldDef{
+		Key: "ShockVelocities", Name: "Shock Velocities", Unit: conv.MetersPerSecond,
+		Category: CategorySuspension, Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 4,
+		Sources: iracingCorners("shockVel"),
+	})
+	WheelSpeeds = Register(FieldDef{
+		Key: "WheelSpeeds", Name: "Wheel Speeds", Unit: conv.MetersPerSecond, Category: CategorySuspension,
+		Type: DataTypeARRAY, Elem: DataTypeFLOAT32, Len: 4,
+		Sources: sources(iracingCorners("speed"), assetto("wheelAngularSpeed")),
+	})
+
+	// Session Data
+	SessionTime = Register(FieldDef{
+		Key: "SessionTime", Name: "SessionTime", Unit: conv.Seconds, Category: CategorySession,
+		Type: DataTypeFLOAT64, Sources: sources(iracing("SessionTime"), f1("sessionTime")),
+	})
+	SessionTimeRemain = Register(FieldDef{
+		Key: "SessionTimeRemain", Name: "Session Time Remaining", Unit: conv.Seconds,
+		Category: CategorySession, Type: DataTypeFLOAT64,
+		Sources: sources(iracing("SessionTimeRemain"), assetto("sessionTimeLeft"), f1("sessionTimeLeft")),
+	})
+	SessionLapsRemain = Register(FieldDef{
+		Key: "SessionLapsRemain", Name: "Session Laps Remaining", Category: CategorySession,
+		Type: DataTypeINT32, Sources: iracing("SessionLapsRemainEx"),
+	})
+	SessionTimeTotal = Register(FieldDef{
+		Key: "SessionTimeTotal", Name: "Session Time Total", Unit: conv.Seconds,
+		Category: CategorySession, Type: DataTypeFLOAT64,
+		Sources: sources(iracing("SessionTimeTotal"), f1("sessionDuration")),
+	})
+	SessionLapsTotal = Register(FieldDef{
+		Key: "SessionLapsTotal", Name: "Session Laps Total", Category: CategorySession,
+		Type: DataTypeINT32, Sources: sources(iracing("SessionLapsTotal"), f1("totalLaps")),
+	})
+	SessionTimeOfDay = Register(FieldDef{
+		Key: "SessionTimeOfDay", Name: "Time Of Day", Unit: conv.Seconds, Category: CategorySession,
+		Type: DataTypeFLOAT32, Sources: iracing("SessionTimeOfDay"),
+	})
+	// iRacing's irsdk_SessionState, 4 is racing
+	SessionState = Register(FieldDef{
+		Key: "SessionState", Name: "Session State", Category: CategorySession,
+		Type: DataTypeUINT8, Sources: iracing("SessionState"),
+	})
+	// The flags as iRacing's irsdk_Flags bits
+	SessionFlags = Register(FieldDef{
+		Key: "SessionFlags", Name: "Session Flags", Category: CategorySession,
+		Type: DataTypeUINT32, Sources: iracing("SessionFlags"),
+	})
+	// Flags out for the player as a Flag set. The iRacing source is read together
+	// with CarIdxSessionFlags, OutGauge doesn't send flags so BeamNG has none
+	Flags = Register(FieldDef{
+		Key: "Flags", Name: "Flags", Category: CategorySession, Type: DataTypeUINT16,
+		Sources: sources(iracing("SessionFlags"), assetto("flag"), f1("vehicleFiaFlags")),
+	})
+	Position = Register(FieldDef{
+		Key: "Position", Name: "Position", Category: CategorySession, Type: DataTypeUINT8,
+		Sources: sources(iracing("PlayerCarPosition"), assetto("position"), f1("carPosition")),
+	})
+	ClassPosition = Register(FieldDef{
+		Key: "ClassPosition", Name: "Class Position", Category: CategorySession,
+		Type: DataTypeUINT8, Sources: iracing("PlayerCarClassPosition"),
+	})
+	Incidents = Register(FieldDef{
+		Key: "Incidents", Name: "Incidents", Category: CategorySession,
+		Type: DataTypeUINT16, Sources: iracing("PlayerCarMyIncidentCount"),
+	})
+	TeamIncidents = Register(FieldDef{
+		Key: "TeamIncidents", Name: "Team Incidents", Category: CategorySession,
+		Type: DataTypeUINT16, Sources: iracing("PlayerCarTeamIncidentCount"),
+	})
+	PitsOpen = Register(FieldDef{
+		Key: "PitsOpen", Name: "Pits Open", Category: CategorySession,
+		Type: DataTypeUINT8, Sources: iracing("PitsOpen"),
+	})
+	PitstopActive = Register(FieldDef{
+		Key: "PitstopActive", Name: "Pit Stop Active", Category: CategorySession,
+		Type: DataTypeUINT8, Sources: iracing("PitstopActive"),
+	})
+	PitRepairLeft = Register(FieldDef{
+		Key: "PitRepairLeft", Name: "Pit Repair Left", Unit: conv.Seconds, Category: CategorySession,
+		Type: DataTypeFLOAT32, Sources: iracing("PitRepairLeft"),
+	})
+	AirTemp = Register(FieldDef{
+		Key: "AirTemp", Name: "Air Temperature", Unit: conv.Celsius, Category: CategorySession,
+		Type: DataTypeFLOAT32, Sources: sources(iracing("AirTemp"), assetto("airTemp"), f1("airTemperature")),
+	})
+	TrackTemp = Register(FieldDef{
+		Key: "TrackTemp", Name: "Track Temperature", Unit: conv.Celsius, Category: CategorySession,
+		Type:    DataTypeFLOAT32,
+		Sources: sources(iracing("TrackTempCrew"), assetto("roadTemp"), f1("trackTemperature")),
+	})
+	WindSpeed = Register(FieldDef{
+		Key: "WindSpeed", Name: "Wind Speed", Unit: conv.MetersPerSecond, Category: CategorySession,
+		Type: DataTypeFLOAT32, Sources: iracing("WindVel"),
+	})
+	WindDir = Register(FieldDef{
+		Key: "WindDir", Name: "Wind Direction", Unit: conv.Radians, Category: CategorySession,
+		Type: DataTypeFLOAT32, Sources: iracing("WindDir"),
+	})
+	// From 0 to 1
+	Humidity = Register(FieldDef{
+		Key: "Humidity", Name: "Relative Humidity", Category: CategorySession,
+		Type: DataTypeFLOAT32, Sources: iracing("RelativeHumidity"),
+	})
+	// iRacing's irsdk_TrackWetness, 1 is dry up to 7 for extremely wet
+	TrackWetness = Register(FieldDef{
+		Key: "TrackWetness", Name: "Track Wetness", Category: CategorySession,
+		Type: DataTypeUINT8, Sources: iracing("TrackWetness"),
+	})
+	TrackName = Register(FieldDef{
+		Key: "TrackName", Name: "Track Name", Category: CategorySession,
+		Type:    DataTypeSTRING,
+		Sources: sources(iracing("TrackDisplayName"), beamng("Track"), assetto("track"), f1("trackId")),
+	})
+	// Lap distances where the sectors of the track start, see ParseSplits
+	TrackSplits = Register(FieldDef{
+		Key: "TrackSplits", Name: "Track Splits", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: iracing("SplitTimeInfo"),
+	})
+	TrackConfig = Register(FieldDef{
+		Key: "TrackConfig", Name: "Track Configuration", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: sources(iracing("TrackConfigName"), assetto("trackConfiguration")),
+	})
+	TrackLength = Register(FieldDef{
+		Key: "TrackLength", Name: "Track Length", Unit: conv.Meters, Category: CategorySession,
+		Type:    DataTypeFLOAT32,
+		Sources: sources(iracing("TrackLength"), assetto("trackSPlineLength"), f1("trackLength")),
+	})
+	TrackSkies = Register(FieldDef{
+		Key: "TrackSkies", Name: "Skies", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: iracing("TrackSkies"),
+	})
+	WeatherType = Register(FieldDef{
+		Key: "WeatherType", Name: "Weather Type", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: iracing("TrackWeatherType"),
+	})
+	// ex: Practice, Lone Qualify, Race
+	SessionType = Register(FieldDef{
+		Key: "SessionType", Name: "Session Type", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: sources(iracing("SessionType"), assetto("session"), f1("sessionType")),
+	})
+	SessionName = Register(FieldDef{
+		Key: "SessionName", Name: "Session Name", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: iracing("SessionName"),
+	})
+	DriverName = Register(FieldDef{
+		Key: "DriverName", Name: "Driver Name", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: sources(iracing("UserName"), assetto("playerName"), f1("name")),
+	})
+	DriverIRating = Register(FieldDef{
+		Key: "DriverIRating", Name: "Driver iRating", Category: CategorySession,
+		Type: DataTypeUINT16, Sources: iracing("IRating"),
+	})
+	DriverLicense = Register(FieldDef{
+		Key: "DriverLicense", Name: "Driver License", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: iracing("LicString"),
+	})
+	CarNumber = Register(FieldDef{
+		Key: "CarNumber", Name: "Car Number", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: iracing("CarNumber"),
+	})
+	CarClass = Register(FieldDef{
+		Key: "CarClass", Name: "Car Class", Category: CategorySession,
+		Type: DataTypeSTRING, Sources: iracing("CarClassShortName"),
+	})
+	// Number of cars in the session, the cars themselves go on TelemetryData.Cars
+	Cars = Register(FieldDef{
+		Key: "Cars", Name: "Cars In Session", Category: CategorySession,
+		Type: DataTypeUINT8, Sources: sources(iracing("CarIdx"), f1("participants")),
+	})
+	// Per car arrays, indexed by the car's slot in the session
+	CarIdxLapDistPct = registerPerCar("CarIdxLapDistPct", "Cars Lap Distance", conv.UnitNone, DataTypeFLOAT32,
+		f1("lapData[].lapDistance"))
+	CarIdxLap = registerPerCar("CarIdxLap", "Cars Lap", conv.UnitNone, DataTypeINT32,
+		f1("lapData[].currentLapNum"))
+	CarIdxPosition = registerPerCar("CarIdxPosition", "Cars Position", conv.UnitNone, DataTypeUINT8,
+		f1("lapData[].carPosition"))
+	CarIdxOnPitRoad = registerPerCar("CarIdxOnPitRoad", "Cars On Pit Road", conv.UnitNone, DataTypeUINT8,
+		f1("lapData[].pitStatus"))
+	CarIdxEstTime     = registerPerCar("CarIdxEstTime", "Cars Estimated Time", conv.Seconds, DataTypeFLOAT32)
+	CarIdxLastLapTime = registerPerCar("CarIdxLastLapTime", "Cars Last Lap Time", conv.Seconds, DataTypeFLOAT32,
+		f1("lapData[].lastLapTimeInMS"))
+
+	ReplaySessionTime = Register(FieldDef{
+		Key: "ReplaySessionTime", Name: "ReplaySessionTime", Unit: conv.Seconds, Category: CategorySession,
+		Type: DataTypeFLOAT64, Sources: iracing("ReplaySessionTime"),
+	})
+	Empty = Register(FieldDef{
+		Key: "Empty", Name: "Empty", Category: CategoryInternal,
+		Type: DataTypeCHAR, Sources: iracing("empty"),
+	})
+
+	// Virtual Fields -- fields derived from primitive fields
+	// RPM Dash Lights
+	RPMStateColour = Register(FieldDef{
+		Key: "RPMStateColour", Name: "RPM State Colour", Category: CategoryVirtual,
+		Type: DataTypeSTRING, Virtual: rpmLightsProcessor,
+	})
+
+	// Fuel Calculator
+	FCCurrentLap = Register(FieldDef{
+		Key: "FCCurrentLap", Name: "Fuel Current Lap", Unit: conv.Litre, Category: CategoryVirtual,
+		Type: DataTypeFLOAT32, Virtual: fuelCalculatorProcessor,
+	})
+	FCLastLap = Register(FieldDef{
+		Key: "FCLastLap", Name: "Fuel Last Lap", Unit: conv.Litre, Category: CategoryVirtual,
+		Type: DataTypeFLOAT32, Virtual: fuelCalculatorProcessor,
+	})
+	FCAverage = Register(FieldDef{
+		Key: "FCAverage", Name: "Fuel Average Usage", Unit: conv.Litre, Category: CategoryVirtual,
+		Type: DataTypeFLOAT32, Virtual: fuelCalculatorProcessor,
+	})
+	FCMaxUsage = Register(FieldDef{
+		Key: "FCMaxUsage", Name: "Fuel Max Usage", Unit: conv.Litre, Category: CategoryVirtual,
+		Type: DataTypeFLOAT32, Virtual: fuelCalculatorProcessor,
+	})
+	FCExpectedLaps = Register(FieldDef{
+		Key: "FCExpectedLaps", Name: "Fuel Expected Laps", Category: CategoryVirtual,
+		Type: DataTypeFLOAT32, Virtual: fuelCalculatorProcessor,
+	})
+	FCRaceLapsRemaining = Register(FieldDef{
+		Key: "FCRaceLapsRemaining", Name: "Race Laps Remaining", Category: CategoryVirtual,
+		Type: DataTypeFLOAT32, Virtual: fuelCalculatorProcessor,
+	})
+	FCFuelToFinish = Register(FieldDef{
+		Key: "FCFuelToFinish", Name: "Fuel To Finish", Unit: conv.Litre, Category: CategoryVirtual,
+		Type: DataTypeFLOAT32, Virtual: fuelCalculatorProcessor,
+	})
+	FCFuelToAdd = Register(FieldDef{
+		Key: "FCFuelToAdd", Name: "Fuel To Add", Unit: conv.Litre, Category: CategoryVirtual,
+		Type: DataTypeFLOAT32, Virtual: fuelCalculatorProcessor,
+	})
+
+	// Lap Timing
+	LTCurrentLap     = registerLapTime("LTCurrentLap", "Lap Timer Current")
+	LTLastLap        = registerLapTime("LTLastLap", "Lap Timer Last")
+	LTBestLap        = registerLapTime("LTBestLap", "Lap Timer Best")
+	LTSessionBest    = registerLapTime("LTSessionBest", "Lap Timer Session Best")
+	LTDelta          = registerLapTime("LTDelta", "Lap Timer Delta")
+	LTCurrentLapStr  = registerLapTimeStr("LTCurrentLapStr", "Lap Timer Current (formatted)")
+	LTLastLapStr     = registerLapTimeStr("LTLastLapStr", "Lap Timer Last (formatted)")
+	LTBestLapStr     = registerLapTimeStr("LTBestLapStr", "Lap Timer Best (formatted)")
+	LTSessionBestStr = registerLapTimeStr("LTSessionBestStr", "Lap Timer Session Best (formatted)")
+	LTDeltaStr       = registerLapTimeStr("LTDeltaStr", "Lap Timer Delta (formatted)")
+
+	// Sector Timing
+	CurrentSector = Register(FieldDef{
+		Key: "CurrentSector", Name: "Current Sector", Category: CategoryVirtual,
+		Type: DataTypeUINT8, Virtual: sectorTimingProcessor,
+	})
+	TheoreticalBest = Register(FieldDef{
+		Key: "TheoreticalBest", Name: "Theoretical Best Lap", Unit: conv.Seconds,
+		Category: CategoryVirtual, Type: DataTypeFLOAT32, Virtual: sectorTimingProcessor,
+	})
+	TheoreticalBestStr = Register(FieldDef{
+		Key: "TheoreticalBestStr", Name: "Theoretical Best Lap (formatted)",
+		Category: CategoryVirtual, Type: DataTypeSTRING, Virtual: sectorTimingProcessor,
+	})
+
+	SectorCurrent, SectorLast, SectorBest, SectorColour = registerSectors()
+
+	SectorTable = Register(FieldDef{
+		Key: "SectorTable", Name: "Sectors Table", Category: CategoryVirtual,
+		Type: DataTypeTABLE, Virtual: sectorTimingProcessor,
+	})
+
+	// Standings, the player is on the middle row of the relative
+	StandingsFields = registerStandings("Std", "Standings", StandingsRows)
+	RelativeFields  = registerStandings("Rel", "Relative", RelativeRows)
+	StandingsTable  = Register(FieldDef{
+		Key: "StandingsTable", Name: "Standings Table", Category: CategoryVirtual,
+		Type: DataTypeTABLE, Virtual: standingsProcessor,
+	})
+	RelativeTable = Register(FieldDef{
+		Key: "RelativeTable", Name: "Relative Table", Category: CategoryVirtual,
+		Type: DataTypeTABLE, Virtual: standingsProcessor,
+	})
+
+	// Flags
+	FlagState = Register(FieldDef{
+		Key: "FlagState", Name: "Flag State", Category: CategoryVirtual,
+		Type: DataTypeSTRING, Virtual: flagStateProcessor,
+	})
+
+	// Tyres
+	TyreTempsTable = Register(FieldDef{
+		Key: "TyreTempsTable", Name: "Tyre Temps Table", Unit: conv.Celsius, Category: CategoryVirtual,
+		Type: DataTypeTABLE, Virtual: tyreTableProcessor,
+	})
+)
+
+var (
+	rpmLightsProcessor = &Processor{
+		Name: "RPM Lights",
+		Build: func(*slog.Logger) VirtualField {
+			return NewRPMLights()
+		},
+	}
+
+	fuelCalculatorProcessor = &Processor{
+		Name: "Fuel Calculator",
+		Build: func(logger *slog.Logger) VirtualField {
+			return NewFuelCalculator(logger.WithGroup("FUEL CALC"))
+		},
+	}
+
+	lapTimingProcessor = &Processor{
+		Name: "Lap Timing",
+		Build: func(logger *slog.Logger) VirtualField {
+			return NewLapTiming(logger.WithGroup("LAP TIMING"))
+		},
+	}
+
+	sectorTimingProcessor = &Processor{
+		Name: "Sector Timing",
+		Build: func(logger *slog.Logger) VirtualField {
+			return NewSectorTiming(logger.WithGroup("SECTOR TIMING"))
+		},
+	}
+
+	standingsProcessor = &Processor{
+		Name: "Standings",
+		Build: func(*slog.Logger) VirtualField {
+			return NewStandings()
+		},
+	}
+
+	flagStateProcessor = &Processor{
+		Name: "Flag State",
+		Build: func(*slog.Logger) VirtualField {
+			return NewFlagStateField()
+		},
+	}
+
+	tyreTableProcessor = &Processor{
+		Name: "Tyre Table",
+		Build: func(*slog.Logger) VirtualField {
+			return NewTyreTable()
+		},
+	}
+)
+
+func registerTyreTemp(key string, name string, irKey string) FieldID {
+	return Register(FieldDef{
+		Key: key, Name: name, Unit: conv.Celsius, Category: CategoryTyres,
+		Type: DataTypeFLOAT32, Sources: iracing(irKey),
+	})
+}
+
+// registerPerCar registers an array with a value per car, read from the iRacing
+// array of the same name and the channels of the other providers that have it
+func registerPerCar(key string, name string, unit conv.Unit, elem DataType, others ...map[string]string) FieldID {
+	return Register(FieldDef{
+		Key: key, Name: name, Unit: unit, Category: CategorySession,
+		Type: DataTypeARRAY, Elem: elem, Len: MaxArrayLen, Sources: sources(append(others, iracing(key))...),
+	})
+}
+
+func registerLapTime(key string, name string) FieldID {
+	return Register(FieldDef{
+		Key: key, Name: name, Unit: conv.Seconds, Category: CategoryVirtual,
+		Type: DataTypeFLOAT32, Virtual: lapTimingProcessor,
+	})
+}
+
+func registerLapTimeStr(key string, name string) FieldID {
+	return Register(FieldDef{
+		Key: key, Name: name, Category: CategoryVirtual,
+		Type: DataTypeSTRING, Virtual: lapTimingProcessor,
+	})
+}
+
+// registerSectors registers the times and colour of every sector, S1Current,
+// S1Last, S1Best, S1Colour, S2Current...
+func registerSectors() (cur, last, best, colour [MaxSectors]FieldID) {
+	for k := range MaxSectors {
+		n := k + 1
+		cur[k] = Register(FieldDef{
+			Key: fmt.Sprintf("S%dCurrent", n), Name: fmt.Sprintf("Sector %d Current", n),
+			Unit: conv.Seconds, Category: CategoryVirtual, Type: DataTypeFLOAT32,
+			Virtual: sectorTimingProcessor,
+		})
+		last[k] = Register(FieldDef{
+			Key: fmt.Sprintf("S%dLast", n), Name: fmt.Sprintf("Sector %d Last", n),
+			Unit: conv.Seconds, Category: CategoryVirtual, Type: DataTypeFLOAT32,
+			Virtual: sectorTimingProcessor,
+		})
+		best[k] = Register(FieldDef{
+			Key: fmt.Sprintf("S%dBest", n), Name: fmt.Sprintf("Sector %d Best", n),
+			Unit: conv.Seconds, Category: CategoryVirtual, Type: DataTypeFLOAT32,
+			Virtual: sectorTimingProcessor,
+		})
+		colour[k] = Register(FieldDef{
+			Key: fmt.Sprintf("S%dColour", n), Name: fmt.Sprintf("Sector %d Colour", n),
+			Category: CategoryVirtual, Type: DataTypeSTRING, Virtual: sectorTimingProcessor,
+		})
+	}
+
+	return cur, last, best, colour
+}
+
+// standingsRowFields are the fields of a row of the standings or the relative
+type standingsRowFields struct {
+	Position FieldID
+	Name     FieldID
+	Gap      FieldID
+}
+
+// registerStandings registers the fields of each row of a table, ex: Std1Pos,
+// Std1Name, Std1Gap, Std2Pos...
+func registerStandings(prefix string, name string, rows int) []standingsRowFields {
+	res := make([]standingsRowFields, rows)
+	for k := range res {
+		n := k + 1
+		res[k].Position = Register(FieldDef{
+			Key: fmt.Sprintf("%s%dPos", prefix, n), Name: fmt.Sprintf("%s %d Position", name, n),
+			Category: CategoryVirtual, Type: DataTypeUINT8, Virtual: standingsProcessor,
+		})
+		res[k].Name = Register(FieldDef{
+			Key: fmt.Sprintf("%s%dName", prefix, n), Name: fmt.Sprintf("%s %d Driver", name, n),
+			Category: CategoryVirtual, Type: DataTypeSTRING, Virtual: standingsProcessor,
+		})
+		res[k].Gap = Register(FieldDef{
+			Key: fmt.Sprintf("%s%dGap", prefix, n), Name: fmt.Sprintf("%s %d Gap", name, n),
+			Unit: conv.Seconds, Category: CategoryVirtual, Type: DataTypeFLOAT32,
+			Virtual: standingsProcessor,
+		})
+	}
+
+	return res
+}
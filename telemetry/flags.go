@@ -0,0 +1,95 @@
+package telemetry
+
+// Flag is the set of race flags out for the player. The providers translate
+// whatever their sim reports into these so the dash doesn't need to know how
+// each sim does it
+type Flag uint16
+
+const (
+	FlagGreen Flag = 1 << iota
+	FlagYellow
+	FlagBlue
+	FlagWhite
+	FlagChequered
+	FlagBlack
+	FlagMeatball
+	FlagRed
+)
+
+// Names the flags go by on FlagState and on the layouts
+const (
+	FlagNameNone      = "NONE"
+	FlagNameGreen     = "GREEN"
+	FlagNameYellow    = "YELLOW"
+	FlagNameBlue      = "BLUE"
+	FlagNameWhite     = "WHITE"
+	FlagNameChequered = "CHEQUERED"
+	FlagNameBlack     = "BLACK"
+	FlagNameMeatball  = "MEATBALL"
+	FlagNameRed       = "RED"
+)
+
+// flagPriority is the order in which flags win when more than one is out, the
+// ones the driver has to act on come first
+var flagPriority = []struct {
+	Flag Flag
+	Name string
+}{
+	{FlagRed, FlagNameRed},
+	{FlagBlack, FlagNameBlack},
+	{FlagMeatball, FlagNameMeatball},
+	{FlagChequered, FlagNameChequered},
+	{FlagYellow, FlagNameYellow},
+	{FlagBlue, FlagNameBlue},
+	{FlagWhite, FlagNameWhite},
+	{FlagGreen, FlagNameGreen},
+}
+
+// String is the name of the most important flag that is out
+func (f Flag) String() string {
+	for _, p := range flagPriority {
+		if f&p.Flag != 0 {
+			return p.Name
+		}
+	}
+
+	return FlagNameNone
+}
+
+// IsFlagName reports if name is one of the names FlagState can take
+func IsFlagName(name string) bool {
+	if name == FlagNameNone {
+		return true
+	}
+
+	for _, p := range flagPriority {
+		if p.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FlagStateField acts as a Virtual Field, it reduces the flags that are out to
+// the one the dash should show
+type FlagStateField struct{}
+
+func NewFlagStateField() *FlagStateField {
+	return &FlagStateField{}
+}
+
+func (fs *FlagStateField) Process(td *TelemetryData) {
+	var flags Flag
+	// Providers without flags leave the field unused
+	if td.Values[Flags].Type == DataTypeUINT16 {
+		flags = Flag(td.Values[Flags].Raw)
+	}
+
+	td.Values[FlagState].Type = DataTypeSTRING
+	td.Values[FlagState].Str = flags.String()
+}
+
+func (fs *FlagStateField) EnsureSubscribed() []FieldID {
+	return []FieldID{Flags}
+}
@@ -0,0 +1,38 @@
+package telemetry
+
+import "testing"
+
+func Test_FlagState(t *testing.T) {
+	tests := []struct {
+		name   string
+		flags  TelemetryField
+		expect string
+	}{
+		{"test_unused", TelemetryField{Type: DataTypeCHAR, Raw: uint64('-')}, FlagNameNone},
+		{"test_no_flags", TelemetryField{Type: DataTypeUINT16}, FlagNameNone},
+		{"test_green", TelemetryField{Type: DataTypeUINT16, Raw: uint64(FlagGreen)}, FlagNameGreen},
+		{"test_lapped", TelemetryField{Type: DataTypeUINT16, Raw: uint64(FlagGreen | FlagBlue)}, FlagNameBlue},
+		{"test_yellow_over_blue", TelemetryField{Type: DataTypeUINT16, Raw: uint64(FlagYellow | FlagBlue)}, FlagNameYellow},
+		{"test_black_over_all", TelemetryField{Type: DataTypeUINT16, Raw: uint64(FlagBlack | FlagYellow | FlagChequered)}, FlagNameBlack},
+		{"test_chequered", TelemetryField{Type: DataTypeUINT16, Raw: uint64(FlagChequered | FlagWhite)}, FlagNameChequered},
+	}
+
+	fs := NewFlagStateField()
+	td := NewTelemetryData()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			td.Values[Flags] = test.flags
+
+			fs.Process(td)
+
+			if got := td.Values[FlagState].Str; got != test.expect {
+				t.Errorf("expected %s, got %s", test.expect, got)
+			}
+
+			if !IsFlagName(test.expect) {
+				t.Errorf("%s is not a flag name", test.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package telemetry
+
+import "time"
+
+// FrameSource reads a recording frame by frame as fast as it can, for the tools
+// that go over a whole session instead of streaming it. The clock of the data
+// is the one of the recording, LastDataPoll is the time of the frame
+type FrameSource interface {
+	Name() string
+	Subscribe(map[int16]FieldID)
+	// ReadFrame reads the next frame, io.EOF after the last one. The time is
+	// where the frame is in the recording, the first one isn't always at 0. The
+	// data is overwritten by the next frame
+	ReadFrame() (*TelemetryData, time.Duration, error)
+	Close() error
+}
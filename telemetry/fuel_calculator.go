@@ -3,19 +3,21 @@ package telemetry
 import (
 	"fmt"
 	"log/slog"
-	"strconv"
+	"math"
 	"sync"
 )
 
-// NOTE: for managing fuel consumption and predictions we need to filter out
-// abnormal laps like going into the pits and refueling and whatnot
-
 // NOTE: create a log file specifically for this, I reckon it would be better
 
 type fuelCalcState int
 
 const (
-	MaxLaps = 256
+	// DefaultFuelHistoryLaps is how many valid laps the rolling average uses
+	DefaultFuelHistoryLaps = 3
+
+	// iRacing reports these when the session has no lap or time limit
+	unlimitedLaps = 32767
+	unlimitedTime = 604800
 )
 
 const (
@@ -31,18 +33,12 @@ const (
 type lapFuelData struct {
 	Lap            int     // Number of the lap of this data
 	StartFuelLevel float64 // Fuel level at the start/finish line
+	StartTime      float64 // Session time at the start/finish line
 	FuelUsage      float64 // Fuel used during the lap
+	LapTime        float64 // Time the lap took
 	Valid          bool    // If we should consider this data for calculations
 }
 
-func (lfd *lapFuelData) SetLap(lap int) {
-	lfd.Lap = lap
-}
-
-func (lfd *lapFuelData) SetStartFuelLevel(fuelLevel float64) {
-	lfd.StartFuelLevel = fuelLevel
-}
-
 func (lfd *lapFuelData) InvalidateLap() {
 	lfd.Valid = false
 }
@@ -51,169 +47,211 @@ func (lfd *lapFuelData) CalculateFuelUsage(fuelLevel float64) {
 	lfd.FuelUsage = lfd.StartFuelLevel - fuelLevel
 }
 
-// FuelCalculator acts as a Virtual Field middleware
+// FuelCalculator acts as a Virtual Field middleware. It keeps track of the fuel
+// used on each lap and from there works out the strategy numbers:
+// - a rolling average and the max usage of the last valid laps
+// - how many laps the fuel in the tank lasts
+// - how many laps are left in the race and the fuel needed to finish them
+// - how much fuel to add on the next stop
+//
+// Laps driven through the pit lane (in and out laps) and laps where the fuel
+// went up are not valid, they don't count for the averages
 type FuelCalculator struct {
 	Logger *slog.Logger
 
-	// --- Internal State (The Memory) ---
-	lastLapNumber  int     // Tracks when we cross the start/finish line
-	fuelAtLapStart float64 // Snapshot of fuel when the lap began
-
 	setup *sync.Once
 
-	lapHistory     []float64 // Buffer holding the last N valid laps
-	maxHistoryLaps int       // How many laps to keep in the buffer (e.g., 3)
-
-	// --- Validation Flags ---
-
-	isOutLap       bool // True if we just left the pits
-	isUnderCaution bool // True if safety car is out (optional, based on flags)
-
-	// --- The Outputs (What gets pushed back to the TUI) ---
-
-	lastLapUsage float64
-	avgUsage     float64
-	maxUsage     float64
-
 	state fuelCalcState
 
-	Laps       [MaxLaps]lapFuelData
-	CurrentLap int
+	currentLap  lapFuelData
+	lapHistory  []lapFuelData // Valid laps, newest last
+	historyLaps int           // How many laps the rolling average uses
+	onPitRoad   bool
 
 	averageFuelUsage float64
+	maxFuelUsage     float64
+	averageLapTime   float64
 	expectedLaps     float64
 }
 
 func NewFuelCalculator(logger *slog.Logger) *FuelCalculator {
 	return &FuelCalculator{
-		Logger:           logger,
-		setup:            &sync.Once{},
-		lapHistory:       make([]float64, 0, 5), // Pre-allocate space for 5 laps
-		maxHistoryLaps:   3,                     // We want a 3-lap rolling average
-		lastLapNumber:    0,
-		state:            OutlapState,
-		CurrentLap:       0,
-		averageFuelUsage: 0.0,
+		Logger:      logger,
+		setup:       &sync.Once{},
+		lapHistory:  make([]lapFuelData, 0, DefaultFuelHistoryLaps),
+		historyLaps: DefaultFuelHistoryLaps,
+		state:       OutlapState,
 	}
 }
 
-func (fc *FuelCalculator) initializeFCTelemetryFields(td *TelemetryData) {
-	td.Values[FCLastLap].Type = DataTypeSTRING
-	td.Values[FCLastLap].Str = "No Data"
-
-	td.Values[FCCurrentLap].Type = DataTypeSTRING
-	td.Values[FCCurrentLap].Str = "No Data"
-
-	td.Values[FCExpectedLaps].Type = DataTypeSTRING
-	td.Values[FCExpectedLaps].Str = "No Data"
+// fuelCalculatorOutputs are all the fields this calculator writes to
+var fuelCalculatorOutputs = []*FieldID{
+	&FCCurrentLap, &FCLastLap, &FCAverage, &FCMaxUsage, &FCExpectedLaps,
+	&FCRaceLapsRemaining, &FCFuelToFinish, &FCFuelToAdd,
+}
 
-	td.Values[FCAverage].Type = DataTypeSTRING
-	td.Values[FCAverage].Str = "No Data"
+func (fc *FuelCalculator) initializeFCTelemetryFields(td *TelemetryData) {
+	for _, id := range fuelCalculatorOutputs {
+		td.Values[*id].Type = DataTypeSTRING
+		td.Values[*id].Str = "No Data"
+	}
 }
 
-func (fc *FuelCalculator) newLapData(lap int, fuelLevel float64) {
-	fc.Laps[fc.CurrentLap].SetStartFuelLevel(fuelLevel)
-	fc.Laps[fc.CurrentLap].SetLap(lap)
-	fc.Laps[fc.CurrentLap].Valid = true
+func (fc *FuelCalculator) newLapData(td *TelemetryData, lap int, fuelLevel float64) {
+	fc.currentLap = lapFuelData{
+		Lap:            lap,
+		StartFuelLevel: fuelLevel,
+		StartTime:      td.Values[SessionTime].Float(),
+		// A lap started in the pits is an out lap
+		Valid: !fc.onPitRoad && fc.state != OutlapState,
+	}
 }
 
-func (fc *FuelCalculator) MaxLapsReached(td *TelemetryData) {
-	td.Values[FCLastLap].Str = "MAX!!!"
-	td.Values[FCCurrentLap].Str = "MAX!!!"
+// setLapStatus replaces the lap usage outputs with a status message
+func (fc *FuelCalculator) setLapStatus(td *TelemetryData, msg string) {
+	td.Values[FCLastLap].Type = DataTypeSTRING
+	td.Values[FCLastLap].Str = msg
+
+	td.Values[FCCurrentLap].Type = DataTypeSTRING
+	td.Values[FCCurrentLap].Str = msg
 }
 
 func (fc *FuelCalculator) updateLapFuelUsage(td *TelemetryData, fuel float64) {
-	fc.Logger.Debug(fmt.Sprintf("= %-2d =============================================", fc.CurrentLap))
-	fc.Logger.Debug(fmt.Sprintf("-> Updating Lap Fuel Usage"))
-	fc.Logger.Debug(fmt.Sprintf("   %+v", fc.Laps[fc.CurrentLap]))
+	fc.Logger.Debug(fmt.Sprintf("= %-2d =============================================", fc.currentLap.Lap))
+	fc.Logger.Debug(fmt.Sprintf("   %+v", fc.currentLap))
 	fc.Logger.Debug(fmt.Sprintf("   %f - %f = %f",
-		fc.Laps[fc.CurrentLap].StartFuelLevel, fuel, fc.Laps[fc.CurrentLap].StartFuelLevel-fuel))
+		fc.currentLap.StartFuelLevel, fuel, fc.currentLap.StartFuelLevel-fuel))
+
+	fc.currentLap.CalculateFuelUsage(fuel)
+	fc.currentLap.LapTime = td.Values[SessionTime].Float() - fc.currentLap.StartTime
+	td.Values[FCLastLap].SetFloat32(float32(fc.currentLap.FuelUsage))
 
-	fc.Laps[fc.CurrentLap].CalculateFuelUsage(fuel)
-	td.Values[FCLastLap].Str = fmt.Sprintf("%.1f", fc.Laps[fc.CurrentLap].FuelUsage)
+	if !fc.currentLap.Valid {
+		fc.Logger.Debug("   lap is not valid, not adding it to the history")
+		return
+	}
+
+	fc.lapHistory = append(fc.lapHistory, fc.currentLap)
+	if len(fc.lapHistory) > fc.historyLaps {
+		fc.lapHistory = fc.lapHistory[len(fc.lapHistory)-fc.historyLaps:]
+	}
 }
 
+// updateAverageFuelUsage works out the rolling average and max usage of the
+// valid laps in the history
 func (fc *FuelCalculator) updateAverageFuelUsage(td *TelemetryData) {
-	// NOTE: this is a dumb approach on how to calculte fuel usage
-	// sum := 0.0
-	// lapCount := 0
-	// for k := 0; k < fc.CurrentLap; k++ {
-	// 	if !fc.Laps[k].Valid {
-	// 		continue
-	// 	}
-	//
-	// 	sum += fc.Laps[k].FuelUsage
-	// 	lapCount += 1
-	// }
-	//
-	// if lapCount == 0 {
-	// 	return
-	// }
-	//
-	// fc.averageFuelUsage = sum / float64(lapCount)
-	fc.averageFuelUsage = fc.Laps[fc.CurrentLap].FuelUsage
-
-	td.Values[FCAverage].Str = fmt.Sprintf("%.2f", fc.averageFuelUsage)
-}
+	if len(fc.lapHistory) == 0 {
+		return
+	}
 
-func (fc *FuelCalculator) updateExpectedLaps(td *TelemetryData, fuel float64) {
-	fc.expectedLaps = fuel / fc.averageFuelUsage
+	sum, lapTimes := 0.0, 0.0
+	fc.maxFuelUsage = 0
+	for _, lap := range fc.lapHistory {
+		sum += lap.FuelUsage
+		lapTimes += lap.LapTime
+		fc.maxFuelUsage = math.Max(fc.maxFuelUsage, lap.FuelUsage)
+	}
+
+	fc.averageFuelUsage = sum / float64(len(fc.lapHistory))
+	fc.averageLapTime = lapTimes / float64(len(fc.lapHistory))
 
-	td.Values[FCExpectedLaps].Str = fmt.Sprintf("%.1f", fc.expectedLaps)
+	td.Values[FCAverage].SetFloat32(float32(fc.averageFuelUsage))
+	td.Values[FCMaxUsage].SetFloat32(float32(fc.maxFuelUsage))
 }
 
 func (fc *FuelCalculator) startFinishLineCrossed(td *TelemetryData, lap int, fuel float64) {
-	if fc.state == OutlapState {
+	switch fc.state {
+	case OutlapState:
 		fc.state = FirstLapState
+	case FirstLapState:
+		fc.state = NormalState
 	}
 
-	if fc.state == FirstLapState {
-		fc.state = NormalState
+	// 1. Update last lap (we are still on the curret lap) fuel usage
+	fc.updateLapFuelUsage(td, fuel)
+	// 2. Update the average usage
+	fc.updateAverageFuelUsage(td)
+
+	// Go into the next lap
+	fc.newLapData(td, lap, fuel)
+}
+
+// raceLapsRemaining returns how many laps, counting the one we are on, are left
+// in the race. Time limited races are estimated from the average lap time
+func (fc *FuelCalculator) raceLapsRemaining(td *TelemetryData) (float64, bool) {
+	lapDist := td.Values[LapDistPct].Float()
+
+	lapsRemain := td.Values[SessionLapsRemain].Float()
+	if lapsRemain > 0 && lapsRemain < unlimitedLaps {
+		return lapsRemain - lapDist, true
 	}
 
-	// if fc.state == NormalState {
-	// 	// In the normal state we have normal calculations to compute
-	// 	fc.updateLapFuelUsage(td, fuel)
-	// }
+	timeRemain := td.Values[SessionTimeRemain].Float()
+	if timeRemain <= 0 || timeRemain >= unlimitedTime || fc.averageLapTime <= 0 {
+		return 0, false
+	}
 
-	if fc.CurrentLap == MaxLaps {
-		fc.MaxLapsReached(td)
+	// When the clock runs out we still have to finish the lap we are on
+	return math.Ceil(lapDist+timeRemain/fc.averageLapTime) - lapDist, true
+}
+
+func (fc *FuelCalculator) updateStrategy(td *TelemetryData, fuel float64) {
+	if fc.averageFuelUsage <= 0 {
 		return
 	}
 
-	// 1. Update last lap (we are still on the curret index) lap's fuel usage
-	fc.updateLapFuelUsage(td, fuel)
-	// 2. Update the average usage
-	fc.updateAverageFuelUsage(td)
-	// 3. Update the expected laps
-	fc.updateExpectedLaps(td, fuel)
+	fc.expectedLaps = fuel / fc.averageFuelUsage
+	td.Values[FCExpectedLaps].SetFloat32(float32(fc.expectedLaps))
+
+	lapsRemaining, ok := fc.raceLapsRemaining(td)
+	if !ok {
+		td.Values[FCRaceLapsRemaining].Type = DataTypeSTRING
+		td.Values[FCRaceLapsRemaining].Str = "Unlimited"
+		for _, id := range []FieldID{FCFuelToFinish, FCFuelToAdd} {
+			td.Values[id].Type = DataTypeSTRING
+			td.Values[id].Str = "No Data"
+		}
+		return
+	}
 
-	// Go into the next lap
-	fc.CurrentLap += 1
-	fc.newLapData(lap, fuel)
+	fuelToFinish := lapsRemaining * fc.averageFuelUsage
+
+	// We can't add more than what fits in the tank with the fuel on board
+	fuelToAdd := math.Max(fuelToFinish-fuel, 0)
+	if capacity := td.Values[FuelTankCapacity].Float(); capacity > 0 {
+		fuelToAdd = math.Min(fuelToAdd, math.Max(capacity-fuel, 0))
+	}
+
+	td.Values[FCRaceLapsRemaining].SetFloat32(float32(lapsRemaining))
+	td.Values[FCFuelToFinish].SetFloat32(float32(fuelToFinish))
+	td.Values[FCFuelToAdd].SetFloat32(float32(fuelToAdd))
 }
 
-func (fc *FuelCalculator) updateCurrentLapFuelUsage(td *TelemetryData, fuel float64) {
-	// NOTE: find a more performant way of float64 -> string
-	fuelDelta := fc.Laps[fc.CurrentLap].StartFuelLevel - fuel
+// updatePitStatus invalidates the laps that go through the pit lane. The lap we
+// come out of the pits on is an out lap, the next one is valid again
+func (fc *FuelCalculator) updatePitStatus(td *TelemetryData) {
+	onPitRoad := td.Values[OnPitRoad].Raw != 0
+	if onPitRoad && !fc.onPitRoad {
+		fc.Logger.Debug(fmt.Sprintf("lap %d: entered the pit lane", fc.currentLap.Lap))
+	}
+
+	if onPitRoad {
+		fc.currentLap.InvalidateLap()
+	}
 
-	td.Values[FCCurrentLap].Str = fmt.Sprintf("%.1f", fuelDelta)
+	fc.onPitRoad = onPitRoad
 }
 
-func (fc *FuelCalculator) updateRealTimeVariables(td *TelemetryData, lap int, fuel float64) {
-	// if fc.state == OutlapState {
-	// Nothing to calculate here currently
-	// 	return
-	// }
+func (fc *FuelCalculator) updateCurrentLapFuelUsage(td *TelemetryData, fuel float64) {
+	fuelDelta := fc.currentLap.StartFuelLevel - fuel
 
-	// Real time updates
-	fc.updateCurrentLapFuelUsage(td, fuel)
+	td.Values[FCCurrentLap].SetFloat32(float32(fuelDelta))
 }
 
-// NOTE: I need to add better logic for this. We need to detect pit stops
-// instead of just checking if the fuel is greater than before
+// refueled reports if the fuel went up, either a pit stop or a reset. The
+// usage of this lap can't be trusted after that
 func (fc *FuelCalculator) refueled(fuel float64) bool {
-	return fuel > fc.Laps[fc.CurrentLap].StartFuelLevel
+	return fuel > fc.currentLap.StartFuelLevel
 }
 
 func (fc *FuelCalculator) Process(td *TelemetryData) {
@@ -221,46 +259,62 @@ func (fc *FuelCalculator) Process(td *TelemetryData) {
 	// with on this fuel calculator
 	fc.setup.Do(func() {
 		fc.initializeFCTelemetryFields(td)
+		fc.onPitRoad = td.Values[OnPitRoad].Raw != 0
+		fc.newLapData(td, int(td.Values[LapNumber].Raw), td.Values[FuelLevel].Float())
 	})
 
-	scannedLap := int(td.Values[LapNumber].Raw)
-	// NOTE: this is stupid as, fix it. I mean don't store fuel level as a string
-	scannedFuelLevel, err := strconv.ParseFloat(td.Values[FuelLevel].Str, 64)
-	if err != nil {
-		fc.Logger.Debug(fmt.Sprintf("error parsing fuel level: %+v", err))
+	if !td.Values[FuelLevel].IsFloat() {
+		fc.Logger.Debug(fmt.Sprintf("fuel level is not a float: %d", td.Values[FuelLevel].Type))
 
-		td.Values[FCLastLap].Str = "ERROR"
-		td.Values[FCCurrentLap].Str = "ERROR"
+		fc.setLapStatus(td, "ERROR")
 
 		return
 	}
 
-	// Start the 0th lap
-	if fc.CurrentLap == 0 && fc.Laps[0].StartFuelLevel == 0 {
-		fc.newLapData(scannedLap, scannedFuelLevel)
-	}
+	scannedLap := int(td.Values[LapNumber].Raw)
+	scannedFuelLevel := td.Values[FuelLevel].Float()
+
+	fc.updatePitStatus(td)
 
 	if fc.refueled(scannedFuelLevel) {
-		fc.Laps[fc.CurrentLap].StartFuelLevel = scannedFuelLevel
-		fc.Laps[fc.CurrentLap].InvalidateLap()
+		fc.currentLap.StartFuelLevel = scannedFuelLevel
+		fc.currentLap.InvalidateLap()
 	}
 
-	if scannedLap > fc.CurrentLap {
+	if scannedLap > fc.currentLap.Lap {
 		// Crossed the start finish line to the next lap
 		fc.startFinishLineCrossed(td, scannedLap, scannedFuelLevel)
+	} else if scannedLap < fc.currentLap.Lap {
+		// New session or a reset, the history doesn't apply anymore
+		fc.resetHistory()
+		fc.newLapData(td, scannedLap, scannedFuelLevel)
 	}
 
-	fc.updateRealTimeVariables(td, scannedLap, scannedFuelLevel)
+	fc.updateCurrentLapFuelUsage(td, scannedFuelLevel)
+	fc.updateStrategy(td, scannedFuelLevel)
+}
+
+// SessionChanged wipes the history, the laps of another session don't tell how
+// much fuel this one takes
+func (fc *FuelCalculator) SessionChanged(td *TelemetryData) {
+	fc.resetHistory()
+	fc.initializeFCTelemetryFields(td)
+	fc.onPitRoad = td.Values[OnPitRoad].Raw != 0
+	fc.newLapData(td, int(td.Values[LapNumber].Raw), td.Values[FuelLevel].Float())
 }
 
 // Simple helper to wipe state on session changes
 func (fc *FuelCalculator) resetHistory() {
-	fc.lapHistory = make([]float64, 0, fc.maxHistoryLaps)
-	fc.lastLapNumber = 0
-	fc.avgUsage = 0
-	fc.maxUsage = 0
+	fc.lapHistory = fc.lapHistory[:0]
+	fc.state = OutlapState
+	fc.averageFuelUsage = 0
+	fc.maxFuelUsage = 0
+	fc.averageLapTime = 0
 }
 
 func (fc *FuelCalculator) EnsureSubscribed() []FieldID {
-	return []FieldID{FuelLevel, LapNumber}
+	return []FieldID{
+		FuelLevel, LapNumber, LapDistPct, OnPitRoad, SessionTime,
+		SessionLapsRemain, SessionTimeRemain, FuelTankCapacity,
+	}
 }
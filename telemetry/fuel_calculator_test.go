@@ -0,0 +1,171 @@
+package telemetry
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func Test_FuelCalculatorLapRace(t *testing.T) {
+	fc := NewFuelCalculator(slog.Default())
+
+	replayLaps(t, fc, loadFixture(t, "fuel_lap_race.fixture"), map[int]lapExpectation{
+		// Started on pit road, not valid
+		0: {FCLastLap: 0.1, FCAverage: "No Data", FCMaxUsage: "No Data"},
+		1: {FCLastLap: 2.0, FCAverage: 2.0, FCMaxUsage: 2.0},
+		2: {FCLastLap: 2.2, FCAverage: 2.1, FCMaxUsage: 2.2},
+		// In lap with a refuel
+		3: {FCLastLap: 0.0, FCAverage: 2.1, FCMaxUsage: 2.2},
+		// Out lap
+		4: {FCLastLap: 2.1, FCAverage: 2.1, FCMaxUsage: 2.2},
+		5: {
+			FCLastLap:           2.4,
+			FCAverage:           2.2,
+			FCMaxUsage:          2.4,
+			FCExpectedLaps:      25.5 / 2.2,
+			FCRaceLapsRemaining: 4.99,
+			FCFuelToFinish:      4.99 * 2.2,
+			FCFuelToAdd:         0.0,
+		},
+	})
+}
+
+func Test_FuelCalculatorTimedRace(t *testing.T) {
+	fc := NewFuelCalculator(slog.Default())
+
+	replayLaps(t, fc, loadFixture(t, "fuel_timed_race.fixture"), map[int]lapExpectation{
+		1: {
+			FCAverage:           3.0,
+			FCExpectedLaps:      8.9 / 3,
+			FCRaceLapsRemaining: 8.99,
+			FCFuelToFinish:      8.99 * 3,
+			// Needs 18.07 but only the 3.1 free in the tank fit
+			FCFuelToAdd: 12 - 8.9,
+		},
+		2: {
+			FCLastLap:           3.0,
+			FCRaceLapsRemaining: 7.99,
+			FCFuelToFinish:      7.99 * 3,
+			FCFuelToAdd:         12 - 5.9,
+		},
+	})
+}
+
+// ibtLaps cuts the frames of an .ibt into laps of lapFrames frames, of a race of
+// raceLaps laps away from the pit lane. The fuel, the time and the rest stay as
+// they were recorded
+func ibtLaps(frames replayFrames, lapFrames int, raceLaps int) replayFrames {
+	set := func(td *TelemetryData, id FieldID, v float64) {
+		def, _ := GetField(id)
+		CoerceTransform(def.Type)(v, &td.Values[id])
+	}
+
+	return func(yield func(*TelemetryData) bool) {
+		frame := 0
+		for td := range frames {
+			lap := frame / lapFrames
+			set(td, LapNumber, float64(lap))
+			set(td, LapDistPct, float64(frame%lapFrames)/float64(lapFrames))
+			set(td, SessionLapsRemain, float64(raceLaps-lap))
+			set(td, OnPitRoad, 0)
+
+			if !yield(td) {
+				return
+			}
+			frame++
+		}
+	}
+}
+
+func Test_FuelCalculatorIBT(t *testing.T) {
+	const (
+		lapFrames = 240 // 4s, the recording has 4 laps of these
+		raceLaps  = 10
+	)
+
+	// The fuel on board when each lap starts, as recorded
+	var start []float64
+	frame := 0
+	for td := range loadIBT(t, "../test_telem.ibt") {
+		if frame%lapFrames == 0 {
+			start = append(start, td.Values[FuelLevel].Float())
+		}
+		frame++
+	}
+
+	// The first lap is the out lap, the rest count for the average
+	expect := map[int]lapExpectation{
+		0: {FCLastLap: start[0] - start[1], FCAverage: "No Data", FCRaceLapsRemaining: "No Data"},
+	}
+
+	sum := 0.0
+	for lap := 1; lap+1 < len(start); lap++ {
+		used := start[lap] - start[lap+1]
+		sum += used
+		average := sum / float64(lap)
+
+		expect[lap] = lapExpectation{
+			FCLastLap:           used,
+			FCAverage:           average,
+			FCExpectedLaps:      start[lap+1] / average,
+			FCRaceLapsRemaining: float64(raceLaps - lap - 1),
+			FCFuelToFinish:      float64(raceLaps-lap-1) * average,
+		}
+	}
+
+	if len(expect) < 4 {
+		t.Fatalf("expected the recording to make 4 laps, got %d", len(expect))
+	}
+
+	// The car drives off after a few seconds, the laps use more fuel each time
+	if used := expect[3][FCLastLap].(float64); used <= expect[1][FCLastLap].(float64) || used <= 0.01 {
+		t.Errorf("expected the fuel use to go up with the speed, got %f", used)
+	}
+
+	fc := NewFuelCalculator(slog.Default())
+	replayLaps(t, fc, ibtLaps(loadIBT(t, "../test_telem.ibt"), lapFrames, raceLaps), expect)
+}
+
+func Test_FuelCalculatorSessionChanged(t *testing.T) {
+	fc := NewFuelCalculator(slog.Default())
+
+	var last *TelemetryData
+	replay(fc, loadFixture(t, "fuel_lap_race.fixture"), func(frame int, td *TelemetryData) {
+		last = td
+	})
+
+	if len(fc.lapHistory) == 0 {
+		t.Fatalf("expected the race to leave some history")
+	}
+
+	last.VirtualBinds = []VirtualField{fc}
+	last.SessionChanged()
+
+	if last.Session != 1 {
+		t.Errorf("expected the session count to be 1, got %d", last.Session)
+	}
+
+	if len(fc.lapHistory) != 0 || fc.state != OutlapState {
+		t.Errorf("expected the history to be wiped, got %d laps in state %d", len(fc.lapHistory), fc.state)
+	}
+
+	checkFields(t, "after the session change", last, lapExpectation{
+		FCAverage: "No Data", FCMaxUsage: "No Data", FCLastLap: "No Data",
+	})
+}
+
+func Test_FuelCalculatorUnlimited(t *testing.T) {
+	fc := NewFuelCalculator(slog.Default())
+
+	var last *TelemetryData
+	replay(fc, loadFixture(t, "fuel_timed_race.fixture"), func(frame int, td *TelemetryData) {
+		last = td
+	})
+
+	// The session becomes a practice, what we worked out for the race goes away
+	last.Values[SessionTimeRemain].SetFloat64(unlimitedTime)
+	fc.updateStrategy(last, last.Values[FuelLevel].Float())
+
+	checkFields(t, "unlimited session", last, lapExpectation{
+		FCRaceLapsRemaining: "Unlimited", FCFuelToFinish: "No Data", FCFuelToAdd: "No Data",
+	})
+}
@@ -0,0 +1,115 @@
+package telemetry
+
+import (
+	"math"
+)
+
+const (
+	// lineRadius is how close to the start/finish line the car has to get for
+	// us to take it as driving through it
+	lineRadius = 15.0
+	// maxStep is the most the car can move between two positions, more than it
+	// is a reset or a teleport and the lap distance is lost until the line
+	maxStep = 50.0
+)
+
+// LapDistance works the lap distance out from the car's position, for the sims
+// that don't report it (BeamNG). The tracks need their start/finish line set up
+// on the track sectors: the lap starts when the car drives through one of them
+// and the distance is how far it drove since, over the length of the lap.
+//
+// The length from the configuration is only a first guess, every lap measures
+// it again. Without one the first lap is spent measuring it. The sims don't say
+// which track this is either, the track is the one whose line the car drove
+// through
+type LapDistance struct {
+	track  int     // Track whose line we drove through last, -1 if none
+	length float64 // Of the lap, 0 while unknown
+	driven float64 // Since the line, negative while unknown
+
+	last    [2]float64
+	hasLast bool
+
+	near     int     // Track whose line we are close to, -1 if none
+	nearDist float64 // Closest we got to it
+	crossed  bool    // We went through it already
+}
+
+func NewLapDistance() *LapDistance {
+	return &LapDistance{track: -1, near: -1, driven: -1}
+}
+
+// Update moves the car to x, y of the sim's world, in metres
+func (ld *LapDistance) Update(x, y float64) {
+	pos := [2]float64{x, y}
+	if ld.hasLast {
+		step := math.Hypot(pos[0]-ld.last[0], pos[1]-ld.last[1])
+		switch {
+		case step > maxStep:
+			ld.driven = -1
+		case ld.driven >= 0:
+			ld.driven += step
+		}
+	}
+	ld.last, ld.hasLast = pos, true
+
+	ld.checkLines(pos)
+}
+
+// checkLines looks for the car driving through a line, it did when it got as
+// close as it gets to it and starts moving away
+func (ld *LapDistance) checkLines(pos [2]float64) {
+	for k, ts := range trackSectors {
+		if len(ts.Line) != 2 {
+			continue
+		}
+
+		d := math.Hypot(pos[0]-ts.Line[0], pos[1]-ts.Line[1])
+		switch {
+		case d >= lineRadius:
+			if ld.near == k {
+				ld.near = -1
+			}
+		case ld.near != k:
+			ld.near, ld.nearDist, ld.crossed = k, d, false
+		case d <= ld.nearDist:
+			ld.nearDist = d
+		case !ld.crossed:
+			ld.crossed = true
+			ld.lineCrossed(k, d)
+		}
+	}
+}
+
+// lineCrossed starts a lap on track k, d past its line
+func (ld *LapDistance) lineCrossed(k int, d float64) {
+	switch {
+	case k != ld.track:
+		ld.track = k
+		ld.length = trackSectors[k].Length
+	case ld.driven > ld.length/2:
+		// The lap we just did is the best measure of the next one
+		ld.length = ld.driven
+	}
+
+	ld.driven = d
+}
+
+// Pct is the lap distance from 0 to 1, -1 while it isn't known
+func (ld *LapDistance) Pct() float64 {
+	if ld.track < 0 || ld.length <= 0 || ld.driven < 0 {
+		return -1
+	}
+
+	return min(ld.driven/ld.length, 0.9999)
+}
+
+// Track is the name of the track whose line the car drove through, "" until it
+// does
+func (ld *LapDistance) Track() string {
+	if ld.track < 0 || ld.track >= len(trackSectors) {
+		return ""
+	}
+
+	return trackSectors[ld.track].Track
+}
@@ -0,0 +1,106 @@
+package telemetry
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+)
+
+// lapRadius is the radius of the round track of the tests, its line is east of
+// the centre
+const lapRadius = 100.0
+
+// driveRound moves the car around the round track from angle from to angle to,
+// in degrees, a degree at a time
+func driveRound(ld *LapDistance, from int, to int, each func(deg int)) {
+	for deg := from; deg <= to; deg++ {
+		rad := float64(deg) * math.Pi / 180
+		ld.Update(lapRadius*math.Cos(rad), lapRadius*math.Sin(rad))
+		if each != nil {
+			each(deg)
+		}
+	}
+}
+
+func Test_LapDistance(t *testing.T) {
+	lap := 2 * math.Pi * lapRadius
+
+	tests := []struct {
+		name   string
+		length float64
+		// First degree the lap distance is known on, a lap is 360
+		known int
+	}{
+		{"test_measured", 0, 361},
+		{"test_configured", lap, 1},
+		{"test_configured_short", lap * 0.95, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetTrackSectors([]TrackSectors{{Track: "round", Line: []float64{lapRadius, 0}, Length: test.length}})
+			t.Cleanup(func() { SetTrackSectors(nil) })
+
+			ld := NewLapDistance()
+			driveRound(ld, -30, 3*360, func(deg int) {
+				pct := ld.Pct()
+				switch {
+				case deg < test.known:
+					if pct != -1 {
+						t.Fatalf("%d degrees: expected no lap distance yet, got %.3f", deg, pct)
+					}
+				case deg >= 360+test.known:
+					// Measured, within the degree it takes to notice the line
+					want := float64(deg%360) / 360
+					if math.Abs(math.Remainder(pct-want, 1)) > 0.005 {
+						t.Fatalf("%d degrees: expected %.3f, got %.3f", deg, want, pct)
+					}
+				}
+			})
+
+			if ld.Track() != "round" {
+				t.Errorf("expected the track to be found, got %q", ld.Track())
+			}
+		})
+	}
+
+	t.Run("test_teleport", func(t *testing.T) {
+		SetTrackSectors([]TrackSectors{{Track: "round", Line: []float64{lapRadius, 0}, Length: lap}})
+		t.Cleanup(func() { SetTrackSectors(nil) })
+
+		ld := NewLapDistance()
+		driveRound(ld, -10, 90, nil)
+		ld.Update(-lapRadius, 0)
+		if pct := ld.Pct(); pct != -1 {
+			t.Errorf("expected the lap distance to be lost, got %.3f", pct)
+		}
+
+		driveRound(ld, 180, 361, nil)
+		if pct := ld.Pct(); pct < 0 || pct > 0.01 {
+			t.Errorf("expected the lap to start over at the line, got %.3f", pct)
+		}
+	})
+}
+
+func Test_LapDistanceTiming(t *testing.T) {
+	SetTrackSectors([]TrackSectors{{Track: "round", Line: []float64{lapRadius, 0}}})
+	t.Cleanup(func() { SetTrackSectors(nil) })
+
+	// A degree every 100ms, 36s laps. The first lap measures the track, the
+	// second is timed
+	ld := NewLapDistance()
+	lt := NewLapTiming(slog.Default())
+	td := NewTelemetryData()
+	td.Values[SessionTime].Unused()
+
+	driveRound(ld, -10, 3*360+10, func(deg int) {
+		td.Values[LapDistPct].SetFloat32(float32(ld.Pct()))
+		td.LastDataPoll = td.InitialTime.Add(time.Duration(deg+10) * 100 * time.Millisecond)
+		lt.Process(td)
+	})
+
+	if got := td.Values[LTLastLap].Float(); math.Abs(got-36) > 0.2 {
+		t.Errorf("expected a 36s lap, got %s", td.Values[LTLastLap].String())
+	}
+}
@@ -0,0 +1,325 @@
+package telemetry
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+)
+
+const (
+	// lapTimingBuckets is how many points of the lap we keep in the reference,
+	// one every 0.1% of the lap
+	lapTimingBuckets = 1000
+
+	// The distance has to go from above wrapFrom to below wrapTo in a single
+	// frame for us to count it as crossing the line
+	lapWrapFrom = 0.9
+	lapWrapTo   = 0.1
+
+	// Moving more than lapMaxDistJump between frames faster than lapMaxDistRate
+	// (laps per second), or going back more than it, is a reset or a tow and not
+	// driving. No car does a lap in less than 10 seconds
+	lapMaxDistJump = 0.05
+	lapMaxDistRate = 0.1
+
+	lapTimeNoData  = "-:--.---"
+	lapDeltaNoData = "-.---"
+)
+
+// lapSamples holds the time it took to get to each bucket of the lap. The last
+// slot is the time of the full lap
+type lapSamples [lapTimingBuckets + 1]float64
+
+// at interpolates the time it took to get to the given lap distance
+func (ls *lapSamples) at(dist float64) float64 {
+	pos := dist * lapTimingBuckets
+	k := min(int(pos), lapTimingBuckets-1)
+	frac := pos - float64(k)
+
+	return ls[k] + (ls[k+1]-ls[k])*frac
+}
+
+// LapTiming acts as a Virtual Field. It times the laps by itself from the lap
+// distance, so it works with any provider that supplies one, even when the sim
+// doesn't time laps or has no delta of its own.
+//
+// The line is crossed when the lap distance wraps around. The best lap is kept
+// as a reference, the live delta is the difference between the time we took to
+// get to where we are and the time the reference took to get there.
+// Laps that didn't start on the line or had the car jump around the track
+// (resets, tows) are not valid and aren't taken as best.
+// Sims that don't report the lap distance have it worked out from the car's
+// position, see LapDistance. Providers without any get no lap timing, the
+// fields stay at No Data and it's logged once
+type LapTiming struct {
+	Logger *slog.Logger
+
+	setup *sync.Once
+
+	car      string
+	lastDist float64
+	lastTime float64
+	noDist   bool // The provider doesn't supply the lap distance, told once
+
+	lapStart float64 // Time the current lap started at, negative if unknown
+	lapValid bool
+	current  lapSamples
+	filled   int // Buckets of the current lap already sampled
+
+	lastLap     float64
+	bestLap     float64
+	sessionBest float64
+	reference   lapSamples // The best lap
+}
+
+func NewLapTiming(logger *slog.Logger) *LapTiming {
+	return &LapTiming{
+		Logger:   logger,
+		setup:    &sync.Once{},
+		lapStart: -1,
+	}
+}
+
+// lapTimingOutputs are the fields this timer writes to, numeric first and the
+// formatted versions of them after
+var lapTimingOutputs = []*FieldID{
+	&LTCurrentLap, &LTLastLap, &LTBestLap, &LTSessionBest, &LTDelta,
+}
+
+var lapTimingFormatted = []*FieldID{
+	&LTCurrentLapStr, &LTLastLapStr, &LTBestLapStr, &LTSessionBestStr,
+}
+
+func (lt *LapTiming) initializeLTTelemetryFields(td *TelemetryData) {
+	for _, id := range lapTimingOutputs {
+		td.Values[*id].Type = DataTypeSTRING
+		td.Values[*id].Str = "No Data"
+	}
+
+	for _, id := range lapTimingFormatted {
+		td.Values[*id].Type = DataTypeSTRING
+		td.Values[*id].Str = lapTimeNoData
+	}
+
+	td.Values[LTDeltaStr].Type = DataTypeSTRING
+	td.Values[LTDeltaStr].Str = lapDeltaNoData
+}
+
+// timingClock returns the current time in seconds, from the session time when
+// the provider has it and from our own clock otherwise
+func timingClock(td *TelemetryData) float64 {
+	if td.Values[SessionTime].IsFloat() {
+		return td.Values[SessionTime].Float()
+	}
+
+	return td.LastDataPoll.Sub(td.InitialTime).Seconds()
+}
+
+// lineCrossed reports if the lap distance wrapped around between two frames
+func lineCrossed(fromDist float64, toDist float64) bool {
+	return fromDist > lapWrapFrom && toDist < lapWrapTo
+}
+
+// crossingTime works out when the car went through point p of the lap from the
+// distance covered on each side of it. If the line was crossed the distances
+// after it must be past 1
+func crossingTime(fromDist, fromTime, toDist, toTime, p float64) float64 {
+	if toDist <= fromDist {
+		return toTime
+	}
+
+	return fromTime + (toTime-fromTime)*(p-fromDist)/(toDist-fromDist)
+}
+
+// distJumped reports if the car got from one distance to the other without
+// driving there
+func distJumped(fromDist, fromTime, toDist, toTime float64) bool {
+	moved := toDist - fromDist
+	if moved < -lapMaxDistJump {
+		return true
+	}
+
+	return moved > lapMaxDistJump && moved > lapMaxDistRate*(toTime-fromTime)
+}
+
+// FormatLapTime renders a lap time in seconds as m:ss.mmm
+func FormatLapTime(seconds float64) string {
+	if seconds < 0 || math.IsNaN(seconds) {
+		return lapTimeNoData
+	}
+
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%d:%02d.%03d", ms/60000, (ms/1000)%60, ms%1000)
+}
+
+// FormatDelta renders a delta in seconds with its sign, ex: +0.153
+func FormatDelta(seconds float64) string {
+	if math.IsNaN(seconds) {
+		return lapDeltaNoData
+	}
+
+	return fmt.Sprintf("%+.3f", seconds)
+}
+
+func setLapTime(td *TelemetryData, id FieldID, strID FieldID, seconds float64) {
+	td.Values[id].SetFloat32(float32(seconds))
+	td.Values[strID].Type = DataTypeSTRING
+	td.Values[strID].Str = FormatLapTime(seconds)
+}
+
+// sample records the time we got to every bucket between the last frame and
+// this one, interpolating between both frames
+func (lt *LapTiming) sample(dist float64, now float64) {
+	target := min(int(dist*lapTimingBuckets), lapTimingBuckets-1)
+	for ; lt.filled <= target; lt.filled++ {
+		bucketDist := float64(lt.filled) / lapTimingBuckets
+
+		t := now
+		if dist > lt.lastDist && bucketDist > lt.lastDist {
+			frac := (bucketDist - lt.lastDist) / (dist - lt.lastDist)
+			t = lt.lastTime + (now-lt.lastTime)*frac
+		}
+
+		lt.current[lt.filled] = t - lt.lapStart
+	}
+}
+
+// startLap starts timing a new lap, crossedAt is when we crossed the line or
+// negative if we don't know
+func (lt *LapTiming) startLap(crossedAt float64) {
+	lt.lapStart = crossedAt
+	lt.lapValid = crossedAt >= 0
+	lt.filled = 0
+}
+
+// lapCompleted is called when we cross the line, crossedAt is the time we did
+func (lt *LapTiming) lapCompleted(td *TelemetryData, crossedAt float64) {
+	if !lt.lapValid {
+		lt.Logger.Debug("lap is not valid, not timing it")
+		return
+	}
+
+	lapTime := crossedAt - lt.lapStart
+	for ; lt.filled < lapTimingBuckets; lt.filled++ {
+		lt.current[lt.filled] = lapTime
+	}
+	lt.current[lapTimingBuckets] = lapTime
+
+	lt.lastLap = lapTime
+	setLapTime(td, LTLastLap, LTLastLapStr, lapTime)
+
+	if lt.sessionBest == 0 || lapTime < lt.sessionBest {
+		lt.sessionBest = lapTime
+		setLapTime(td, LTSessionBest, LTSessionBestStr, lapTime)
+	}
+
+	if lt.bestLap == 0 || lapTime < lt.bestLap {
+		lt.Logger.Debug(fmt.Sprintf("new best lap: %s", FormatLapTime(lapTime)))
+		lt.bestLap = lapTime
+		lt.reference = lt.current
+		setLapTime(td, LTBestLap, LTBestLapStr, lapTime)
+	}
+}
+
+// reset wipes the laps, a new car makes the reference lap meaningless
+func (lt *LapTiming) reset(td *TelemetryData) {
+	lt.lastLap, lt.bestLap, lt.sessionBest = 0, 0, 0
+	lt.lapStart = -1
+	lt.lapValid = false
+	lt.initializeLTTelemetryFields(td)
+}
+
+// resetSession is for when the session time goes back, a new session or a
+// replay. The best lap stays as the reference
+func (lt *LapTiming) resetSession(td *TelemetryData) {
+	lt.sessionBest = 0
+	lt.lapStart = -1
+	lt.lapValid = false
+
+	td.Values[LTSessionBest].Type = DataTypeSTRING
+	td.Values[LTSessionBest].Str = "No Data"
+	td.Values[LTSessionBestStr].Type = DataTypeSTRING
+	td.Values[LTSessionBestStr].Str = lapTimeNoData
+}
+
+// SessionChanged starts the session over, the best lap stays as the reference
+func (lt *LapTiming) SessionChanged(td *TelemetryData) {
+	lt.resetSession(td)
+}
+
+func (lt *LapTiming) Process(td *TelemetryData) {
+	dist := td.Values[LapDistPct].Float()
+	now := timingClock(td)
+
+	lt.setup.Do(func() {
+		lt.initializeLTTelemetryFields(td)
+		lt.car = td.Values[CarName].Str
+		lt.lastDist = dist
+		lt.lastTime = now
+	})
+
+	if car := td.Values[CarName].Str; car != lt.car {
+		lt.car = car
+		lt.reset(td)
+	}
+
+	if now < lt.lastTime {
+		lt.resetSession(td)
+	}
+
+	if !td.Values[LapDistPct].IsFloat() && !lt.noDist {
+		lt.Logger.Warn("the provider doesn't supply the lap distance, laps won't be timed")
+		lt.noDist = true
+	}
+
+	// iRacing reports -1 when the car is not on track, right on the line it can
+	// also go a hair under 0
+	if !td.Values[LapDistPct].IsFloat() || dist < -lapMaxDistJump {
+		lt.lapValid = false
+		lt.lastDist, lt.lastTime = 0, now
+		return
+	}
+	dist = math.Max(dist, 0)
+
+	switch {
+	case lineCrossed(lt.lastDist, dist):
+		crossedAt := crossingTime(lt.lastDist, lt.lastTime, dist+1, now, 1)
+
+		lt.lapCompleted(td, crossedAt)
+		lt.startLap(crossedAt)
+		lt.lastDist, lt.lastTime = 0, crossedAt
+	case distJumped(lt.lastDist, lt.lastTime, dist, now):
+		lt.Logger.Debug(fmt.Sprintf("lap distance jumped from %.3f to %.3f", lt.lastDist, dist))
+		lt.lapValid = false
+	}
+
+	if lt.lapStart >= 0 {
+		lt.sample(dist, now)
+		lt.updateCurrent(td, dist, now)
+	}
+
+	lt.lastDist, lt.lastTime = dist, now
+}
+
+func (lt *LapTiming) updateCurrent(td *TelemetryData, dist float64, now float64) {
+	elapsed := now - lt.lapStart
+	setLapTime(td, LTCurrentLap, LTCurrentLapStr, elapsed)
+
+	if lt.bestLap == 0 || !lt.lapValid {
+		td.Values[LTDelta].Type = DataTypeSTRING
+		td.Values[LTDelta].Str = "No Data"
+		td.Values[LTDeltaStr].Type = DataTypeSTRING
+		td.Values[LTDeltaStr].Str = lapDeltaNoData
+		return
+	}
+
+	delta := elapsed - lt.reference.at(dist)
+	td.Values[LTDelta].SetFloat32(float32(delta))
+	td.Values[LTDeltaStr].Type = DataTypeSTRING
+	td.Values[LTDeltaStr].Str = FormatDelta(delta)
+}
+
+func (lt *LapTiming) EnsureSubscribed() []FieldID {
+	return []FieldID{LapDistPct, SessionTime, CarName}
+}
@@ -0,0 +1,103 @@
+package telemetry
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+func Test_LapTiming(t *testing.T) {
+	lt := NewLapTiming(slog.Default())
+
+	expect := map[int]lapExpectation{
+		// Joined mid lap, that one isn't timed
+		2: {LTLastLap: "No Data", LTCurrentLap: 0.5, LTCurrentLapStr: "0:00.500"},
+		3: {LTDelta: "No Data", LTDeltaStr: "-.---"},
+		5: {
+			LTLastLap: 60.0, LTLastLapStr: "1:00.000",
+			LTBestLap: 60.0, LTSessionBest: 60.0,
+		},
+		// 25s to half way, the reference took 29.5s
+		6: {LTCurrentLap: 25.0, LTDelta: -4.5, LTDeltaStr: "-4.500"},
+		8: {
+			LTLastLap: 55.0, LTBestLap: 55.0, LTBestLapStr: "0:55.000",
+			LTSessionBest: 55.0,
+		},
+		// Reset, no delta until the next lap
+		9: {LTDelta: "No Data"},
+		// The reset lap is not timed
+		11: {LTLastLap: 55.0},
+		// The reference got from 5% to 50% in 24.5s
+		12: {LTDelta: 1.5 - (0.5 + 24.5*0.01/0.45), LTDeltaStr: "+0.456"},
+		// New session, the best lap is kept
+		13: {LTSessionBest: "No Data", LTSessionBestStr: "-:--.---", LTBestLap: 55.0},
+	}
+
+	checked := 0
+	replay(lt, loadFixture(t, "lap_timing.fixture"), func(frame int, td *TelemetryData) {
+		if fields, ok := expect[frame]; ok {
+			checkFields(t, fmt.Sprintf("frame %d", frame), td, fields)
+			checked++
+		}
+	})
+
+	if checked != len(expect) {
+		t.Errorf("only %d of the %d frames were replayed", checked, len(expect))
+	}
+}
+
+func Test_FormatLapTime(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		want    string
+	}{
+		{83.4567, "1:23.457"},
+		{59.9996, "1:00.000"},
+		{0, "0:00.000"},
+		{-1, "-:--.---"},
+	}
+
+	for _, tc := range tests {
+		if got := FormatLapTime(tc.seconds); got != tc.want {
+			t.Errorf("FormatLapTime(%f) expected %q, got %q", tc.seconds, tc.want, got)
+		}
+	}
+}
+
+func Test_LapTimingIBT(t *testing.T) {
+	lt := NewLapTiming(slog.Default())
+
+	// The recording crosses the line once coming out of the pits, the lap it
+	// starts is being timed by the end but there's nothing to compare it to
+	var last *TelemetryData
+	replay(lt, loadIBT(t, "../test_telem.ibt"), func(_ int, td *TelemetryData) {
+		last = td
+	})
+
+	checkFields(t, "end of recording", last, lapExpectation{
+		LTLastLap: "No Data", LTDelta: "No Data",
+	})
+	if cur := last.Values[LTCurrentLap].Float(); cur <= 0 {
+		t.Errorf("expected the current lap to be timed, got %f", cur)
+	}
+}
+
+func Test_LapTimingNoLapDist(t *testing.T) {
+	lt := NewLapTiming(slog.Default())
+
+	// What a provider without the lap distance sends
+	td := NewTelemetryData()
+	for k := range 3 {
+		td.Values[LapDistPct].Unused()
+		td.Values[SessionTime].SetFloat64(float64(k))
+		lt.Process(td)
+	}
+
+	if !lt.noDist {
+		t.Errorf("expected the missing lap distance to be noticed")
+	}
+
+	checkFields(t, "without a lap distance", td, lapExpectation{
+		LTCurrentLap: "No Data", LTLastLap: "No Data", LTDelta: "No Data",
+	})
+}
@@ -0,0 +1,287 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SourceState is where a provider is with the sim it reads from
+type SourceState uint8
+
+const (
+	SourceWaiting      SourceState = iota // Looking for the sim
+	SourceConnected                       // Found the sim, it isn't sending telemetry yet
+	SourceStreaming                       // Telemetry is coming in
+	SourceDisconnected                    // The sim went away or the recording ended
+)
+
+var sourceStateNames = []string{"WAITING", "CONNECTED", "STREAMING", "DISCONNECTED"}
+
+func (s SourceState) String() string {
+	if int(s) < len(sourceStateNames) {
+		return sourceStateNames[s]
+	}
+
+	return "UNKNOWN"
+}
+
+// SourceEvent tells the listeners that the source of a provider changed state
+// or moved to another session
+type SourceEvent struct {
+	Provider string
+	State    SourceState
+	Session  uint32 // TelemetryData.Session when it happened
+	Err      error  // Why the source was lost, when we know
+	Time     time.Time
+}
+
+// LifecycleProvider is a provider that reports the state of its source. The
+// events channel lives as long as the provider does
+type LifecycleProvider interface {
+	TelemetryProvider
+	Events() <-chan SourceEvent
+}
+
+// Backoff hands out the time to wait between retries, doubling it from Min up
+// to Max
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+
+	cur time.Duration
+}
+
+// Next returns how long to wait before the next retry
+func (b *Backoff) Next() time.Duration {
+	switch {
+	case b.cur < b.Min:
+		b.cur = b.Min
+	case b.cur < b.Max:
+		b.cur = min(2*b.cur, b.Max)
+	}
+
+	return b.cur
+}
+
+// Reset goes back to Min, for when the retries paid off
+func (b *Backoff) Reset() {
+	b.cur = 0
+}
+
+// eventsBuffer is how many events wait for the listeners before they're dropped
+const eventsBuffer = 16
+
+// SourceLifecycle keeps the state of a provider's source and reports it on the
+// events channel. Providers embed it, which makes them LifecycleProviders, and
+// either drive it with Run or move it through the states themselves
+type SourceLifecycle struct {
+	logger *slog.Logger
+	name   string
+
+	// The provider's data, the events carry its session
+	mut  sync.Locker
+	data *TelemetryData
+
+	state  SourceState
+	events chan SourceEvent
+}
+
+// NewSourceLifecycle starts the lifecycle of the named provider at state. mut
+// is the lock the provider holds while it writes data
+func NewSourceLifecycle(logger *slog.Logger, name string, state SourceState, mut sync.Locker,
+	data *TelemetryData,
+) SourceLifecycle {
+	return SourceLifecycle{
+		logger: logger,
+		name:   name,
+		mut:    mut,
+		data:   data,
+		state:  state,
+		events: make(chan SourceEvent, eventsBuffer),
+	}
+}
+
+// Events returns the channel the state of the source is reported on
+func (l *SourceLifecycle) Events() <-chan SourceEvent {
+	return l.events
+}
+
+// State is where the source is at
+func (l *SourceLifecycle) State() SourceState {
+	return l.state
+}
+
+// SetState moves to the given state and tells the listeners about it
+func (l *SourceLifecycle) SetState(state SourceState, err error) {
+	if err != nil {
+		l.logger.Info(fmt.Sprintf("%s %s -> %s: %v", l.name, l.state, state, err))
+	} else {
+		l.logger.Info(fmt.Sprintf("%s %s -> %s", l.name, l.state, state))
+	}
+
+	l.state = state
+	l.Publish(err)
+}
+
+// Lost reports we lost the source, the listeners stop getting frames
+func (l *SourceLifecycle) Lost(err error) {
+	l.SetState(SourceDisconnected, err)
+}
+
+// Publish sends the current state to the listeners, events are dropped when
+// nobody reads them
+func (l *SourceLifecycle) Publish(err error) {
+	l.mut.Lock()
+	session := l.data.Session
+	l.mut.Unlock()
+
+	select {
+	case l.events <- SourceEvent{
+		Provider: l.name,
+		State:    l.state,
+		Session:  session,
+		Err:      err,
+		Time:     time.Now(),
+	}:
+	default:
+		l.logger.Warn(fmt.Sprintf("dropped the %s event, nobody is listening", l.state))
+	}
+}
+
+// SourceSteps are what a provider does on each state of its source, Run takes
+// care of the rest
+type SourceSteps struct {
+	// Connect looks for the sim, the source is connected once it's found
+	Connect func() error
+	// Ready reports if the sim sends telemetry, the source streams once it
+	// does. Failing sends the source back to waiting
+	Ready func() (bool, error)
+	// Read reads a frame, reports if the session changed. Failing loses the
+	// source
+	Read func() (bool, error)
+	// Reset drops what Connect found, for when the source goes back to waiting
+	Reset func()
+
+	// Tick paces the frames
+	Tick <-chan time.Time
+	// Retry is how long to wait for the sim between tries. Without it the
+	// tries wait for a tick, for the sims that send to us
+	Retry *Backoff
+	// Once is for sources that can't come back, like a recording. Nothing
+	// comes after they're lost
+	Once bool
+}
+
+// Run runs the lifecycle of the source until the context is done: it waits for
+// the sim, waits for it to send telemetry, sends the frames to out and starts
+// over when the sim goes away. Frames out can't take are skipped
+func (l *SourceLifecycle) Run(ctx context.Context, steps SourceSteps, out chan<- TelemetryData) {
+	l.data.InitialTime = time.Now()
+
+	// Let the listeners know where we are starting from
+	l.Publish(nil)
+
+	go func() {
+		tries := 0
+		wait := func() {
+			tries++
+			if steps.Retry != nil {
+				sleep(ctx, steps.Retry.Next())
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-steps.Tick:
+			}
+		}
+		found := func() {
+			tries = 0
+			if steps.Retry != nil {
+				steps.Retry.Reset()
+			}
+		}
+
+		for {
+			// Explicitly intercept cancellation
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			switch l.state {
+			case SourceWaiting:
+				if err := steps.Connect(); err != nil {
+					if tries == 0 {
+						l.logger.Debug(fmt.Sprintf("%s not found: %v", l.name, err))
+					}
+					wait()
+					continue
+				}
+
+				found()
+				l.SetState(SourceConnected, nil)
+
+			case SourceConnected:
+				ready, err := steps.Ready()
+				if err != nil {
+					steps.Reset()
+					l.SetState(SourceWaiting, err)
+					continue
+				}
+				if !ready {
+					wait()
+					continue
+				}
+
+				found()
+				l.SetState(SourceStreaming, nil)
+
+			case SourceStreaming:
+				select {
+				case <-ctx.Done():
+					return
+				case <-steps.Tick:
+				}
+
+				sessionChanged, err := steps.Read()
+				if err != nil {
+					l.Lost(err)
+					continue
+				}
+
+				if sessionChanged {
+					l.Publish(nil)
+				}
+
+				// Publish data
+				select {
+				case out <- *l.data:
+				default:
+					// skip this data, don't allow publishers to lag behind
+				}
+
+			case SourceDisconnected:
+				if steps.Once {
+					<-ctx.Done()
+					return
+				}
+
+				steps.Reset()
+				l.SetState(SourceWaiting, nil)
+			}
+		}
+	}()
+}
+
+// sleep waits for d unless the context is done first
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
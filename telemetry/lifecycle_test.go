@@ -0,0 +1,88 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Backoff(t *testing.T) {
+	b := Backoff{Min: 100 * time.Millisecond, Max: time.Second}
+
+	expect := []time.Duration{
+		100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond,
+		800 * time.Millisecond, time.Second, time.Second,
+	}
+	for k, want := range expect {
+		if got := b.Next(); got != want {
+			t.Errorf("retry %d: expected %s, got %s", k, want, got)
+		}
+	}
+
+	b.Reset()
+	if got := b.Next(); got != b.Min {
+		t.Errorf("expected %s after the reset, got %s", b.Min, got)
+	}
+}
+
+func Test_SourceLifecycleRun(t *testing.T) {
+	var mut sync.Mutex
+	data := NewTelemetryData()
+	l := NewSourceLifecycle(slog.New(slog.DiscardHandler), "test", SourceWaiting, &mut, data)
+
+	// The sim is found on the second try, streams two frames and goes away
+	tick := make(chan time.Time)
+	tries, frames, resets := 0, 0, 0
+	errGone := errors.New("gone")
+	steps := SourceSteps{
+		Connect: func() error {
+			if tries++; tries < 2 {
+				return errGone
+			}
+			return nil
+		},
+		Ready: func() (bool, error) { return true, nil },
+		Read: func() (bool, error) {
+			if frames++; frames > 2 {
+				return false, errGone
+			}
+			return frames == 2, nil
+		},
+		Reset: func() { resets++ },
+		Tick:  tick,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := make(chan TelemetryData, 1)
+	l.Run(ctx, steps, out)
+
+	expect := []SourceState{
+		SourceWaiting, SourceConnected, SourceStreaming, SourceStreaming, SourceDisconnected, SourceWaiting,
+	}
+	var states []SourceState
+	for len(states) < len(expect) {
+		select {
+		case tick <- time.Now():
+		case <-out:
+		case ev := <-l.Events():
+			states = append(states, ev.State)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out, got the states %v", states)
+		}
+	}
+
+	if !slices.Equal(states, expect) {
+		t.Errorf("expected the states %v, got %v", expect, states)
+	}
+
+	cancel()
+	if resets != 1 {
+		t.Errorf("expected the source to be reset once, got %d", resets)
+	}
+}
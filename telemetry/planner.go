@@ -0,0 +1,138 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// SubscriptionPlan is what a provider needs to serve a set of windows: the
+// primitive fields it has to read and the virtual fields to run after reading
+// them, already in an order where every virtual field runs after the ones it
+// depends on
+type SubscriptionPlan struct {
+	Windows    map[int16]FieldID
+	Primitives []FieldID
+	Virtuals   []VirtualField
+}
+
+type visitState uint8
+
+const (
+	unvisited visitState = iota
+	visiting
+	visited
+)
+
+type planner struct {
+	logger *slog.Logger
+	lookup func(FieldID) (*FieldDef, bool)
+	plan   *SubscriptionPlan
+	fields map[FieldID]visitState
+	procs  map[*Processor]visitState
+	errs   []error
+}
+
+// PlanSubscription works out everything that has to run to fill the requested
+// window -> field map. Dependencies are followed transitively and each virtual
+// field is built only once, no matter how many of its outputs are requested.
+// Fields that can't be planned are reported in the error, the returned plan
+// still serves the rest
+func PlanSubscription(logger *slog.Logger, requested map[int16]FieldID) (*SubscriptionPlan, error) {
+	return planSubscription(logger, requested, GetField)
+}
+
+// planSubscription plans against the fields lookup knows, the registry outside
+// of the tests
+func planSubscription(logger *slog.Logger, requested map[int16]FieldID,
+	lookup func(FieldID) (*FieldDef, bool),
+) (*SubscriptionPlan, error) {
+	p := &planner{
+		logger: logger,
+		lookup: lookup,
+		plan: &SubscriptionPlan{
+			Windows: requested,
+		},
+		fields: make(map[FieldID]visitState),
+		procs:  make(map[*Processor]visitState),
+	}
+
+	// Go through the windows in order so the plan is the same every time
+	for _, winID := range slices.Sorted(maps.Keys(requested)) {
+		p.visitField(requested[winID], nil)
+	}
+
+	return p.plan, errors.Join(p.errs...)
+}
+
+func (p *planner) visitField(id FieldID, path []string) {
+	def, ok := p.lookup(id)
+	if !ok {
+		p.errs = append(p.errs, fmt.Errorf("unknown field id %d", id))
+		return
+	}
+
+	switch p.fields[id] {
+	case visited:
+		return
+	case visiting:
+		p.errs = append(p.errs, fmt.Errorf("dependency cycle: %v -> %s", path, def.Key))
+		return
+	}
+
+	p.fields[id] = visiting
+
+	if def.IsVirtual() {
+		p.visitProcessor(def.Virtual, append(slices.Clone(path), def.Key))
+	} else {
+		p.plan.Primitives = append(p.plan.Primitives, id)
+	}
+
+	p.fields[id] = visited
+}
+
+func (p *planner) visitProcessor(proc *Processor, path []string) {
+	switch p.procs[proc] {
+	case visited:
+		return
+	case visiting:
+		p.errs = append(p.errs, fmt.Errorf("dependency cycle: %v", path))
+		return
+	}
+
+	p.procs[proc] = visiting
+
+	vf := proc.Build(p.logger)
+	for _, dep := range vf.EnsureSubscribed() {
+		p.visitField(dep, path)
+	}
+
+	// Post order, whatever this processor depends on is already in the list
+	p.plan.Virtuals = append(p.plan.Virtuals, vf)
+	p.procs[proc] = visited
+}
+
+// Apply sets up the data to receive this plan. It clears any previous
+// subscription, routes each field to its windows and installs the virtual
+// fields. The provider is left to set up ActiveBinds for the primitives.
+// Negative window ids are fields the host wants for itself (ex: the flag
+// alerts), they are read but not routed anywhere
+func (plan *SubscriptionPlan) Apply(td *TelemetryData) {
+	for k := range td.Values {
+		td.Values[k].IDs = nil
+	}
+
+	for _, winID := range slices.Sorted(maps.Keys(plan.Windows)) {
+		id := plan.Windows[winID]
+		if _, ok := GetField(id); !ok || winID < 0 {
+			continue
+		}
+
+		td.Values[id].IDs = append(td.Values[id].IDs, winID)
+	}
+
+	td.ActiveBinds = make([]BoundField, 0, len(plan.Primitives))
+	td.VirtualBinds = plan.Virtuals
+}
@@ -0,0 +1,141 @@
+package telemetry
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+type testVirtualField struct {
+	id   FieldID
+	deps []FieldID
+}
+
+func (tv *testVirtualField) Process(*TelemetryData) {}
+
+func (tv *testVirtualField) EnsureSubscribed() []FieldID {
+	return tv.deps
+}
+
+// testFields are virtual fields the planner sees on top of the registry, they
+// get the ids past its end so the registry is left alone
+type testFields map[FieldID]*FieldDef
+
+func (tf testFields) virtual(key string, deps ...FieldID) FieldID {
+	id := FieldID(MaxFields + len(tf))
+	tf[id] = &FieldDef{ID: id, Key: key, Name: key, Virtual: &Processor{
+		Name:  key,
+		Build: func(*slog.Logger) VirtualField { return &testVirtualField{id: id, deps: deps} },
+	}}
+
+	return id
+}
+
+func (tf testFields) lookup(id FieldID) (*FieldDef, bool) {
+	if def, ok := tf[id]; ok {
+		return def, true
+	}
+
+	return GetField(id)
+}
+
+func Test_PlanSubscription(t *testing.T) {
+	t.Run("test_dependencies_are_bound", func(t *testing.T) {
+		plan, err := PlanSubscription(slog.Default(), map[int16]FieldID{1: FCAverage})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expect := NewFuelCalculator(slog.Default()).EnsureSubscribed()
+		if !slices.Equal(plan.Primitives, expect) {
+			t.Errorf("expected primitives %v, got %v", expect, plan.Primitives)
+		}
+
+		if len(plan.Virtuals) != 1 {
+			t.Errorf("expected 1 virtual field, got %d", len(plan.Virtuals))
+		}
+	})
+
+	t.Run("test_virtual_built_once", func(t *testing.T) {
+		plan, err := PlanSubscription(slog.Default(), map[int16]FieldID{
+			1: FCCurrentLap, 2: FCLastLap, 3: FCAverage, 4: FCExpectedLaps, 5: FuelLevel,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(plan.Virtuals) != 1 {
+			t.Errorf("expected 1 virtual field, got %d", len(plan.Virtuals))
+		}
+
+		expect := NewFuelCalculator(slog.Default()).EnsureSubscribed()
+		if len(plan.Primitives) != len(expect) {
+			t.Errorf("expected %d primitives, got %v", len(expect), plan.Primitives)
+		}
+	})
+
+	t.Run("test_transitive_order", func(t *testing.T) {
+		// outer needs inner and Speed, inner needs RPM
+		fields := testFields{}
+		inner := fields.virtual("PlannerInner", RPM)
+		outer := fields.virtual("PlannerOuter", inner, Speed)
+
+		plan, err := planSubscription(slog.Default(), map[int16]FieldID{1: outer}, fields.lookup)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expect := []FieldID{RPM, Speed}
+		if !slices.Equal(plan.Primitives, expect) {
+			t.Errorf("expected primitives %v, got %v", expect, plan.Primitives)
+		}
+
+		if len(plan.Virtuals) != 2 || plan.Virtuals[0].(*testVirtualField).id != inner {
+			t.Errorf("expected the inner field to run first: %+v", plan.Virtuals)
+		}
+	})
+
+	t.Run("test_cycle", func(t *testing.T) {
+		// a needs b, the next field, and b needs a
+		fields := testFields{}
+		a := fields.virtual("PlannerCycleA", FieldID(MaxFields+1))
+		fields.virtual("PlannerCycleB", a)
+
+		_, err := planSubscription(slog.Default(), map[int16]FieldID{1: a}, fields.lookup)
+		if err == nil {
+			t.Errorf("expected a dependency cycle error")
+		}
+	})
+}
+
+func Test_PlanApply(t *testing.T) {
+	td := NewTelemetryData()
+	td.Values[RPM].IDs = []int16{9}
+
+	plan, _ := PlanSubscription(slog.Default(), map[int16]FieldID{2: Speed, 1: Speed})
+	plan.Apply(td)
+
+	if len(td.Values[RPM].IDs) != 0 {
+		t.Errorf("expected the old subscription to be cleared")
+	}
+
+	if !slices.Equal(td.Values[Speed].IDs, []int16{1, 2}) {
+		t.Errorf("expected Speed to go to windows 1 and 2, got %v", td.Values[Speed].IDs)
+	}
+}
+
+func Test_PlanApplyHostFields(t *testing.T) {
+	td := NewTelemetryData()
+
+	plan, _ := PlanSubscription(slog.Default(), map[int16]FieldID{-1: FlagState, 1: Speed})
+	plan.Apply(td)
+
+	if len(td.Values[FlagState].IDs) != 0 {
+		t.Errorf("expected the host field not to go to any window, got %v", td.Values[FlagState].IDs)
+	}
+
+	if !slices.Contains(plan.Primitives, Flags) || len(td.VirtualBinds) != 1 {
+		t.Errorf("expected the host field to be read, got %v and %d virtual fields",
+			plan.Primitives, len(td.VirtualBinds))
+	}
+}
@@ -0,0 +1,47 @@
+package telemetry
+
+import (
+	"fmt"
+	"time"
+)
+
+// Speeds a recording can be played at
+const (
+	MinPlaybackSpeed = 0.25
+	MaxPlaybackSpeed = 8.0
+)
+
+// PlaybackStatus is where the playback of a recording is at
+type PlaybackStatus struct {
+	Time   time.Duration // From the start of the recording
+	Length time.Duration
+	Lap    int
+	Speed  float64
+	Paused bool
+	Loop   bool
+}
+
+// PlaybackProvider is a provider that plays a recording, it can be controlled
+// while it streams
+type PlaybackProvider interface {
+	LifecycleProvider
+	Pause()
+	Resume()
+	SetSpeed(speed float64) error
+	SetLoop(loop bool)
+	// Seek goes to the given time from the start of the recording
+	Seek(t time.Duration) error
+	// SeekLap goes to the start of the given lap
+	SeekLap(lap int) error
+	Status() PlaybackStatus
+}
+
+// ValidatePlaybackSpeed checks the speed is one we can play at
+func ValidatePlaybackSpeed(speed float64) error {
+	if speed < MinPlaybackSpeed || speed > MaxPlaybackSpeed {
+		return fmt.Errorf("playback speed %gx is out of %gx..%gx", speed,
+			MinPlaybackSpeed, MaxPlaybackSpeed)
+	}
+
+	return nil
+}
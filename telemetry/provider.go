@@ -2,6 +2,8 @@
 package telemetry
 
 type TelemetryProvider interface {
+	// Name is the sim the telemetry comes from, as the field sources know it
+	Name() string
 	StopStream()
 	Stream() (<-chan TelemetryData, error)
 	Subscribe(map[int16]FieldID)
@@ -0,0 +1,237 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	conv "esdi/conversions"
+)
+
+// Names of the providers as the registry knows them. The providers use these as
+// their NAME so a field's sources and the provider list never drift apart
+const (
+	SourceIRacing      = "iRacing"
+	SourceBeamNG       = "BeamNG.drive"
+	SourceAssettoCorsa = "Assetto Corsa"
+	SourceF1           = "F1"
+	SourceLFS          = "Live for Speed"
+)
+
+// MaxFields is the capacity of the registry. TelemetryData keeps its values in a
+// fixed size array so that copying it around the channels stays cheap and safe.
+// The built-in fields take about half of it, the rest is for the user's virtual
+// fields
+const MaxFields = 512
+
+// ErrRegistryFull is returned when a field doesn't fit in the registry anymore
+var ErrRegistryFull = errors.New("telemetry registry is full")
+
+type FieldID uint16
+
+type Category uint8
+
+const (
+	CategoryCar Category = iota
+	CategoryEngine
+	CategoryElectrics
+	CategoryAdjustments
+	CategoryLap
+	CategoryTyres
+	CategoryInputs
+	CategorySuspension
+	CategorySession
+	CategoryVirtual
+	CategoryInternal
+)
+
+var CategoryNames = []string{
+	CategoryCar:         "Car",
+	CategoryEngine:      "Engine",
+	CategoryElectrics:   "Electrics",
+	CategoryAdjustments: "Adjustments",
+	CategoryLap:         "Lap",
+	CategoryTyres:       "Tyres",
+	CategoryInputs:      "Inputs",
+	CategorySuspension:  "Suspension",
+	CategorySession:     "Session",
+	CategoryVirtual:     "Virtual",
+	CategoryInternal:    "Internal",
+}
+
+func (c Category) String() string {
+	if int(c) >= len(CategoryNames) {
+		return "Unknown"
+	}
+
+	return CategoryNames[c]
+}
+
+// FieldDef describes a telemetry field. Every field is described exactly once, in
+// fields.go, and the rest of the application (providers, layouts, UI) reads from
+// here instead of keeping their own lists
+type FieldDef struct {
+	ID       FieldID
+	Key      string    // Identifier of the field, no spaces, ex: LFtempL
+	Name     string    // Display name, this is what the layouts store
+	Unit     conv.Unit // Unit the providers fill the field in
+	Category Category
+	Type     DataType
+	// Elem and Len describe the elements of DataTypeARRAY fields, the unit
+	// applies to every element
+	Elem DataType
+	Len  int
+	// Sources maps a provider name to the name of the channel that provider
+	// reads this field from. Arrays can list a channel per element, comma
+	// separated, when the provider has no array for them
+	Sources map[string]string
+	// Virtual is the processor that derives this field from other fields.
+	// Its nil for primitive fields
+	Virtual *Processor
+}
+
+// Processor describes a VirtualField. Fields that are all computed by the same
+// VirtualField (ex: the fuel calculator outputs) share the same Processor so
+// it only gets built once
+type Processor struct {
+	Name  string
+	Build func(logger *slog.Logger) VirtualField
+}
+
+// IsVirtual reports whether the field is derived from other fields
+func (fd *FieldDef) IsVirtual() bool {
+	return fd.Virtual != nil
+}
+
+// SourceKey returns the channel the given provider reads this field from
+func (fd *FieldDef) SourceKey(provider string) (string, bool) {
+	key, ok := fd.Sources[provider]
+	return key, ok
+}
+
+// SourceKeys returns the channels the given provider reads this field from, a
+// single one unless it's an array made from a channel per element
+func (fd *FieldDef) SourceKeys(provider string) []string {
+	key, ok := fd.Sources[provider]
+	if !ok {
+		return nil
+	}
+
+	return strings.Split(key, ",")
+}
+
+var (
+	registry      = make([]FieldDef, 0, MaxFields)
+	fieldNameToID = make(map[string]FieldID, MaxFields)
+	fieldKeyToID  = make(map[string]FieldID, MaxFields)
+)
+
+// Register adds a new field to the registry and returns its FieldID. It panics
+// on duplicated names and keys given those are programming errors
+func Register(def FieldDef) FieldID {
+	id, err := register(def)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return id
+}
+
+func register(def FieldDef) (FieldID, error) {
+	if len(registry) >= MaxFields {
+		return 0, fmt.Errorf("%w, it holds %d fields and can't take %q", ErrRegistryFull, MaxFields, def.Key)
+	}
+
+	if _, exists := fieldNameToID[def.Name]; exists {
+		return 0, fmt.Errorf("telemetry field name %q registered twice", def.Name)
+	}
+
+	if _, exists := fieldKeyToID[def.Key]; exists {
+		return 0, fmt.Errorf("telemetry field key %q registered twice", def.Key)
+	}
+
+	if def.Type == DataTypeARRAY {
+		if def.Len <= 0 || def.Len > MaxArrayLen {
+			return 0, fmt.Errorf("telemetry array %q has %d elements, it can have 1 to %d",
+				def.Key, def.Len, MaxArrayLen)
+		}
+
+		if !def.Elem.numeric() {
+			return 0, fmt.Errorf("telemetry array %q has non numeric elements", def.Key)
+		}
+	}
+
+	def.ID = FieldID(len(registry))
+	registry = append(registry, def)
+
+	fieldNameToID[def.Name] = def.ID
+	fieldKeyToID[def.Key] = def.ID
+
+	return def.ID, nil
+}
+
+// FieldCount returns the number of registered fields
+func FieldCount() int {
+	return len(registry)
+}
+
+// Fields returns the registered fields ordered by their FieldID
+func Fields() []FieldDef {
+	return registry
+}
+
+// FieldsFromSource returns the fields the given provider supplies
+func FieldsFromSource(provider string) []FieldDef {
+	fields := make([]FieldDef, 0, len(registry))
+	for _, def := range registry {
+		if _, ok := def.Sources[provider]; ok {
+			fields = append(fields, def)
+		}
+	}
+
+	return fields
+}
+
+func GetField(id FieldID) (*FieldDef, bool) {
+	if int(id) >= len(registry) {
+		return nil, false
+	}
+
+	return &registry[id], true
+}
+
+func GetFieldName(id FieldID) string {
+	if int(id) >= len(registry) {
+		return "Unknown"
+	}
+
+	return registry[id].Name
+}
+
+func GetFieldID(name string) (FieldID, bool) {
+	id, ok := fieldNameToID[name]
+	return id, ok
+}
+
+func GetFieldIDByKey(key string) (FieldID, bool) {
+	id, ok := fieldKeyToID[key]
+	return id, ok
+}
+
+// ValidateSources checks that every channel the registry expects from a provider
+// is known by it. Providers call this when they are built so a field missing its
+// implementation is reported instead of silently sending nothing
+func ValidateSources(provider string, hasChannel func(key string) bool) error {
+	var errs []error
+	for _, def := range FieldsFromSource(provider) {
+		for _, key := range def.SourceKeys(provider) {
+			if !hasChannel(key) {
+				errs = append(errs, fmt.Errorf("%s: field %q expects unknown channel %q",
+					provider, def.Name, key))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,95 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func Test_Registry(t *testing.T) {
+	for k, def := range Fields() {
+		if def.ID != FieldID(k) {
+			t.Errorf("%s: expected id %d, got %d", def.Key, k, def.ID)
+		}
+
+		id, ok := GetFieldID(def.Name)
+		if !ok || id != def.ID {
+			t.Errorf("%s: name lookup returned %d, %v", def.Key, id, ok)
+		}
+
+		id, ok = GetFieldIDByKey(def.Key)
+		if !ok || id != def.ID {
+			t.Errorf("%s: key lookup returned %d, %v", def.Key, id, ok)
+		}
+	}
+
+	if _, ok := GetFieldID("Not A Field"); ok {
+		t.Errorf("expected unknown field name to fail the lookup")
+	}
+}
+
+func Test_ValidateSources(t *testing.T) {
+	known := map[string]bool{}
+	for _, def := range FieldsFromSource(SourceBeamNG) {
+		known[def.Sources[SourceBeamNG]] = true
+	}
+
+	err := ValidateSources(SourceBeamNG, func(key string) bool { return known[key] })
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	delete(known, "Speed")
+	err = ValidateSources(SourceBeamNG, func(key string) bool { return known[key] })
+	if err == nil {
+		t.Errorf("expected an error for the missing Speed channel")
+	}
+}
+
+func Test_RegisterArray(t *testing.T) {
+	tests := []FieldDef{
+		{Key: "TestArrayEmpty", Name: "Test Array Empty", Type: DataTypeARRAY, Elem: DataTypeFLOAT32},
+		{Key: "TestArrayLong", Name: "Test Array Long", Type: DataTypeARRAY, Elem: DataTypeFLOAT32,
+			Len: MaxArrayLen + 1},
+		{Key: "TestArrayStr", Name: "Test Array Str", Type: DataTypeARRAY, Elem: DataTypeSTRING, Len: 4},
+	}
+
+	for _, def := range tests {
+		if _, err := register(def); err == nil {
+			t.Errorf("%s: expected an error", def.Key)
+		}
+	}
+
+	def, _ := GetField(TyreTemps)
+	keys := def.SourceKeys(SourceIRacing)
+	if len(keys) != def.Len || keys[0] != "LFtempCL" || keys[11] != "RRtempCR" {
+		t.Errorf("expected a channel per tyre temp, got %v", keys)
+	}
+}
+
+func Test_RegistryFull(t *testing.T) {
+	if FieldCount() > MaxFields/2 {
+		t.Errorf("the built-in fields take %d of %d, leave room for the user's", FieldCount(), MaxFields)
+	}
+
+	// Put the registry back, the other tests share it
+	saved, names, keys := slices.Clone(registry), maps.Clone(fieldNameToID), maps.Clone(fieldKeyToID)
+	t.Cleanup(func() {
+		registry, fieldNameToID, fieldKeyToID = saved, names, keys
+	})
+
+	var err error
+	for k := FieldCount(); k <= MaxFields && err == nil; k++ {
+		_, err = RegisterExpression(ExpressionDef{Key: fmt.Sprintf("TestFull%d", k), Expr: "Speed * 2"})
+	}
+
+	if !errors.Is(err, ErrRegistryFull) {
+		t.Fatalf("expected the registry to fill up, got %v", err)
+	}
+
+	if FieldCount() != MaxFields {
+		t.Errorf("expected %d fields, got %d", MaxFields, FieldCount())
+	}
+}
@@ -0,0 +1,217 @@
+package telemetry
+
+import (
+	"bufio"
+	"fmt"
+	"iter"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ESilva15/goirsdk"
+)
+
+// This file is a small harness to replay recorded telemetry through virtual
+// fields, so their logic can be tested without a sim running. Frames come from
+// an .ibt file or from a fixture, a compact text format:
+//
+//	# comments start with a hash
+//	SessionTime, LapNumber, FuelLevel   <- header, registry keys
+//	0,           0,         20.0
+//	10,          ,          19.9        <- empty cells keep the previous value
+//
+// The same TelemetryData is reused between frames, just like the providers do
+
+type replayFrames = iter.Seq[*TelemetryData]
+
+// loadFixture reads a fixture file from testdata
+func loadFixture(t *testing.T, name string) replayFrames {
+	t.Helper()
+
+	file, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	var header []FieldID
+	var rows [][]string
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		cells := strings.Split(text, ",")
+		for k := range cells {
+			cells[k] = strings.TrimSpace(cells[k])
+		}
+
+		if header != nil {
+			if len(cells) > len(header) {
+				t.Fatalf("%s:%d: more cells than columns", name, line)
+			}
+			rows = append(rows, cells)
+			continue
+		}
+
+		for _, key := range cells {
+			id, ok := GetFieldIDByKey(key)
+			if !ok {
+				t.Fatalf("%s:%d: unknown field %q", name, line, key)
+			}
+			header = append(header, id)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	return func(yield func(*TelemetryData) bool) {
+		td := NewTelemetryData()
+		for _, row := range rows {
+			for k, cell := range row {
+				if cell == "" {
+					continue
+				}
+
+				if err := setFromString(&td.Values[header[k]], header[k], cell); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+			}
+
+			if !yield(td) {
+				return
+			}
+		}
+	}
+}
+
+// setFromString parses a fixture cell into the field with the registry type
+func setFromString(tf *TelemetryField, id FieldID, cell string) error {
+	def, _ := GetField(id)
+	if def.Type == DataTypeSTRING {
+		tf.Type = DataTypeSTRING
+		tf.Str = cell
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(cell, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w", def.Key, err)
+	}
+
+	CoerceTransform(def.Type)(v, tf)
+	return nil
+}
+
+// loadIBT replays an .ibt file, the fields are read from the iRacing sources in
+// the registry. Fields that come from the session info are not filled in
+func loadIBT(t *testing.T, path string) replayFrames {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open ibt: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	sdk, err := goirsdk.Init(file, "", "")
+	if err != nil {
+		t.Fatalf("failed to read ibt: %v", err)
+	}
+
+	// The record count on the sub header isn't always filled in, the size of
+	// the file is what tells how many frames there are
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatalf("failed to stat ibt: %v", err)
+	}
+	records := (info.Size() - int64(sdk.Headers.BufOffset)) / int64(sdk.Headers.BufLen)
+
+	var binds []BoundField
+	for _, def := range FieldsFromSource(SourceIRacing) {
+		key := def.Sources[SourceIRacing]
+		if _, ok := sdk.Vars.Vars[key]; ok {
+			binds = append(binds, BoundField{Key: key, ID: def.ID, Transform: CoerceTransform(def.Type)})
+		}
+	}
+
+	return func(yield func(*TelemetryData) bool) {
+		td := NewTelemetryData()
+		for range records {
+			if _, err := sdk.Update(0); err != nil {
+				t.Fatalf("failed to read ibt frame: %v", err)
+			}
+
+			for _, b := range binds {
+				b.Transform(sdk.Vars.Vars[b.Key].Value, &td.Values[b.ID])
+			}
+
+			if !yield(td) {
+				return
+			}
+		}
+	}
+}
+
+// replay runs every frame through the virtual field, check is called after each
+// frame is processed
+func replay(vf VirtualField, frames replayFrames, check func(frame int, td *TelemetryData)) {
+	frame := 0
+	for td := range frames {
+		vf.Process(td)
+		check(frame, td)
+		frame++
+	}
+}
+
+// lapExpectation holds the expected outputs when a lap is completed. Floats
+// are compared with a small tolerance, strings as they are
+type lapExpectation map[FieldID]any
+
+// replayLaps runs the frames and checks the outputs on the frame each lap is
+// completed, expectations are indexed by the lap that was just completed
+func replayLaps(t *testing.T, vf VirtualField, frames replayFrames, expect map[int]lapExpectation) {
+	t.Helper()
+
+	checked := 0
+	lastLap := -1
+	replay(vf, frames, func(frame int, td *TelemetryData) {
+		lap := int(td.Values[LapNumber].Raw)
+		if lastLap != -1 && lap > lastLap {
+			if fields, ok := expect[lastLap]; ok {
+				checkFields(t, fmt.Sprintf("lap %d (frame %d)", lastLap, frame), td, fields)
+				checked++
+			}
+		}
+		lastLap = lap
+	})
+
+	if checked != len(expect) {
+		t.Errorf("only %d of the %d laps were completed", checked, len(expect))
+	}
+}
+
+func checkFields(t *testing.T, where string, td *TelemetryData, fields lapExpectation) {
+	t.Helper()
+
+	for id, want := range fields {
+		got := &td.Values[id]
+		switch want := want.(type) {
+		case float64:
+			if !got.IsFloat() || math.Abs(got.Float()-want) > 1e-3 {
+				t.Errorf("%s: %s expected %f, got %s", where, GetFieldName(id), want, got.Format(4))
+			}
+		case string:
+			if got.String() != want {
+				t.Errorf("%s: %s expected %q, got %q", where, GetFieldName(id), want, got.String())
+			}
+		}
+	}
+}
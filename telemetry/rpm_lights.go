@@ -1,30 +1,180 @@
 package telemetry
 
+import (
+	"strings"
+	"time"
+)
+
+// Colours the devices know how to show on their RPM lights
+const (
+	RPMColourIdle   = "WHITE"
+	RPMColourGreen  = "GREEN"
+	RPMColourYellow = "YELLOW"
+	RPMColourRed    = "RED"
+)
+
+// RPMBand lights the given colour from RPM upwards
+type RPMBand struct {
+	RPM    float64 `yaml:"rpm"`
+	Colour string  `yaml:"colour"`
+}
+
+// ShiftLightProfile describes the shift lights of a car
+type ShiftLightProfile struct {
+	// Car is matched against the car name the sim reports, case insensitive. A
+	// profile matches if the car name contains it, so "GT3" matches every GT3
+	Car string `yaml:"car"`
+	// Bands must be sorted by RPM
+	Bands []RPMBand `yaml:"bands"`
+	// UseSimShiftPoints replaces the bands with the shift points the sim
+	// reports for the car, when it reports any
+	UseSimShiftPoints bool `yaml:"use_sim_shift_points"`
+	// BlinkAtRedline makes the lights blink from BlinkRPM upwards. If the sim
+	// shift points are used the sim's blink RPM takes over
+	BlinkAtRedline bool    `yaml:"blink_at_redline"`
+	BlinkRPM       float64 `yaml:"blink_rpm"`
+	BlinkHz        float64 `yaml:"blink_hz"`
+	IdleColour     string  `yaml:"idle_colour"`
+}
+
+// ShiftLightConfig holds all the profiles, Default is used for cars without one
+type ShiftLightConfig struct {
+	Default  ShiftLightProfile   `yaml:"default"`
+	Profiles []ShiftLightProfile `yaml:"profiles"`
+}
+
+// DefaultShiftLights is what the lights looked like before profiles existed,
+// used when the configuration doesn't have any
+var DefaultShiftLights = ShiftLightConfig{
+	Default: ShiftLightProfile{
+		Bands: []RPMBand{
+			{RPM: 2000, Colour: RPMColourGreen},
+			{RPM: 4000, Colour: RPMColourYellow},
+			{RPM: 6000, Colour: RPMColourRed},
+		},
+		UseSimShiftPoints: true,
+	},
+}
+
+var shiftLights = DefaultShiftLights
+
+// SetShiftLights replaces the shift light profiles, lights that are already
+// running pick them up on the next car change
+func SetShiftLights(cfg ShiftLightConfig) {
+	if len(cfg.Default.Bands) == 0 && !cfg.Default.UseSimShiftPoints {
+		cfg.Default = DefaultShiftLights.Default
+	}
+
+	shiftLights = cfg
+}
+
+// ProfileFor returns the profile for the given car
+func (cfg *ShiftLightConfig) ProfileFor(car string) ShiftLightProfile {
+	car = strings.ToLower(car)
+	for _, p := range cfg.Profiles {
+		if p.Car != "" && strings.Contains(car, strings.ToLower(p.Car)) {
+			return p
+		}
+	}
+
+	return cfg.Default
+}
+
 type RPMLights struct {
 	State string
+
+	car      string
+	simFirst float64 // Sim first shift light RPM the bands were built with
+	profile  ShiftLightProfile
+	bands    []RPMBand
+	blink    float64 // RPM from which we blink, 0 to never blink
 }
 
 func NewRPMLights() *RPMLights {
+	profile := shiftLights.ProfileFor("")
+
 	return &RPMLights{
-		State: "WHITE",
+		State:   RPMColourIdle,
+		profile: profile,
+		bands:   profile.Bands,
+	}
+}
+
+// selectProfile picks the profile of the active car and works out the bands
+func (rl *RPMLights) selectProfile(td *TelemetryData) {
+	rl.car = td.Values[CarName].Str
+	rl.profile = shiftLights.ProfileFor(rl.car)
+	rl.bands = rl.profile.Bands
+	rl.blink = 0
+	if rl.profile.BlinkAtRedline {
+		rl.blink = rl.profile.BlinkRPM
+	}
+
+	if !rl.profile.UseSimShiftPoints {
+		return
+	}
+
+	first := td.Values[ShiftLightFirstRPM].Float()
+	shift := td.Values[ShiftLightShiftRPM].Float()
+	rl.simFirst = first
+	if first <= 0 || shift <= first {
+		return
+	}
+
+	rl.bands = []RPMBand{
+		{RPM: first, Colour: RPMColourGreen},
+		{RPM: (first + shift) / 2, Colour: RPMColourYellow},
+		{RPM: shift, Colour: RPMColourRed},
+	}
+
+	if blink := td.Values[ShiftLightBlinkRPM].Float(); rl.profile.BlinkAtRedline && blink > 0 {
+		rl.blink = blink
 	}
 }
 
 func (rl *RPMLights) Process(td *TelemetryData) {
-	curRPM := td.Values[RPM].Raw
+	// The session info can come after the first RPM values, so changes on the
+	// sim shift points also trigger a new selection
+	simChanged := rl.profile.UseSimShiftPoints &&
+		td.Values[ShiftLightFirstRPM].Float() != rl.simFirst
+	if td.Values[CarName].Str != rl.car || simChanged {
+		rl.selectProfile(td)
+	}
+
+	curRPM := td.Values[RPM].Float()
+
+	idle := rl.profile.IdleColour
+	if idle == "" {
+		idle = RPMColourIdle
+	}
+
+	rl.State = idle
+	for _, band := range rl.bands {
+		if curRPM < band.RPM {
+			break
+		}
+		rl.State = band.Colour
+	}
+
+	if rl.blink > 0 && curRPM >= rl.blink && rl.blinkOff(td.LastDataPoll) {
+		rl.State = idle
+	}
 
 	td.Values[RPMStateColour].Type = DataTypeSTRING
-	if curRPM >= 2000 && curRPM < 4000 {
-		td.Values[RPMStateColour].Str = "GREEN"
-	} else if curRPM >= 4000 && curRPM < 6000 {
-		td.Values[RPMStateColour].Str = "YELLOW"
-	} else if curRPM >= 6000 {
-		td.Values[RPMStateColour].Str = "RED"
-	} else {
-		td.Values[RPMStateColour].Str = "WHITE"
+	td.Values[RPMStateColour].Str = rl.State
+}
+
+// blinkOff reports if we are in the off half of the blink cycle
+func (rl *RPMLights) blinkOff(now time.Time) bool {
+	hz := rl.profile.BlinkHz
+	if hz <= 0 {
+		hz = 4
 	}
+
+	halfPeriod := max(int64(500/hz), 1)
+	return (now.UnixMilli()/halfPeriod)%2 == 1
 }
 
 func (rl *RPMLights) EnsureSubscribed() []FieldID {
-	return []FieldID{RPM}
+	return []FieldID{RPM, CarName, ShiftLightFirstRPM, ShiftLightShiftRPM, ShiftLightBlinkRPM}
 }
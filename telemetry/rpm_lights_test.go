@@ -0,0 +1,96 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_RPMLights(t *testing.T) {
+	defer SetShiftLights(DefaultShiftLights)
+
+	SetShiftLights(ShiftLightConfig{
+		Default: ShiftLightProfile{
+			Bands: []RPMBand{{RPM: 1000, Colour: RPMColourGreen}, {RPM: 2000, Colour: RPMColourRed}},
+		},
+		Profiles: []ShiftLightProfile{
+			{
+				Car:            "gt3",
+				Bands:          []RPMBand{{RPM: 6000, Colour: RPMColourGreen}, {RPM: 8000, Colour: RPMColourRed}},
+				BlinkAtRedline: true,
+				BlinkRPM:       8500,
+				BlinkHz:        1,
+			},
+			{Car: "MX-5", UseSimShiftPoints: true},
+		},
+	})
+
+	type RPMTest struct {
+		name   string
+		car    string
+		rpm    float32
+		poll   time.Time
+		expect string
+	}
+
+	onPhase := time.UnixMilli(0)
+	offPhase := time.UnixMilli(500)
+
+	tests := []RPMTest{
+		{"test_default_idle", "Unknown Car", 500, onPhase, RPMColourIdle},
+		{"test_default_band", "Unknown Car", 1500, onPhase, RPMColourGreen},
+		{"test_profile_match", "Ford Mustang GT3", 7000, onPhase, RPMColourGreen},
+		{"test_profile_redline", "Ford Mustang GT3", 8200, offPhase, RPMColourRed},
+		{"test_profile_blink_on", "Ford Mustang GT3", 8600, onPhase, RPMColourRed},
+		{"test_profile_blink_off", "Ford Mustang GT3", 8600, offPhase, RPMColourIdle},
+		{"test_sim_first", "Mazda MX-5 Cup", 5700, onPhase, RPMColourGreen},
+		{"test_sim_mid", "Mazda MX-5 Cup", 6500, onPhase, RPMColourYellow},
+		{"test_sim_shift", "Mazda MX-5 Cup", 7300, onPhase, RPMColourRed},
+	}
+
+	rl := NewRPMLights()
+	td := NewTelemetryData()
+	td.Values[ShiftLightFirstRPM].SetFloat32(5600)
+	td.Values[ShiftLightShiftRPM].SetFloat32(7200)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			td.Values[CarName].Type = DataTypeSTRING
+			td.Values[CarName].Str = test.car
+			td.Values[RPM].SetFloat32(test.rpm)
+			td.LastDataPoll = test.poll
+
+			rl.Process(td)
+
+			if td.Values[RPMStateColour].Str != test.expect {
+				t.Errorf("expected %s, got %s", test.expect, td.Values[RPMStateColour].Str)
+			}
+		})
+	}
+}
+
+func Test_RPMLightsIBT(t *testing.T) {
+	rl := NewRPMLights()
+
+	// The replay doesn't carry the session info so the default bands are used
+	seen := map[string]bool{}
+	replay(rl, loadIBT(t, "../test_telem.ibt"), func(frame int, td *TelemetryData) {
+		rpm := td.Values[RPM].Float()
+
+		expect := RPMColourIdle
+		for _, band := range DefaultShiftLights.Default.Bands {
+			if rpm >= band.RPM {
+				expect = band.Colour
+			}
+		}
+
+		if td.Values[RPMStateColour].Str != expect {
+			t.Fatalf("frame %d: %.0f rpm expected %s, got %s",
+				frame, rpm, expect, td.Values[RPMStateColour].Str)
+		}
+		seen[expect] = true
+	})
+
+	if !seen[RPMColourRed] {
+		t.Errorf("expected the recording to reach the red band")
+	}
+}
@@ -0,0 +1,381 @@
+package telemetry
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxSectors is how many sectors we have fields for. Tracks split in more
+// sectors than this get their neighbouring sectors merged
+const MaxSectors = 8
+
+// Colours of the sector times, the same names the devices know for the lights
+const (
+	SectorColourNone   = RPMColourIdle
+	SectorColourPurple = "PURPLE" // Best we've seen on this track
+	SectorColourGreen  = RPMColourGreen
+	SectorColourYellow = RPMColourYellow
+)
+
+// TrackSectors defines the sectors of a track. Splits are the lap distances
+// (0 to 1) where each sector starts, the start/finish line doesn't need to be
+// listed
+type TrackSectors struct {
+	// Track is matched against the track name the sim reports, case
+	// insensitive. It matches if the track name contains it
+	Track  string    `yaml:"track"`
+	Splits []float64 `yaml:"splits"`
+	// Line and Length are for the sims that don't report the lap distance, see
+	// LapDistance. Line is where the start/finish line is in the sim's world,
+	// [x, y] in metres, and Length how long the lap is in metres
+	Line   []float64 `yaml:"line"`
+	Length float64   `yaml:"length"`
+}
+
+// defaultSplits are used when neither the sim nor the configuration have the
+// sectors of the track
+var defaultSplits = []float64{0, 1.0 / 3, 2.0 / 3}
+
+var trackSectors []TrackSectors
+
+// SetTrackSectors replaces the sectors defined by the user, these take over the
+// ones the sim reports. Timers that are already running pick them up on the
+// next track change
+func SetTrackSectors(tracks []TrackSectors) {
+	trackSectors = tracks
+}
+
+// sectorsFor returns the user defined splits for the given track
+func sectorsFor(track string) ([]float64, bool) {
+	track = strings.ToLower(track)
+	for _, ts := range trackSectors {
+		if ts.Track != "" && strings.Contains(track, strings.ToLower(ts.Track)) {
+			return ts.Splits, true
+		}
+	}
+
+	return nil, false
+}
+
+// ParseSplits parses the splits the providers put on the TrackSplits field, a
+// comma separated list of lap distances
+func ParseSplits(s string) ([]float64, error) {
+	var splits []float64
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid split %q: %w", part, err)
+		}
+		splits = append(splits, v)
+	}
+
+	return splits, nil
+}
+
+// FormatSplits is the inverse of ParseSplits
+func FormatSplits(splits []float64) string {
+	parts := make([]string, len(splits))
+	for k, v := range splits {
+		parts[k] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// normalizeSplits sorts the splits, drops the ones outside of the lap and makes
+// sure the first sector starts at the line. Tracks with more than MaxSectors
+// keep MaxSectors of their splits, evenly picked
+func normalizeSplits(splits []float64) []float64 {
+	res := []float64{0}
+	for _, s := range splits {
+		if s > 0 && s < 1 {
+			res = append(res, s)
+		}
+	}
+	slices.Sort(res)
+	res = slices.Compact(res)
+
+	if len(res) <= MaxSectors {
+		return res
+	}
+
+	merged := make([]float64, MaxSectors)
+	for k := range merged {
+		merged[k] = res[k*len(res)/MaxSectors]
+	}
+
+	return merged
+}
+
+// sectorTimes holds a time for each sector, 0 when there's none
+type sectorTimes [MaxSectors]float64
+
+// SectorTiming acts as a Virtual Field. It splits the lap in sectors and times
+// each of them, keeping the current lap, last and best times of every sector
+// and the theoretical best lap made from the best sectors.
+//
+// The sectors come from the configuration for the track, from the sim when
+// there's none (iRacing's SplitTimeInfo) or are thirds of the lap otherwise.
+//
+// Each sector time gets a colour: purple if it's the best we've seen on the
+// track, green if it's the best of this session and yellow otherwise
+type SectorTiming struct {
+	Logger *slog.Logger
+
+	setup *sync.Once
+
+	track     string
+	simSplits string
+	splits    []float64
+
+	lastDist float64
+	lastTime float64
+
+	sector      int     // Sector we are in, -1 if unknown
+	sectorStart float64 // Time we got into the sector
+	sectorValid bool    // If we saw the start of the sector and drove it all
+
+	current     sectorTimes
+	last        sectorTimes
+	best        sectorTimes
+	sessionBest sectorTimes
+}
+
+func NewSectorTiming(logger *slog.Logger) *SectorTiming {
+	return &SectorTiming{
+		Logger: logger,
+		setup:  &sync.Once{},
+		sector: -1,
+	}
+}
+
+func (st *SectorTiming) initializeSTTelemetryFields(td *TelemetryData) {
+	for k := range MaxSectors {
+		if k >= len(st.splits) {
+			td.Values[SectorCurrent[k]].Unused()
+			td.Values[SectorLast[k]].Unused()
+			td.Values[SectorBest[k]].Unused()
+			td.Values[SectorColour[k]].Unused()
+			continue
+		}
+
+		for _, id := range []FieldID{SectorCurrent[k], SectorLast[k], SectorBest[k]} {
+			td.Values[id].Type = DataTypeSTRING
+			td.Values[id].Str = "No Data"
+		}
+
+		td.Values[SectorColour[k]].Type = DataTypeSTRING
+		td.Values[SectorColour[k]].Str = SectorColourNone
+	}
+
+	td.Values[TheoreticalBest].Type = DataTypeSTRING
+	td.Values[TheoreticalBest].Str = "No Data"
+	td.Values[TheoreticalBestStr].Type = DataTypeSTRING
+	td.Values[TheoreticalBestStr].Str = lapTimeNoData
+
+	td.Values[CurrentSector].Type = DataTypeUINT8
+	td.Values[CurrentSector].Raw = 0
+}
+
+// selectSplits works out the sectors of the track and starts over
+func (st *SectorTiming) selectSplits(td *TelemetryData) {
+	st.track = td.Values[TrackName].Str
+	st.simSplits = td.Values[TrackSplits].Str
+
+	splits, ok := sectorsFor(st.track)
+	if !ok {
+		var err error
+		splits, err = ParseSplits(st.simSplits)
+		if err != nil {
+			st.Logger.Error(fmt.Sprintf("failed to read the sim splits: %v", err))
+		}
+	}
+
+	st.splits = normalizeSplits(splits)
+	if len(st.splits) == 1 {
+		st.splits = defaultSplits
+	}
+
+	st.Logger.Debug(fmt.Sprintf("%q has %d sectors: %v", st.track, len(st.splits), st.splits))
+
+	st.current, st.last, st.best, st.sessionBest = sectorTimes{}, sectorTimes{}, sectorTimes{}, sectorTimes{}
+	st.sector = -1
+	st.sectorValid = false
+	st.initializeSTTelemetryFields(td)
+}
+
+// resetSession is for when the session time goes back, the best sectors stay
+func (st *SectorTiming) resetSession(td *TelemetryData) {
+	st.sessionBest = sectorTimes{}
+	st.sectorValid = false
+
+	for k := range st.splits {
+		td.Values[SectorColour[k]].Str = SectorColourNone
+	}
+}
+
+// SessionChanged starts the session over, the best sectors stay
+func (st *SectorTiming) SessionChanged(td *TelemetryData) {
+	st.resetSession(td)
+}
+
+// sectorAt returns the sector the given lap distance is in
+func (st *SectorTiming) sectorAt(dist float64) int {
+	k, _ := slices.BinarySearch(st.splits, dist)
+	if k == len(st.splits) || st.splits[k] != dist {
+		k--
+	}
+
+	return max(k, 0)
+}
+
+// sectorCompleted records the time of sector k
+func (st *SectorTiming) sectorCompleted(td *TelemetryData, k int, t float64) {
+	st.last[k] = t
+	td.Values[SectorLast[k]].SetFloat32(float32(t))
+	td.Values[SectorCurrent[k]].SetFloat32(float32(t))
+
+	colour := SectorColourYellow
+	switch {
+	case st.best[k] == 0 || t < st.best[k]:
+		colour = SectorColourPurple
+		st.best[k] = t
+		st.sessionBest[k] = t
+		td.Values[SectorBest[k]].SetFloat32(float32(t))
+		st.updateTheoreticalBest(td)
+	case st.sessionBest[k] == 0 || t < st.sessionBest[k]:
+		colour = SectorColourGreen
+		st.sessionBest[k] = t
+	}
+
+	td.Values[SectorColour[k]].Type = DataTypeSTRING
+	td.Values[SectorColour[k]].Str = colour
+}
+
+func (st *SectorTiming) updateTheoreticalBest(td *TelemetryData) {
+	sum := 0.0
+	for k := range st.splits {
+		if st.best[k] == 0 {
+			return
+		}
+		sum += st.best[k]
+	}
+
+	setLapTime(td, TheoreticalBest, TheoreticalBestStr, sum)
+}
+
+// enterSector is called when the car gets into sector k at time t
+func (st *SectorTiming) enterSector(td *TelemetryData, k int, t float64) {
+	prev := (k - 1 + len(st.splits)) % len(st.splits)
+	if st.sector == prev && st.sectorValid {
+		st.sectorCompleted(td, prev, t-st.sectorStart)
+	}
+
+	// A new lap, the times of the current lap start over
+	if k == 0 {
+		for s := range st.splits {
+			st.current[s] = 0
+			td.Values[SectorCurrent[s]].Type = DataTypeSTRING
+			td.Values[SectorCurrent[s]].Str = "No Data"
+		}
+	}
+
+	st.sector = k
+	st.sectorStart = t
+	st.sectorValid = true
+}
+
+// crossSplits goes through the sector starts between both distances in order.
+// toDist is past 1 if the line was crossed
+func (st *SectorTiming) crossSplits(td *TelemetryData, toDist float64, now float64) {
+	for _, base := range []float64{0, 1} {
+		for k, split := range st.splits {
+			p := base + split
+			if p <= st.lastDist || p > toDist {
+				continue
+			}
+
+			st.enterSector(td, k, crossingTime(st.lastDist, st.lastTime, toDist, now, p))
+		}
+	}
+}
+
+func (st *SectorTiming) Process(td *TelemetryData) {
+	dist := td.Values[LapDistPct].Float()
+	now := timingClock(td)
+
+	st.setup.Do(func() {
+		st.selectSplits(td)
+		st.lastDist = dist
+		st.lastTime = now
+	})
+
+	if td.Values[TrackName].Str != st.track || td.Values[TrackSplits].Str != st.simSplits {
+		st.selectSplits(td)
+	}
+
+	if now < st.lastTime {
+		st.resetSession(td)
+	}
+
+	// Same as for the lap timing, -1 is off track
+	if !td.Values[LapDistPct].IsFloat() || dist < -lapMaxDistJump {
+		st.sectorValid = false
+		st.lastDist, st.lastTime = 0, now
+		return
+	}
+	dist = math.Max(dist, 0)
+
+	switch {
+	case lineCrossed(st.lastDist, dist):
+		st.crossSplits(td, dist+1, now)
+	case distJumped(st.lastDist, st.lastTime, dist, now):
+		st.Logger.Debug(fmt.Sprintf("lap distance jumped from %.3f to %.3f", st.lastDist, dist))
+		st.sector = st.sectorAt(dist)
+		st.sectorValid = false
+	default:
+		st.crossSplits(td, dist, now)
+	}
+
+	td.Values[CurrentSector].Type = DataTypeUINT8
+	td.Values[CurrentSector].Raw = uint64(st.sectorAt(dist) + 1)
+
+	if st.sector >= 0 && st.sectorValid {
+		st.current[st.sector] = now - st.sectorStart
+		td.Values[SectorCurrent[st.sector]].SetFloat32(float32(st.current[st.sector]))
+	}
+
+	st.lastDist, st.lastTime = dist, now
+
+	td.Values[SectorTable].SetTable(st.table(td))
+}
+
+// table summarises the sectors, a row per sector
+func (st *SectorTiming) table(td *TelemetryData) *Table {
+	t := NewTable("Sector", "Current", "Last", "Best", "Colour")
+	for k := range st.splits {
+		t.AddRow(
+			UintCell(uint8(k+1)),
+			td.Values[SectorCurrent[k]],
+			td.Values[SectorLast[k]],
+			td.Values[SectorBest[k]],
+			td.Values[SectorColour[k]],
+		)
+	}
+
+	return t
+}
+
+func (st *SectorTiming) EnsureSubscribed() []FieldID {
+	return []FieldID{LapDistPct, SessionTime, TrackName, TrackSplits}
+}
@@ -0,0 +1,89 @@
+package telemetry
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+func Test_SectorTiming(t *testing.T) {
+	SetTrackSectors([]TrackSectors{{Track: "test ring", Splits: []float64{0.5}}})
+	t.Cleanup(func() { SetTrackSectors(nil) })
+
+	st := NewSectorTiming(slog.Default())
+
+	s1, s2 := 0, 1
+	expect := map[int]lapExpectation{
+		0: {CurrentSector: "2", SectorColour[2]: "-"},
+		1: {CurrentSector: "1", SectorCurrent[s1]: 5.0},
+		2: {SectorLast[s1]: 25.0, SectorColour[s1]: SectorColourPurple, CurrentSector: "2"},
+		// New lap, the times of the last one are cleared
+		4: {
+			SectorLast[s2]: 35.0, SectorCurrent[s1]: 5.0, SectorCurrent[s2]: "No Data",
+			TheoreticalBest: 60.0, TheoreticalBestStr: "1:00.000",
+		},
+		5: {SectorLast[s1]: 30.0, SectorBest[s1]: 25.0, SectorColour[s1]: SectorColourYellow},
+		7: {SectorLast[s2]: 27.5, SectorColour[s2]: SectorColourPurple, TheoreticalBestStr: "0:52.500"},
+		// New session, the best times stay
+		8: {SectorColour[s1]: SectorColourNone, SectorColour[s2]: SectorColourNone, SectorBest[s2]: 27.5},
+		// Joined sector 1 half way, it isn't timed. Sector 2 is the best of the
+		// session but not the best one
+		12: {
+			SectorLast[s1]: 30.0, SectorLast[s2]: 35.0, SectorBest[s2]: 27.5,
+			SectorColour[s2]: SectorColourGreen,
+		},
+	}
+
+	checked := 0
+	replay(st, loadFixture(t, "sector_timing.fixture"), func(frame int, td *TelemetryData) {
+		if fields, ok := expect[frame]; ok {
+			checkFields(t, fmt.Sprintf("frame %d", frame), td, fields)
+			checked++
+		}
+	})
+
+	if checked != len(expect) {
+		t.Errorf("only %d of the %d frames were replayed", checked, len(expect))
+	}
+}
+
+func Test_NormalizeSplits(t *testing.T) {
+	tests := []struct {
+		name   string
+		splits []float64
+		want   []float64
+	}{
+		{"adds the line", []float64{0.5, 0.25}, []float64{0, 0.25, 0.5}},
+		{"drops outside the lap", []float64{0, 0.5, 1, -0.1}, []float64{0, 0.5}},
+		{
+			"merges sectors",
+			[]float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9},
+			[]float64{0, 0.1, 0.2, 0.3, 0.5, 0.6, 0.7, 0.8},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := normalizeSplits(tc.splits); !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_ParseSplits(t *testing.T) {
+	splits := []float64{0, 0.259885, 0.509689}
+
+	got, err := ParseSplits(FormatSplits(splits))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(got, splits) {
+		t.Errorf("expected %v, got %v", splits, got)
+	}
+
+	if _, err := ParseSplits("0,abc"); err == nil {
+		t.Errorf("expected an error for an invalid split")
+	}
+}
@@ -0,0 +1,221 @@
+package telemetry
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+const (
+	// RelativeRows is how many rows the relative has, the player sits in the
+	// middle one with the cars ahead on track above and the ones behind below
+	RelativeRows = 7
+	// StandingsRows is how many positions of the overall standings we publish
+	StandingsRows = 10
+)
+
+// CarState is what we know about a car in the session. Providers that know
+// about the other cars on track fill a snapshot of these on TelemetryData.Cars
+type CarState struct {
+	Idx         int
+	Number      string
+	Name        string
+	Lap         int     // Lap the car is on, negative if it isn't in the world
+	LapDistPct  float64 // Negative if the car isn't in the world
+	EstTime     float64 // Estimated time from the line to where the car is
+	EstLapTime  float64 // Estimated lap time of the car's class
+	IsPlayer    bool
+	IsPaceCar   bool
+	IsSpectator bool
+}
+
+// racing reports if the car should show up on the tables
+func (c *CarState) racing() bool {
+	return !c.IsPaceCar && !c.IsSpectator && c.Lap >= 0 && c.LapDistPct >= 0
+}
+
+// progress is how far the car got in the race, in laps
+func (c *CarState) progress() float64 {
+	return float64(c.Lap) + c.LapDistPct
+}
+
+// StandingsRow is a line of the standings or the relative
+type StandingsRow struct {
+	Position int
+	Car      CarState
+	// Overall: seconds to the car ahead. Relative: seconds to the player,
+	// negative for the cars ahead of them on track
+	Gap float64
+}
+
+// timeBetween is how many seconds car b is behind car a, counting the laps
+// between them. Comparing the estimated times alone breaks when one of them
+// already crossed the line and the other didn't
+func timeBetween(a *CarState, b *CarState) float64 {
+	lapTime := a.EstLapTime
+	if lapTime <= 0 {
+		lapTime = b.EstLapTime
+	}
+
+	return float64(a.Lap-b.Lap)*lapTime + a.EstTime - b.EstTime
+}
+
+// OverallStandings orders the cars by how far they got in the race, the gap
+// is the interval to the car ahead
+func OverallStandings(cars []CarState) []StandingsRow {
+	rows := make([]StandingsRow, 0, len(cars))
+	for _, car := range cars {
+		if car.racing() {
+			rows = append(rows, StandingsRow{Car: car})
+		}
+	}
+
+	slices.SortStableFunc(rows, func(a, b StandingsRow) int {
+		return cmp.Compare(b.Car.progress(), a.Car.progress())
+	})
+
+	for k := range rows {
+		rows[k].Position = k + 1
+		if k > 0 {
+			rows[k].Gap = timeBetween(&rows[k-1].Car, &rows[k].Car)
+		}
+	}
+
+	return rows
+}
+
+// RelativeStandings returns the cars around the player on track, regardless of
+// the lap they are on. The player is always on the row at index ahead, rows
+// without a car have a zero Position
+func RelativeStandings(cars []CarState, ahead int, behind int) []StandingsRow {
+	overall := OverallStandings(cars)
+
+	playerRow := slices.IndexFunc(overall, func(r StandingsRow) bool { return r.Car.IsPlayer })
+	if playerRow < 0 {
+		return make([]StandingsRow, ahead+behind+1)
+	}
+	player := overall[playerRow].Car
+
+	type relCar struct {
+		row  StandingsRow
+		dist float64 // Lap distance to the player, positive ahead
+	}
+
+	var others []relCar
+	for _, row := range overall {
+		if row.Car.IsPlayer {
+			continue
+		}
+
+		// Wrap the distance so every car is at most half a lap away
+		dist := row.Car.LapDistPct - player.LapDistPct
+		dist -= math.Round(dist)
+
+		// Same for the time, the estimated times restart at the line
+		rel := row.Car.EstTime - player.EstTime
+		lapTime := cmp.Or(player.EstLapTime, row.Car.EstLapTime)
+		switch {
+		case dist > 0 && rel < 0:
+			rel += lapTime
+		case dist < 0 && rel > 0:
+			rel -= lapTime
+		}
+
+		row.Gap = -rel
+		others = append(others, relCar{row, dist})
+	}
+
+	slices.SortStableFunc(others, func(a, b relCar) int {
+		return cmp.Compare(b.dist, a.dist)
+	})
+
+	rows := make([]StandingsRow, ahead+behind+1)
+	rows[ahead] = overall[playerRow]
+	rows[ahead].Gap = 0
+
+	nAhead := 0
+	for _, o := range others {
+		if o.dist > 0 {
+			nAhead++
+		}
+	}
+
+	// Closest cars ahead go right above the player, the rest above them
+	for k := 0; k < nAhead && k < ahead; k++ {
+		rows[ahead-1-k] = others[nAhead-1-k].row
+	}
+	for k := 0; nAhead+k < len(others) && k < behind; k++ {
+		rows[ahead+1+k] = others[nAhead+k].row
+	}
+
+	return rows
+}
+
+// Standings acts as a Virtual Field, it publishes the overall standings and
+// the relative from the cars the provider reports
+type Standings struct{}
+
+func NewStandings() *Standings {
+	return &Standings{}
+}
+
+func setStandingsRow(td *TelemetryData, ids standingsRowFields, row StandingsRow) {
+	if row.Position == 0 {
+		td.Values[ids.Position].Unused()
+		td.Values[ids.Name].Unused()
+		td.Values[ids.Gap].Unused()
+		return
+	}
+
+	td.Values[ids.Position].Type = DataTypeUINT8
+	td.Values[ids.Position].Raw = uint64(row.Position)
+
+	td.Values[ids.Name].Type = DataTypeSTRING
+	td.Values[ids.Name].Str = "#" + row.Car.Number + " " + row.Car.Name
+
+	td.Values[ids.Gap].SetFloat32(float32(row.Gap))
+}
+
+// standingsTable puts the rows on a table, the rows without a car are left
+// empty to keep the player in the middle of the relative
+func standingsTable(rows []StandingsRow) *Table {
+	t := NewTable("Pos", "Driver", "Gap")
+	for _, row := range rows {
+		if row.Position == 0 {
+			t.AddRow()
+			continue
+		}
+
+		t.AddRow(
+			UintCell(uint8(row.Position)),
+			StringCell("#"+row.Car.Number+" "+row.Car.Name),
+			Float32Cell(row.Gap),
+		)
+	}
+
+	return t
+}
+
+func (s *Standings) Process(td *TelemetryData) {
+	overall := OverallStandings(td.Cars)
+	for k, ids := range StandingsFields {
+		row := StandingsRow{}
+		if k < len(overall) {
+			row = overall[k]
+		}
+		setStandingsRow(td, ids, row)
+	}
+
+	relative := RelativeStandings(td.Cars, RelativeRows/2, RelativeRows/2)
+	for k, ids := range RelativeFields {
+		setStandingsRow(td, ids, relative[k])
+	}
+
+	// The table has every car, devices show as many rows as they fit
+	td.Values[StandingsTable].SetTable(standingsTable(overall))
+	td.Values[RelativeTable].SetTable(standingsTable(relative))
+}
+
+func (s *Standings) EnsureSubscribed() []FieldID {
+	return []FieldID{Cars}
+}
@@ -0,0 +1,114 @@
+package telemetry
+
+import (
+	"math"
+	"testing"
+)
+
+// The player is finishing lap 4 with the leader just across the line, one car
+// is a lap down right behind the player
+func standingsCars() []CarState {
+	return []CarState{
+		{Idx: 0, Number: "20", Name: "Player", Lap: 4, LapDistPct: 0.98, EstTime: 98, IsPlayer: true},
+		{Idx: 1, Number: "1", Name: "Leader", Lap: 5, LapDistPct: 0.02, EstTime: 2},
+		{Idx: 2, Number: "2", Name: "Chaser", Lap: 4, LapDistPct: 0.90, EstTime: 90},
+		{Idx: 3, Number: "3", Name: "Lapped", Lap: 3, LapDistPct: 0.95, EstTime: 95},
+		{Idx: 4, Number: "0", Name: "Pace Car", Lap: 5, LapDistPct: 0.5, EstTime: 50, IsPaceCar: true},
+		{Idx: 5, Name: "Spectator", IsSpectator: true},
+		{Idx: 6, Number: "6", Name: "In Garage", Lap: -1, LapDistPct: -1},
+		{Idx: 7, Number: "7", Name: "Far", Lap: 4, LapDistPct: 0.30, EstTime: 30},
+	}
+}
+
+func withLapTime(cars []CarState, lapTime float64) []CarState {
+	for k := range cars {
+		cars[k].EstLapTime = lapTime
+	}
+	return cars
+}
+
+func Test_OverallStandings(t *testing.T) {
+	rows := OverallStandings(withLapTime(standingsCars(), 100))
+
+	want := []struct {
+		name string
+		gap  float64
+	}{
+		{"Leader", 0},
+		// Across the line from the player, not 96s ahead
+		{"Player", 4},
+		{"Chaser", 8},
+		{"Far", 60},
+		{"Lapped", 35},
+	}
+
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %d", len(want), len(rows))
+	}
+
+	for k, w := range want {
+		if rows[k].Car.Name != w.name || rows[k].Position != k+1 || math.Abs(rows[k].Gap-w.gap) > 1e-9 {
+			t.Errorf("row %d: expected P%d %s %.1f, got P%d %s %.1f",
+				k, k+1, w.name, w.gap, rows[k].Position, rows[k].Car.Name, rows[k].Gap)
+		}
+	}
+}
+
+func Test_RelativeStandings(t *testing.T) {
+	rows := RelativeStandings(withLapTime(standingsCars(), 100), 3, 3)
+
+	want := []struct {
+		name string
+		pos  int
+		gap  float64
+	}{
+		{"", 0, 0},
+		{"Far", 4, -32},
+		{"Leader", 1, -4},
+		{"Player", 2, 0},
+		// A lap down but right behind on track
+		{"Lapped", 5, 3},
+		{"Chaser", 3, 8},
+		{"", 0, 0},
+	}
+
+	for k, w := range want {
+		if rows[k].Car.Name != w.name || rows[k].Position != w.pos || math.Abs(rows[k].Gap-w.gap) > 1e-9 {
+			t.Errorf("row %d: expected P%d %q %.1f, got P%d %q %.1f",
+				k, w.pos, w.name, w.gap, rows[k].Position, rows[k].Car.Name, rows[k].Gap)
+		}
+	}
+}
+
+func Test_StandingsFields(t *testing.T) {
+	td := NewTelemetryData()
+	td.Cars = withLapTime(standingsCars(), 100)
+
+	NewStandings().Process(td)
+
+	checkFields(t, "standings", td, lapExpectation{
+		StandingsFields[0].Name:     "#1 Leader",
+		StandingsFields[1].Gap:      4.0,
+		StandingsFields[5].Position: "-",
+		RelativeFields[0].Name:      "-",
+		RelativeFields[3].Name:      "#20 Player",
+		RelativeFields[3].Position:  "2",
+		RelativeFields[4].Gap:       3.0,
+	})
+
+	rel := td.Values[RelativeTable].Table
+	if len(rel.Rows) != RelativeRows || rel.Rows[3][1].Str != "#20 Player" {
+		t.Errorf("expected the player in the middle of the relative table, got:\n%s", rel)
+	}
+	if got := len(td.Values[StandingsTable].Table.Rows); got != 5 {
+		t.Errorf("expected a standings row per car, got %d", got)
+	}
+
+	// No cars, every row is unused
+	td.Cars = nil
+	NewStandings().Process(td)
+	checkFields(t, "no cars", td, lapExpectation{
+		StandingsFields[0].Name: "-",
+		RelativeFields[3].Name:  "-",
+	})
+}
@@ -0,0 +1,172 @@
+package telemetry
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	conv "esdi/conversions"
+)
+
+// Table is the value of a DataTypeTABLE field: rows of typed cells, for the
+// windows that show many values at once (standings, tyre temps, sectors...).
+//
+// Tables are never changed after they are put on a field, producers build a new
+// one every time. The copies of the data that were already sent keep pointing
+// to the table they had, and the Packer can tell a table changed by its address.
+//
+// Cells are converted like any other field: the Packer converts the columns with
+// a unit to the unit the window wants for the table field
+type Table struct {
+	Columns []string    // Names of the columns, these are not sent to the devices
+	Units   []conv.Unit // Units of the columns the producer filled in, none when empty
+	Rows    [][]TelemetryField
+}
+
+// NewTable returns an empty table with the given columns
+func NewTable(columns ...string) *Table {
+	return &Table{Columns: columns}
+}
+
+// AddRow appends a row, cells past the number of columns are dropped and the
+// missing ones are left unused
+func (t *Table) AddRow(cells ...TelemetryField) {
+	row := make([]TelemetryField, len(t.Columns))
+	for k := range row {
+		if k < len(cells) {
+			row[k] = cells[k]
+			row[k].IDs = nil
+			continue
+		}
+		row[k].Unused()
+	}
+
+	t.Rows = append(t.Rows, row)
+}
+
+// SetTable puts a table on the field
+func (tf *TelemetryField) SetTable(t *Table) {
+	tf.Type = DataTypeTABLE
+	tf.Table = t
+}
+
+// Helpers to build the cells of a table
+
+func StringCell(s string) TelemetryField {
+	return TelemetryField{Type: DataTypeSTRING, Str: s}
+}
+
+func Float32Cell(v float64) TelemetryField {
+	var tf TelemetryField
+	tf.SetFloat32(float32(v))
+	return tf
+}
+
+func UintCell(v uint8) TelemetryField {
+	return TelemetryField{Type: DataTypeUINT8, Raw: uint64(v)}
+}
+
+func sameCell(a *TelemetryField, b *TelemetryField) bool {
+	return a.Type == b.Type && a.Raw == b.Raw && a.Str == b.Str
+}
+
+func sameRow(a []TelemetryField, b []TelemetryField) bool {
+	return slices.EqualFunc(a, b, func(x, y TelemetryField) bool {
+		return sameCell(&x, &y)
+	})
+}
+
+// pack packs the rows that changed from prev, all of them if prev is nil.
+// Format, after the field ID:
+// 0x00 - DataType
+// 0x01 - number of rows of the table
+// 0x02 - number of columns
+// 0x03 - number of rows in this packet
+// then for each of those rows:
+// 0x00 - row index
+// [0x01] - a cell per column, its DataType and value as for any other field
+//
+// Rows past the row count are gone, the device should clear them. Tables are
+// limited to 255 rows and columns
+func (t *Table) pack(dest []byte, prev *Table) []byte {
+	dest, _ = t.packChanges(dest, prev, conv.UnitNone)
+	return dest
+}
+
+// columnUnit returns the unit of a column, none for the ones without
+func (t *Table) columnUnit(c int) conv.Unit {
+	if c >= len(t.Units) {
+		return conv.UnitNone
+	}
+
+	return t.Units[c]
+}
+
+// packChanges packs the table and reports if anything changed from prev. The
+// cells of the columns of the same dimension as to are converted to it
+func (t *Table) packChanges(dest []byte, prev *Table, to conv.Unit) ([]byte, bool) {
+	if t == nil {
+		t = &Table{}
+	}
+
+	nRows := min(len(t.Rows), math.MaxUint8)
+	nCols := min(len(t.Columns), math.MaxUint8)
+
+	// A different shape and the device has to draw it all again
+	if prev != nil && len(prev.Columns) != len(t.Columns) {
+		prev = nil
+	}
+
+	changed := make([]int, 0, nRows)
+	for k := range nRows {
+		if prev == nil || k >= len(prev.Rows) || !sameRow(prev.Rows[k], t.Rows[k]) {
+			changed = append(changed, k)
+		}
+	}
+
+	dest = append(dest, uint8(DataTypeTABLE), uint8(nRows), uint8(nCols), uint8(len(changed)))
+	for _, k := range changed {
+		dest = append(dest, uint8(k))
+		for c := range nCols {
+			cell := TelemetryField{}
+			if c < len(t.Rows[k]) {
+				cell = t.Rows[k][c]
+			} else {
+				cell.Unused()
+			}
+
+			// A failed conversion sends the cell as it is, as for the fields
+			from := t.columnUnit(c)
+			if from.Dimension() != conv.DimensionNone && from.Dimension() == to.Dimension() {
+				_ = cell.Convert(from, to)
+			}
+			dest = cell.packValue(dest)
+		}
+	}
+
+	resized := prev == nil || len(prev.Rows) != nRows
+	return dest, len(changed) > 0 || resized
+}
+
+// String renders a row per line, cells separated by a bar
+func (t *Table) String() string {
+	if t == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for k, row := range t.Rows {
+		if k > 0 {
+			sb.WriteByte('\n')
+		}
+
+		for c := range row {
+			if c > 0 {
+				sb.WriteString(" | ")
+			}
+			sb.WriteString(row[c].String())
+		}
+	}
+
+	return sb.String()
+}
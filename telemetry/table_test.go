@@ -0,0 +1,111 @@
+package telemetry
+
+import (
+	"bytes"
+	"testing"
+
+	conv "esdi/conversions"
+)
+
+func standingsTestTable(leader string) *Table {
+	t := NewTable("Pos", "Driver")
+	t.AddRow(UintCell(1), StringCell(leader))
+	t.AddRow(UintCell(2), StringCell("B"))
+	return t
+}
+
+func Test_TablePack(t *testing.T) {
+	tf := TelemetryField{IDs: []int16{0x10}}
+	tf.SetTable(standingsTestTable("A"))
+
+	expect := []byte{
+		0x10, 0x00, 0x0C, // Window, TABLE
+		0x02, 0x02, 0x02, // 2 rows, 2 columns, 2 rows in the packet
+		0x00, 0x00, 0x01, 0x08, 0x01, 'A', // Row 0: UINT8 1, STRING "A"
+		0x01, 0x00, 0x02, 0x08, 0x01, 'B', // Row 1: UINT8 2, STRING "B"
+	}
+
+	if got := tf.Pack(nil); !bytes.Equal(got, expect) {
+		t.Errorf("expected % x, got % x", expect, got)
+	}
+}
+
+func Test_PackerTables(t *testing.T) {
+	td := NewTelemetryData()
+	td.Values[StandingsTable].IDs = []int16{0x10}
+	td.Values[StandingsTable].SetTable(standingsTestTable("A"))
+
+	p := NewPacker(nil)
+	if got := p.Pack(td); len(got) != 18 {
+		t.Fatalf("expected the whole table first, got % x", got)
+	}
+
+	// Same content on a new table, nothing to send
+	td.Values[StandingsTable].SetTable(standingsTestTable("A"))
+	if got := p.Pack(td); len(got) != 0 {
+		t.Errorf("expected nothing to be sent, got % x", got)
+	}
+
+	// Only the first row changed
+	td.Values[StandingsTable].SetTable(standingsTestTable("C"))
+	expect := []byte{0x10, 0x00, 0x0C, 0x02, 0x02, 0x01, 0x00, 0x00, 0x01, 0x08, 0x01, 'C'}
+	if got := p.Pack(td); !bytes.Equal(got, expect) {
+		t.Errorf("expected % x, got % x", expect, got)
+	}
+
+	// A row is gone, the new row count goes out even if no row changed
+	shrunk := NewTable("Pos", "Driver")
+	shrunk.AddRow(UintCell(1), StringCell("C"))
+	td.Values[StandingsTable].SetTable(shrunk)
+	expect = []byte{0x10, 0x00, 0x0C, 0x01, 0x02, 0x00}
+	if got := p.Pack(td); !bytes.Equal(got, expect) {
+		t.Errorf("expected % x, got % x", expect, got)
+	}
+
+	// A window that was created again gets it all
+	p.Forget(0x10)
+	if got := p.Pack(td); len(got) != 12 {
+		t.Errorf("expected the whole table after forgetting it, got % x", got)
+	}
+}
+
+func Test_TableAddRow(t *testing.T) {
+	tbl := NewTable("A", "B")
+	tbl.AddRow(StringCell("x"))
+	tbl.AddRow(StringCell("y"), StringCell("z"), StringCell("dropped"))
+
+	if got := tbl.String(); got != "x | -\ny | z" {
+		t.Errorf("unexpected table: %q", got)
+	}
+}
+
+func Test_PackerTableUnits(t *testing.T) {
+	td := NewTelemetryData()
+	td.Values[LFtempL].SetFloat32(100)
+	NewTyreTable().Process(td)
+	td.Values[TyreTempsTable].IDs = []int16{0x10}
+
+	p := NewPacker(func(winID int16, id FieldID) conv.Unit {
+		return conv.Fahrenheit
+	})
+
+	// Row 0: STRING "LF", then FLOAT32 212 for the 100C on the left
+	expect := []byte{0x00, 0x08, 0x02, 'L', 'F', 0x0A, 0x00, 0x00, 0x54, 0x43}
+	if got := p.Pack(td); !bytes.Contains(got, expect) {
+		t.Errorf("expected the temperatures in F, got % x", got)
+	}
+
+	// The conversion goes on the packet, the table keeps the provider units
+	if got := td.Values[TyreTempsTable].Table.Rows[0][1].String(); got != "100.0" {
+		t.Errorf("expected the table in C, got %s", got)
+	}
+
+	// Nothing changed, until the device loses the windows
+	if got := p.Pack(td); len(got) != 0 {
+		t.Errorf("expected nothing to be sent, got % x", got)
+	}
+	p.Reset()
+	if got := p.Pack(td); len(got) == 0 {
+		t.Errorf("expected the whole table after a reset")
+	}
+}
@@ -1,5 +1,23 @@
 package telemetry
 
-func Init() {
-	initFieldNamesMap()
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Init is called once the logger and configuration are set up. The built-in
+// fields register when the package is loaded (see fields.go), here we add the
+// ones the user defined, the user's shift light profiles and track sectors. A
+// nil shiftLights keeps the default profile
+func Init(expressions []ExpressionDef, shiftLights *ShiftLightConfig, sectors []TrackSectors) error {
+	if shiftLights != nil {
+		SetShiftLights(*shiftLights)
+	}
+	SetTrackSectors(sectors)
+
+	err := RegisterExpressions(expressions)
+
+	slog.Debug(fmt.Sprintf("telemetry registry holds %d fields", FieldCount()))
+
+	return err
 }
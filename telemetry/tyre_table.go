@@ -0,0 +1,52 @@
+package telemetry
+
+import (
+	conv "esdi/conversions"
+)
+
+// tyreCorners are the rows of the tyre table, each with the fields of its left,
+// middle and right surface temperatures
+var tyreCorners = []struct {
+	Name  string
+	Temps [3]*FieldID
+}{
+	{"LF", [3]*FieldID{&LFtempL, &LFtempM, &LFtempR}},
+	{"RF", [3]*FieldID{&RFtempL, &RFtempM, &RFtempR}},
+	{"LR", [3]*FieldID{&LRtempL, &LRtempM, &LRtempR}},
+	{"RR", [3]*FieldID{&RRtempL, &RRtempM, &RRtempR}},
+}
+
+// TyreTable acts as a Virtual Field, it puts the surface temperatures of the
+// four tyres on a table so a single window can show them all. The temperatures
+// are converted to the unit the window wants for the table
+type TyreTable struct{}
+
+func NewTyreTable() *TyreTable {
+	return &TyreTable{}
+}
+
+func (tt *TyreTable) Process(td *TelemetryData) {
+	t := NewTable("Tyre", "Left", "Middle", "Right")
+	t.Units = []conv.Unit{conv.UnitNone, conv.Celsius, conv.Celsius, conv.Celsius}
+	for _, corner := range tyreCorners {
+		t.AddRow(
+			StringCell(corner.Name),
+			td.Values[*corner.Temps[0]],
+			td.Values[*corner.Temps[1]],
+			td.Values[*corner.Temps[2]],
+		)
+	}
+
+	td.Values[TyreTempsTable].SetTable(t)
+}
+
+func (tt *TyreTable) EnsureSubscribed() []FieldID {
+	deps := make([]FieldID, 0, len(tyreCorners)*3)
+	for _, corner := range tyreCorners {
+		for _, id := range corner.Temps {
+			deps = append(deps, *id)
+		}
+	}
+
+	return deps
+}
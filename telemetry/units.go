@@ -0,0 +1,83 @@
+package telemetry
+
+import (
+	"math"
+
+	conv "esdi/conversions"
+)
+
+// UnitResolver tells in which unit the given window wants to see a field
+type UnitResolver func(winID int16, id FieldID) conv.Unit
+
+// Convert converts the value of this field between two units. Integer fields
+// are rounded and keep their type, strings and chars are left alone. Arrays
+// get their elements converted into a new slice
+func (tf *TelemetryField) Convert(from conv.Unit, to conv.Unit) error {
+	if from == to || tf.Type == DataTypeSTRING || tf.Type == DataTypeCHAR || tf.Type == DataTypeTABLE {
+		return nil
+	}
+
+	if tf.Type == DataTypeARRAY {
+		elems := make([]uint64, len(tf.Elems))
+		for k := range tf.Elems {
+			e := tf.At(k)
+			if err := e.Convert(from, to); err != nil {
+				return err
+			}
+			elems[k] = e.Raw
+		}
+
+		tf.Elems = elems
+		return nil
+	}
+
+	v, err := conv.Convert(tf.Float(), from, to)
+	if err != nil {
+		return err
+	}
+
+	switch tf.Type {
+	case DataTypeFLOAT32:
+		tf.SetFloat32(float32(v))
+	case DataTypeFLOAT64:
+		tf.SetFloat64(v)
+	case DataTypeINT8, DataTypeINT16, DataTypeINT32, DataTypeINT64:
+		tf.Raw = uint64(int64(math.Round(v)))
+	default:
+		tf.Raw = uint64(math.Round(max(v, 0)))
+	}
+
+	return nil
+}
+
+// convertible reports whether this field holds a value that has a unit we can
+// convert
+func (tf *TelemetryField) convertible(id FieldID) bool {
+	if tf.Type == DataTypeSTRING || tf.Type == DataTypeCHAR || tf.Type == DataTypeTABLE {
+		return false
+	}
+
+	def, ok := GetField(id)
+	return ok && def.Unit.Dimension() != conv.DimensionNone
+}
+
+// packConverted packs one entry per window, each in the unit that window asked
+// for
+func (tf *TelemetryField) packConverted(dest []byte, id FieldID, resolve UnitResolver) []byte {
+	def, _ := GetField(id)
+
+	var winIDs [1]int16
+	for _, winID := range tf.IDs {
+		out := *tf
+		winIDs[0] = winID
+		out.IDs = winIDs[:]
+
+		// If the conversion fails we still send the value as it is, the layout
+		// validation is the place to complain about bad units
+		_ = out.Convert(def.Unit, resolve(winID, id))
+
+		dest = out.Pack(dest)
+	}
+
+	return dest
+}
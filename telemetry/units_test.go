@@ -0,0 +1,125 @@
+package telemetry
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	conv "esdi/conversions"
+)
+
+func Test_Convert(t *testing.T) {
+	type ConvertTest struct {
+		name   string
+		tf     TelemetryField
+		from   conv.Unit
+		to     conv.Unit
+		expect float64
+	}
+
+	tests := []ConvertTest{
+		{"ms_to_kph", TelemetryField{}, conv.MetersPerSecond, conv.Kph, 36},
+		{"ms_to_mph", TelemetryField{}, conv.MetersPerSecond, conv.Mph, 22.3694},
+		{"c_to_f", TelemetryField{}, conv.Celsius, conv.Fahrenheit, 50},
+		{"bar_to_psi", TelemetryField{}, conv.Bar, conv.Psi, 145.0377},
+		{"l_to_gal", TelemetryField{}, conv.Litre, conv.USGallon, 2.6417},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.tf.SetFloat64(10)
+
+			err := test.tf.Convert(test.from, test.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if math.Abs(test.tf.Float()-test.expect) > 0.001 {
+				t.Errorf("expected %f, got %f", test.expect, test.tf.Float())
+			}
+		})
+	}
+
+	tf := TelemetryField{}
+	tf.SetFloat32(1)
+	if err := tf.Convert(conv.Celsius, conv.Psi); err == nil {
+		t.Errorf("expected an error converting a temperature to a pressure")
+	}
+}
+
+func Test_ResolveUnits(t *testing.T) {
+	tests := []struct {
+		name  string
+		prefs conv.UnitPreferences
+		from  conv.Unit
+		want  conv.Unit
+	}{
+		{"test_speed_default", conv.UnitPreferences{}, conv.MetersPerSecond, conv.Kph},
+		{"test_speed_set", conv.UnitPreferences{Speed: conv.MetersPerSecond}, conv.MetersPerSecond,
+			conv.MetersPerSecond},
+		{"test_temperature_as_is", conv.UnitPreferences{}, conv.Celsius, conv.Celsius},
+		{"test_dimensionless", conv.UnitPreferences{Speed: conv.Mph}, conv.UnitNone, conv.UnitNone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.prefs.Resolve(test.from); got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func Test_PackWithUnits(t *testing.T) {
+	td := NewTelemetryData()
+	td.Values[Speed].IDs = []int16{0x01, 0x02}
+	td.Values[Speed].SetFloat32(10)
+
+	packet := td.PackWithUnits(func(winID int16, id FieldID) conv.Unit {
+		if winID == 0x02 {
+			return conv.Kph
+		}
+
+		return conv.MetersPerSecond
+	})
+
+	expect := []byte{
+		0x01, 0x00, 0x0A, 0x00, 0x00, 0x20, 0x41, // 10 m/s
+		0x02, 0x00, 0x0A, 0x00, 0x00, 0x10, 0x42, // 36 km/h
+	}
+
+	if !bytes.Equal(packet, expect) {
+		t.Errorf("expected % x, got % x", expect, packet)
+	}
+}
+
+func Test_PackArrays(t *testing.T) {
+	td := NewTelemetryData()
+	td.Values[TyrePressures].IDs = []int16{0x01, 0x02}
+	ArrayTransform(DataTypeFLOAT32, 4)([]float32{100, 200, 300, 400}, &td.Values[TyrePressures])
+
+	p := NewPacker(func(winID int16, id FieldID) conv.Unit {
+		return conv.Bar
+	})
+	p.Elements = func(winID int16, id FieldID) (int, bool) {
+		return 2, winID == 0x02
+	}
+
+	expect := []byte{
+		0x01, 0x00, 0x0D, 0x0A, 0x04, // Whole array
+		0x00, 0x00, 0x80, 0x3F, // 1 bar
+		0x00, 0x00, 0x00, 0x40, // 2 bar
+		0x00, 0x00, 0x40, 0x40, // 3 bar
+		0x00, 0x00, 0x80, 0x40, // 4 bar
+		0x02, 0x00, 0x0A, 0x00, 0x00, 0x40, 0x40, // Element 2, 3 bar
+	}
+
+	if packet := p.Pack(td); !bytes.Equal(packet, expect) {
+		t.Errorf("expected % x, got % x", expect, packet)
+	}
+
+	// The conversion goes on a copy, the data keeps the provider units
+	if got := td.Values[TyrePressures].String(); got != "[100.0, 200.0, 300.0, 400.0]" {
+		t.Errorf("expected the pressures in kPa, got %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"esdi/analysis"
+	"esdi/config"
+	"esdi/providers"
+	"esdi/tui/internal/views"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type CompareCtrl struct {
+	*Controller
+	CompareView *views.CompareToolView
+	Messages    chan string
+	OnExit      func()
+}
+
+func NewCompareCtrl(base *Controller) *CompareCtrl {
+	compareView := views.NewCompareToolView()
+	compareView.Charts.Units = config.GetCfg().Units
+
+	ctrl := &CompareCtrl{
+		Controller:  base,
+		CompareView: compareView,
+		Messages:    make(chan string, 10),
+	}
+
+	ctrl.registerHooks()
+
+	return ctrl
+}
+
+func (cc *CompareCtrl) registerHooks() {
+	cc.CompareView.Options.Form.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		if ev.Key() == tcell.KeyEsc {
+			cc.OnExit()
+		}
+
+		return ev
+	})
+
+	err := SetFormButtonCallback(cc.CompareView.Options.Form, "Compare", func() {
+		cc.compare()
+	})
+	if err != nil {
+		panic("Failed to set callback for compare controller form compare button")
+	}
+}
+
+// lapPick is a lap of a recording picked on the form
+type lapPick struct {
+	path string
+	lap  int
+}
+
+func (cc *CompareCtrl) parseCompareForm(form *views.CompareOptionsView) ([2]lapPick, error) {
+	var picks [2]lapPick
+
+	picks[0].path = form.RecordingA.GetText()
+	picks[1].path = form.RecordingB.GetText()
+	if picks[1].path == "" {
+		picks[1].path = picks[0].path
+	}
+	if picks[0].path == "" {
+		return picks, errors.New("no recording to compare")
+	}
+
+	var err error
+	if picks[0].lap, err = strconv.Atoi(form.LapA.GetText()); err != nil {
+		return picks, fmt.Errorf("bad lap A: %w", err)
+	}
+	if picks[1].lap, err = strconv.Atoi(form.LapB.GetText()); err != nil {
+		return picks, fmt.Errorf("bad lap B: %w", err)
+	}
+
+	return picks, nil
+}
+
+// compare loads both laps and charts them, the recordings are read away from
+// the UI since long sessions take a while
+func (cc *CompareCtrl) compare() {
+	picks, err := cc.parseCompareForm(cc.CompareView.Options)
+	if err != nil {
+		cc.Messages <- fmt.Sprintf("compare: %v\n", err)
+		return
+	}
+
+	go func() {
+		var traces [2]*analysis.Trace
+		for k, pick := range picks {
+			if traces[k], err = cc.loadLap(pick); err != nil {
+				cc.Messages <- fmt.Sprintf("compare: %s: %v\n", pick.path, err)
+				return
+			}
+		}
+
+		cmp, err := analysis.Compare(traces[0], traces[1], analysis.DefaultComparePoints)
+		if err != nil {
+			cc.Messages <- fmt.Sprintf("compare: %v\n", err)
+			return
+		}
+
+		cc.App.QueueUpdateDraw(func() {
+			cc.CompareView.Charts.Show(cmp, picks[0].path, picks[1].path)
+		})
+	}()
+}
+
+func (cc *CompareCtrl) loadLap(pick lapPick) (*analysis.Trace, error) {
+	src, err := providers.OpenFrames(cc.Logger, pick.path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return analysis.LoadLap(src, pick.lap)
+}
@@ -30,7 +30,7 @@ func SetFormButtonCallback(form *tview.Form, btnLabel string, fn func()) error {
 	btnIndex := form.GetButtonIndex(btnLabel)
 	if btnIndex == -1 {
 		availableButtons := ListFormButtonLabels(form)
-		return fmt.Errorf("no button with label: `%s` : [%v]", availableButtons)
+		return fmt.Errorf("no button with label: `%s` : [%v]", btnLabel, availableButtons)
 	}
 
 	button := form.GetButton(btnIndex)
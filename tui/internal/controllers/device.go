@@ -8,6 +8,7 @@ import (
 	"esdi/tui/internal/views"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
 )
 
 type DeviceController struct {
@@ -15,6 +16,7 @@ type DeviceController struct {
 	DeviceAPIView *views.DeviceAPIView
 	LayoutCtrl    *LayoutController
 	StreamCtrl    *StreamingCtrl
+	CompareCtrl   *CompareCtrl
 	DevService    *serv.CDashService
 }
 
@@ -24,10 +26,11 @@ func NewDeviceController(
 	telemService *serv.TelemetryService,
 ) *DeviceController {
 	mc := &DeviceController{
-		Controller: base,
-		LayoutCtrl: NewLayoutController(base, devService),
-		DevService: devService,
-		StreamCtrl: NewStreamingCtrl(base, devService, telemService),
+		Controller:  base,
+		LayoutCtrl:  NewLayoutController(base, devService),
+		DevService:  devService,
+		StreamCtrl:  NewStreamingCtrl(base, devService, telemService),
+		CompareCtrl: NewCompareCtrl(base),
 	}
 
 	return mc
@@ -41,7 +44,9 @@ func (mc *DeviceController) PrintToOutputWindow(msg string) {
 
 func (mc *DeviceController) setAppEventCapture() {
 	mc.App.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyCtrlC || event.Rune() == 'q' {
+		// A q typed in a text field isn't a quit
+		_, typing := mc.App.GetFocus().(*tview.InputField)
+		if event.Key() == tcell.KeyCtrlC || (event.Rune() == 'q' && !typing) {
 			mc.App.Stop()
 			return nil
 		}
@@ -84,6 +89,15 @@ func (mc *DeviceController) AddDeviceAPIListItems() {
 
 			mc.App.SetFocus(mc.StreamCtrl.StreamView.Options.Form)
 		})
+	mc.DeviceAPIView.DevAPIList.
+		AddItem("compare", "compare two laps by lap distance", func() {
+			views.AddAndShowPage(mc.DeviceAPIView.DevAPIToolView.Pages,
+				"compare-tool",
+				mc.CompareCtrl.CompareView.Flex,
+			)
+
+			mc.App.SetFocus(mc.CompareCtrl.CompareView.Options.Form)
+		})
 }
 
 func (mc *DeviceController) injectViewCallbacks() {
@@ -98,6 +112,7 @@ func (mc *DeviceController) injectControllerCallbacks() {
 
 	mc.LayoutCtrl.OnExit = giveFocusToAPIList
 	mc.StreamCtrl.OnExit = giveFocusToAPIList
+	mc.CompareCtrl.OnExit = giveFocusToAPIList
 }
 
 func (mc *DeviceController) injectChannels() {
@@ -118,6 +133,12 @@ func (mc *DeviceController) injectChannels() {
 			mc.PrintToOutputWindow(msg)
 		}
 	}()
+
+	go func() {
+		for msg := range mc.CompareCtrl.Messages {
+			mc.PrintToOutputWindow(msg)
+		}
+	}()
 }
 
 func (mc *DeviceController) mainUI() error {
@@ -155,11 +155,25 @@ func (lc *LayoutController) parseWindowFormData(
 		return nil, err
 	}
 
+	precisionInputID, _ := form.Precision.GetCurrentOption()
+	if precisionInputID == -1 {
+		return nil, fmt.Errorf("no option selected for precision")
+	}
+
 	telemFieldInputID, telemField := form.TelemetryField.GetCurrentOption()
 	if telemFieldInputID == -1 {
 		return nil, fmt.Errorf("no option selected for telemetry field")
 	}
 
+	var index *int
+	if indexInput := form.Index.GetText(); indexInput != "" {
+		indexValue, err := strconv.Atoi(indexInput)
+		if err != nil {
+			return nil, err
+		}
+		index = &indexValue
+	}
+
 	showIDValue := cdashdisplay.ShowIDFalse
 	if form.ShowID.IsChecked() {
 		showIDValue = cdashdisplay.ShowIDTrue
@@ -181,12 +195,14 @@ func (lc *LayoutController) parseWindowFormData(
 			ShowID:       showIDValue,
 			PreviewValue: helper.B32(form.PreviewValue.GetText()),
 		},
-		Decor: winDecor,
-		Title: helper.B32(form.Title.GetText()),
+		Decor:     winDecor,
+		Title:     helper.B32(form.Title.GetText()),
+		Precision: uint8(precisionInputID),
 	}
 
 	uiData := cdashdisplay.DesktopUIData{
 		TelemetryField: telemField,
+		Index:          index,
 	}
 
 	return &cdashdisplay.DesktopUIWindow{
@@ -243,6 +259,11 @@ func (lc *LayoutController) updateFormView(win *cdashdisplay.DesktopUIWindow) er
 
 		window.UIData.IDX = formView.WinID
 
+		// The form doesn't edit the window units, keep the ones the layout had
+		if current, ok := lc.DevService.CDash.State.Layout.Windows[window.UIData.IDX]; ok {
+			window.UIData.Units = current.UIData.Units
+		}
+
 		lc.updateWindowAction(window)
 	})
 	if err != nil {
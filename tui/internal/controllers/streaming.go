@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"log/slog"
 	"sync/atomic"
+	"time"
 
 	"esdi/config"
 	"esdi/providers"
+	"esdi/recording"
 	"esdi/services"
 	"esdi/telemetry"
 	"esdi/tui/internal/models"
@@ -15,6 +17,13 @@ import (
 	"github.com/gdamore/tcell/v2"
 )
 
+// flagAlertsWindow subscribes the flag state for the flag alerts, negative
+// window ids are read but not sent to the device
+const flagAlertsWindow int16 = -1
+
+// seekStep is how far the seek keys move a recording
+const seekStep = 10 * time.Second
+
 type StreamingCtrl struct {
 	*Controller
 	Service     *services.CDashService
@@ -22,6 +31,7 @@ type StreamingCtrl struct {
 	Messages    chan string
 	Internal    chan string
 	TelemetryCh <-chan telemetry.TelemetryData
+	SourceCh    <-chan telemetry.SourceEvent
 	Run         bool
 	OnExit      func()
 	TelemServ   *services.TelemetryService
@@ -41,6 +51,7 @@ func NewStreamingCtrl(
 		providerList = append(providerList, item)
 	}
 	streamView := views.NewStreamToolView(providerList, config.GetCfg().DefaultSim)
+	streamView.Visualizer.Units = config.GetCfg().Units
 
 	ctrl := &StreamingCtrl{
 		Controller:  base,
@@ -57,12 +68,14 @@ func NewStreamingCtrl(
 	ctrl.registerHooks()
 	ctrl.subscribeListeners()
 	go ctrl.listenToUIStream()
+	go ctrl.listenToSourceEvents()
 
 	return ctrl
 }
 
 func (sc *StreamingCtrl) subscribeListeners() {
 	sc.TelemetryCh = sc.TelemServ.SubscribeListener("UI", 1)
+	sc.SourceCh = sc.TelemServ.SubscribeEvents("UI", 8)
 }
 
 func (sc *StreamingCtrl) registerHooks() {
@@ -79,6 +92,10 @@ func (sc *StreamingCtrl) registerHooks() {
 		case 'u':
 			// Update
 			sc.updateStream()
+		case 'p', '+', '-', 'l', '<', '>', '[', ']':
+			sc.playbackControl(ev.Rune())
+		case 'r':
+			sc.toggleRecording()
 		}
 
 		return ev
@@ -162,6 +179,11 @@ func (sc *StreamingCtrl) SetInternalState() {
 		fields[w.UIData.IDX] = fieldID
 	}
 
+	// The flag alerts need the flag state even when no window shows it
+	if len(sc.Service.CDash.State.Layout.FlagAlerts) > 0 {
+		fields[flagAlertsWindow] = telemetry.FlagState
+	}
+
 	sc.TelemServ.SubscribeToFields(fields)
 
 	sc.Messages <- fmt.Sprintf("Subscribed Fields: %+v [%d]\n", fields, len(fields))
@@ -177,8 +199,84 @@ func (sc *StreamingCtrl) listenToUIStream() {
 		isDrawing.Store(true)
 
 		sc.App.QueueUpdateDraw(func() {
+			if pb, ok := sc.TelemServ.Playback(); ok {
+				sc.StreamView.Visualizer.SetPlayback(pb.Status())
+			}
 			sc.StreamView.Visualizer.Update(&msg)
 			isDrawing.Store(false)
 		})
 	}
 }
+
+// listenToSourceEvents shows the state of the provider's source, the data stops
+// coming when it is lost so the visualizer would keep the last frame otherwise
+func (sc *StreamingCtrl) listenToSourceEvents() {
+	for ev := range sc.SourceCh {
+		sc.App.QueueUpdateDraw(func() {
+			sc.StreamView.Visualizer.SetSource(ev)
+		})
+	}
+}
+
+// toggleRecording starts recording the stream to a file named after the time
+// it started, or stops the recording going on
+func (sc *StreamingCtrl) toggleRecording() {
+	if path, ok := sc.TelemServ.Recording(); ok {
+		if err := sc.TelemServ.StopRecording(); err != nil {
+			sc.Messages <- fmt.Sprintf("recording: %v\n", err)
+		} else {
+			sc.Messages <- fmt.Sprintf("recorded %s\n", path)
+		}
+
+		sc.StreamView.Visualizer.SetRecording("")
+		return
+	}
+
+	path := time.Now().Format("esdi_20060102_150405") + recording.Ext
+	if err := sc.TelemServ.StartRecording(path); err != nil {
+		sc.Messages <- fmt.Sprintf("recording: %v\n", err)
+		return
+	}
+
+	sc.StreamView.Visualizer.SetRecording(path)
+}
+
+// playbackControl handles the keys of a recording: pause, speed, loop and
+// seeking by time or by lap
+func (sc *StreamingCtrl) playbackControl(key rune) {
+	pb, ok := sc.TelemServ.Playback()
+	if !ok {
+		return
+	}
+
+	var err error
+	st := pb.Status()
+	switch key {
+	case 'p':
+		if st.Paused {
+			pb.Resume()
+		} else {
+			pb.Pause()
+		}
+	case '+':
+		err = pb.SetSpeed(min(st.Speed*2, telemetry.MaxPlaybackSpeed))
+	case '-':
+		err = pb.SetSpeed(max(st.Speed/2, telemetry.MinPlaybackSpeed))
+	case 'l':
+		pb.SetLoop(!st.Loop)
+	case '>':
+		err = pb.Seek(min(st.Time+seekStep, st.Length))
+	case '<':
+		err = pb.Seek(max(st.Time-seekStep, 0))
+	case ']':
+		err = pb.SeekLap(st.Lap + 1)
+	case '[':
+		err = pb.SeekLap(st.Lap - 1)
+	}
+
+	if err != nil {
+		sc.Messages <- fmt.Sprintf("playback: %v\n", err)
+	}
+
+	sc.StreamView.Visualizer.SetPlayback(pb.Status())
+}
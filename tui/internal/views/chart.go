@@ -0,0 +1,99 @@
+package views
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// chartLabelLen is the room the range takes on the left of a chart
+const chartLabelLen = 8
+
+// Series is a line of a chart, in a tview color
+type Series struct {
+	Values []float64
+	Color  string
+	Mark   rune
+}
+
+// Chart plots the series in a grid of characters, width columns by height
+// rows, between low and high. The values are spread over the columns, where
+// the series meet the last one is drawn
+func Chart(title string, low float64, high float64, width int, height int, series ...Series) string {
+	width = max(width-chartLabelLen-1, 1)
+	height = max(height, 2)
+	if high <= low {
+		high = low + 1
+	}
+
+	grid := make([][]int, height)
+	for r := range grid {
+		grid[r] = make([]int, width)
+		for c := range grid[r] {
+			grid[r][c] = -1
+		}
+	}
+
+	for s, line := range series {
+		if len(line.Values) == 0 {
+			continue
+		}
+
+		for c := range width {
+			v := line.Values[c*len(line.Values)/width]
+			if math.IsNaN(v) {
+				continue
+			}
+
+			r := int(math.Round((high - v) / (high - low) * float64(height-1)))
+			grid[min(max(r, 0), height-1)][c] = s
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[yellow]%s[-]\n", title)
+	for r, row := range grid {
+		label := ""
+		switch r {
+		case 0:
+			label = chartLabel(high)
+		case height - 1:
+			label = chartLabel(low)
+		}
+		fmt.Fprintf(&b, "%*s│", chartLabelLen, label)
+
+		last := -1
+		for _, s := range row {
+			if s != last {
+				if last >= 0 {
+					b.WriteString("[-]")
+				}
+				if s >= 0 {
+					b.WriteString("[" + series[s].Color + "]")
+				}
+				last = s
+			}
+
+			if s < 0 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteRune(series[s].Mark)
+			}
+		}
+		if last >= 0 {
+			b.WriteString("[-]")
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+func chartLabel(v float64) string {
+	s := fmt.Sprintf("%.4g", v)
+	if len(s) > chartLabelLen {
+		s = s[:chartLabelLen]
+	}
+
+	return s
+}
@@ -0,0 +1,172 @@
+package views
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"esdi/analysis"
+	conv "esdi/conversions"
+
+	"github.com/rivo/tview"
+)
+
+// chartRows is the height of every chart of the comparison
+const chartRows = 6
+
+// Colors of the laps on the charts
+const (
+	lapAColor = "green"
+	lapBColor = "red"
+)
+
+// Form to pick the laps ↓↓↓↓
+
+type CompareOptionsView struct {
+	Form       *tview.Form
+	RecordingA *tview.InputField
+	LapA       *tview.InputField
+	RecordingB *tview.InputField
+	LapB       *tview.InputField
+}
+
+func NewCompareOptionsView() *CompareOptionsView {
+	cov := &CompareOptionsView{
+		RecordingA: tview.NewInputField().SetLabel("Recording A"),
+		LapA:       tview.NewInputField().SetLabel("Lap A").SetAcceptanceFunc(tview.InputFieldInteger),
+		RecordingB: tview.NewInputField().SetLabel("Recording B").SetPlaceholder("same as A"),
+		LapB:       tview.NewInputField().SetLabel("Lap B").SetAcceptanceFunc(tview.InputFieldInteger),
+	}
+
+	cov.Form = tview.NewForm().
+		AddFormItem(cov.RecordingA).
+		AddFormItem(cov.LapA).
+		AddFormItem(cov.RecordingB).
+		AddFormItem(cov.LapB)
+	cov.Form.SetTitle("Compare Laps").SetBorder(true)
+
+	// Inject callback on the controller
+	cov.Form.AddButton("Compare", func() {})
+
+	return cov
+}
+
+// Form to pick the laps ↑↑↑↑
+
+// Charts of the laps lined up ↓↓↓↓
+
+type CompareChartsView struct {
+	TextView *tview.TextView
+	Units    conv.UnitPreferences
+}
+
+func NewCompareChartsView() *CompareChartsView {
+	tv := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	tv.SetTitle("Lap Comparison").SetBorder(true)
+
+	return &CompareChartsView{
+		TextView: tv,
+	}
+}
+
+// Show charts the delta and the traces of both laps by lap distance, A and B
+// name where the laps come from
+func (cv *CompareChartsView) Show(cmp *analysis.Comparison, a string, b string) {
+	_, _, width, _ := cv.TextView.GetInnerRect()
+	if width <= chartLabelLen {
+		width = 100
+	}
+
+	n := len(cmp.Points)
+	delta := make([]float64, n)
+	zero := make([]float64, n)
+	var speed, throttle, brake, gear [2][]float64
+	for k := range speed {
+		speed[k] = make([]float64, n)
+		throttle[k] = make([]float64, n)
+		brake[k] = make([]float64, n)
+		gear[k] = make([]float64, n)
+	}
+
+	speedUnit := cv.Units.Resolve(conv.MetersPerSecond)
+	deltaRange, topSpeed, topGear := 0.1, 0.0, 1.0
+	for k, pt := range cmp.Points {
+		delta[k] = pt.Delta
+		deltaRange = math.Max(deltaRange, math.Abs(pt.Delta))
+
+		for l, lap := range [2]analysis.TracePoint{pt.A, pt.B} {
+			speed[l][k] = convert(lap.Speed, conv.MetersPerSecond, speedUnit)
+			throttle[l][k] = lap.Throttle * 100
+			brake[l][k] = lap.Brake * 100
+			gear[l][k] = float64(lap.Gear)
+
+			topSpeed = math.Max(topSpeed, speed[l][k])
+			topGear = math.Max(topGear, gear[l][k])
+		}
+	}
+
+	lines := func(values [2][]float64) []Series {
+		return []Series{
+			{Values: values[0], Color: lapAColor, Mark: '•'},
+			{Values: values[1], Color: lapBColor, Mark: '•'},
+		}
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "[%s]A[-] lap %d of %s\n[%s]B[-] lap %d of %s\n",
+		lapAColor, cmp.A.Lap, tview.Escape(a), lapBColor, cmp.B.Lap, tview.Escape(b))
+	fmt.Fprintf(&text, "Lap distance %.0f%% to %.0f%%, B is %+.3fs at the end\n\n",
+		cmp.Points[0].Dist*100, cmp.Points[n-1].Dist*100, delta[n-1])
+
+	text.WriteString(Chart("Delta s, B behind above 0", -deltaRange, deltaRange, width, chartRows,
+		Series{Values: zero, Color: "gray", Mark: '·'},
+		Series{Values: delta, Color: "white", Mark: '•'},
+	))
+	text.WriteString(Chart("Speed "+string(speedUnit), 0, topSpeed, width, chartRows, lines(speed)...))
+	text.WriteString(Chart("Throttle %", 0, 100, width, chartRows, lines(throttle)...))
+	text.WriteString(Chart("Brake %", 0, 100, width, chartRows, lines(brake)...))
+	text.WriteString(Chart("Gear", math.Min(0, minOf(gear)), topGear, width, chartRows, lines(gear)...))
+
+	cv.TextView.SetText(text.String()).ScrollToBeginning()
+}
+
+// minOf is the lowest value of both laps, the reverse gear shows when used
+func minOf(values [2][]float64) float64 {
+	low := math.Inf(1)
+	for _, lap := range values {
+		for _, v := range lap {
+			low = math.Min(low, v)
+		}
+	}
+
+	return low
+}
+
+// Charts of the laps lined up ↑↑↑↑
+
+// Compare Tool ↓↓↓↓
+
+type CompareToolView struct {
+	Flex    *tview.Flex
+	Options *CompareOptionsView
+	Charts  *CompareChartsView
+}
+
+func NewCompareToolView() *CompareToolView {
+	optionsView := NewCompareOptionsView()
+	chartsView := NewCompareChartsView()
+	flex := tview.NewFlex().SetDirection(tview.FlexColumn)
+	flex.SetTitle("Compare Tool")
+
+	flex.
+		AddItem(optionsView.Form, 0, 2, true).
+		AddItem(chartsView.TextView, 0, 5, false)
+
+	return &CompareToolView{
+		Flex:    flex,
+		Options: optionsView,
+		Charts:  chartsView,
+	}
+}
+
+// Compare Tool ↑↑↑↑
@@ -0,0 +1,107 @@
+package views
+
+import (
+	"fmt"
+	"strings"
+
+	"esdi/analysis"
+	conv "esdi/conversions"
+	telem "esdi/telemetry"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// LapReportView shows a lap per row of an analysis report
+type LapReportView struct {
+	Table *tview.Table
+}
+
+func NewLapReportView(report *analysis.Report, units conv.UnitPreferences) *LapReportView {
+	table := tview.NewTable().SetFixed(1, 1).SetSelectable(true, false)
+	table.SetTitle(fmt.Sprintf("%s - %s, [q] to quit", report.Car, report.Track)).SetBorder(true)
+
+	speedUnit := units.Resolve(report.Units.Speed)
+	tempUnit := units.Resolve(report.Units.Temperature)
+	fuelUnit := units.Resolve(report.Units.Fuel)
+
+	header := []string{"Lap", "Time"}
+	for k := range report.Sectors {
+		header = append(header, fmt.Sprintf("S%d", k+1))
+	}
+	header = append(header,
+		"Fuel "+string(fuelUnit),
+		"Tyres avg "+string(tempUnit), "Tyres max "+string(tempUnit),
+		"Top "+string(speedUnit), "Corners "+string(speedUnit), "",
+	)
+	for c, name := range header {
+		table.SetCell(0, c, tview.NewTableCell(name).
+			SetTextColor(tcell.ColorYellow).SetSelectable(false))
+	}
+
+	for k := range report.Laps {
+		lap := &report.Laps[k]
+
+		row := []string{fmt.Sprint(lap.Number), telem.FormatLapTime(lap.Time)}
+		for _, s := range lap.Sectors {
+			row = append(row, sectorTime(s))
+		}
+
+		var avg, hot []string
+		for _, tyre := range lap.Tyres {
+			avg = append(avg, formatConverted(tyre.Avg, report.Units.Temperature, tempUnit, 0))
+			hot = append(hot, formatConverted(tyre.Max, report.Units.Temperature, tempUnit, 0))
+		}
+
+		var corners []string
+		for _, corner := range lap.CornerSpeeds {
+			corners = append(corners, formatConverted(corner.Speed, report.Units.Speed, speedUnit, 0))
+		}
+
+		row = append(row,
+			formatConverted(lap.FuelUsed, report.Units.Fuel, fuelUnit, 2),
+			strings.Join(avg, " "), strings.Join(hot, " "),
+			formatConverted(lap.TopSpeed, report.Units.Speed, speedUnit, 0),
+			strings.Join(corners, " "), lap.Invalid,
+		)
+
+		color := tcell.ColorWhite
+		switch {
+		case k == report.BestLap:
+			color = tcell.ColorPurple
+		case !lap.Valid:
+			color = tcell.ColorGray
+		}
+
+		for c, text := range row {
+			table.SetCell(k+1, c, tview.NewTableCell(text).SetTextColor(color))
+		}
+	}
+
+	return &LapReportView{
+		Table: table,
+	}
+}
+
+// convert turns a value into the unit the user prefers, it's kept as is when
+// it can't be
+func convert(v float64, from conv.Unit, to conv.Unit) float64 {
+	if c, err := conv.Convert(v, from, to); err == nil {
+		return c
+	}
+
+	return v
+}
+
+// formatConverted renders a value in the unit the user prefers
+func formatConverted(v float64, from conv.Unit, to conv.Unit, precision int) string {
+	return fmt.Sprintf("%.*f", precision, convert(v, from, to))
+}
+
+func sectorTime(s float64) string {
+	if s <= 0 {
+		return "-"
+	}
+
+	return telem.FormatLapTime(s)
+}
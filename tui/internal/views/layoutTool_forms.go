@@ -9,6 +9,9 @@ import (
 	"github.com/rivo/tview"
 )
 
+// MaxPrecision is the maximum number of decimal places a window can show
+const MaxPrecision = 6
+
 type CDashDisplayWindowFormView struct {
 	Form           *tview.Form
 	X              *tview.InputField
@@ -21,7 +24,9 @@ type CDashDisplayWindowFormView struct {
 	WinType        *tview.DropDown
 	TitleSize      *tview.DropDown
 	TextSize       *tview.DropDown
+	Precision      *tview.DropDown
 	TelemetryField *tview.DropDown
+	Index          *tview.InputField
 }
 
 func NewCDashDisplayWindowFormView() *CDashDisplayWindowFormView {
@@ -50,12 +55,22 @@ func NewCDashDisplayWindowFormView() *CDashDisplayWindowFormView {
 	}
 	view.TextSize.SetCurrentOption(0)
 
+	view.Precision = tview.NewDropDown().SetLabel("Precision")
+	for k := range MaxPrecision + 1 {
+		view.Precision.AddOption(fmt.Sprintf("%d", k), blankDropdownOptionCallback)
+	}
+	view.Precision.SetCurrentOption(0)
+
 	view.TelemetryField = tview.NewDropDown().SetLabel("Telemetry Field")
-	for _, fieldName := range telemetry.FieldNames {
-		view.TelemetryField.AddOption(fieldName, blankDropdownOptionCallback)
+	// The options follow the registry order so an option index is its FieldID
+	for _, def := range telemetry.Fields() {
+		view.TelemetryField.AddOption(def.Name, blankDropdownOptionCallback)
 	}
 	view.TelemetryField.SetCurrentOption(0)
 
+	// Only for array fields, left empty the window gets the whole array
+	view.Index = tview.NewInputField().SetLabel("index")
+
 	view.Form = tview.NewForm().
 		AddFormItem(view.X).
 		AddFormItem(view.Y).
@@ -67,7 +82,9 @@ func NewCDashDisplayWindowFormView() *CDashDisplayWindowFormView {
 		AddFormItem(view.WinType).
 		AddFormItem(view.TitleSize).
 		AddFormItem(view.TextSize).
-		AddFormItem(view.TelemetryField)
+		AddFormItem(view.Precision).
+		AddFormItem(view.TelemetryField).
+		AddFormItem(view.Index)
 
 	view.Form.SetBorder(true).
 		SetTitle("New Window Form").
@@ -131,12 +148,17 @@ func (fv *WindowFormView) SetValues(win *cdashdisplay.DesktopUIWindow) {
 	fv.Form.WinType.SetCurrentOption(int(win.Opts.WinType)) // NOTE: this needs to set the correct option
 	fv.Form.TitleSize.SetCurrentOption(int(win.Decor.TitleSize))
 	fv.Form.TextSize.SetCurrentOption(int(win.Decor.TextSize))
+	fv.Form.Precision.SetCurrentOption(int(min(win.Precision, MaxPrecision)))
 
 	telemFieldID, ok := telemetry.GetFieldID(win.UIData.TelemetryField)
 	if !ok {
 		telemFieldID = 9999
 	}
 	fv.Form.TelemetryField.SetCurrentOption(int(telemFieldID))
+
+	if win.UIData.Index != nil {
+		fv.Form.Index.SetText(fmt.Sprintf("%d", *win.UIData.Index))
+	}
 }
 
 func windowInfoPageID(idx int16) string {
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	conv "esdi/conversions"
 	"esdi/providers"
 	telem "esdi/telemetry"
 
@@ -59,6 +60,12 @@ func NewStreamOptionsView(providerList []providers.Provider, defaultProvider str
 
 type StreamVisualizerView struct {
 	TextView *tview.TextView
+	Units    conv.UnitPreferences
+
+	source    string // State of the provider's source
+	playback  string // Where the recording is, when playing one
+	recording string // Where the stream is recorded to, when it is
+	frame     string // Last frame we got
 }
 
 func NewStreamVisualizerView() *StreamVisualizerView {
@@ -71,20 +78,75 @@ func NewStreamVisualizerView() *StreamVisualizerView {
 }
 
 func (sv *StreamVisualizerView) Update(data *telem.TelemetryData) {
-	sv.TextView.SetText(stringify(data))
+	sv.frame = stringify(data, sv.Units)
+	sv.render()
+}
+
+func (sv *StreamVisualizerView) render() {
+	sv.TextView.SetText(sv.source + sv.playback + sv.recording + sv.frame)
 }
 
-func stringify(data *telem.TelemetryData) string {
+// SetRecording shows where the stream is recorded to, an empty path when it
+// isn't
+func (sv *StreamVisualizerView) SetRecording(path string) {
+	sv.recording = ""
+	if path != "" {
+		sv.recording = fmt.Sprintf("Recording to %s, [r] to stop\n", path)
+	}
+
+	sv.render()
+}
+
+// SetPlayback shows where the recording is and the keys to control it
+func (sv *StreamVisualizerView) SetPlayback(st telem.PlaybackStatus) {
+	state := "playing"
+	if st.Paused {
+		state = "paused"
+	}
+
+	loop := ""
+	if st.Loop {
+		loop = ", looping"
+	}
+
+	sv.playback = fmt.Sprintf("Replay: %s / %s, lap %d, %gx %s%s\n"+
+		"  [p] pause [+/-] speed [l] loop [</>] seek 10s [[/]] lap\n",
+		st.Time.Truncate(time.Second), st.Length.Truncate(time.Second), st.Lap, st.Speed, state, loop)
+	sv.render()
+}
+
+// SetSource shows the state of the provider's source above the data. A lost
+// source drops the last frame, it won't be updated anymore
+func (sv *StreamVisualizerView) SetSource(ev telem.SourceEvent) {
+	sv.source = fmt.Sprintf("Source: %s %s (session %d)\n", ev.Provider, ev.State, ev.Session)
+	if ev.Err != nil {
+		sv.source += fmt.Sprintf("  %v\n", ev.Err)
+	}
+
+	if ev.State != telem.SourceStreaming {
+		sv.frame = ""
+	}
+
+	sv.render()
+}
+
+func stringify(data *telem.TelemetryData, units conv.UnitPreferences) string {
 	var buffer strings.Builder
 
 	delta := data.LastDataPoll.Sub(data.PenultimateDataPoll)
 	buffer.WriteString(fmt.Sprintf("[%s]\n", time.Now().Format("2006/01/02 15:04:05.000")))
 	buffer.WriteString(fmt.Sprintf("Delta: %d [%f]\n\n", delta.Milliseconds(), 1000.0/60.0))
 
-	buffer.WriteString(fmt.Sprintf("Gear: %s, RPM: %s, Speed: %s\n",
+	speed := data.Values[telem.Speed]
+	speedUnit := units.Resolve(conv.MetersPerSecond)
+	if err := speed.Convert(conv.MetersPerSecond, speedUnit); err != nil {
+		speedUnit = conv.MetersPerSecond
+	}
+
+	buffer.WriteString(fmt.Sprintf("Gear: %s, RPM: %s, Speed: %s %s\n",
 		data.Values[telem.Gear].String(),
 		data.Values[telem.RPM].String(),
-		data.Values[telem.Speed].String(),
+		speed.Format(0), speedUnit,
 	))
 
 	return buffer.String()
@@ -4,9 +4,14 @@ package tui
 import (
 	"log/slog"
 
+	"esdi/analysis"
+	"esdi/config"
 	"esdi/services"
+	"esdi/telemetry"
 	"esdi/tui/internal/controllers"
+	"esdi/tui/internal/views"
 
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
@@ -16,7 +21,9 @@ type ControlPanel struct {
 	DeviceController *controllers.DeviceController
 }
 
-func NewControlPanel(logger *slog.Logger) *ControlPanel {
+// NewControlPanel builds the TUI, provider replaces the default provider when
+// given (ex: a recording to play)
+func NewControlPanel(logger *slog.Logger, provider telemetry.TelemetryProvider) *ControlPanel {
 	// NOTE: given the services should not be only for the TUI should this be here?
 	baseController := &controllers.Controller{
 		Logger: logger,
@@ -29,6 +36,10 @@ func NewControlPanel(logger *slog.Logger) *ControlPanel {
 		panic("failed to create the telemetry service")
 	}
 
+	if provider != nil {
+		telemService.SwitchProvider(provider)
+	}
+
 	return &ControlPanel{
 		Controller:       baseController,
 		DeviceController: controllers.NewDeviceController(baseController, devService, telemService),
@@ -43,7 +54,24 @@ func (cp *ControlPanel) Run() error {
 	return cp.App.Run()
 }
 
-func Run(logger *slog.Logger) error {
-	progController := NewControlPanel(logger)
+func Run(logger *slog.Logger, provider telemetry.TelemetryProvider) error {
+	progController := NewControlPanel(logger, provider)
 	return progController.Run()
 }
+
+// ShowLapReport shows the laps of an analysis in a table until q or Esc is
+// pressed
+func ShowLapReport(report *analysis.Report) error {
+	app := tview.NewApplication()
+	view := views.NewLapReportView(report, config.GetCfg().Units)
+
+	view.Table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' {
+			app.Stop()
+			return nil
+		}
+		return event
+	})
+
+	return app.SetRoot(view.Table, true).Run()
+}